@@ -0,0 +1,318 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	Checks the guarantees of atomic broadcast on the values that the
+	replicas broadcast and the batches that they deliver:
+		Total order - Two correct replicas deliver the same batch at every
+			index that both of them delivered
+		Integrity   - A correct replica delivers a value at most as many
+			times as it was broadcast (once, if one replica broadcast it)
+		Validity    - Every value that a correct replica broadcast is
+			delivered by every correct replica
+		Agreement   - Every value that a correct replica delivered is
+			delivered by every correct replica (as many times)
+	Total order and integrity are safety properties that every prefix of a
+	run keeps (CheckSafety). Validity and agreement only hold once the run
+	is over, i.e. the replicas are quiescent (Check). The first violation is
+	reported with the batches around it.
+*/
+
+// Properties checked
+const (
+	TotalOrder = "total order"
+	Integrity  = "integrity"
+	Validity   = "validity"
+	Agreement  = "agreement"
+)
+
+// contextSize - How many batches before a divergence a violation shows
+const contextSize = 2
+
+// Batch - A batch that a replica delivered, at the index of its delivery
+type Batch struct {
+	Index  int
+	Values [][]byte
+}
+
+// Violation - The first point where a run breaks a property
+type Violation struct {
+	Property string
+	Replica  int
+	Other    int // The replica that the other one diverges from (-1 if there is none)
+	Index    int // The index of the batch (0 if the property is not about a batch)
+	Detail   string
+	Context  []string // The batches around the violation, one per line
+}
+
+func (v *Violation) Error() string {
+	s := fmt.Sprintf("%s violated by replica %d", v.Property, v.Replica)
+	if v.Other >= 0 {
+		s += fmt.Sprintf(" (against replica %d)", v.Other)
+	}
+	if v.Index > 0 {
+		s += fmt.Sprintf(" at index %d", v.Index)
+	}
+	s += ": " + v.Detail
+	if len(v.Context) > 0 {
+		s += "\n\t" + strings.Join(v.Context, "\n\t")
+	}
+	return s
+}
+
+// Checker - Collects what the replicas broadcast and deliver (safe for concurrent use)
+type Checker struct {
+	mutex     sync.Mutex
+	correct   map[int]bool
+	broadcast map[string][]int // value -> replicas that broadcast it
+	batches   map[int][]Batch  // replica -> the batches it delivered, in order
+	errors    []error          // Deliveries out of order, found when they are recorded
+}
+
+// New - A checker of the given correct replicas (the others are Byzantine, and their deliveries are
+// ignored)
+func New(correct ...int) *Checker {
+	c := &Checker{
+		correct:   make(map[int]bool, len(correct)),
+		broadcast: make(map[string][]int),
+		batches:   make(map[int][]Batch, len(correct)),
+	}
+	for _, id := range correct {
+		c.correct[id] = true
+	}
+	return c
+}
+
+// Broadcast - Records that replica broadcast value
+func (c *Checker) Broadcast(replica int, value []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.broadcast[string(value)] = append(c.broadcast[string(value)], replica)
+}
+
+// Deliver - Records that replica delivered a batch at index (the indexes of a replica must increase)
+func (c *Checker) Deliver(replica int, index int, values [][]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.correct[replica] {
+		return
+	}
+	batches := c.batches[replica]
+	if k := len(batches); k > 0 && index <= batches[k-1].Index {
+		c.errors = append(c.errors, &Violation{Property: TotalOrder, Replica: replica, Other: -1, Index: index,
+			Detail:  fmt.Sprintf("delivered after index %d", batches[k-1].Index),
+			Context: []string{describe(replica, batches[k-1]), describe(replica, Batch{index, values})}})
+	}
+	copied := make([][]byte, len(values))
+	for k, v := range values {
+		copied[k] = append([]byte(nil), v...)
+	}
+	c.batches[replica] = append(batches, Batch{Index: index, Values: copied})
+}
+
+// Delivered - The batches that replica delivered so far
+func (c *Checker) Delivered(replica int) []Batch {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]Batch(nil), c.batches[replica]...)
+}
+
+// CheckSafety - The first violation of total order or integrity (nil if there is none)
+func (c *Checker) CheckSafety() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.errors) > 0 {
+		return c.errors[0]
+	}
+	if err := c.totalOrder(); err != nil {
+		return err
+	}
+	return c.integrity()
+}
+
+// Check - The first violation of any property, once the run is over (nil if there is none)
+func (c *Checker) Check() error {
+	if err := c.CheckSafety(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.validity(); err != nil {
+		return err
+	}
+	return c.agreement()
+}
+
+// replicas - The correct replicas, in order
+func (c *Checker) replicas() []int {
+	ids := make([]int, 0, len(c.correct))
+	for id := range c.correct {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// totalOrder - Compares the batches of every correct replica with those of the first one that
+// delivered the same index
+func (c *Checker) totalOrder() error {
+	first := make(map[int]int) // index -> the first replica that delivered it
+	for _, id := range c.replicas() {
+		for k, b := range c.batches[id] {
+			other, in := first[b.Index]
+			if !in {
+				first[b.Index] = id
+				continue
+			}
+			o := c.find(other, b.Index)
+			if !sameBatch(b.Values, c.batches[other][o].Values) {
+				context := make([]string, 0, 2*contextSize+2)
+				for j := max(0, o-contextSize); j <= o; j++ {
+					context = append(context, describe(other, c.batches[other][j]))
+				}
+				for j := max(0, k-contextSize); j <= k; j++ {
+					context = append(context, describe(id, c.batches[id][j]))
+				}
+				return &Violation{Property: TotalOrder, Replica: id, Other: other, Index: b.Index,
+					Detail: "the batches differ", Context: context}
+			}
+		}
+	}
+	return nil
+}
+
+// find - The position of the batch that replica delivered at index
+func (c *Checker) find(replica int, index int) int {
+	batches := c.batches[replica]
+	return sort.Search(len(batches), func(k int) bool { return batches[k].Index >= index })
+}
+
+// integrity - Checks that no correct replica delivers a value more times than it was broadcast
+func (c *Checker) integrity() error {
+	for _, id := range c.replicas() {
+		seen := make(map[string][]int) // value -> the indexes of its deliveries
+		for _, b := range c.batches[id] {
+			for _, v := range b.Values {
+				seen[string(v)] = append(seen[string(v)], b.Index)
+				broadcast := len(c.broadcast[string(v)])
+				if broadcast == 0 {
+					return &Violation{Property: Integrity, Replica: id, Other: -1, Index: b.Index,
+						Detail: fmt.Sprintf("%s was not broadcast", quote(v)), Context: []string{describe(id, b)}}
+				}
+				if indexes := seen[string(v)]; len(indexes) > broadcast {
+					previous := indexes[len(indexes)-2]
+					return &Violation{Property: Integrity, Replica: id, Other: -1, Index: b.Index,
+						Detail: fmt.Sprintf("%s was delivered %d times but broadcast %d times (last at index %d)",
+							quote(v), len(indexes), broadcast, previous),
+						Context: []string{describe(id, c.batches[id][c.find(id, previous)]), describe(id, b)}}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validity - Checks that every correct replica delivered each value at least as many times as the
+// correct replicas broadcast it
+func (c *Checker) validity() error {
+	values := make([]string, 0, len(c.broadcast))
+	for v := range c.broadcast {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, id := range c.replicas() {
+		delivered := c.counts(id)
+		for _, v := range values {
+			correct := 0
+			for _, sender := range c.broadcast[v] {
+				if !c.correct[sender] {
+					continue
+				}
+				if correct++; delivered[v] < correct {
+					return &Violation{Property: Validity, Replica: id, Other: sender,
+						Detail: fmt.Sprintf("%s, which replica %d broadcast, was not delivered", quote([]byte(v)),
+							sender)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// agreement - Checks that the correct replicas delivered every value the same number of times
+func (c *Checker) agreement() error {
+	ids := c.replicas()
+	counts := make(map[int]map[string]int, len(ids))
+	for _, id := range ids {
+		counts[id] = c.counts(id)
+	}
+	for _, id := range ids {
+		for _, b := range c.batches[id] {
+			for _, v := range b.Values {
+				for _, other := range ids {
+					if counts[other][string(v)] < counts[id][string(v)] {
+						return &Violation{Property: Agreement, Replica: other, Other: id, Index: b.Index,
+							Detail: fmt.Sprintf("%s was delivered %d times instead of %d", quote(v),
+								counts[other][string(v)], counts[id][string(v)]),
+							Context: []string{describe(id, b)}}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// counts - How many times replica delivered every value
+func (c *Checker) counts(replica int) map[string]int {
+	counts := make(map[string]int)
+	for _, b := range c.batches[replica] {
+		for _, v := range b.Values {
+			counts[string(v)]++
+		}
+	}
+	return counts
+}
+
+func sameBatch(a [][]byte, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !bytes.Equal(a[k], b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe - A batch of a replica in one line
+func describe(replica int, b Batch) string {
+	values := make([]string, len(b.Values))
+	for k, v := range b.Values {
+		values[k] = quote(v)
+	}
+	return fmt.Sprintf("replica %d, index %d: [%s]", replica, b.Index, strings.Join(values, " "))
+}
+
+// quote - A value, cut if it is long
+func quote(v []byte) string {
+	if len(v) > 32 {
+		return fmt.Sprintf("%q...(%d bytes)", v[:32], len(v))
+	}
+	return fmt.Sprintf("%q", v)
+}
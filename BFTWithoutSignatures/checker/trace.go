@@ -0,0 +1,131 @@
+package checker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+	A trace holds what a replica broadcast and delivered, one JSON event per
+	line, so that the replicas of a multi-process run write their own trace
+	and a checker reads all of them once the run is over.
+*/
+
+// Kinds of events in a trace
+const (
+	EventBroadcast = "broadcast"
+	EventDeliver   = "deliver"
+)
+
+// Event - A line of a trace (the values are encoded in base64)
+type Event struct {
+	Replica int      `json:"replica"`
+	Kind    string   `json:"event"`
+	Index   int      `json:"index,omitempty"`
+	Values  [][]byte `json:"values"`
+}
+
+// Recorder - Writes the trace of a replica (safe for concurrent use)
+type Recorder struct {
+	mutex   sync.Mutex
+	replica int
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+// NewRecorder - A recorder of the events of replica to w
+func NewRecorder(w io.Writer, replica int) *Recorder {
+	return &Recorder{replica: replica, writer: w, encoder: json.NewEncoder(w)}
+}
+
+// CreateRecorder - A recorder of the events of replica to the file <replica>.jsonl in folder (an
+// existing trace is truncated)
+func CreateRecorder(folder string, replica int) (*Recorder, error) {
+	file, err := os.Create(filepath.Join(folder, fmt.Sprintf("%d.jsonl", replica)))
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(file, replica), nil
+}
+
+// Broadcast - Records that the replica broadcast value
+func (r *Recorder) Broadcast(value []byte) error {
+	return r.write(Event{Replica: r.replica, Kind: EventBroadcast, Values: [][]byte{value}})
+}
+
+// Deliver - Records that the replica delivered a batch at index
+func (r *Recorder) Deliver(index int, values [][]byte) error {
+	return r.write(Event{Replica: r.replica, Kind: EventDeliver, Index: index, Values: values})
+}
+
+// Close - Closes the file of the trace, if the recorder writes to one
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if closer, ok := r.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *Recorder) write(e Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.encoder.Encode(e)
+}
+
+// Read - Adds the events of a trace to the checker
+func (c *Checker) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		switch e.Kind {
+		case EventBroadcast:
+			for _, v := range e.Values {
+				c.Broadcast(e.Replica, v)
+			}
+		case EventDeliver:
+			c.Deliver(e.Replica, e.Index, e.Values)
+		default:
+			return fmt.Errorf("line %d: unknown event %q", line, e.Kind)
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadFolder - Adds the traces of a folder (its .jsonl files) to the checker
+func (c *Checker) ReadFolder(folder string) error {
+	paths, err := filepath.Glob(filepath.Join(folder, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no trace in %s", folder)
+	}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = c.Read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	The cluster file (JSON) describes the replicas and the protocol they run, and
+	it is shared by the servers and the clients. Every replica listens on a range
+	of ports: replica j receives the messages of replica i on PeerPort+i, the
+	requests of client c on ClientPort+c and publishes the responses to client c
+	on ClientPort+Clients+c. The relative paths in the file are relative to the
+	folder of the file.
+
+	The replicas reload the file on SIGUSR1 and propose the change it makes to
+	their membership (one replica added with the next id, the last one removed
+	or one replaced). The coin keys must then be dealt again for the new
+	replicas (generate_coin_keys) in the coin_keys folder, at the same path on
+	every replica. The clients read the file only when they start.
+
+	The batch options of the protocol are optional (0 keeps the default ones):
+	batch_bytes, batch_requests and batch_linger_ms, as is consensus_window
+	(how many consensus instances run at a time, 1 by default). A replica with a
+	metrics_port serves its metrics on http://<host>:<metrics_port>/metrics.
+
+	{
+		"clients": 5,
+		"keys": "../threshenc/keys/",
+		"protocol": {"scenario": "NORMAL", "algorithm": "ABC", "transient_probability": 0, "auth": "rsa"},
+		"replicas": [
+			{"id": 0, "host": "localhost", "peer_port": 4000, "client_port": 7000,
+				"public_key": "../threshenc/keys/verification_0.key"},
+			...
+		]
+	}
+*/
+
+// Algorithms that the replicas can run
+const (
+	AlgorithmABC   = "ABC"   // Atomic Broadcast
+	AlgorithmSSABC = "SSABC" // Self Stabilized Atomic Broadcast
+)
+
+// Cluster - The replicas of the system and the protocol they run
+type Cluster struct {
+	Replicas []ReplicaConfig `json:"replicas"`
+	Clients  int             `json:"clients"`
+	Keys     string          `json:"keys"`      // The folder with the secret and the coin keys of the replicas
+	CoinKeys string          `json:"coin_keys"` // The folder with the coin keys, if not in Keys
+	Protocol Protocol        `json:"protocol"`
+
+	file string
+}
+
+// ReplicaConfig - Where a replica runs and the key it signs with
+type ReplicaConfig struct {
+	ID          int    `json:"id"`
+	Host        string `json:"host"`
+	PeerPort    int    `json:"peer_port"`
+	ClientPort  int    `json:"client_port"`
+	MetricsPort int    `json:"metrics_port"` // 0 if the replica does not serve its metrics
+	PublicKey   string `json:"public_key"`
+}
+
+// Protocol - The options that every replica of the cluster runs with
+type Protocol struct {
+	Scenario             string  `json:"scenario"`
+	Algorithm            string  `json:"algorithm"`
+	TransientProbability float64 `json:"transient_probability"`
+	Auth                 string  `json:"auth"` // AuthRSA if it is empty
+	BatchBytes           int     `json:"batch_bytes"`
+	BatchRequests        int     `json:"batch_requests"`
+	BatchLingerMs        int     `json:"batch_linger_ms"`
+	ConsensusWindow      int     `json:"consensus_window"`
+	SSABCNumLimit        uint32  `json:"ssabc_num_limit"`
+}
+
+// ClusterError - The problems found in a cluster file
+type ClusterError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ClusterError) Error() string {
+	return "invalid cluster file " + e.File + ":\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// LoadCluster - Reads and validates the cluster file
+func LoadCluster(file string) (*Cluster, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{file: file}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cluster)
+	if err != nil {
+		return nil, &ClusterError{File: file, Problems: []string{err.Error()}}
+	}
+
+	err = cluster.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// The replicas in the order of their ids
+	replicas := make([]ReplicaConfig, len(cluster.Replicas))
+	for _, r := range cluster.Replicas {
+		replicas[r.ID] = r
+	}
+	cluster.Replicas = replicas
+	if cluster.Protocol.Auth == "" {
+		cluster.Protocol.Auth = AuthRSA
+	}
+	return cluster, nil
+}
+
+// Validate - Checks the cluster and returns a *ClusterError with every problem found
+func (c *Cluster) Validate() error {
+	problems := make([]string, 0)
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	n := len(c.Replicas)
+	if n == 0 {
+		problem("no replicas")
+	}
+	if c.Clients < 0 {
+		problem("clients is %d, it must not be negative", c.Clients)
+	}
+
+	ids := make(map[int]bool, n)
+	for k, r := range c.Replicas {
+		name := "replica " + strconv.Itoa(r.ID)
+		if r.ID < 0 || r.ID >= n {
+			problem("replicas[%d]: id %d is not in [0, %d]", k, r.ID, n-1)
+		} else if ids[r.ID] {
+			problem("%s: listed more than once", name)
+		}
+		ids[r.ID] = true
+
+		if r.Host == "" {
+			problem("%s: no host", name)
+		}
+		if r.PeerPort <= 0 || r.PeerPort+n-1 > 65535 {
+			problem("%s: peer_port %d does not leave room for %d ports", name, r.PeerPort, n)
+		}
+		if r.ClientPort <= 0 || r.ClientPort+2*c.Clients-1 > 65535 {
+			problem("%s: client_port %d does not leave room for %d ports", name, r.ClientPort, 2*c.Clients)
+		}
+		if r.MetricsPort < 0 || r.MetricsPort > 65535 {
+			problem("%s: metrics_port %d is not a port", name, r.MetricsPort)
+		}
+		if r.PublicKey == "" {
+			problem("%s: no public_key", name)
+		} else if _, err := os.Stat(c.Path(r.PublicKey)); err != nil {
+			problem("%s: public_key: %v", name, err)
+		}
+	}
+
+	// The port ranges of the replicas that run on the same host must not overlap
+	type portRange struct {
+		name        string
+		host        string
+		first, last int
+	}
+	ranges := make([]portRange, 0, 3*n)
+	for _, r := range c.Replicas {
+		name := "replica " + strconv.Itoa(r.ID)
+		ranges = append(ranges, portRange{name + " peer ports", r.Host, r.PeerPort, r.PeerPort + n - 1})
+		if c.Clients > 0 {
+			ranges = append(ranges, portRange{name + " client ports", r.Host, r.ClientPort, r.ClientPort + 2*c.Clients - 1})
+		}
+		if r.MetricsPort > 0 {
+			ranges = append(ranges, portRange{name + " metrics port", r.Host, r.MetricsPort, r.MetricsPort})
+		}
+	}
+	for a := 0; a < len(ranges); a++ {
+		for b := a + 1; b < len(ranges); b++ {
+			if ranges[a].host == ranges[b].host && ranges[a].first <= ranges[b].last &&
+				ranges[b].first <= ranges[a].last {
+				problem("%s [%d-%d] overlap with %s [%d-%d] on %s", ranges[a].name, ranges[a].first,
+					ranges[a].last, ranges[b].name, ranges[b].first, ranges[b].last, ranges[a].host)
+			}
+		}
+	}
+
+	if c.Keys == "" {
+		problem("no keys folder")
+	} else if info, err := os.Stat(c.Path(c.Keys)); err != nil {
+		problem("keys: %v", err)
+	} else if !info.IsDir() {
+		problem("keys: %s is not a folder", c.Keys)
+	}
+	if c.CoinKeys != "" {
+		if info, err := os.Stat(c.Path(c.CoinKeys)); err != nil {
+			problem("coin_keys: %v", err)
+		} else if !info.IsDir() {
+			problem("coin_keys: %s is not a folder", c.CoinKeys)
+		}
+	}
+
+	if _, in := scenarioIndex(c.Protocol.Scenario); !in {
+		problem("protocol: unknown scenario %q", c.Protocol.Scenario)
+	}
+	if c.Protocol.Algorithm != AlgorithmABC && c.Protocol.Algorithm != AlgorithmSSABC {
+		problem("protocol: algorithm is %q, it must be %q or %q", c.Protocol.Algorithm, AlgorithmABC, AlgorithmSSABC)
+	}
+	if c.Protocol.TransientProbability < 0 || c.Protocol.TransientProbability > 1 {
+		problem("protocol: transient_probability %v is not in [0, 1]", c.Protocol.TransientProbability)
+	}
+	if c.Protocol.Auth != "" && c.Protocol.Auth != AuthRSA && c.Protocol.Auth != AuthHMAC {
+		problem("protocol: auth is %q, it must be %q or %q", c.Protocol.Auth, AuthRSA, AuthHMAC)
+	}
+	if c.Protocol.BatchBytes < 0 || c.Protocol.BatchRequests < 0 || c.Protocol.BatchLingerMs < 0 {
+		problem("protocol: batch_bytes, batch_requests and batch_linger_ms must not be negative")
+	}
+	if c.Protocol.ConsensusWindow < 0 {
+		problem("protocol: consensus_window is %d, it must not be negative", c.Protocol.ConsensusWindow)
+	}
+
+	if len(problems) > 0 {
+		return &ClusterError{File: c.file, Problems: problems}
+	}
+	return nil
+}
+
+// Path - Resolves a path of the cluster file (relative to its folder)
+func (c *Cluster) Path(path string) string {
+	if filepath.IsAbs(path) || c.file == "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.file), path)
+}
+
+// Replica - The variables of replica id in the cluster
+func (c *Cluster) Replica(id int) variables.Replica {
+	rem := 0
+	for _, r := range c.Replicas {
+		if r.Host != "localhost" && r.Host != "127.0.0.1" {
+			rem = 1
+		}
+	}
+	return variables.NewReplica(id, len(c.Replicas), c.Clients, rem)
+}
+
+// RunSSABC - Whether the replicas run Self Stabilized Atomic Broadcast (instead of Atomic Broadcast)
+func (c *Cluster) RunSSABC() bool {
+	return c.Protocol.Algorithm == AlgorithmSSABC
+}
+
+// Options - The options of the protocol
+func (c *Cluster) Options() Options {
+	s, _ := scenarioIndex(c.Protocol.Scenario)
+	transientProb := c.Protocol.TransientProbability
+	if !c.RunSSABC() {
+		transientProb = -1
+	}
+
+	options := InitializeScenario(s, transientProb)
+	options.Auth = c.Protocol.Auth
+	if c.Protocol.BatchBytes > 0 {
+		options.BatchBytes = c.Protocol.BatchBytes
+	}
+	if c.Protocol.BatchRequests > 0 {
+		options.BatchRequests = c.Protocol.BatchRequests
+	}
+	if c.Protocol.BatchLingerMs > 0 {
+		options.BatchLinger = time.Duration(c.Protocol.BatchLingerMs) * time.Millisecond
+	}
+	if c.Protocol.ConsensusWindow > 0 {
+		options.ConsensusWindow = c.Protocol.ConsensusWindow
+	}
+	options.SSABCNumLimit = c.Protocol.SSABCNumLimit
+	return options
+}
+
+// Addresses - The addresses of the sockets of replica id
+func (c *Cluster) Addresses(id int) Addresses {
+	addresses := Addresses{
+		Rep:      make(map[int]string, len(c.Replicas)),
+		Req:      make(map[int]string, len(c.Replicas)),
+		Server:   make(map[int]string, c.Clients),
+		Response: make(map[int]string, c.Clients),
+	}
+
+	self := c.Replicas[id]
+	for i, peer := range c.Replicas {
+		addresses.Rep[i] = "tcp://*:" + strconv.Itoa(self.PeerPort+i)
+		addresses.Req[i] = "tcp://" + peer.Host + ":" + strconv.Itoa(peer.PeerPort+id)
+	}
+	for i := 0; i < c.Clients; i++ {
+		addresses.Server[i] = "tcp://*:" + strconv.Itoa(self.ClientPort+i)
+		addresses.Response[i] = "tcp://*:" + strconv.Itoa(self.ClientPort+c.Clients+i)
+	}
+
+	return addresses
+}
+
+// PublicKeys - The files of the public keys of the replicas
+func (c *Cluster) PublicKeys() map[int]string {
+	files := make(map[int]string, len(c.Replicas))
+	for i, r := range c.Replicas {
+		files[i] = c.Path(r.PublicKey)
+	}
+	return files
+}
+
+// CoinKeysFolder - The folder of the coin keys of the replicas
+func (c *Cluster) CoinKeysFolder() string {
+	if c.CoinKeys == "" {
+		return c.Path(c.Keys)
+	}
+	return c.Path(c.CoinKeys)
+}
+
+// Members - The replicas as members of the membership, with their public keys
+func (c *Cluster) Members() ([]types.Member, error) {
+	members := make([]types.Member, len(c.Replicas))
+	for i, r := range c.Replicas {
+		key, err := ioutil.ReadFile(c.Path(r.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		members[i] = types.Member{ID: r.ID, Host: r.Host, PeerPort: r.PeerPort, ClientPort: r.ClientPort,
+			PublicKey: key}
+	}
+	return members, nil
+}
+
+// Reconfiguration - The change that turns the members into the replicas of the cluster (only one
+// replica can be added, removed or replaced at a time)
+func (c *Cluster) Reconfiguration(members []types.Member) (types.Reconfiguration, error) {
+	replicas, err := c.Members()
+	if err != nil {
+		return types.Reconfiguration{}, err
+	}
+	r := types.Reconfiguration{}
+	if c.CoinKeys != "" {
+		r.CoinKeys = c.Path(c.CoinKeys)
+	}
+
+	switch len(replicas) - len(members) {
+	case 1:
+		r.Op, r.Member = types.AddReplica, replicas[len(members)]
+	case -1:
+		r.Op, r.Member = types.RemoveReplica, types.Member{ID: len(replicas)}
+	case 0:
+		changed := make([]int, 0)
+		for i := range replicas {
+			if !sameMember(replicas[i], members[i]) {
+				changed = append(changed, i)
+			}
+		}
+		if len(changed) != 1 {
+			return r, fmt.Errorf("%d replicas changed, only one can be replaced at a time", len(changed))
+		}
+		r.Op, r.Member = types.ReplaceReplica, replicas[changed[0]]
+	default:
+		return r, fmt.Errorf("%d replicas instead of %d, only one can join or leave at a time",
+			len(replicas), len(members))
+	}
+	for i := 0; i < len(members) && i < len(replicas); i++ {
+		if r.Op != types.ReplaceReplica && !sameMember(replicas[i], members[i]) {
+			return r, fmt.Errorf("replica %d changed too, only one replica can change at a time", i)
+		}
+	}
+	return r, nil
+}
+
+// sameMember - Whether two members run at the same place with the same key
+func sameMember(a types.Member, b types.Member) bool {
+	return a.ID == b.ID && a.Host == b.Host && a.PeerPort == b.PeerPort && a.ClientPort == b.ClientPort &&
+		bytes.Equal(a.PublicKey, b.PublicKey)
+}
+
+// scenarioIndex - The number of the scenario with the given name
+func scenarioIndex(name string) (int, bool) {
+	for s, scenario := range scenarios {
+		if scenario == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
@@ -5,53 +5,38 @@ import (
 	"strconv"
 )
 
-var (
-	// RepAddresses - Initialize the address of local Rep sockets
-	RepAddresses map[int]string
+// Addresses - The addresses of the sockets of a replica
+type Addresses struct {
+	// Rep - The address of REP sockets (receive from the server with that id)
+	Rep map[int]string
 
-	// ReqAddresses - Initialize the address of local Req sockets
-	ReqAddresses map[int]string
+	// Req - The address of REQ sockets (send to the server with that id)
+	Req map[int]string
 
-	// ServerAddresses - Initialize the address of local Server sockets
-	ServerAddresses map[int]string
+	// Server - The address of Server sockets (requests of the client with that id)
+	Server map[int]string
 
-	// ResponseAddresses - Initialize the address of local Response sockets
-	ResponseAddresses map[int]string
-)
+	// Response - The address of Response sockets (responses to the client with that id)
+	Response map[int]string
+}
 
 // InitializeLocal - Initializes system locally.
-func InitializeLocal() {
-	RepAddresses = make(map[int]string, variables.N)
-	ReqAddresses = make(map[int]string, variables.N)
-	ServerAddresses = make(map[int]string, variables.Clients)
-	ResponseAddresses = make(map[int]string, variables.Clients)
-
-	for i := 0; i < variables.N; i++ {
-		RepAddresses[i] = "tcp://*:" + strconv.Itoa(4000+(variables.ID*100)+i)
-		ReqAddresses[i] = "tcp://localhost:" + strconv.Itoa(4000+(i*100)+variables.ID)
-	}
-	for i := 0; i < variables.Clients; i++ {
-		ServerAddresses[i] = "tcp://*:" + strconv.Itoa(7000+(variables.ID*100)+i)
-		ResponseAddresses[i] = "tcp://*:" + strconv.Itoa(10000+(variables.ID*100)+i)
+func InitializeLocal(r variables.Replica) Addresses {
+	addresses := Addresses{
+		Rep:      make(map[int]string, r.N),
+		Req:      make(map[int]string, r.N),
+		Server:   make(map[int]string, r.Clients),
+		Response: make(map[int]string, r.Clients),
 	}
-}
-
-// GetRepAddressLocal - Returns the local REP address of the server with that id
-func GetRepAddressLocal(id int) string {
-	return RepAddresses[id]
-}
 
-// GetReqAddressLocal - Returns the local REQ address of the server with that id
-func GetReqAddressLocal(id int) string {
-	return ReqAddresses[id]
-}
-
-// GetServerAddressLocal - Returns the local Server address of the server with that id
-func GetServerAddressLocal(id int) string {
-	return ServerAddresses[id]
-}
+	for i := 0; i < r.N; i++ {
+		addresses.Rep[i] = "tcp://*:" + strconv.Itoa(4000+(r.ID*100)+i)
+		addresses.Req[i] = "tcp://localhost:" + strconv.Itoa(4000+(i*100)+r.ID)
+	}
+	for i := 0; i < r.Clients; i++ {
+		addresses.Server[i] = "tcp://*:" + strconv.Itoa(7000+(r.ID*100)+i)
+		addresses.Response[i] = "tcp://*:" + strconv.Itoa(10000+(r.ID*100)+i)
+	}
 
-// GetResponseAddressLocal - Returns the local Response address of the server with that id
-func GetResponseAddressLocal(id int) string {
-	return ResponseAddresses[id]
+	return addresses
 }
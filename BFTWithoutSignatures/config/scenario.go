@@ -3,38 +3,86 @@ package config
 import (
 	"BFTWithoutSignatures/logger"
 	"math/big"
+	"time"
 )
 
 var (
-	Scenario string
-
 	scenarios = map[int]string{
 		0: "NORMAL",      // Normal execution
 		1: "IDLE",        // Byzantine processes remain idle (send nothing)
 		2: "BC_ATTACK",   // Byzantine processes send wrong bytes for BC
 		3: "HALF_&_HALF", // Byzantine processes send different messages to half the servers
-		4: "BZ_ALL",			// Byzantine processes send the same value to every server
+		4: "BZ_ALL",      // Byzantine processes send the same value to every server
 	}
+)
+
+// DefaultCheckpointInterval - Every how many delivered batches the replicas agree on a checkpoint
+const DefaultCheckpointInterval = 100
+
+// How a replica packs the client requests into the values it broadcasts
+const (
+	DefaultBatchBytes    = 64 * 1024            // The size of a batch (its requests), unless one request is larger
+	DefaultBatchRequests = 256                  // The number of requests in a batch
+	DefaultBatchLinger   = 5 * time.Millisecond // How long the first request of a batch waits for others
+)
 
-	Transient bool
+// Authentication modes of the messages between the servers
+const (
+	AuthRSA  = "rsa"  // Every message is signed with RSA-PSS and verified by its receivers
+	AuthHMAC = "hmac" // The links carry HMACs with pairwise session keys, agreed on with ECDH at link setup
+)
+
+// Options - The scenario a replica executes
+type Options struct {
+	Scenario string
+
+	Transient            bool
 	TransientProbability float64
 
+	// CheckpointInterval - Every how many delivered batches a checkpoint is taken (0 disables them)
+	CheckpointInterval int
+
+	// BatchBytes, BatchRequests, BatchLinger - A batch of client requests is broadcast when it has
+	// BatchRequests requests or BatchBytes bytes, or BatchLinger after its first request (1 request
+	// disables batching)
+	BatchBytes    int
+	BatchRequests int
+	BatchLinger   time.Duration
+
+	// ConsensusWindow - How many consensus instances (VC for ABC, SSVC for SSABC) run at a time; their
+	// outputs are still delivered in instance order
+	ConsensusWindow int
+
+	// SSABCNumLimit - How many values a replica broadcasts with SSABC in an epoch, before a global reset
+	// starts the next one (math.MaxUint32 if 0; lower for tests)
+	SSABCNumLimit uint32
+
+	// Auth - How the messages between the servers are authenticated (AuthRSA or AuthHMAC)
+	Auth string
+
+	// DeterministicCoin - BC uses the predictable coin (round id % 2) instead of the threshold one (for tests)
+	DeterministicCoin bool
+
+	// TestExecution - Indicates a test execution - from files in tests folder (for debugging)
 	TestExecution bool
-)
+}
 
-func InitializeScenario(s int, transientProb float64) {
+// InitializeScenario - Creates the options of scenario s
+func InitializeScenario(s int, transientProb float64) Options {
 	if s >= len(scenarios) {
 		logger.ErrLogger.Println("Scenario out of bounds! Executing with NORMAL scenario ...")
 		s = 0
 	}
 
-	Scenario = scenarios[s]
-
-	Transient = big.NewFloat(transientProb).Cmp(big.NewFloat(0)) > 0
-	TransientProbability = transientProb
-}
-
-// Indicates a test execution - from files in tests folder (for debugging)
-func SetTest(isTest bool) {
-	TestExecution = isTest
+	return Options{
+		Scenario:             scenarios[s],
+		Transient:            big.NewFloat(transientProb).Cmp(big.NewFloat(0)) > 0,
+		TransientProbability: transientProb,
+		CheckpointInterval:   DefaultCheckpointInterval,
+		BatchBytes:           DefaultBatchBytes,
+		BatchRequests:        DefaultBatchRequests,
+		BatchLinger:          DefaultBatchLinger,
+		ConsensusWindow:      1,
+		Auth:                 AuthRSA,
+	}
 }
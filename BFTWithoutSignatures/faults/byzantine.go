@@ -2,14 +2,14 @@ package faults
 
 import (
 	"BFTWithoutSignatures/variables"
-	"BFTWithoutSignatures/config"
   "strconv"
 )
 
-func ByzantineValuesSSABC(msg map[int]map[string][]ssabcMT) map[int]map[string][]ssabcMT {
+func ByzantineValuesSSABC(r variables.Replica, scenario string,
+	msg map[int]map[string][]ssabcMT) map[int]map[string][]ssabcMT {
   // change values to their corresponding sending ones
-	halfScenario := config.Scenario == "HALF_&_HALF"
-	for i := 0; i < variables.N; i++ {
+	halfScenario := scenario == "HALF_&_HALF"
+	for i := 0; i < r.N; i++ {
     msg[i]["init"] = []ssabcMT{}
   	msg[i]["echo"] = []ssabcMT{}
   	msg[i]["ready"] = []ssabcMT{}
@@ -20,7 +20,7 @@ func ByzantineValuesSSABC(msg map[int]map[string][]ssabcMT) map[int]map[string][
 		}
 		msg[i]["init"] = []ssabcMT{{Sender:i,Num:0,Value:[]byte(valueToSend)}}
 		for _,t := range []string{"echo", "ready"} {
-			for j:=0; j<variables.N;j++{
+			for j:=0; j<r.N;j++{
 				msg[i][t] = append(msg[i][t], ssabcMT{Sender:j,Num:0,Value:[]byte(valueToSend)})
 			}
 		}
@@ -28,10 +28,11 @@ func ByzantineValuesSSABC(msg map[int]map[string][]ssabcMT) map[int]map[string][
   return msg
 }
 
-func ByzantineValuesSSVC(msg map[int]map[string][]ssvcMT) map[int]map[string][]ssvcMT {
+func ByzantineValuesSSVC(r variables.Replica, scenario string,
+	msg map[int]map[string][]ssvcMT) map[int]map[string][]ssvcMT {
   // change values to their corresponding sending ones
-	halfScenario := config.Scenario == "HALF_&_HALF"
-	for i := 0; i < variables.N; i++ {
+	halfScenario := scenario == "HALF_&_HALF"
+	for i := 0; i < r.N; i++ {
     msg[i]["init"] = []ssvcMT{}
   	msg[i]["echo"] = []ssvcMT{}
   	msg[i]["ready"] = []ssvcMT{}
@@ -42,7 +43,7 @@ func ByzantineValuesSSVC(msg map[int]map[string][]ssvcMT) map[int]map[string][]s
 		}
 		msg[i]["init"] = []ssvcMT{{Sender:i,Value:[]byte(valueToSend)}}
 		for _,t := range []string{"echo", "ready"} {
-			for j:=0; j<variables.N;j++{
+			for j:=0; j<r.N;j++{
 				msg[i][t] = append(msg[i][t], ssvcMT{Sender:j,Value:[]byte(valueToSend)})
 			}
 		}
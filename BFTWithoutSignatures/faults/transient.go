@@ -6,7 +6,6 @@ import (
 	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures/types"
 	"BFTWithoutSignatures/variables"
-	"BFTWithoutSignatures/config"
 	"time"
 	"math/rand"
 	"math"
@@ -16,14 +15,17 @@ import (
 type ssvcMT = types.SSVCMessageTuple
 type ssabcMT = types.SSABCMessageTuple
 
+// log - The logger of the transient faults
+var log = logger.Module("faults")
 
-func CreateSSVCTransientMsg(initFault, echoFault, readyFault, senderFault,
+
+func CreateSSVCTransientMsg(r variables.Replica, initFault, echoFault, readyFault, senderFault,
     valueFault bool, msg map[int]map[string][]ssvcMT, ssvcid int, p float64) map[int]map[string][]ssvcMT{
 
 	s := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(s)
+	rnd := rand.New(s)
 
-	if r.Float64() < p {
+	if rnd.Float64() < p {
 		faultTypes := []string{}
 
 		// init
@@ -41,15 +43,15 @@ func CreateSSVCTransientMsg(initFault, echoFault, readyFault, senderFault,
 
 		// transient fault occurence
 		for _, ftype := range faultTypes {
-			logger.OutLogger.Print("SSVC: ",ftype," transient fault:\n")
-			for id:=0;id<variables.N;id++{
+			log.Warn("transient fault", "protocol", "SSVC", "type", ftype)
+			for id:=0;id<r.N;id++{
 				for i,_ := range msg[id][ftype]{
 		        if senderFault{ // change sender
-							logger.OutLogger.Print(id," sender --> sender + 1\n")
+							log.Debug("sender --> sender + 1", "id", id)
 		  				msg[id][ftype][i].Sender = msg[id][ftype][i].Sender+1
 		        }
 		        if valueFault{ // change value
-							logger.OutLogger.Print(id," values --> all '0'\n")
+							log.Debug("values --> all '0'", "id", id)
 		          msg[id][ftype][i].Value = []byte("0")
 		        }
 					}
@@ -62,16 +64,16 @@ func CreateSSVCTransientMsg(initFault, echoFault, readyFault, senderFault,
 // for debugging purposes with 3 tests in TestSSVConsensus
 // sender transient is changing sender to sender + 1
 // checks if every value is the expected
-func IsSSVCTransientRemoved (msg map[int]map[string][]ssvcMT, ssvcid int) bool {
+func IsSSVCTransientRemoved (r variables.Replica, scenario string, msg map[int]map[string][]ssvcMT, ssvcid int) bool {
 
 	var value []byte
-	for id:=0;id<variables.N;id++{
+	for id:=0;id<r.N;id++{
 		// is id-th process Byzantine (in execution where Byzantine exist)
-		isByzantine := id < variables.F && config.Scenario != "NORMAL"
+		isByzantine := id < r.F && scenario != "NORMAL"
 		if !isByzantine {
 			for _,t := range []string{"init","echo","ready"}{
 				for i,_ := range msg[id][t]{ // check if 0 msg exists in any message
-					if msg[id][t][i].Sender >= variables.F {	// not Byzantine message
+					if msg[id][t][i].Sender >= r.F {	// not Byzantine message
 						// value of each process based on the test
 						switch ssvcid {
 						case 1:
@@ -85,7 +87,7 @@ func IsSSVCTransientRemoved (msg map[int]map[string][]ssvcMT, ssvcid int) bool {
 						}
 
 						if  bytes.Compare(msg[id][t][i].Value,value)!=0 ||
-								msg[id][t][i].Sender < 0 || msg[id][t][i].Sender >= variables.N {
+								msg[id][t][i].Sender < 0 || msg[id][t][i].Sender >= r.N {
 							return false
 						}
 					}
@@ -93,17 +95,17 @@ func IsSSVCTransientRemoved (msg map[int]map[string][]ssvcMT, ssvcid int) bool {
 			}
 		}
 	}
-	logger.OutLogger.Print("SSVC: Cleared Transient")
+	log.Info("cleared transient", "protocol", "SSVC")
 	return true
 }
 
-func CreateSSABCTransientMsg(initFault, echoFault, readyFault, senderFault,
+func CreateSSABCTransientMsg(r variables.Replica, initFault, echoFault, readyFault, senderFault,
     valueFault, numFault bool, msg map[int]map[string][]ssabcMT, p float64) map[int]map[string][]ssabcMT{
 
 	s := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(s)
+	rnd := rand.New(s)
 
-	if r.Float64() < p {
+	if rnd.Float64() < p {
 		faultTypes := []string{}
 
 		// init
@@ -121,19 +123,19 @@ func CreateSSABCTransientMsg(initFault, echoFault, readyFault, senderFault,
 
 		// transient fault occurence
 		for _, ftype := range faultTypes {
-			logger.OutLogger.Print("SSABC: ",ftype," transient fault:\n")
-			for id:=0;id<variables.N;id++{
+			log.Warn("transient fault", "protocol", "SSABC", "type", ftype)
+			for id:=0;id<r.N;id++{
 				for i,_ := range msg[id][ftype]{
 		        if senderFault{ // change sender
-							logger.OutLogger.Print(id," sender --> sender + 1\n")
+							log.Debug("sender --> sender + 1", "id", id)
 		  				msg[id][ftype][i].Sender = msg[id][ftype][i].Sender+1
 		        }
 		        if valueFault{ // change value
-							logger.OutLogger.Print(id," values --> all '0'\n")
+							log.Debug("values --> all '0'", "id", id)
 		          msg[id][ftype][i].Value = []byte("0")
 		        }
 						if numFault{ // change num
-							logger.OutLogger.Print(id," num --> all MaxUint32 / 2\n")
+							log.Debug("num --> all MaxUint32 / 2", "id", id)
 		          msg[id][ftype][i].Num = math.MaxUint32/2
 		        }
 					}
@@ -147,19 +149,19 @@ func CreateSSABCTransientMsg(initFault, echoFault, readyFault, senderFault,
 // sender transient is changing sender to sender + 1
 // checks if every value is not "0" ("0" are not sent by processes in tests)
 // checks if message num is less than the process' num on messages sent by me
-func IsSSABCTransientRemoved (msg map[int]map[string][]ssabcMT, num uint32) bool {
+func IsSSABCTransientRemoved (r variables.Replica, scenario string, msg map[int]map[string][]ssabcMT, num uint32) bool {
 
-	byzantineScenario := config.Scenario != "NORMAL"
-	for id:=0;id<variables.N;id++{
+	byzantineScenario := scenario != "NORMAL"
+	for id:=0;id<r.N;id++{
 		// is id-th process Byzantine (in execution where Byzantine exist)
-		isByzantine := id < variables.F && byzantineScenario
+		isByzantine := id < r.F && byzantineScenario
 		if !isByzantine {
 			for _,t := range []string{"init","echo","ready"}{
 				for i,_ := range msg[id][t]{ // check if 0 msg exists in any message
-					if msg[id][t][i].Sender >= variables.F || !byzantineScenario{	// not Byzantine message
+					if msg[id][t][i].Sender >= r.F || !byzantineScenario{	// not Byzantine message
 						if  bytes.Compare(msg[id][t][i].Value, []byte("0"))==0 ||
-								msg[id][t][i].Sender < 0 || msg[id][t][i].Sender >= variables.N ||
-								(msg[id][t][i].Sender==variables.ID && msg[id][t][i].Num >= num) ||
+								msg[id][t][i].Sender < 0 || msg[id][t][i].Sender >= r.N ||
+								(msg[id][t][i].Sender==r.ID && msg[id][t][i].Num >= num) ||
 								msg[id][t][i].Num == math.MaxUint32/2{
 							return false
 						}
@@ -168,6 +170,44 @@ func IsSSABCTransientRemoved (msg map[int]map[string][]ssabcMT, num uint32) bool
 			}
 		}
 	}
-	logger.OutLogger.Print("SSABC: Cleared Transient")
+	log.Info("cleared transient", "protocol", "SSABC")
 	return true
 }
+
+// CreateSSMVCTransientMsg - Corrupts the init, echo and ready messages of a phase of SSMVC (their
+// senders and values) with probability p
+func CreateSSMVCTransientMsg(r variables.Replica, msg map[int]map[string][]ssvcMT, p float64) map[int]map[string][]ssvcMT {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	if rnd.Float64() < p {
+		for _, ftype := range []string{"init", "echo", "ready"} {
+			log.Warn("transient fault", "protocol", "SSMVC", "type", ftype)
+			for id := 0; id < r.N; id++ {
+				for i := range msg[id][ftype] {
+					msg[id][ftype][i].Sender = msg[id][ftype][i].Sender + 1
+					msg[id][ftype][i].Value = []byte("0")
+				}
+			}
+		}
+	}
+	return msg
+}
+
+// CreateSSBCTransientState - Corrupts the rounds of SSBC (out of bounds rounds, non binary EST values
+// and conflicting AUX values) with probability p
+func CreateSSBCTransientState(r variables.Replica, rounds map[int]map[int]types.SSBCRound, p float64) map[int]map[int]types.SSBCRound {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	if rnd.Float64() < p {
+		log.Warn("transient fault", "protocol", "SSBC")
+		for id := 0; id < r.N; id++ {
+			for k, state := range rounds[id] {
+				state.Est = append([]uint{2}, state.Est...)
+				state.Aux = []uint{0, 1}
+				rounds[id][k] = state
+			}
+			rounds[id][math.MaxInt32] = types.SSBCRound{Round: math.MaxInt32, Est: []uint{1}}
+		}
+	}
+	return rounds
+}
@@ -1,44 +1,57 @@
 package logger
 
 import (
-	"BFTWithoutSignatures/variables"
 	"log"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	// OutLogger - Log the outputs
-	OutLogger *log.Logger
+	// OutLogger - Log the outputs (records of LevelInfo of the "main" module, see structured.go)
+	OutLogger = log.New(bridge{LevelInfo}, "", 0)
 
-	// ErrLogger - Log the errors
-	ErrLogger *log.Logger
+	// ErrLogger - Log the errors (records of LevelError of the "main" module, see structured.go)
+	ErrLogger = log.New(bridge{LevelError}, "", 0)
 )
 
-// InitializeLogger - Initializes the Out and Err loggers
-func InitializeLogger(outFolder string, errFolder string) {
-	t := time.Now().Format("01-02-2006_15:04:05")
+// mainLogger - The logger of the records of OutLogger and ErrLogger
+var mainLogger = Module("main")
+
+// bridge - Writes the lines of a *log.Logger as records
+type bridge struct {
+	level Level
+}
 
-	outFilePath := outFolder + strconv.Itoa(variables.ID) + "_out_" + t + ".log"
-	errFilePath := errFolder + strconv.Itoa(variables.ID) + "_err_" + t + ".log"
+func (b bridge) Write(p []byte) (int, error) {
+	if msg := strings.TrimSpace(string(p)); msg != "" {
+		mainLogger.log(b.level, msg, nil)
+	}
+	return len(p), nil
+}
+
+// FileSink - A sink that writes every record of replica id to a file in outFolder, and its warnings
+// and errors to a file in errFolder as well
+func FileSink(outFolder string, errFolder string, id int, format Format) (Sink, error) {
+	t := time.Now().Format("01-02-2006_15:04:05")
 
-	outFile, err := os.OpenFile(outFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	out, err := OpenFileSink(outFolder+strconv.Itoa(id)+"_out_"+t+".log", format)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	errFile, err := os.OpenFile(errFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	errSink, err := OpenFileSink(errFolder+strconv.Itoa(id)+"_err_"+t+".log", format)
 	if err != nil {
-		log.Fatal(err)
+		out.Close()
+		return nil, err
 	}
+	return Tee(out, AtLeast(LevelWarn, errSink)), nil
+}
 
-	OutLogger = log.New(
-		outFile,
-		"INFO: ",
-		log.Lmicroseconds|log.Lshortfile)
-
-	ErrLogger = log.New(
-		errFile,
-		"ERROR: ",
-		log.Lmicroseconds|log.Lshortfile)
+// InitializeLogger - Writes the logs of replica id to files in outFolder and errFolder (in logfmt)
+func InitializeLogger(outFolder string, errFolder string, id int) {
+	sink, err := FileSink(outFolder, errFolder, id, FormatLogfmt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	SetSink(sink)
 }
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Format - How a WriterSink encodes the records
+type Format int
+
+// Formats of the records
+const (
+	FormatLogfmt Format = iota // time=... level=info module=bc msg=decide replica=0 bcid=3
+	FormatJSON                 // {"time":"...","level":"info","module":"bc","msg":"decide","replica":0,"bcid":3}
+)
+
+// ParseFormat - The format with the given name (logfmt or json)
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "logfmt":
+		return FormatLogfmt, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatLogfmt, fmt.Errorf("unknown log format %q (logfmt or json)", name)
+}
+
+// timeFormat - The time of the records (with microseconds, as the logs had before)
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Sink - Where the records are written (safe for concurrent use)
+type Sink interface {
+	Write(r Record) error
+}
+
+// Discard - A sink that drops the records
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) Write(Record) error { return nil }
+
+// stderr - The standard error, as it is when a record is written
+type stderr struct{}
+
+func (stderr) Write(p []byte) (int, error) { return os.Stderr.Write(p) }
+
+/* -------------------------------- Writer -------------------------------- */
+
+// WriterSink - Writes the records to a writer, one per line
+type WriterSink struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	format Format
+	closer io.Closer // The file opened by OpenFileSink
+}
+
+// NewWriterSink - A sink that writes the records to w in the given format
+func NewWriterSink(w io.Writer, format Format) *WriterSink {
+	return &WriterSink{w: w, format: format}
+}
+
+// StderrSink - A sink that writes the records to the standard error
+func StderrSink(format Format) *WriterSink {
+	return NewWriterSink(stderr{}, format)
+}
+
+// OpenFileSink - A sink that appends the records to a file (created if it does not exist)
+func OpenFileSink(path string, format Format) (*WriterSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	sink := NewWriterSink(file, format)
+	sink.closer = file
+	return sink, nil
+}
+
+// Write - Encodes a record and writes it
+func (s *WriterSink) Write(r Record) error {
+	var line []byte
+	if s.format == FormatJSON {
+		line = encodeJSON(r)
+	} else {
+		line = encodeLogfmt(r)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+// Close - Closes the file of the sink, if it opened one
+func (s *WriterSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// encodeLogfmt - The record as a logfmt line
+func encodeLogfmt(r Record) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("time=" + r.Time.Format(timeFormat))
+	b.WriteString(" level=" + r.Level.String())
+	b.WriteString(" module=" + logfmtValue(r.Module))
+	b.WriteString(" msg=" + logfmtValue(r.Msg))
+	for _, f := range r.Fields {
+		b.WriteString(" " + logfmtKey(f.Key) + "=" + logfmtValue(text(f.Value)))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// logfmtKey - A key without the characters that logfmt does not allow in it
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue - A value, quoted if it is empty or has spaces, quotes or equal signs
+func logfmtValue(value string) string {
+	if value == "" || strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// encodeJSON - The record as a JSON object in one line
+func encodeJSON(r Record) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString(`{"time":` + jsonValue(r.Time.Format(timeFormat)))
+	b.WriteString(`,"level":` + jsonValue(r.Level.String()))
+	b.WriteString(`,"module":` + jsonValue(r.Module))
+	b.WriteString(`,"msg":` + jsonValue(r.Msg))
+	for _, f := range r.Fields {
+		b.WriteString("," + jsonValue(f.Key) + ":" + jsonValue(f.Value))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// jsonValue - A number, a boolean or a string as it is, any other value as its text
+func jsonValue(value interface{}) string {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool, string:
+	default:
+		value = text(value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(text(value))
+	}
+	return string(data)
+}
+
+// text - The text of a value
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+/* -------------------------------- Memory -------------------------------- */
+
+// MemorySink - Keeps the records in memory (for the tests)
+type MemorySink struct {
+	mutex   sync.Mutex
+	records []Record
+}
+
+// NewMemorySink - An empty memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write - Keeps a record
+func (s *MemorySink) Write(r Record) error {
+	s.mutex.Lock()
+	s.records = append(s.records, r)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Records - The records written so far
+func (s *MemorySink) Records() []Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Record(nil), s.records...)
+}
+
+/* -------------------------------- Combinations -------------------------------- */
+
+// Tee - A sink that writes the records to every sink (and returns the first error)
+func Tee(sinks ...Sink) Sink {
+	return tee(sinks)
+}
+
+type tee []Sink
+
+func (t tee) Write(r Record) error {
+	var first error
+	for _, s := range t {
+		if err := s.Write(r); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// AtLeast - A sink that only writes the records of level or above to sink
+func AtLeast(level Level, sink Sink) Sink {
+	return atLeast{level: level, sink: sink}
+}
+
+type atLeast struct {
+	level Level
+	sink  Sink
+}
+
+func (a atLeast) Write(r Record) error {
+	if r.Level < a.level {
+		return nil
+	}
+	return a.sink.Write(r)
+}
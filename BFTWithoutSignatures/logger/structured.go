@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Every module logs through its own Logger (Module), whose records carry the
+	name of the module and the fields of its context (e.g. the replica, the
+	instance, the round, the type of a message or the peer). The records below
+	the level of their module are dropped before they are built, so a module
+	can log every message at LevelDebug and cost nothing at LevelInfo. The
+	records go to a Sink: a writer (stderr or a file) in logfmt or JSON, or the
+	memory for the tests. The levels are set per module with SetLevels, e.g.
+	"info,messenger=warn,bc=debug".
+*/
+
+// Level - The severity of a record
+type Level int32
+
+// Levels of the records (LevelOff only as the level of a module, to drop all its records)
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelOff
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelOff {
+		return "level(" + fmt.Sprint(int32(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel - The level with the given name
+func ParseLevel(name string) (Level, error) {
+	for k, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Level(k), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q (debug, info, warn, error or off)", name)
+}
+
+// Field - A key and its value in a record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Record - What a Logger writes to the sink
+type Record struct {
+	Time   time.Time
+	Level  Level
+	Module string
+	Msg    string
+	Fields []Field
+}
+
+// Get - The value of the field key of the record
+func (r Record) Get(key string) (interface{}, bool) {
+	for _, f := range r.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// settings - Where the records go and the level of every module (replaced as a whole)
+type settings struct {
+	sink   Sink
+	level  Level            // Of the modules without their own level
+	levels map[string]Level // module -> level
+}
+
+var (
+	current      atomic.Value // *settings
+	settingMutex sync.Mutex   // Serializes the updates of current
+)
+
+func init() {
+	current.Store(&settings{sink: NewWriterSink(stderr{}, FormatLogfmt), level: LevelInfo,
+		levels: make(map[string]Level)})
+}
+
+func load() *settings {
+	return current.Load().(*settings)
+}
+
+// update - Replaces the settings with a modified copy
+func update(modify func(s *settings)) {
+	settingMutex.Lock()
+	defer settingMutex.Unlock()
+
+	old := load()
+	s := &settings{sink: old.sink, level: old.level, levels: make(map[string]Level, len(old.levels))}
+	for m, l := range old.levels {
+		s.levels[m] = l
+	}
+	modify(s)
+	current.Store(s)
+}
+
+// SetSink - Sends the records of every module to sink
+func SetSink(sink Sink) {
+	update(func(s *settings) { s.sink = sink })
+}
+
+// SetLevel - Sets the level of a module ("" for the modules without their own level)
+func SetLevel(module string, level Level) {
+	update(func(s *settings) {
+		if module == "" {
+			s.level = level
+		} else {
+			s.levels[module] = level
+		}
+	})
+}
+
+// SetLevels - Sets the levels from a comma separated list of levels, each one for a module
+// (module=level) or for the others (level), e.g. "info,messenger=warn,bc=debug"
+func SetLevels(spec string) error {
+	levels := make(map[string]Level)
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		module, name := "", part
+		if k := strings.Index(part, "="); k >= 0 {
+			module, name = strings.TrimSpace(part[:k]), part[k+1:]
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		levels[module] = level
+	}
+
+	for module, level := range levels {
+		SetLevel(module, level)
+	}
+	return nil
+}
+
+// Logger - Writes the records of a module, with the fields of its context
+type Logger struct {
+	module string
+	fields []Field
+}
+
+// Module - The logger of a module
+func Module(name string) *Logger {
+	return &Logger{module: name}
+}
+
+// With - A logger whose records also carry the given fields (keys and values in turn)
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]Field, len(l.fields), len(l.fields)+len(keyvals)/2)
+	copy(fields, l.fields)
+	return &Logger{module: l.module, fields: appendFields(fields, keyvals)}
+}
+
+// Enabled - Whether the records of the level are written (to skip building costly fields)
+func (l *Logger) Enabled(level Level) bool {
+	s := load()
+	threshold, in := s.levels[l.module]
+	if !in {
+		threshold = s.level
+	}
+	return level >= threshold && threshold != LevelOff
+}
+
+// Debug - Writes a record of LevelDebug, with the given fields (keys and values in turn)
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info - Writes a record of LevelInfo, with the given fields (keys and values in turn)
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Warn - Writes a record of LevelWarn, with the given fields (keys and values in turn)
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+// Error - Writes a record of LevelError, with the given fields (keys and values in turn)
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	fields := make([]Field, len(l.fields), len(l.fields)+len(keyvals)/2)
+	copy(fields, l.fields)
+	record := Record{Time: time.Now(), Level: level, Module: l.module, Msg: msg,
+		Fields: appendFields(fields, keyvals)}
+
+	if err := load().sink.Write(record); err != nil {
+		fmt.Fprintln(stderr{}, "logger:", err)
+	}
+}
+
+// appendFields - Appends the keys and values in turn (a key without a value gets "MISSING")
+func appendFields(fields []Field, keyvals []interface{}) []Field {
+	for k := 0; k < len(keyvals); k += 2 {
+		key := fmt.Sprint(keyvals[k])
+		var value interface{} = "MISSING"
+		if k+1 < len(keyvals) {
+			value = keyvals[k+1]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	return fields
+}
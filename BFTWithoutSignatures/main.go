@@ -6,11 +6,14 @@ import (
 	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/modules"
 	"BFTWithoutSignatures/threshenc"
-	"BFTWithoutSignatures/variables"
+	"BFTWithoutSignatures/wal"
+	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"fmt"
 	"syscall"
 	"time"
@@ -18,14 +21,49 @@ import (
 
 var terminate chan os.Signal
 var folderName string
+var node *modules.Node
+
+// Flags of the logs
+var (
+	logTarget = flag.String("log", "file",
+		"Where the logs go: file (the out and error files in the logger folder), stderr or the path of a file")
+	logFormat = flag.String("log-format", "logfmt", "The format of the logs: logfmt or json")
+	logLevel  = flag.String("log-level", "info",
+		"The levels of the logs, for all the modules or per module (e.g. 'info,messenger=warn,bc=debug')")
+)
+
+// initializeLogs - Sends the logs of replica id where the flags say, in their format and levels
+func initializeLogs(outFolder string, errFolder string, id int) {
+	format, err := logger.ParseFormat(*logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := logger.SetLevels(*logLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	var sink logger.Sink
+	switch *logTarget {
+	case "file":
+		sink, err = logger.FileSink(outFolder, errFolder, id, format)
+	case "stderr":
+		sink = logger.StderrSink(format)
+	default:
+		sink, err = logger.OpenFileSink(*logTarget, format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.SetSink(sink)
+}
 
 // Initializer - Method that initializes all required processes
-func initializer(id int, n int, clients int, scenario int, rem int,
-								transientProb float64, runSSABC bool) {
+func initializer(id int, cluster *config.Cluster) {
 
-	variables.Initialize(id, n, clients, rem)
-	variables.SetABCAlgorithm(runSSABC)
-	config.InitializeScenario(scenario, transientProb)
+	replica := cluster.Replica(id)
+	options := cluster.Options()
+	runSSABC := cluster.RunSSABC()
+	n, clients := replica.N, replica.Clients
 
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
@@ -39,7 +77,7 @@ func initializer(id int, n int, clients int, scenario int, rem int,
 		} else {
 			folderName += "ABC"
 		}
-		folderName += "-S:" + strconv.Itoa(n) + "-C:" + strconv.Itoa(clients) + "-Sc:" + config.Scenario + "/"
+		folderName += "-S:" + strconv.Itoa(n) + "-C:" + strconv.Itoa(clients) + "-Sc:" + options.Scenario + "/"
 	}
 	logger_dir += folderName
 
@@ -56,47 +94,113 @@ func initializer(id int, n int, clients int, scenario int, rem int,
 			log.Fatal(err)
 		}
 	}
+	if _, err := os.Stat(logger_dir+"wal/"); os.IsNotExist(err) {
+		err := os.MkdirAll(logger_dir+"wal/", 0770)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	logger.InitializeLogger(logger_dir+"logs/out/", logger_dir+"logs/error/")
+	initializeLogs(logger_dir+"logs/out/", logger_dir+"logs/error/", replica.ID)
 
-	if variables.Remote {
-		config.InitializeIP()
-	} else {
-		config.InitializeLocal()
-	}
+	addresses := cluster.Addresses(replica.ID)
 
 	var s string
-	s = fmt.Sprint("ID:", variables.ID, " | N:", variables.N, " | F:",
-		variables.F, " | Clients:", variables.Clients, " | Scenario:", config.Scenario,
-		" | Remote:", variables.Remote, " | Algorithm:")
+	s = fmt.Sprint("ID:", replica.ID, " | N:", replica.N, " | F:",
+		replica.F, " | Clients:", replica.Clients, " | Scenario:", options.Scenario,
+		" | Remote:", replica.Remote, " | Auth:", options.Auth, " | Batch:", options.BatchRequests, "/",
+		options.BatchBytes, "B/", options.BatchLinger, " | Algorithm:")
 
 	if runSSABC {
-		s = fmt.Sprint(s, "Self Stabilized Atomic Broadcast | Transient:", config.Transient,
-		" | TransientProbability:", config.TransientProbability, "\n\n")
+		s = fmt.Sprint(s, "Self Stabilized Atomic Broadcast | Transient:", options.Transient,
+		" | TransientProbability:", options.TransientProbability, "\n\n")
 	} else {
 		s = fmt.Sprint(s, "Atomic Broadcast\n\n")
 	}
 	logger.OutLogger.Print(s)
 
-	threshenc.ReadKeys(user_dirname+"/go/src/BFTWithoutSignatures/threshenc/keys/")
+	keys := threshenc.ReadClusterKeys(cluster.Path(cluster.Keys), replica.ID, cluster.PublicKeys())
+	keys.Coin, err = threshenc.LoadCoinKeys(cluster.CoinKeysFolder()+"/", replica.ID, replica.N)
+	if err != nil {
+		logger.ErrLogger.Fatalf("cannot read the coin keys of replica %d from %s (deal them with "+
+			"\"BFTWithoutSignatures generate_coin_keys %d <Folder>\" and set coin_keys in the cluster file): %v",
+			replica.ID, cluster.CoinKeysFolder(), replica.N, err)
+	}
+	members, err := cluster.Members()
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	msgr := messenger.NewMessenger(replica, options.Scenario, keys)
+	msgr.Auth = options.Auth
+	msgr.InitializeMessenger(addresses)
+	msgr.Subscribe()
 
+	node = modules.NewNode(replica, options, runSSABC, msgr)
+	node.SetMembers(members)
 
-	messenger.InitializeMessenger()
-	messenger.Subscribe()
+	if port := cluster.Replicas[replica.ID].MetricsPort; port > 0 {
+		_, err := msgr.Metrics.Registry.Listen(":" + strconv.Itoa(port))
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+		logger.OutLogger.Println("Metrics on port", port)
+	}
+
+	// Rebuild the state from the delivered batches before rejoining
+	walLog, err := wal.Open(logger_dir + "wal/wal_" + strconv.Itoa(replica.ID) + ".log")
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	err = node.Recover(walLog)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
 
-	if (config.Scenario == "IDLE") && (variables.Byzantine) {
-		logger.ErrLogger.Println(config.Scenario)
+	if (options.Scenario == "IDLE") && (replica.Byzantine) {
+		logger.ErrLogger.Println(options.Scenario)
 		return
 	}
 
-	messenger.TransmitMessages()
+	msgr.TransmitMessages()
+
+	// Get the state that the others delivered while this replica was down
+	node.StateTransfer()
+	err = node.CatchUp(5 * time.Second)
+	if err != nil && !errors.Is(err, modules.ErrNoMatchingStates) { // Without f+1 of them, it starts as it is
+		logger.ErrLogger.Fatal(err)
+	}
+
 	if runSSABC {
-		modules.InitiateSelfStabilizedAtomicBroadcast()
+		node.InitiateSelfStabilizedAtomicBroadcast()
 	} else {
-		modules.InitiateAtomicBroadcast()
+		node.InitiateAtomicBroadcast()
 	}
 	time.Sleep(2 * time.Second) // Wait 2s before start accepting requests to initiate all maps
-	modules.RequestHandler()
+	node.RequestHandler()
+}
+
+// reconfiguration - Proposes the change of the membership in the cluster file every time it is
+// reloaded (SIGUSR1)
+func reconfiguration(file string) {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGUSR1)
+	go func() {
+		for range reload {
+			cluster, err := config.LoadCluster(file)
+			if err != nil {
+				logger.ErrLogger.Println("RECONFIGURATION:", err)
+				continue
+			}
+			r, err := cluster.Reconfiguration(node.Members())
+			if err == nil {
+				err = node.Propose(r)
+			}
+			if err != nil {
+				logger.ErrLogger.Println("RECONFIGURATION:", err)
+			}
+		}
+	}()
 }
 
 func cleanup() {
@@ -109,34 +213,38 @@ func cleanup() {
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			if (config.Scenario == "IDLE") && (variables.Byzantine) {
+			if (node.Scenario == "IDLE") && (node.Byzantine) {
 				logger.OutLogger.Printf("\n\nMessage Complexity: 0.00 msgs\nMessage Size: 0.000 MB\n\n")
 			} else {
 				logger.OutLogger.Printf(
 					"\n\nMessage Complexity: %.2f msgs\nMessage Size: %.3f MB\n\n",
-					float64(variables.MsgComplexity/modules.Aid),
-					float64(float64(variables.MsgSize/int64(modules.Aid))/1048576)) // OR 1000000
+					float64(node.Messenger.MsgComplexity/node.Aid),
+					float64(float64(node.Messenger.MsgSize/int64(node.Aid))/1048576)) // OR 1000000
 			}
 
-			for i := 0; i < variables.N; i++ {
-				if i == variables.ID {
-					continue // Not myself
-				}
-				messenger.ReceiveSockets[i].Close()
-				messenger.SendSockets[i].Close()
-			}
-
-			for i := 0; i < variables.Clients; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
+			node.Messenger.Close()
+			node.WAL.Close()
 			os.Exit(0)
 		}
 	}()
+
+	// A replica that is removed from the membership stops
+	go func() {
+		<-node.Retired
+		logger.OutLogger.Println("Removed from the cluster")
+		terminate <- syscall.SIGTERM
+	}()
 }
 
 func main() {
-	args := os.Args[1:]
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: BFTWithoutSignatures [flags] <Cluster_File> <ID> [<Folder>]\n"+
+			"       BFTWithoutSignatures generate_keys <N>\n"+
+			"       BFTWithoutSignatures generate_coin_keys <N> <Folder>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
 	if len(args) == 2 && string(args[0]) == "generate_keys" {
 		N, _ := strconv.Atoi(args[1])
 		user_dirname, err := os.UserHomeDir()
@@ -145,27 +253,37 @@ func main() {
 		}
 		threshenc.GenerateKeys(N, user_dirname+"/go/src/BFTWithoutSignatures/threshenc/keys/")
 
-	} else if len(args) == 7 || len(args) == 8 {
-		id, _ := strconv.Atoi(args[0])
-		n, _ := strconv.Atoi(args[1])
-		clients, _ := strconv.Atoi(args[2])
-		scenario, _ := strconv.Atoi(args[3])
-		remote, _ := strconv.Atoi(args[4])
-		transientProb, _ := strconv.ParseFloat(args[5], 64)
-		runSSABC, _ := strconv.ParseBool(args[6])
-		if !runSSABC {transientProb=-1}
-
-		if len(args) == 8 {
-			folderName = args[7]
+	} else if len(args) == 3 && string(args[0]) == "generate_coin_keys" {
+		N, _ := strconv.Atoi(args[1])
+		err := os.MkdirAll(args[2], 0770)
+		if err != nil {
+			log.Fatal(err)
+		}
+		threshenc.GenerateCoinKeys(N, strings.TrimSuffix(args[2], "/")+"/")
+
+	} else if len(args) == 2 || len(args) == 3 {
+		cluster, err := config.LoadCluster(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id < 0 || id >= len(cluster.Replicas) {
+			log.Fatalf("Replica ID %q is not in the cluster file (0 to %d)", args[1], len(cluster.Replicas)-1)
+		}
+
+		if len(args) == 3 {
+			folderName = args[2]
 		}
 
-		initializer(id, n, clients, scenario, remote, transientProb, runSSABC)
+		initializer(id, cluster)
 		cleanup()
+		reconfiguration(args[0])
 
 		done := make(chan interface{}) // To keep the server running
 		<-done
 
 	} else {
-		log.Fatal("Arguments should be '<ID> <N> <Clients> <Scenario> <Remote> <Transient_Probability> <Run_SSABC?>'")
+		flag.Usage()
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,195 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/threshenc"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+/*
+	With config.AuthRSA every message is signed by its sender and verified by
+	its receivers. With config.AuthHMAC the messages are not signed: when a link
+	is set up, both servers send a Hello with an ephemeral ECDH key, signed once
+	with their RSA key, and every frame of the link carries an HMAC with the
+	session key of its direction. A restarted server sends a new Hello, and the
+	other one derives new session keys from it: a Hello carries the incarnation
+	of its sender, and only a later incarnation replaces the session keys (an
+	earlier Hello that is replayed cannot). The messages that RB delivers
+	are attributed to the process that broadcasted them, as RB only delivers
+	values that f+1 correct servers received over authenticated links.
+*/
+
+// ErrInvalidMAC - Returned for frames whose HMAC does not match the session key of their link
+var ErrInvalidMAC = errors.New("invalid MAC")
+
+// ErrStaleHello - Returned for Hellos that are not from a later incarnation than the session keys of their link
+var ErrStaleHello = errors.New("stale hello")
+
+// Hello - The ephemeral public key that a server sends when a link is set up (signed with its RSA key)
+type Hello struct {
+	Incarnation uint64 // Of the sender
+	Public      []byte
+	Signature   []byte
+}
+
+// session - The session keys of a link in HMAC mode (guarded by the mutex of the link)
+type session struct {
+	private *ecdh.PrivateKey
+	hello   Hello
+
+	peerPublic      []byte // The key of the last Hello of the other server (nil until it arrives)
+	peerIncarnation uint64 // The incarnation of the last Hello of the other server
+	sendKey         []byte
+	recvKey         []byte
+
+	confirmed bool // The other server sent a valid MAC (it has our Hello)
+}
+
+// helloMessage - What the signature of a Hello covers (the key is only valid on the link from -> to, for
+// the given incarnation of from)
+func helloMessage(from int, to int, incarnation uint64, public []byte) []byte {
+	return append([]byte("HELLO|"+strconv.Itoa(from)+"|"+strconv.Itoa(to)+"|"+
+		strconv.FormatUint(incarnation, 10)+"|"), public...)
+}
+
+// newSession - Generates the ephemeral key of the link to server peer and signs its Hello
+func (msgr *Messenger) newSession(peer int, keys *threshenc.Keys) *session {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	public := private.PublicKey().Bytes()
+	return &session{
+		private: private,
+		hello: Hello{
+			Incarnation: msgr.incarnation,
+			Public:      public,
+			Signature:   keys.SignMessage(helloMessage(msgr.ID, peer, msgr.incarnation, public)),
+		},
+	}
+}
+
+// acceptHello - Derives the session keys from the Hello of the server of the link, unless the keys are
+// from the same or a later incarnation of it
+func (msgr *Messenger) acceptHello(l *link, hello *Hello) error {
+	message := helloMessage(l.peer, msgr.ID, hello.Incarnation, hello.Public)
+	if !msgr.CurrentKeys().VerifyMessage(message, hello.Signature, l.peer) {
+		return ErrInvalidSignature
+	}
+	public, err := ecdh.P256().NewPublicKey(hello.Public)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	s := l.session
+	if hello.Incarnation == s.peerIncarnation && bytes.Equal(s.peerPublic, hello.Public) {
+		return nil // Sent again until it is confirmed
+	}
+	if s.peerPublic != nil && hello.Incarnation <= s.peerIncarnation {
+		return ErrStaleHello // Replayed
+	}
+	shared, err := s.private.ECDH(public)
+	if err != nil {
+		return err
+	}
+	s.peerPublic, s.peerIncarnation = hello.Public, hello.Incarnation
+	s.sendKey = deriveKey(shared, msgr.ID, l.peer)
+	s.recvKey = deriveKey(shared, l.peer, msgr.ID)
+	s.confirmed = false
+	msgr.log.Info("session established", "peer", l.peer)
+	return nil
+}
+
+// deriveKey - The session key of the direction from -> to
+func deriveKey(shared []byte, from int, to int) []byte {
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte("BFT link " + strconv.Itoa(from) + "->" + strconv.Itoa(to)))
+	return mac.Sum(nil)
+}
+
+// frameMAC - The HMAC of the sequence number, the acknowledgement, the incarnations and the payload of
+// a frame
+func frameMAC(key []byte, frame Frame) []byte {
+	header := make([]byte, 32)
+	binary.BigEndian.PutUint64(header[:8], frame.Seq)
+	binary.BigEndian.PutUint64(header[8:16], frame.Ack)
+	binary.BigEndian.PutUint64(header[16:24], frame.Incarnation)
+	binary.BigEndian.PutUint64(header[24:], frame.Peer)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	mac.Write(frame.Payload)
+	return mac.Sum(nil)
+}
+
+// ready - Whether the link can send messages (in HMAC mode, once the session keys are derived)
+func (l *link) ready() bool {
+	if l.session == nil {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.session.peerPublic != nil
+}
+
+// greeting - The Hello to send, until the other server shows that it has received it
+func (l *link) greeting() (*Hello, bool) {
+	if l.session == nil {
+		return nil, false
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return &l.session.hello, !l.session.confirmed
+}
+
+// seal - Adds the HMAC to a frame, and returns false if the session keys are not derived yet
+func (l *link) seal(frame *Frame) bool {
+	if l.session == nil {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.session.sendKey == nil {
+		return false
+	}
+	frame.MAC = frameMAC(l.session.sendKey, *frame)
+	return true
+}
+
+// open - Checks the HMAC of a received frame. A frame that arrives before the session keys is
+// dropped without an error (it is retransmitted once the Hello of its sender arrives).
+func (l *link) open(frame Frame) (bool, error) {
+	if l.session == nil {
+		return true, nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.session.recvKey == nil {
+		return false, nil
+	}
+	if !hmac.Equal(frame.MAC, frameMAC(l.session.recvKey, frame)) {
+		return false, ErrInvalidMAC
+	}
+	l.session.confirmed = true
+	return true, nil
+}
+
+// authenticated - Whether the messages are authenticated by their links instead of their signatures
+func (msgr *Messenger) authenticated() bool {
+	return msgr.Auth == config.AuthHMAC
+}
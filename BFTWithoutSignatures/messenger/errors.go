@@ -0,0 +1,80 @@
+package messenger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Delays between the attempts to reach a server (or rebuild a socket) after a link error
+const (
+	reconnectDelay    = 100 * time.Millisecond
+	maxReconnectDelay = 5 * time.Second
+)
+
+// ErrInvalidSignature - Returned for messages whose signature does not match their sender
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// errInvalidInstance - Returned for messages that refer to an instance or a process that cannot exist
+var errInvalidInstance = errors.New("invalid instance")
+
+// MalformedError - A message of another server that could not be decoded or is invalid. It is
+// dropped and attributed to its sender (From is -1 if the sender could not be authenticated).
+type MalformedError struct {
+	From int
+	Type string
+	Err  error
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("malformed %s message from %d: %v", e.Type, e.From, e.Err)
+}
+
+// malformed - Wraps the error of a message of the given type from server from
+func malformed(from int, Type string, err error) error {
+	return &MalformedError{From: from, Type: Type, Err: err}
+}
+
+// ReportMalformed - Logs and counts a message that HandleMessage rejected. It is attributed to the
+// authenticated sender if there is one, else to link (the server it was received from).
+func (msgr *Messenger) ReportMalformed(link int, err error) {
+	from := link
+	if m, ok := err.(*MalformedError); ok && m.From >= 0 {
+		from = m.From
+	}
+	msgr.log.Warn("dropped malformed message", "peer", link, "from", from, "err", err)
+
+	msgr.MsgMutex.Lock()
+	msgr.Malformed[from]++
+	msgr.MsgMutex.Unlock()
+
+	msgr.Metrics.Malformed.Inc(strconv.Itoa(from))
+	if m, ok := err.(*MalformedError); ok && m.Err == ErrInvalidSignature {
+		msgr.Metrics.VerificationFailures.Inc(strconv.Itoa(link), "signature")
+	} else if ok && m.Err == ErrInvalidMAC {
+		msgr.Metrics.VerificationFailures.Inc(strconv.Itoa(link), "mac")
+	}
+}
+
+// MalformedCount - The number of malformed messages that have been attributed to server from
+func (msgr *Messenger) MalformedCount(from int) int {
+	msgr.MsgMutex.RLock()
+	defer msgr.MsgMutex.RUnlock()
+
+	return msgr.Malformed[from]
+}
+
+// isServer - Whether id is the identifier of a server
+func (msgr *Messenger) isServer(id int) bool {
+	return id >= 0 && id < msgr.n()
+}
+
+// nextDelay - Doubles the delay before the next reconnection attempt (up to maxReconnectDelay)
+func nextDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxReconnectDelay {
+		return maxReconnectDelay
+	}
+	return delay
+}
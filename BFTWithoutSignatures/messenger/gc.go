@@ -0,0 +1,204 @@
+package messenger
+
+import (
+	"math"
+	"math/bits"
+)
+
+/*
+	Garbage collection of the per-instance channels. The identifiers of the
+	instances are derived from the instance of VC (or SSVC) that ABC (or SSABC)
+	runs for a delivery, by Cantor's pairing (modules.ComputeUniqueIdentifier),
+	so the VC instance, the root, can always be recovered from them. When a
+	checkpoint becomes stable, every instance up to its root is collected:
+	its channels are removed, the goroutines that wait on it are released and
+	late messages for it are dropped.
+*/
+
+// Depths of the identifiers, i.e. how many times they have been paired starting from their root
+const (
+	DepthVC  = 0 // VC, SSVC (and the SSMVC and SSBC that it runs) and RB for VC
+	DepthMVC = 1 // MVC (and the BC that it runs)
+	DepthBC  = 2 // BC rounds, common coin and RB for MVC
+)
+
+// closedChannel - Returned as the done channel of every collected instance
+var closedChannel = make(chan struct{})
+
+func init() {
+	close(closedChannel)
+}
+
+// RootInstance - The instance of VC (or SSVC) from which an identifier of the given depth was derived
+func RootInstance(id int, depth int) int {
+	for ; depth > 0; depth-- {
+		id, _ = unpair(id)
+	}
+	return id
+}
+
+// IsCollected - Whether the instances of the given root have been collected
+func (msgr *Messenger) IsCollected(root int) bool {
+	msgr.GCMutex.RLock()
+	defer msgr.GCMutex.RUnlock()
+
+	return root <= msgr.collected
+}
+
+// Done - Returns a channel that is closed when the instances of the given root are collected
+func (msgr *Messenger) Done(root int) <-chan struct{} {
+	msgr.GCMutex.Lock()
+	defer msgr.GCMutex.Unlock()
+
+	if root <= msgr.collected {
+		return closedChannel
+	}
+	if _, in := msgr.done[root]; !in {
+		msgr.done[root] = make(chan struct{})
+	}
+	return msgr.done[root]
+}
+
+// Collect - Removes the channels of every instance up to root and releases whoever waits on them
+func (msgr *Messenger) Collect(root int) {
+	msgr.GCMutex.Lock()
+	if root <= msgr.collected {
+		msgr.GCMutex.Unlock()
+		return
+	}
+	msgr.collected = root
+	for r, done := range msgr.done {
+		if r <= root {
+			close(done)
+			delete(msgr.done, r)
+		}
+	}
+	msgr.GCMutex.Unlock()
+
+	// Messages that arrive from now on are dropped (checked under the lock of each map)
+	msgr.BvbMutex.Lock()
+	for id := range msgr.BvbChannel {
+		if RootInstance(id, DepthBC) <= root {
+			delete(msgr.BvbChannel, id)
+		}
+	}
+	msgr.BvbMutex.Unlock()
+
+	msgr.BcMutex.Lock()
+	for id := range msgr.BcChannel {
+		if RootInstance(id, DepthBC) <= root {
+			delete(msgr.BcChannel, id)
+		}
+	}
+	msgr.BcMutex.Unlock()
+
+	msgr.CoinMutex.Lock()
+	for id := range msgr.CoinChannel {
+		if RootInstance(id, DepthBC) <= root {
+			delete(msgr.CoinChannel, id)
+		}
+	}
+	msgr.CoinMutex.Unlock()
+
+	msgr.RbMutex.Lock()
+	for rbType, channels := range msgr.RbChannel {
+		for id := range channels {
+			if RootInstance(id, rbDepth(rbType)) <= root {
+				delete(channels, id)
+			}
+		}
+	}
+	msgr.RbMutex.Unlock()
+
+	msgr.MvcMutex.Lock()
+	for id := range msgr.MvcChannel {
+		if RootInstance(id, DepthMVC) <= root {
+			delete(msgr.MvcChannel, id)
+		}
+	}
+	msgr.MvcMutex.Unlock()
+
+	msgr.VcMutex.Lock()
+	for id := range msgr.VcChannel {
+		if id <= root {
+			delete(msgr.VcChannel, id)
+		}
+	}
+	msgr.VcMutex.Unlock()
+
+	msgr.SSVCMutex.Lock()
+	for id := range msgr.SSVCChannel {
+		if id <= root {
+			delete(msgr.SSVCChannel, id)
+		}
+	}
+	msgr.SSVCMutex.Unlock()
+
+	msgr.SSVCDecisionsMutex.Lock()
+	for id := range msgr.SSVCDecisionsChannel {
+		if id <= root {
+			delete(msgr.SSVCDecisionsChannel, id)
+		}
+	}
+	msgr.SSVCDecisionsMutex.Unlock()
+
+	msgr.SSMVCMutex.Lock()
+	for id := range msgr.SSMVCChannel {
+		if id <= root {
+			delete(msgr.SSMVCChannel, id)
+		}
+	}
+	msgr.SSMVCMutex.Unlock()
+
+	msgr.SSBCMutex.Lock()
+	for id := range msgr.SSBCChannel {
+		if id <= root {
+			delete(msgr.SSBCChannel, id)
+		}
+	}
+	msgr.SSBCMutex.Unlock()
+}
+
+// rbDepth - The depth of the identifiers of RB for the given type of values
+func rbDepth(rbType string) int {
+	if rbType == "MVC" {
+		return DepthBC
+	}
+	return DepthVC
+}
+
+// unpair - The inverse of Cantor's pairing: z = ((a+b)(a+b+1))/2 + a, for every z >= 0 (the
+// identifiers come from the peers, so nothing may overflow)
+func unpair(z int) (int, int) {
+	u := uint64(z)
+	w := isqrt(2 * u) // w = a+b or a+b+1
+	for w > 0 && triangle(w) > u {
+		w--
+	}
+	for triangle(w+1) <= u {
+		w++
+	}
+	a := u - triangle(w)
+	return int(a), int(w - a)
+}
+
+// isqrt - The integer square root of n
+func isqrt(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n))) // Within one of the root
+	for r > 0 && r > n/r {
+		r--
+	}
+	for r+1 <= n/(r+1) {
+		r++
+	}
+	return r
+}
+
+// triangle - w(w+1)/2, or the largest uint64 if it does not fit
+func triangle(w uint64) uint64 {
+	hi, lo := bits.Mul64(w, w+1)
+	if hi > 1 {
+		return math.MaxUint64
+	}
+	return hi<<63 | lo>>1
+}
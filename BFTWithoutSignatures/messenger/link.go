@@ -0,0 +1,440 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	The links between the servers are reliable on top of a Transport that may
+	lose, duplicate or reorder messages. Every message gets a sequence number
+	and stays in the window of its link until the other server acknowledges
+	it, and it is retransmitted (with exponential backoff) until then. The
+	acknowledgements are cumulative and piggybacked on the messages sent in
+	the opposite direction. Messages wait in a bounded queue per server: when
+	it is full, Broadcast and SendMessage report the backpressure instead of
+	blocking the caller on one slow or silent server; BroadcastWait and
+	SendMessageWait first wait for room for a bounded time, for the messages
+	that the protocols send only once (the self-stabilizing modules send
+	their state again anyway).
+	The sequence numbers of a link start again when a server restarts: every
+	messenger has an incarnation (the time it was created), which its frames
+	carry along with the incarnation of the receiver that their sequence
+	number and acknowledgement refer to. A frame of a later incarnation of
+	the other server resets the messages received from it and numbers the
+	messages that it has not acknowledged from 1 again; a frame of an earlier
+	incarnation is dropped, and so is a frame numbered for an earlier
+	incarnation of this server (its acknowledgement tells the sender ours).
+*/
+
+// DefaultQueueSize - The default number of messages that can wait to be sent to a server
+const DefaultQueueSize = 4096
+
+// DefaultSendTimeout - How long BroadcastWait and SendMessageWait wait for room in a full queue
+const DefaultSendTimeout = 10 * time.Second
+
+const (
+	window               = 1024 // Messages sent to a server that it has not acknowledged yet
+	retransmitTimeout    = 500 * time.Millisecond
+	maxRetransmitTimeout = 8 * time.Second
+	retransmitTick       = 100 * time.Millisecond
+)
+
+// ErrBackpressure - The queue of a server is full (it does not keep up or it does not acknowledge)
+var ErrBackpressure = errors.New("outbound queue is full")
+
+// BackpressureError - Returned when a message could not be queued for some servers
+type BackpressureError struct {
+	Type  string
+	Peers []int
+}
+
+func (e *BackpressureError) Error() string {
+	peers := make([]string, len(e.Peers))
+	for k, p := range e.Peers {
+		peers[k] = strconv.Itoa(p)
+	}
+	return ErrBackpressure.Error() + ": " + e.Type + " not sent to " + strings.Join(peers, ", ")
+}
+
+// Frame - What the links send through the Transport (Seq is 0 for a bare acknowledgement)
+type Frame struct {
+	Seq         uint64
+	Ack         uint64 // Every message of the other direction up to it has been received
+	Incarnation uint64 // Of the sender
+	Peer        uint64 // The incarnation of the receiver that Seq and Ack refer to (0 until the sender hears from it)
+	Payload     []byte // The encoded types.Message
+	Hello       *Hello // Only in HMAC mode, while the link is set up (see auth.go)
+	MAC         []byte // Only in HMAC mode
+}
+
+// pending - A message that has not been acknowledged yet
+type pending struct {
+	seq     uint64
+	payload []byte
+	sent    time.Time
+	timeout time.Duration
+}
+
+// link - The reliable link to another server
+type link struct {
+	peer  int
+	mutex sync.Mutex
+
+	nextSeq uint64
+	unacked []*pending // Ordered by seq
+
+	received uint64          // Every message up to it has been received
+	ahead    map[uint64]bool // Messages received after a gap
+
+	incarnation uint64 // Of the other server (0 until a frame arrives from it)
+
+	ackNeeded chan struct{} // Signals the sender that an acknowledgement is due
+	opened    chan struct{} // Signals the sender that the window is not full anymore
+
+	queue  chan types.Message // The messages that wait to be sent (also in MessageChannel)
+	closed chan struct{}      // Closed when the server leaves (see membership.go)
+
+	session *session // The session keys in HMAC mode (nil in RSA mode)
+}
+
+func newLink(peer int, queueSize int) *link {
+	return &link{
+		peer:      peer,
+		nextSeq:   1,
+		ahead:     make(map[uint64]bool),
+		ackNeeded: make(chan struct{}, 1),
+		opened:    make(chan struct{}, 1),
+		queue:     make(chan types.Message, queueSize),
+		closed:    make(chan struct{}),
+	}
+}
+
+// EncodeFrame - Encodes a frame to be sent through a Transport
+func EncodeFrame(frame Frame) ([]byte, error) {
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(frame)
+	return w.Bytes(), err
+}
+
+// DecodeFrame - Decodes a frame received from a Transport
+func DecodeFrame(data []byte) (Frame, error) {
+	var frame Frame
+	err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&frame)
+	return frame, err
+}
+
+// push - Assigns the next sequence number to a message and keeps it until it is acknowledged, and
+// returns its frame
+func (l *link) push(payload []byte) Frame {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	p := &pending{seq: l.nextSeq, payload: payload, sent: time.Now(), timeout: retransmitTimeout}
+	l.unacked = append(l.unacked, p)
+	l.nextSeq++
+	return Frame{Seq: p.seq, Ack: l.received, Peer: l.incarnation, Payload: payload}
+}
+
+// full - Whether the window of unacknowledged messages is full
+func (l *link) full() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.unacked) >= window
+}
+
+// expired - The frames of the messages whose acknowledgement did not arrive in time (their timeout is
+// doubled)
+func (l *link) expired(now time.Time) []Frame {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	resend := make([]Frame, 0)
+	for _, p := range l.unacked {
+		if now.Sub(p.sent) >= p.timeout {
+			p.sent = now
+			p.timeout *= 2
+			if p.timeout > maxRetransmitTimeout {
+				p.timeout = maxRetransmitTimeout
+			}
+			resend = append(resend, Frame{Seq: p.seq, Ack: l.received, Peer: l.incarnation, Payload: p.payload})
+		}
+	}
+	return resend
+}
+
+// acknowledge - Removes the messages up to ack from the window
+func (l *link) acknowledge(ack uint64) {
+	l.mutex.Lock()
+	k := 0
+	for k < len(l.unacked) && l.unacked[k].seq <= ack {
+		k++
+	}
+	l.unacked = l.unacked[k:]
+	l.mutex.Unlock()
+
+	if k > 0 {
+		signal(l.opened)
+	}
+}
+
+// receive - Records a received message and returns whether it is new (not a duplicate)
+func (l *link) receive(seq uint64) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	defer signal(l.ackNeeded) // Also for duplicates, as the acknowledgement may have been lost
+
+	if seq <= l.received || l.ahead[seq] || seq > l.received+window {
+		return false
+	}
+	l.ahead[seq] = true
+	for l.ahead[l.received+1] {
+		delete(l.ahead, l.received+1)
+		l.received++
+	}
+	return true
+}
+
+// ack - The frame of the cumulative acknowledgement to send to the other server
+func (l *link) ack() Frame {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return Frame{Ack: l.received, Peer: l.incarnation}
+}
+
+// meet - Records the incarnation of the other server that sent a frame, and returns false if it is an
+// earlier one. A later one starts the link again (the other server restarted): nothing was received
+// from it, and the messages that are not acknowledged are numbered from 1 and retransmitted at once.
+func (l *link) meet(incarnation uint64) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if incarnation < l.incarnation {
+		return false
+	}
+	if incarnation > l.incarnation {
+		if l.incarnation != 0 {
+			l.received = 0
+			l.ahead = make(map[uint64]bool)
+			for k, p := range l.unacked {
+				p.seq, p.sent, p.timeout = uint64(k+1), time.Time{}, retransmitTimeout
+			}
+			l.nextSeq = uint64(len(l.unacked) + 1)
+		}
+		l.incarnation = incarnation
+	}
+	return true
+}
+
+// signal - Notifies the goroutine that waits on c, without blocking
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// transmit - Sends the queued messages to the server of the link and retransmits the ones that are
+// not acknowledged [go started from TransmitMessages]
+func (msgr *Messenger) transmit(l *link) {
+	ticker := time.NewTicker(retransmitTick)
+	defer ticker.Stop()
+
+	if !msgr.greet(l) {
+		return
+	}
+	for {
+		queue := l.queue
+		if l.full() || !l.ready() {
+			queue = nil // New messages wait in the queue until the window opens
+		}
+
+		select {
+		case message := <-queue:
+			w := new(bytes.Buffer)
+			err := gob.NewEncoder(w).Encode(message)
+			if err != nil {
+				msgr.log.Error("dropped message", "type", message.Type, "peer", l.peer, "err", err)
+				continue
+			}
+			frame := l.push(w.Bytes())
+			if !msgr.sendFrame(l, frame) {
+				return
+			}
+			if msgr.log.Enabled(logger.LevelDebug) {
+				msgr.log.Debug("sent", "type", message.Type, "peer", l.peer, "seq", frame.Seq)
+			}
+			msgr.MsgMutex.Lock()
+			msgr.MsgComplexity++
+			msgr.MsgSize += int64(len(w.Bytes()))
+			msgr.MsgMutex.Unlock()
+			msgr.Metrics.sent(message.Type, strconv.Itoa(l.peer), len(w.Bytes()))
+
+		case <-l.ackNeeded:
+			if !msgr.greet(l) || !msgr.sendFrame(l, l.ack()) {
+				return
+			}
+
+		case <-ticker.C:
+			if !msgr.greet(l) {
+				return
+			}
+			resend := l.expired(time.Now())
+			if len(resend) > 0 {
+				msgr.MsgMutex.Lock()
+				msgr.Retransmissions += len(resend)
+				msgr.MsgMutex.Unlock()
+				msgr.Metrics.Retransmissions.Add(float64(len(resend)), strconv.Itoa(l.peer))
+			}
+			for _, frame := range resend {
+				if !msgr.sendFrame(l, frame) {
+					return
+				}
+			}
+
+		case <-l.opened:
+
+		case <-l.closed:
+			return
+
+		case <-msgr.stop:
+			return
+		}
+	}
+}
+
+// greet - Sends the Hello of the link while the other server has not confirmed it (HMAC mode only)
+func (msgr *Messenger) greet(l *link) bool {
+	hello, unconfirmed := l.greeting()
+	if !unconfirmed {
+		return true
+	}
+	return msgr.sendFrame(l, Frame{Hello: hello})
+}
+
+// sendFrame - Hands a frame to the transport, and returns false if the transport has been closed
+// (a frame that the transport fails to send, or that has no session key yet, is retransmitted later)
+func (msgr *Messenger) sendFrame(l *link, frame Frame) bool {
+	frame.Incarnation = msgr.incarnation
+	if frame.Hello == nil && !l.seal(&frame) {
+		return true
+	}
+	data, err := EncodeFrame(frame)
+	if err != nil {
+		msgr.log.Error("dropped frame", "peer", l.peer, "err", err)
+		return true
+	}
+
+	err = msgr.transport.Send(l.peer, data)
+	if err == ErrNetworkClosed {
+		return false
+	} else if err != nil {
+		msgr.log.Warn("send failed", "peer", l.peer, "err", err)
+	}
+	return true
+}
+
+// deliver - Handles a frame received from the server of the link [called from Subscribe]
+func (msgr *Messenger) deliver(l *link, data []byte) {
+	frame, err := DecodeFrame(data)
+	if err != nil {
+		msgr.ReportMalformed(l.peer, malformed(-1, "", err))
+		return
+	}
+
+	if l.session != nil {
+		if frame.Hello != nil {
+			if err := msgr.acceptHello(l, frame.Hello); err != nil {
+				msgr.ReportMalformed(l.peer, malformed(l.peer, "HELLO", err))
+				return
+			}
+			signal(l.ackNeeded) // Sends our Hello back, and an acknowledgement that confirms it
+			return
+		}
+		if valid, err := l.open(frame); !valid {
+			if err != nil {
+				msgr.ReportMalformed(l.peer, malformed(l.peer, "", err))
+			}
+			return
+		}
+	}
+
+	if !l.meet(frame.Incarnation) {
+		return // From an earlier incarnation of the other server
+	}
+	if frame.Peer != 0 && frame.Peer != msgr.incarnation {
+		signal(l.ackNeeded) // Numbered for an earlier incarnation of this server: tells the sender ours
+		return
+	}
+	l.acknowledge(frame.Ack)
+	if frame.Seq == 0 || !l.receive(frame.Seq) {
+		return // A bare acknowledgement or a duplicate
+	}
+
+	go func() {
+		message, err := msgr.openMessage(frame.Payload, l.peer)
+		if err == nil {
+			msgr.Metrics.received(message.Type, l.peer, len(frame.Payload))
+			err = msgr.handle(message)
+		}
+		if err != nil {
+			msgr.ReportMalformed(l.peer, err)
+		}
+	}()
+}
+
+// enqueue - Puts a message in the queue of server to, waiting for room until deadline (not at all if
+// it is zero). A message to a server that has left is dropped.
+func (msgr *Messenger) enqueue(message types.Message, to int, deadline time.Time) bool {
+	l := msgr.link(to)
+	if l == nil {
+		return true
+	}
+	select {
+	case l.queue <- message:
+		return true
+	default:
+	}
+	if deadline.IsZero() {
+		return false
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case l.queue <- message:
+		return true
+	case <-timer.C:
+		return false
+	case <-l.closed:
+		return true
+	case <-msgr.stop:
+		return true
+	}
+}
+
+// backpressure - Logs and counts the servers whose queue was full for a message
+func (msgr *Messenger) backpressure(Type string, peers []int) error {
+	if len(peers) == 0 {
+		return nil
+	}
+	err := &BackpressureError{Type: Type, Peers: peers}
+	msgr.log.Warn("backpressure", "type", Type, "peers", peers)
+
+	msgr.MsgMutex.Lock()
+	msgr.Backpressure += len(peers)
+	msgr.MsgMutex.Unlock()
+	for _, peer := range peers {
+		msgr.Metrics.Backpressure.Inc(Type, strconv.Itoa(peer))
+	}
+	return err
+}
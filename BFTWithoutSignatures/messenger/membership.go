@@ -0,0 +1,134 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"strconv"
+)
+
+/*
+	The servers may change while the messenger runs (see membership.go of the
+	modules). Reconfigure closes the links to the servers that leave, opens
+	links to the ones that join and switches to the keys of the new servers.
+	A Transport that implements Reconfigurable connects and disconnects its
+	sockets; the others (e.g. a MemoryNetwork) already reach every server.
+	The frames of a server that has left are dropped.
+*/
+
+// Reconfigurable - A Transport whose servers can change while it runs
+type Reconfigurable interface {
+	// Connect - Opens the link with server peer (receives from it on rep and sends to it on req)
+	Connect(peer int, rep string, req string) error
+
+	// Disconnect - Closes the link with server peer
+	Disconnect(peer int)
+}
+
+// Reconfigure - Switches to n servers with the given keys: the links of the removed servers are
+// closed, and links are opened to the added ones (self is where this server runs). A replaced server
+// is both removed and added, and gets a new link.
+func (msgr *Messenger) Reconfigure(n int, keys *threshenc.Keys, self types.Member, removed []int,
+	added []types.Member) {
+	reconfigurable, _ := msgr.transport.(Reconfigurable)
+
+	opened := make([]*link, 0, len(added))
+	for _, member := range added {
+		if member.ID != msgr.ID {
+			opened = append(opened, msgr.newLink(member.ID, keys))
+		}
+	}
+
+	msgr.membershipMutex.Lock()
+	defer msgr.membershipMutex.Unlock()
+
+	for _, peer := range removed {
+		if l, in := msgr.links[peer]; in {
+			close(l.closed)
+			delete(msgr.links, peer)
+			delete(msgr.MessageChannel, peer)
+		}
+		if reconfigurable != nil {
+			reconfigurable.Disconnect(peer)
+		}
+		msgr.log.Info("disconnected", "peer", peer)
+	}
+
+	for k, l := range opened {
+		if reconfigurable != nil {
+			member := added[k]
+			rep := "tcp://*:" + strconv.Itoa(self.PeerPort+member.ID)
+			req := "tcp://" + member.Host + ":" + strconv.Itoa(member.PeerPort+msgr.ID)
+			err := reconfigurable.Connect(l.peer, rep, req)
+			if err != nil {
+				msgr.log.Error("connect failed", "peer", l.peer, "err", err)
+			}
+		}
+		msgr.links[l.peer] = l
+		msgr.MessageChannel[l.peer] = l.queue
+		msgr.subscribe(l.peer)
+		go msgr.transmit(l)
+		msgr.log.Info("connected", "peer", l.peer)
+	}
+
+	msgr.N = n
+	msgr.F = (n - 1) / 3
+	msgr.Keys = keys
+}
+
+// n - The number of servers
+func (msgr *Messenger) n() int {
+	msgr.membershipMutex.RLock()
+	defer msgr.membershipMutex.RUnlock()
+
+	return msgr.N
+}
+
+// CurrentKeys - The keys of the current servers
+func (msgr *Messenger) CurrentKeys() *threshenc.Keys {
+	msgr.membershipMutex.RLock()
+	defer msgr.membershipMutex.RUnlock()
+
+	return msgr.Keys
+}
+
+// link - The link to server peer (nil if it is not a server)
+func (msgr *Messenger) link(peer int) *link {
+	msgr.membershipMutex.RLock()
+	defer msgr.membershipMutex.RUnlock()
+
+	return msgr.links[peer]
+}
+
+// newLink - Creates the link to server peer (with a new session in HMAC mode)
+func (msgr *Messenger) newLink(peer int, keys *threshenc.Keys) *link {
+	l := newLink(peer, msgr.QueueSize)
+	if msgr.authenticated() {
+		l.session = msgr.newSession(peer, keys)
+	}
+	return l
+}
+
+// subscribe - Handles the frames of server peer, once per server (membershipMutex must be held)
+func (msgr *Messenger) subscribe(peer int) {
+	if msgr.subscribed[peer] {
+		return
+	}
+	msgr.subscribed[peer] = true
+
+	go func() {
+		frames := msgr.transport.Receive(peer)
+		for {
+			select {
+			case frame, open := <-frames:
+				if !open {
+					return
+				}
+				if l := msgr.link(peer); l != nil {
+					msgr.deliver(l, frame)
+				}
+			case <-msgr.stop:
+				return
+			}
+		}
+	}()
+}
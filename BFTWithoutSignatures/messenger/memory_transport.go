@@ -0,0 +1,82 @@
+package messenger
+
+import (
+	"errors"
+	"sync"
+)
+
+// MemoryNetwork - An in-process network that connects the transports of N servers (used in tests)
+type MemoryNetwork struct {
+	n     int
+	links map[int]map[int]chan []byte // to, from
+	done  chan struct{}
+	once  sync.Once
+}
+
+// memoryTransport - The endpoint of a server in a MemoryNetwork
+type memoryTransport struct {
+	id      int
+	network *MemoryNetwork
+}
+
+// ErrNetworkClosed - Returned when sending through a closed MemoryNetwork (or a closed transport)
+var ErrNetworkClosed = errors.New("network is closed")
+
+// NewMemoryNetwork - Creates an in-process network between n servers
+func NewMemoryNetwork(n int) *MemoryNetwork {
+	network := &MemoryNetwork{
+		n:     n,
+		links: make(map[int]map[int]chan []byte, n),
+		done:  make(chan struct{}),
+	}
+	for to := 0; to < n; to++ {
+		network.links[to] = make(map[int]chan []byte, n)
+		for from := 0; from < n; from++ {
+			if from == to {
+				continue // Not myself
+			}
+			network.links[to][from] = make(chan []byte)
+		}
+	}
+	return network
+}
+
+// Transport - Returns the transport of server id in the network
+func (network *MemoryNetwork) Transport(id int) Transport {
+	return &memoryTransport{id: id, network: network}
+}
+
+// Close - Shuts down the network (pending and future sends fail)
+func (network *MemoryNetwork) Close() {
+	network.once.Do(func() {
+		close(network.done)
+	})
+}
+
+// Send - Hands a copy of the message to server `to` (blocks until it is received, like REQ/REP)
+func (t *memoryTransport) Send(to int, message []byte) error {
+	link, in := t.network.links[to][t.id]
+	if !in {
+		return errors.New("memory network: no link to server")
+	}
+
+	data := make([]byte, len(message))
+	copy(data, message)
+
+	select {
+	case link <- data:
+		return nil
+	case <-t.network.done:
+		return ErrNetworkClosed
+	}
+}
+
+// Receive - Returns the channel with the messages received from server `from`
+func (t *memoryTransport) Receive(from int) <-chan []byte {
+	return t.network.links[t.id][from]
+}
+
+// Close - Closes the whole network, as every server runs in the same process
+func (t *memoryTransport) Close() {
+	t.network.Close()
+}
@@ -8,230 +8,330 @@ import (
 	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
-	"time"
+	"errors"
 	"math"
 	"sync"
 	"strconv"
+	"time"
 
 	"github.com/pebbe/zmq4"
 )
 
-// Sockets
-var (
-	// Context to initialize sockets
-	Context *zmq4.Context
+// SSGossipBuffer - The capacity of the channels of the self-stabilizing modules that gossip their
+// state (SSMVC and SSBC): a message that finds its channel full is dropped, as it is sent again
+const SSGossipBuffer = 64
+
+// Messenger - The messenger of a replica (links to the other servers and the clients)
+type Messenger struct {
+	variables.Replica
 
-	// SendSockets - Send messages to other servers
-	SendSockets map[int]*zmq4.Socket
+	// Scenario - The scenario executed (Byzantine processes modify the messages they send)
+	Scenario string
 
-	// ReceiveSockets - Receive messages from other servers
-	ReceiveSockets map[int]*zmq4.Socket
+	// Keys - The keys used to sign and verify the messages (replaced when the servers change, see
+	// CurrentKeys)
+	Keys *threshenc.Keys
+
+	// Auth - How the messages are authenticated (config.AuthRSA or config.AuthHMAC, set before InitializeTransport)
+	Auth string
+
+	// transport - The link layer used by Broadcast, TransmitMessages and Subscribe
+	transport Transport
+
+	// Context to initialize sockets
+	Context *zmq4.Context
 
 	// ServerSockets - Get the client requests
 	ServerSockets map[int]*zmq4.Socket
 
 	// ResponseSockets - Send responses to clients
 	ResponseSockets map[int]*zmq4.Socket
-)
+	ResponseMutex   sync.Mutex
+
+	// addresses - The addresses of the sockets (to rebuild them after an error)
+	addresses config.Addresses
+
+	// MessageChannel - Channel to put the messages that need to be transmitted in (one bounded queue per server)
+	MessageChannel map[int]chan types.Message
+
+	// QueueSize - The capacity of the queue of every server (set before InitializeTransport)
+	QueueSize int
+
+	// SendTimeout - How long BroadcastWait and SendMessageWait wait for room in a full queue
+	SendTimeout time.Duration
+
+	// links - The reliable links to the other servers (see link.go)
+	links map[int]*link
+
+	// incarnation - Tells the frames of this messenger from the ones of a messenger of this server that
+	// ran before (see link.go)
+	incarnation uint64
+
+	// subscribed - The servers whose frames are received (even after they leave)
+	subscribed map[int]bool
+
+	// Guards N, F, Keys, MessageChannel, links and subscribed while the servers change (see membership.go)
+	membershipMutex sync.RWMutex
 
-// Channels for messages
-var (
-	// MessageChannel - Channel to put the messages that need to be transmitted in
-	MessageChannel = make(map[int]chan types.Message)
+	// stop - Closed when the messenger is closed
+	stop     chan struct{}
+	stopOnce sync.Once
 
 	// BvbChannel - Channel to put the BVB messages in
-	BvbChannel = make(map[int]chan struct {
+	BvbChannel map[int]chan struct {
 		BcMessage types.BcMessage
 		From      int
-	})
+	}
 
 	// BcChannel - Channel to put the BC messages in
-	BcChannel = make(map[int]chan struct {
+	BcChannel map[int]chan struct {
 		BcMessage types.BcMessage
 		From      int
-	})
+	}
+
+	// CoinChannel - Channel to put the common coin shares in
+	CoinChannel map[int]chan struct {
+		CoinMessage types.CoinMessage
+		From        int
+	}
 
 	// RbChannel - Channel to put the RB messages in
-	RbChannel = make(map[string]map[int]chan struct {
+	RbChannel map[string]map[int]chan struct {
 		RbMessage types.RbMessage
 		From      int
-	})
+	}
 
 	// RbAbcChannel - Channel to put the RB messages for ABC in
-	RbAbcChannel = make(chan struct {
+	RbAbcChannel chan struct {
 		RbMessage types.RbMessage
 		From      int
-	})
+	}
 
 	// MvcChannel - Channel to put the MVC messages in
-	MvcChannel = make(map[int]chan struct {
+	MvcChannel map[int]chan struct {
 		MvcMessage types.MvcMessage
 		From       int
-	})
+	}
 
 	// VcChannel - Channel to put the VC messages in
-	VcChannel = make(map[int]chan struct {
+	VcChannel map[int]chan struct {
 		VcMessage types.VcMessage
 		From      int
-	})
+	}
 
 	// AbcChannel - Channel to put the ABC messages in
-	AbcChannel = make(chan struct {
+	AbcChannel chan struct {
 		AbcMessage types.AbcMessage
 		From       int
-	})
+	}
 
 	// RequestChannel - Channel to put the client requests in
-	RequestChannel = make(chan []byte, 100)
+	RequestChannel chan []byte
 
 	// SSVCChannel - Channel to put the Self Stabilized VC messages in
-	SSVCChannel = make(map[int]chan struct {
+	SSVCChannel map[int]chan struct {
 		SSVCMessage types.SSVCMessage
-		From      int
-	})
+		From        int
+	}
 
 	// SSVCDecisionsChannel - Channel to put decided vectors from SSVC
-	SSVCDecisionsChannel = make(map[int]chan struct {
+	SSVCDecisionsChannel map[int]chan struct {
 		Vector map[int][]byte
-		From      int
-	})
+		From   int
+	}
+
+	// SSMVCChannel - Channel to put the Self Stabilized MVC messages in (see SSGossipBuffer)
+	SSMVCChannel map[int]chan struct {
+		SSMVCMessage types.SSMVCMessage
+		From         int
+	}
+
+	// SSBCChannel - Channel to put the Self Stabilized BC messages in (see SSGossipBuffer)
+	SSBCChannel map[int]chan struct {
+		SSBCMessage types.SSBCMessage
+		From        int
+	}
 
 	// SSABCChannel - Channel to put the Self Stabilized ABC messages in
-	SSABCChannel = make(chan struct {
+	SSABCChannel chan struct {
 		SSABCMessage types.SSABCMessage
-		From      int
-	})
-
-)
+		From         int
+	}
 
-// Locks for channels in maps (to avoid race conditions)
-var (
+	// StateChannel - Channel to put the state transfer messages in
+	StateChannel chan struct {
+		StateMessage types.StateMessage
+		From         int
+	}
 
-	BvbMutex = sync.RWMutex{}
-	BcMutex = sync.RWMutex{}
-	RbMutex = sync.RWMutex{}
-	MvcMutex = sync.RWMutex{}
-	VcMutex = sync.RWMutex{}
-	SSVCMutex = sync.RWMutex{}
-	SSVCDecisionsMutex = sync.RWMutex{}
+	// Locks for channels in maps (to avoid race conditions)
+	BvbMutex           sync.RWMutex
+	BcMutex            sync.RWMutex
+	CoinMutex          sync.RWMutex
+	RbMutex            sync.RWMutex
+	MvcMutex           sync.RWMutex
+	VcMutex            sync.RWMutex
+	SSVCMutex          sync.RWMutex
+	SSVCDecisionsMutex sync.RWMutex
+	SSMVCMutex         sync.RWMutex
+	SSBCMutex          sync.RWMutex
+
+	// Garbage collection of the instances up to the last stable checkpoint (see gc.go)
+	collected int                   // The last collected root instance
+	done      map[int]chan struct{} // Closed when the instances of a root are collected
+	GCMutex   sync.RWMutex
+
+	// Server metrics regarding the experiment evaluation
+	MsgComplexity   int
+	MsgSize         int64
+	Malformed       map[int]int // server -> malformed messages dropped (see ReportMalformed)
+	Retransmissions int         // Messages sent again because they were not acknowledged
+	Backpressure    int         // Messages not queued because the queue of a server was full
+	MsgMutex        sync.RWMutex
+
+	// Metrics - The metrics of the replica, served over HTTP (see metrics.go)
+	Metrics *Metrics
+
+	log *logger.Logger
+}
 
-)
+// NewMessenger - Creates the messenger of replica r
+func NewMessenger(r variables.Replica, scenario string, keys *threshenc.Keys) *Messenger {
+	msgr := &Messenger{
+		Replica:        r,
+		Scenario:       scenario,
+		Keys:           keys,
+		Auth:           config.AuthRSA,
+		MessageChannel: make(map[int]chan types.Message),
+		QueueSize:      DefaultQueueSize,
+		SendTimeout:    DefaultSendTimeout,
+		links:          make(map[int]*link),
+		incarnation:    uint64(time.Now().UnixNano()),
+		subscribed:     make(map[int]bool),
+		stop:           make(chan struct{}),
+		BvbChannel: make(map[int]chan struct {
+			BcMessage types.BcMessage
+			From      int
+		}),
+		BcChannel: make(map[int]chan struct {
+			BcMessage types.BcMessage
+			From      int
+		}),
+		CoinChannel: make(map[int]chan struct {
+			CoinMessage types.CoinMessage
+			From        int
+		}),
+		RbChannel: make(map[string]map[int]chan struct {
+			RbMessage types.RbMessage
+			From      int
+		}),
+		RbAbcChannel: make(chan struct {
+			RbMessage types.RbMessage
+			From      int
+		}),
+		MvcChannel: make(map[int]chan struct {
+			MvcMessage types.MvcMessage
+			From       int
+		}),
+		VcChannel: make(map[int]chan struct {
+			VcMessage types.VcMessage
+			From      int
+		}),
+		AbcChannel: make(chan struct {
+			AbcMessage types.AbcMessage
+			From       int
+		}),
+		RequestChannel: make(chan []byte, 100),
+		SSVCChannel: make(map[int]chan struct {
+			SSVCMessage types.SSVCMessage
+			From        int
+		}),
+		SSVCDecisionsChannel: make(map[int]chan struct {
+			Vector map[int][]byte
+			From   int
+		}),
+		SSMVCChannel: make(map[int]chan struct {
+			SSMVCMessage types.SSMVCMessage
+			From         int
+		}),
+		SSBCChannel: make(map[int]chan struct {
+			SSBCMessage types.SSBCMessage
+			From        int
+		}),
+		SSABCChannel: make(chan struct {
+			SSABCMessage types.SSABCMessage
+			From         int
+		}),
+		StateChannel: make(chan struct {
+			StateMessage types.StateMessage
+			From         int
+		}),
+		collected: -1,
+		done:      make(map[int]chan struct{}),
+		Malformed: make(map[int]int),
+		log:       logger.Module("messenger").With("replica", r.ID),
+	}
+	msgr.Metrics = newMetrics(msgr)
+	return msgr
+}
 
 // InitializeMessenger - Initializes the 0MQ sockets (between Servers and Clients)
-func InitializeMessenger() {
+func (msgr *Messenger) InitializeMessenger(addresses config.Addresses) {
 	Context, err := zmq4.NewContext()
 	if err != nil {
 		logger.ErrLogger.Fatal(err)
 	}
+	msgr.Context = Context
+	msgr.addresses = addresses
 
 	// Initialization of a socket pair to communicate with each one of the other servers
-	ReceiveSockets = make(map[int]*zmq4.Socket)
-	SendSockets = make(map[int]*zmq4.Socket)
-	for i := 0; i < variables.N; i++ {
-		if i == variables.ID {
-			continue // Not myself
-		}
-
-		// ReceiveSockets initialization to get information from other servers
-		ReceiveSockets[i], err = Context.NewSocket(zmq4.REP)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		var receiveAddr string
-		if !variables.Remote {
-			receiveAddr = config.GetRepAddressLocal(i)
-		} else {
-			receiveAddr = config.GetRepAddress(i)
-		}
-		err = ReceiveSockets[i].Bind(receiveAddr)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		logger.OutLogger.Println("Receive from Server", i, "on", receiveAddr)
-
-		// SendSockets initialization to send information to other servers
-		SendSockets[i], err = Context.NewSocket(zmq4.REQ)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		var sendAddr string
-		if !variables.Remote {
-			sendAddr = config.GetReqAddressLocal(i)
-		} else {
-			sendAddr = config.GetReqAddress(i)
-		}
-		err = SendSockets[i].Connect(sendAddr)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		logger.OutLogger.Println("Send to Server", i, "on", sendAddr)
-
-		// Init message channel
-		MessageChannel[i] = make(chan types.Message)
-	}
-
-	logger.OutLogger.Println("-----------------------------------------")
+	msgr.InitializeTransport(NewZMQTransport(Context, msgr.Replica, addresses))
 
 	// Initialization of a socket pair to communicate with each one of the clients
-	ServerSockets = make(map[int]*zmq4.Socket, variables.Clients)
-	ResponseSockets = make(map[int]*zmq4.Socket, variables.Clients)
-	for i := 0; i < variables.Clients; i++ {
+	msgr.ServerSockets = make(map[int]*zmq4.Socket, msgr.Clients)
+	msgr.ResponseSockets = make(map[int]*zmq4.Socket, msgr.Clients)
+	for i := 0; i < msgr.Clients; i++ {
 
 		// ServerSockets initialization to get clients requests
-		ServerSockets[i], err = Context.NewSocket(zmq4.REP)
+		msgr.ServerSockets[i], err = msgr.newServerSocket(i)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		var serverAddr string
-		if !variables.Remote {
-			serverAddr = config.GetServerAddressLocal(i)
-		} else {
-			serverAddr = config.GetServerAddress(i)
-		}
-		err = ServerSockets[i].Bind(serverAddr)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		logger.OutLogger.Println("Requests from Client", i, "on", serverAddr)
+		msgr.log.Info("requests from client", "client", i, "address", addresses.Server[i])
 
 		// ResponseSockets initialization to publish the response back to the clients
-		ResponseSockets[i], err = Context.NewSocket(zmq4.PUB)
+		msgr.ResponseSockets[i], err = msgr.newResponseSocket(i)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		var responseAddr string
-		if !variables.Remote {
-			responseAddr = config.GetResponseAddressLocal(i)
-		} else {
-			responseAddr = config.GetResponseAddress(i)
-		}
-		err = ResponseSockets[i].Bind(responseAddr)
-		if err != nil {
-			logger.ErrLogger.Fatal(err)
-		}
-		logger.OutLogger.Println("Response to Client", i, "on", responseAddr)
+		msgr.log.Info("responses to client", "client", i, "address", addresses.Response[i])
 	}
+}
 
-	initRBChannels()
-
-	logger.OutLogger.Print("-----------------------------------------\n\n")
+// NewMessage - Creates a new payload message signed by this replica (unsigned in HMAC mode, where the
+// links authenticate it)
+func (msgr *Messenger) NewMessage(payload []byte, Type string) types.Message {
+	if msgr.authenticated() {
+		return types.NewMessage(payload, Type, msgr.ID, nil)
+	}
+	return types.NewMessage(payload, Type, msgr.ID, msgr.CurrentKeys().SignMessage(payload))
 }
 
 // Initializes the RB channels
-func initRBChannels() {
-	RbChannel["MVC"] = make(map[int]chan struct {
+func (msgr *Messenger) initRBChannels() {
+	msgr.RbChannel["MVC"] = make(map[int]chan struct {
 		RbMessage types.RbMessage
 		From      int
 	})
 
-	RbChannel["VC"] = make(map[int]chan struct {
+	msgr.RbChannel["VC"] = make(map[int]chan struct {
 		RbMessage types.RbMessage
 		From      int
 	})
 }
 
 // Function to modify BC messages if byzantine
-func modifyMessageBC(message types.Message, receiver int) types.Message {
+func (msgr *Messenger) modifyMessageBC(message types.Message, receiver int) types.Message {
 	msg := new(types.BcMessage)
 	buf := bytes.NewBuffer(message.Payload)
 	dec := gob.NewDecoder(buf)
@@ -249,8 +349,8 @@ func modifyMessageBC(message types.Message, receiver int) types.Message {
 		msg.Value = uint(1)
 	}
 
-	logger.ErrLogger.Print(config.Scenario, ": (", message.Type, ") ", receiver, " --> [",
-		msg.Tag, ",", msg.Value, "]\n")
+	msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+		"tag", msg.Tag, "value", msg.Value)
 
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
@@ -259,14 +359,14 @@ func modifyMessageBC(message types.Message, receiver int) types.Message {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	return types.NewMessage(w.Bytes(), message.Type)
+	return msgr.NewMessage(w.Bytes(), message.Type)
 }
 
 // Function to modify messages if byzantine
 // halfScenario = true -> send only to the half the right message
 // halfScenario = false -> send same value to everyone
-func modifyMessage(message types.Message, receiver int, halfScenario bool) types.Message {
-	var newPayload []byte
+func (msgr *Messenger) modifyMessage(message types.Message, receiver int, halfScenario bool) types.Message {
+	newPayload := message.Payload // Other types of messages are sent unchanged
 
 	valueToSend := "0"
 	if halfScenario {
@@ -285,8 +385,8 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 		val,_ := strconv.Atoi(valueToSend)
 		msg.Value = uint(val)
 
-		logger.ErrLogger.Print(config.Scenario, ": (", message.Type, ") ", receiver, " --> [",
-			msg.Tag, ",", msg.Value, "]\n")
+		msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+			"tag", msg.Tag, "value", msg.Value)
 
 		w := new(bytes.Buffer)
 		encoder := gob.NewEncoder(w)
@@ -327,8 +427,8 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 
 			m.Value = []byte(valueToSend)
 
-			logger.ErrLogger.Print(config.Scenario, ": (", msg.Type, ") ", receiver, " --> [",
-				m.Cid, ",", m.Value, "]\n")
+			msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", msg.Type, "peer", receiver,
+				"cid", m.Cid, "value", m.Value)
 
 			w := new(bytes.Buffer)
 			encoder := gob.NewEncoder(w)
@@ -349,8 +449,8 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 
 			m.Value = []byte(valueToSend)
 
-			logger.ErrLogger.Print(config.Scenario, ": (", msg.Type, ") ", receiver, " --> [",
-				m.Vcid, ",", m.Value, "]\n")
+			msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", msg.Type, "peer", receiver,
+				"vcid", m.Vcid, "value", m.Value)
 
 			w := new(bytes.Buffer)
 			encoder := gob.NewEncoder(w)
@@ -371,8 +471,8 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 
 			m.Value = []byte(valueToSend)
 
-			logger.ErrLogger.Print(config.Scenario, ": (", msg.Type, ") ", receiver, " --> [",
-				m.Num, ",", m.Value, "]\n")
+			msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", msg.Type, "peer", receiver,
+				"num", m.Num, "value", m.Value)
 
 			w := new(bytes.Buffer)
 			encoder := gob.NewEncoder(w)
@@ -383,7 +483,7 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 			tempPayload = w.Bytes()
 		}
 
-		temp := types.NewMessage(tempPayload, msg.Type)
+		temp := msgr.NewMessage(tempPayload, msg.Type)
 
 		w := new(bytes.Buffer)
 		encoder := gob.NewEncoder(w)
@@ -419,8 +519,73 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 			}
 		}
 
-		logger.ErrLogger.Print(config.Scenario, ": (", message.Type, ") ", receiver,
-			" every value sent --> [", valueToSend, "]\n")
+		msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+			"value", valueToSend)
+
+		// encode new message
+		w := new(bytes.Buffer)
+		encoder := gob.NewEncoder(w)
+		err = encoder.Encode(msg)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+		newPayload = w.Bytes()
+	} else if (message.Type == "SSMVC") {
+
+		// encode SSMVC message
+		msg := new(types.SSMVCMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err := dec.Decode(&msg)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+
+		// change values to send
+		for _, content := range []map[string][]types.SSVCMessageTuple{msg.Init, msg.Vect} {
+			for _, t := range []string{"init", "echo", "ready"} {
+				for i := range content[t] {
+					content[t][i].Value = []byte(valueToSend)
+				}
+			}
+		}
+
+		msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+			"value", valueToSend)
+
+		// encode new message
+		w := new(bytes.Buffer)
+		encoder := gob.NewEncoder(w)
+		err = encoder.Encode(msg)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+		newPayload = w.Bytes()
+	} else if (message.Type == "SSBC") {
+
+		// encode SSBC message
+		msg := new(types.SSBCMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err := dec.Decode(&msg)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+
+		// change values to send
+		val, _ := strconv.Atoi(valueToSend)
+		for i := range msg.Rounds {
+			msg.Rounds[i].Est = []uint{uint(val)}
+			if len(msg.Rounds[i].Aux) > 0 {
+				msg.Rounds[i].Aux = []uint{uint(val)}
+			}
+		}
+		if len(msg.Decided) > 0 {
+			msg.Decided = []uint{uint(val)}
+		}
+
+		msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+			"value", valueToSend)
 
 		// encode new message
 		w := new(bytes.Buffer)
@@ -449,8 +614,8 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 			}
 		}
 
-		logger.ErrLogger.Print(config.Scenario, ": (", message.Type, ") ", receiver,
-			" every value sent --> [", valueToSend, "]\n")
+		msgr.log.Warn("byzantine message", "scenario", msgr.Scenario, "type", message.Type, "peer", receiver,
+			"value", valueToSend)
 
 		// encode new message
 		w := new(bytes.Buffer)
@@ -462,140 +627,184 @@ func modifyMessage(message types.Message, receiver int, halfScenario bool) types
 		newPayload = w.Bytes()
 	}
 
-	return types.NewMessage(newPayload, message.Type)
+	return msgr.NewMessage(newPayload, message.Type)
+}
+
+// Broadcast - Broadcasts a message to all other servers (without blocking). If the queue of some
+// servers is full, the message is not sent to them and a *BackpressureError is returned.
+func (msgr *Messenger) Broadcast(message types.Message) error {
+	return msgr.broadcast(message, time.Time{})
+}
+
+// BroadcastWait - Broadcasts a message to all other servers, waiting up to SendTimeout for room in the
+// full queues (for the messages that are sent only once). A *BackpressureError is returned for the
+// servers whose queue stayed full.
+func (msgr *Messenger) BroadcastWait(message types.Message) error {
+	return msgr.broadcast(message, time.Now().Add(msgr.SendTimeout))
 }
 
-// Broadcast - Broadcasts a message to all other servers
-func Broadcast(message types.Message) {
+// broadcast - Broadcasts a message, waiting for room in the full queues until deadline (if not zero)
+func (msgr *Messenger) broadcast(message types.Message, deadline time.Time) error {
 
-	if (config.Scenario == "IDLE") && (variables.Byzantine) {return}
+	if (msgr.Scenario == "IDLE") && (msgr.Byzantine) {return nil}
 
-	halfScenario := config.Scenario == "HALF_&_HALF"
+	halfScenario := msgr.Scenario == "HALF_&_HALF"
 
-	for i := 0; i < variables.N; i++ {
-		if i == variables.ID {
+	full := make([]int, 0)
+	for i := 0; i < msgr.n(); i++ {
+		if i == msgr.ID {
 			continue // Not myself
 		}
 
 		if message.Type != "SSVCDS"{
 			// Modify message before sending it, in case of a special scenario
-			if (config.Scenario == "BC_ATTACK") && (variables.Byzantine) &&
+			if (msgr.Scenario == "BC_ATTACK") && (msgr.Byzantine) &&
 					((message.Type == "BVB") || (message.Type == "BC")) {
-				message = modifyMessageBC(message, i)
-			} else if (config.Scenario == "HALF_&_HALF" || config.Scenario == "BZ_ALL") &&
-					(variables.Byzantine) {
-				message = modifyMessage(message, i, halfScenario)
+				message = msgr.modifyMessageBC(message, i)
+			} else if (msgr.Scenario == "HALF_&_HALF" || msgr.Scenario == "BZ_ALL") &&
+					(msgr.Byzantine) {
+				message = msgr.modifyMessage(message, i, halfScenario)
 			}
 		}
 
-		timeout := time.NewTicker(10 * time.Second)
-		select {
-		case MessageChannel[i] <- message:
-		case <-timeout.C:
+		if !msgr.enqueue(message, i, deadline) {
+			full = append(full, i)
 		}
 	}
+
+	return msgr.backpressure(message.Type, full)
 }
 
-// TransmitMessages - Transmits the messages to the other servers [started from main]
-func TransmitMessages() {
-	for i := 0; i < variables.N; i++ {
-		if i == variables.ID {
-			continue // Not myself
-		}
-		go func(i int) { // Initializes them with a goroutine and waits forever
-			for message := range MessageChannel[i] {
-				w := new(bytes.Buffer)
-				encoder := gob.NewEncoder(w)
-				err := encoder.Encode(message)
-				if err != nil {
-					logger.ErrLogger.Fatal(err)
-				}
+// SendMessage - Sends a message only to server to (a *BackpressureError is returned if its queue is full)
+func (msgr *Messenger) SendMessage(message types.Message, to int) error {
 
-				_, err = SendSockets[i].SendBytes(w.Bytes(), 0)
-				if err != nil {
-					logger.ErrLogger.Fatal(err)
-				}
+	if (msgr.Scenario == "IDLE") && (msgr.Byzantine) {return nil}
 
-				_, err = SendSockets[i].Recv(0)
-				if err != nil {
-					logger.ErrLogger.Fatal(err)
-				}
-				logger.OutLogger.Println("SENT", message.Type, "to", i)
-				variables.MsgMutex.Lock()
-				variables.MsgComplexity++
-				variables.MsgSize += int64(len(w.Bytes()))
-				variables.MsgMutex.Unlock()
-			}
-		}(i)
+	if !msgr.enqueue(message, to, time.Time{}) {
+		return msgr.backpressure(message.Type, []int{to})
 	}
+	return nil
 }
 
-// Subscribe - Handles the inputs from both clients and other servers [started from main]
-func Subscribe() {
-	// Gets messages from other servers and handles them
-	for i := 0; i < variables.N; i++ {
-		if i == variables.ID {
-			continue // Not myself
-		}
-		go func(i int) { // Initializes them with a goroutine and waits forever
-			for {
-				message, err := ReceiveSockets[i].RecvBytes(0)
-				if err != nil {
-					logger.ErrLogger.Fatal(err)
-				}
+// SendMessageWait - Sends a message only to server to, waiting up to SendTimeout for room in its queue
+// (a *BackpressureError is returned if it stayed full)
+func (msgr *Messenger) SendMessageWait(message types.Message, to int) error {
 
-				go HandleMessage(message)
+	if (msgr.Scenario == "IDLE") && (msgr.Byzantine) {return nil}
 
-				_, err = ReceiveSockets[i].Send("", 0)
-				if err != nil {
-					logger.ErrLogger.Fatal(err)
-				}
-			}
-		}(i)
+	if !msgr.enqueue(message, to, time.Now().Add(msgr.SendTimeout)) {
+		return msgr.backpressure(message.Type, []int{to})
+	}
+	return nil
+}
+
+// TransmitMessages - Transmits the messages to the other servers over the links [started from main]
+func (msgr *Messenger) TransmitMessages() {
+	msgr.membershipMutex.RLock()
+	defer msgr.membershipMutex.RUnlock()
+
+	for _, l := range msgr.links {
+		go msgr.transmit(l)
+	}
+}
+
+// Subscribe - Handles the inputs from both clients and other servers [started from main]
+func (msgr *Messenger) Subscribe() {
+	// Gets messages from other servers and handles them
+	msgr.membershipMutex.Lock()
+	for i := range msgr.links {
+		msgr.subscribe(i)
 	}
+	msgr.membershipMutex.Unlock()
 
 	// Gets requests from clients and handles them
-	for i := 0; i < variables.Clients; i++ {
+	for i := 0; i < msgr.Clients; i++ {
 		go func(i int) { // Initialize them with a goroutine and waits forever
 			for {
-				message, err := ServerSockets[i].RecvBytes(0)
+				message, err := msgr.ServerSockets[i].RecvBytes(0)
 				if err != nil {
-					logger.ErrLogger.Fatal(err)
+					msgr.log.Error("receive from client failed", "client", i, "err", err)
+					msgr.reconnectServerSocket(i)
+					continue
 				}
 
-				go handleRequest(message, i)
+				go msgr.handleRequest(message, i)
 
-				_, err = ServerSockets[i].Send("", 0)
+				_, err = msgr.ServerSockets[i].Send("", 0)
 				if err != nil {
-					logger.ErrLogger.Fatal(err)
+					msgr.log.Error("ack to client failed", "client", i, "err", err)
+					msgr.reconnectServerSocket(i)
 				}
 			}
 		}(i)
 	}
 }
 
+// Close - Closes the transport and the client sockets, and collects every instance so that the modules
+// stop (closing it again does nothing)
+func (msgr *Messenger) Close() {
+	msgr.stopOnce.Do(func() {
+		close(msgr.stop)
+		msgr.Collect(math.MaxInt)
+		if msgr.transport != nil {
+			msgr.transport.Close()
+		}
+
+		for i := range msgr.ServerSockets {
+			msgr.ServerSockets[i].Close()
+			msgr.ResponseSockets[i].Close()
+		}
+	})
+}
+
+// Stopped - Closed when the messenger is closed (for the modules that do not run in an instance)
+func (msgr *Messenger) Stopped() <-chan struct{} {
+	return msgr.stop
+}
+
 // Put client's message in RequestChannel to be handled
-func handleRequest(message []byte, from int) {
-	logger.OutLogger.Println("RECEIVED REQ from", from)
-	RequestChannel <- message
+func (msgr *Messenger) handleRequest(message []byte, from int) {
+	msgr.log.Debug("received request", "client", from)
+	msgr.RequestChannel <- message
 }
 
 // HandleMessage - Handles the messages from the other servers								SS
-func HandleMessage(msg []byte) {
+// (sender is the server of the link, or the process whose RB delivered the message; a message that
+// is malformed or invalid is dropped, and returned as a *MalformedError)
+func (msgr *Messenger) HandleMessage(msg []byte, sender int) error {
+	message, err := msgr.openMessage(msg, sender)
+	if err != nil {
+		return err
+	}
+	return msgr.handle(message)
+}
+
+// openMessage - Decodes a message and checks that it comes from sender
+func (msgr *Messenger) openMessage(msg []byte, sender int) (*types.Message, error) {
 	message := new(types.Message)
 	buffer := bytes.NewBuffer([]byte(msg))
 	decoder := gob.NewDecoder(buffer)
 	err := decoder.Decode(&message)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, malformed(-1, "", err)
 	}
 
-	if !(threshenc.VerifyMessage(message.Payload, message.Signature, message.From)) {
-		logger.OutLogger.Println("INVALID", message.Type, "from", message.From)
-		return
+	if message.From != sender {
+		return nil, malformed(-1, message.Type, errors.New("sender "+strconv.Itoa(message.From)+
+			" received from "+strconv.Itoa(sender)))
 	}
+	if !msgr.authenticated() && !(msgr.CurrentKeys().VerifyMessage(message.Payload, message.Signature, message.From)) {
+		return nil, malformed(-1, message.Type, ErrInvalidSignature)
+	}
+	return message, nil
+}
 
-	logger.OutLogger.Println("RECEIVED", message.Type, "from", message.From)
+// handle - Passes a valid message to the module it is for
+func (msgr *Messenger) handle(message *types.Message) error {
+	var err error
+	if msgr.log.Enabled(logger.LevelDebug) {
+		msgr.log.Debug("received", "type", message.Type, "peer", message.From)
+	}
 
 	switch message.Type {
 	case "BVB":
@@ -604,24 +813,35 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&bcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		tag := bcMessage.Tag
-		BvbMutex.Lock()
-		if _, in := BvbChannel[tag]; !in {
-			BvbChannel[tag] = make(chan struct {
+		if tag < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(tag, DepthBC)
+		msgr.BvbMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.BvbMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.BvbChannel[tag]; !in {
+			msgr.BvbChannel[tag] = make(chan struct {
 				BcMessage types.BcMessage
 				From      int
 			})
 		}
-		msgChannel := BvbChannel[tag]
-		BvbMutex.Unlock()
+		msgChannel := msgr.BvbChannel[tag]
+		msgr.BvbMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			BcMessage types.BcMessage
 			From      int
-		}{BcMessage: *bcMessage, From: message.From}
+		}{BcMessage: *bcMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "BC":
 		bcMessage := new(types.BcMessage)
@@ -629,24 +849,71 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&bcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		tag := bcMessage.Tag
-		BcMutex.Lock()
-		if _, in := BcChannel[tag]; !in {
-			BcChannel[tag] = make(chan struct {
+		if tag < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(tag, DepthBC)
+		msgr.BcMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.BcMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.BcChannel[tag]; !in {
+			msgr.BcChannel[tag] = make(chan struct {
 				BcMessage types.BcMessage
 				From      int
 			})
 		}
-		msgChannel := BcChannel[tag]
-		BcMutex.Unlock()
+		msgChannel := msgr.BcChannel[tag]
+		msgr.BcMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			BcMessage types.BcMessage
 			From      int
-		}{BcMessage: *bcMessage, From: message.From}
+		}{BcMessage: *bcMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
+
+	case "COIN":
+		coinMessage := new(types.CoinMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err = dec.Decode(&coinMessage)
+		if err != nil {
+			return malformed(message.From, message.Type, err)
+		}
+
+		id := coinMessage.Id
+		if id < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(id, DepthBC)
+		msgr.CoinMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.CoinMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.CoinChannel[id]; !in {
+			msgr.CoinChannel[id] = make(chan struct {
+				CoinMessage types.CoinMessage
+				From        int
+			})
+		}
+		msgChannel := msgr.CoinChannel[id]
+		msgr.CoinMutex.Unlock()
+
+		select {
+		case msgChannel <- struct {
+			CoinMessage types.CoinMessage
+			From        int
+		}{CoinMessage: *coinMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "RB":
 		rbMessage := new(types.RbMessage)
@@ -654,25 +921,36 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&rbMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		rbid := rbMessage.Rbid
 		rbType := rbMessage.Type
-		RbMutex.Lock()
-		if _, in := RbChannel[rbType][rbid]; !in {
-			RbChannel[rbType][rbid] = make(chan struct {
+		if rbid < 0 || (rbType != "VC" && rbType != "MVC") || !msgr.isServer(rbMessage.Process) {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(rbid, rbDepth(rbType))
+		msgr.RbMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.RbMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.RbChannel[rbType][rbid]; !in {
+			msgr.RbChannel[rbType][rbid] = make(chan struct {
 				RbMessage types.RbMessage
 				From      int
 			})
 		}
-		msgChannel := RbChannel[rbType][rbid]
-		RbMutex.Unlock()
+		msgChannel := msgr.RbChannel[rbType][rbid]
+		msgr.RbMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			RbMessage types.RbMessage
 			From      int
-		}{RbMessage: *rbMessage, From: message.From}
+		}{RbMessage: *rbMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "RB_ABC":
 		rbMessage := new(types.RbMessage)
@@ -680,10 +958,14 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&rbMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
+		}
+
+		if rbMessage.Rbid < 0 || !msgr.isServer(rbMessage.Process) {
+			return malformed(message.From, message.Type, errInvalidInstance)
 		}
 
-		RbAbcChannel <- struct {
+		msgr.RbAbcChannel <- struct {
 			RbMessage types.RbMessage
 			From      int
 		}{RbMessage: *rbMessage, From: message.From}
@@ -694,24 +976,35 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&mvcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		cid := mvcMessage.Cid
-		MvcMutex.Lock()
-		if _, in := MvcChannel[cid]; !in {
-			MvcChannel[cid] = make(chan struct {
+		if cid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(cid, DepthMVC)
+		msgr.MvcMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.MvcMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.MvcChannel[cid]; !in {
+			msgr.MvcChannel[cid] = make(chan struct {
 				MvcMessage types.MvcMessage
 				From       int
 			})
 		}
-		msgChannel := MvcChannel[cid]
-		MvcMutex.Unlock()
+		msgChannel := msgr.MvcChannel[cid]
+		msgr.MvcMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			MvcMessage types.MvcMessage
 			From       int
-		}{MvcMessage: *mvcMessage, From: message.From}
+		}{MvcMessage: *mvcMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "VC":
 		vcMessage := new(types.VcMessage)
@@ -719,24 +1012,35 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&vcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		vcid := vcMessage.Vcid
-		VcMutex.Lock()
-		if _, in := VcChannel[vcid]; !in {
-			VcChannel[vcid] = make(chan struct {
+		if vcid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(vcid, DepthVC)
+		msgr.VcMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.VcMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.VcChannel[vcid]; !in {
+			msgr.VcChannel[vcid] = make(chan struct {
 				VcMessage types.VcMessage
 				From      int
 			})
 		}
-		msgChannel := VcChannel[vcid]
-		VcMutex.Unlock()
+		msgChannel := msgr.VcChannel[vcid]
+		msgr.VcMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			VcMessage types.VcMessage
 			From      int
-		}{VcMessage: *vcMessage, From: message.From}
+		}{VcMessage: *vcMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "ABC":
 		abcMessage := new(types.AbcMessage)
@@ -744,38 +1048,63 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&abcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
-		AbcChannel <- struct {
+		msgr.AbcChannel <- struct {
 			AbcMessage types.AbcMessage
 			From       int
 		}{AbcMessage: *abcMessage, From: message.From}
 
+	case "STATE":
+		stateMessage := new(types.StateMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err = dec.Decode(&stateMessage)
+		if err != nil {
+			return malformed(message.From, message.Type, err)
+		}
+
+		msgr.StateChannel <- struct {
+			StateMessage types.StateMessage
+			From         int
+		}{StateMessage: *stateMessage, From: message.From}
+
 	case "SSVC":
 		ssvcMessage := new(types.SSVCMessage)
 		buf := bytes.NewBuffer(message.Payload)
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&ssvcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		ssvcid := ssvcMessage.SSVCid
-		SSVCMutex.Lock()
-		if _, in := SSVCChannel[ssvcid]; !in {
-			SSVCChannel[ssvcid] = make(chan struct {
+		if ssvcid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(ssvcid, DepthVC)
+		msgr.SSVCMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.SSVCMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.SSVCChannel[ssvcid]; !in {
+			msgr.SSVCChannel[ssvcid] = make(chan struct {
 				SSVCMessage types.SSVCMessage
 				From      int
 			})
 		}
-		msgChannel := SSVCChannel[ssvcid]
-		SSVCMutex.Unlock()
+		msgChannel := msgr.SSVCChannel[ssvcid]
+		msgr.SSVCMutex.Unlock()
 
-		msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			SSVCMessage types.SSVCMessage
 			From      int
-		}{SSVCMessage: *ssvcMessage, From: message.From}
+		}{SSVCMessage: *ssvcMessage, From: message.From}:
+		case <-msgr.Done(root):
+		}
 
 	case "SSVCDS": // SSVC decision
 		type ssvcDecision struct{
@@ -787,24 +1116,105 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&vectorMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
 		ssvcid := vectorMessage.SSVCid
-		SSVCDecisionsMutex.Lock()
-		if _, in := SSVCDecisionsChannel[ssvcid]; !in {
-			SSVCDecisionsChannel[ssvcid] = make(chan struct {
+		if ssvcid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		root := RootInstance(ssvcid, DepthVC)
+		msgr.SSVCDecisionsMutex.Lock()
+		if msgr.IsCollected(root) {
+			msgr.SSVCDecisionsMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.SSVCDecisionsChannel[ssvcid]; !in {
+			msgr.SSVCDecisionsChannel[ssvcid] = make(chan struct {
 				Vector map[int][]byte
 				From      int
 			})
 		}
-		msgChannel := SSVCDecisionsChannel[ssvcid]
-		SSVCDecisionsMutex.Unlock()
+		msgChannel := msgr.SSVCDecisionsChannel[ssvcid]
+		msgr.SSVCDecisionsMutex.Unlock()
 
-		 msgChannel <- struct {
+		select {
+		case msgChannel <- struct {
 			Vector map[int][]byte
 			From      int
-		}{Vector: vectorMessage.Vector, From: message.From}
+		}{Vector: vectorMessage.Vector, From: message.From}:
+		case <-msgr.Done(root):
+		}
+
+	case "SSMVC":
+		ssmvcMessage := new(types.SSMVCMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err = dec.Decode(&ssmvcMessage)
+		if err != nil {
+			return malformed(message.From, message.Type, err)
+		}
+
+		ssmvcid := ssmvcMessage.SSMVCid
+		if ssmvcid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		msgr.SSMVCMutex.Lock()
+		if msgr.IsCollected(ssmvcid) {
+			msgr.SSMVCMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.SSMVCChannel[ssmvcid]; !in {
+			msgr.SSMVCChannel[ssmvcid] = make(chan struct {
+				SSMVCMessage types.SSMVCMessage
+				From         int
+			}, SSGossipBuffer)
+		}
+		msgChannel := msgr.SSMVCChannel[ssmvcid]
+		msgr.SSMVCMutex.Unlock()
+
+		select {
+		case msgChannel <- struct {
+			SSMVCMessage types.SSMVCMessage
+			From         int
+		}{SSMVCMessage: *ssmvcMessage, From: message.From}:
+		default: // Dropped, the sender gossips its state again
+		}
+
+	case "SSBC":
+		ssbcMessage := new(types.SSBCMessage)
+		buf := bytes.NewBuffer(message.Payload)
+		dec := gob.NewDecoder(buf)
+		err = dec.Decode(&ssbcMessage)
+		if err != nil {
+			return malformed(message.From, message.Type, err)
+		}
+
+		ssbcid := ssbcMessage.SSBCid
+		if ssbcid < 0 {
+			return malformed(message.From, message.Type, errInvalidInstance)
+		}
+		msgr.SSBCMutex.Lock()
+		if msgr.IsCollected(ssbcid) {
+			msgr.SSBCMutex.Unlock()
+			return nil // Late message of a collected instance
+		}
+		if _, in := msgr.SSBCChannel[ssbcid]; !in {
+			msgr.SSBCChannel[ssbcid] = make(chan struct {
+				SSBCMessage types.SSBCMessage
+				From        int
+			}, SSGossipBuffer)
+		}
+		msgChannel := msgr.SSBCChannel[ssbcid]
+		msgr.SSBCMutex.Unlock()
+
+		select {
+		case msgChannel <- struct {
+			SSBCMessage types.SSBCMessage
+			From        int
+		}{SSBCMessage: *ssbcMessage, From: message.From}:
+		default: // Dropped, the sender gossips its state again
+		}
 
 	case "SSABC":
 		ssabcMessage := new(types.SSABCMessage)
@@ -812,34 +1222,51 @@ func HandleMessage(msg []byte) {
 		dec := gob.NewDecoder(buf)
 		err = dec.Decode(&ssabcMessage)
 		if err != nil {
-			logger.ErrLogger.Fatal(err)
+			return malformed(message.From, message.Type, err)
 		}
 
-		SSABCChannel <- struct {
+		msgr.SSABCChannel <- struct {
 			SSABCMessage types.SSABCMessage
 			From      int
 		}{SSABCMessage: *ssabcMessage, From: message.From}
 
+	default:
+		return malformed(message.From, message.Type, errors.New("unknown message type"))
 	} // switch end
+
+	return nil
 }
 
 // ReplyClient - Sends back a response to the client
-func ReplyClient(reply types.Reply, to int) {
+func (msgr *Messenger) ReplyClient(reply types.Reply, to int) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(reply)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		msgr.log.Error("dropped reply", "client", to, "err", err)
+		return
 	}
 
-	_, err = ResponseSockets[to].SendBytes(w.Bytes(), 0)
+	msgr.ResponseMutex.Lock()
+	socket, in := msgr.ResponseSockets[to]
+	if !in {
+		msgr.ResponseMutex.Unlock()
+		msgr.log.Warn("dropped reply to a process that is not a client", "client", to)
+		return
+	}
+	_, err = socket.SendBytes(w.Bytes(), 0)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		msgr.ResponseMutex.Unlock()
+		msgr.log.Error("reply to client failed", "client", to, "err", err)
+		msgr.reconnectResponseSocket(to)
+		return
 	}
-	logger.OutLogger.Println("REPLIED Client", to, "-", reply.Value)
-
-	variables.MsgMutex.Lock()
-	variables.MsgComplexity++
-	variables.MsgSize += int64(len(w.Bytes()))
-	variables.MsgMutex.Unlock()
+	msgr.ResponseMutex.Unlock()
+	msgr.log.Debug("replied", "client", to, "num", reply.Num)
+
+	msgr.MsgMutex.Lock()
+	msgr.MsgComplexity++
+	msgr.MsgSize += int64(len(w.Bytes()))
+	msgr.MsgMutex.Unlock()
+	msgr.Metrics.sent("REPLY", "client-"+strconv.Itoa(to), len(w.Bytes()))
 }
@@ -0,0 +1,121 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/metrics"
+	"strconv"
+)
+
+/*
+	Every replica keeps its metrics in a registry (see the metrics package),
+	which main serves over HTTP when the replica has a metrics_port in the
+	cluster file. The messenger counts what goes through the links and the
+	client sockets, and the modules add the consensus and delivery metrics to
+	the same Metrics. The peer label is the id of the server, or "client-<id>"
+	for the replies to a client.
+*/
+
+// Metrics - The metrics of a replica
+type Metrics struct {
+	Registry *metrics.Registry
+
+	MessagesSent     *metrics.Counter // type, peer
+	BytesSent        *metrics.Counter // type, peer
+	MessagesReceived *metrics.Counter // type, peer
+	BytesReceived    *metrics.Counter // type, peer
+
+	VerificationFailures *metrics.Counter // peer, kind (signature or mac)
+	Malformed            *metrics.Counter // peer
+	Retransmissions      *metrics.Counter // peer
+	Backpressure         *metrics.Counter // type, peer
+
+	BCRounds        *metrics.Histogram // The rounds each BC instance took to decide
+	DeliveryLatency *metrics.Histogram // protocol (ABC or SSABC): from the broadcast of a value to its delivery
+	RequestLatency  *metrics.Histogram // From the reception of a client request to its execution
+	Flushes         *metrics.Counter   // protocol (SSABC, SSVC, SSMVC or SSBC), scope (all, process, types, vect, round or num)
+	SSABCResets     *metrics.Counter   // cause (agreed by an SSVC instance, or adopted from 2f+1 replicas)
+	Gossip          *metrics.Counter   // protocol (SSVC or SSABC), kind (full, delta, digest or pull)
+}
+
+// newMetrics - Registers the metrics of the messenger
+func newMetrics(msgr *Messenger) *Metrics {
+	r := metrics.NewRegistry()
+	m := &Metrics{
+		Registry: r,
+
+		MessagesSent: r.NewCounter("bft_messages_sent_total",
+			"Messages sent to the other servers and the clients.", "type", "peer"),
+		BytesSent: r.NewCounter("bft_bytes_sent_total",
+			"Bytes of the messages sent to the other servers and the clients.", "type", "peer"),
+		MessagesReceived: r.NewCounter("bft_messages_received_total",
+			"Valid messages received from the other servers.", "type", "peer"),
+		BytesReceived: r.NewCounter("bft_bytes_received_total",
+			"Bytes of the valid messages received from the other servers.", "type", "peer"),
+
+		VerificationFailures: r.NewCounter("bft_verification_failures_total",
+			"Messages and frames whose signature or MAC did not verify.", "peer", "kind"),
+		Malformed: r.NewCounter("bft_malformed_messages_total",
+			"Messages dropped as malformed or invalid, by the server they are attributed to.", "peer"),
+		Retransmissions: r.NewCounter("bft_retransmissions_total",
+			"Messages sent again because they were not acknowledged.", "peer"),
+		Backpressure: r.NewCounter("bft_backpressure_total",
+			"Messages not queued because the queue of a server was full.", "type", "peer"),
+
+		BCRounds: r.NewHistogram("bft_bc_rounds",
+			"The rounds that each BC instance took to decide.", metrics.RoundBuckets),
+		DeliveryLatency: r.NewHistogram("bft_delivery_latency_seconds",
+			"From the broadcast of a value by this replica to its delivery.", metrics.LatencyBuckets, "protocol"),
+		RequestLatency: r.NewHistogram("bft_request_latency_seconds",
+			"From the reception of a client request to its execution.", metrics.LatencyBuckets),
+		Flushes: r.NewCounter("bft_flushes_total",
+			"Message sets emptied by the consistency checks of the self-stabilizing modules.", "protocol", "scope"),
+		SSABCResets: r.NewCounter("bft_ssabc_resets_total",
+			"Global resets of SSABC, each one to the next epoch.", "cause"),
+		Gossip: r.NewCounter("bft_gossip_messages_total",
+			"Messages of the anti-entropy gossip of the self-stabilizing modules, by what they carry.", "protocol", "kind"),
+	}
+
+	r.NewGaugeFunc("bft_link_queue_depth", "Messages that wait to be sent to a server.", []string{"peer"},
+		func(set func(float64, ...string)) {
+			for peer, l := range msgr.allLinks() {
+				set(float64(len(l.queue)), strconv.Itoa(peer))
+			}
+		})
+	r.NewGaugeFunc("bft_link_unacked", "Messages sent to a server that it has not acknowledged yet.",
+		[]string{"peer"}, func(set func(float64, ...string)) {
+			for peer, l := range msgr.allLinks() {
+				l.mutex.Lock()
+				unacked := len(l.unacked)
+				l.mutex.Unlock()
+				set(float64(unacked), strconv.Itoa(peer))
+			}
+		})
+	r.NewGaugeFunc("bft_request_queue_depth", "Client requests that wait to be handled.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(len(msgr.RequestChannel)))
+		})
+	return m
+}
+
+// allLinks - The links to the current servers
+func (msgr *Messenger) allLinks() map[int]*link {
+	msgr.membershipMutex.RLock()
+	defer msgr.membershipMutex.RUnlock()
+
+	links := make(map[int]*link, len(msgr.links))
+	for peer, l := range msgr.links {
+		links[peer] = l
+	}
+	return links
+}
+
+// sent - Counts a message sent to peer
+func (m *Metrics) sent(Type string, peer string, size int) {
+	m.MessagesSent.Inc(Type, peer)
+	m.BytesSent.Add(float64(size), Type, peer)
+}
+
+// received - Counts a valid message received from server peer
+func (m *Metrics) received(Type string, peer int, size int) {
+	m.MessagesReceived.Inc(Type, strconv.Itoa(peer))
+	m.BytesReceived.Add(float64(size), Type, strconv.Itoa(peer))
+}
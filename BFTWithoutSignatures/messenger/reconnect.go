@@ -0,0 +1,79 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/logger"
+	"time"
+
+	"github.com/pebbe/zmq4"
+)
+
+// newServerSocket - Creates the socket that gets the requests of client i
+func (msgr *Messenger) newServerSocket(i int) (*zmq4.Socket, error) {
+	return newSocket(msgr.Context, zmq4.REP, msgr.addresses.Server[i], true)
+}
+
+// newResponseSocket - Creates the socket that publishes the responses to client i
+func (msgr *Messenger) newResponseSocket(i int) (*zmq4.Socket, error) {
+	return newSocket(msgr.Context, zmq4.PUB, msgr.addresses.Response[i], true)
+}
+
+// reconnectServerSocket - Replaces the socket of client i after an error (a REP socket that failed
+// in the middle of a request/reply cycle cannot be used again)
+func (msgr *Messenger) reconnectServerSocket(i int) {
+	msgr.ServerSockets[i].Close()
+	msgr.ServerSockets[i] = retrySocket(msgr.log.With("client", i), nil, func() (*zmq4.Socket, error) {
+		return msgr.newServerSocket(i)
+	})
+}
+
+// reconnectResponseSocket - Replaces the response socket of client i after an error
+func (msgr *Messenger) reconnectResponseSocket(i int) {
+	msgr.ResponseMutex.Lock()
+	defer msgr.ResponseMutex.Unlock()
+
+	msgr.ResponseSockets[i].Close()
+	msgr.ResponseSockets[i] = retrySocket(msgr.log.With("client", i), nil, func() (*zmq4.Socket, error) {
+		return msgr.newResponseSocket(i)
+	})
+}
+
+// newSocket - Creates a socket of the given type and binds it to (or connects it to) address
+func newSocket(context *zmq4.Context, t zmq4.Type, address string, bind bool) (*zmq4.Socket, error) {
+	socket, err := context.NewSocket(t)
+	if err != nil {
+		return nil, err
+	}
+	socket.SetLinger(0) // Pending messages of a broken socket are dropped when it is closed
+
+	if bind {
+		err = socket.Bind(address)
+	} else {
+		err = socket.Connect(address)
+	}
+	if err != nil {
+		socket.Close()
+		return nil, err
+	}
+	return socket, nil
+}
+
+// retrySocket - Calls create until it returns a socket, waiting longer after every failure
+// (returns nil if stop is closed meanwhile); log has the fields of the peer of the socket
+func retrySocket(log *logger.Logger, stop <-chan struct{}, create func() (*zmq4.Socket, error)) *zmq4.Socket {
+	delay := reconnectDelay
+	for {
+		socket, err := create()
+		if err == nil {
+			log.Info("reconnected")
+			return socket
+		}
+		log.Warn("reconnect failed", "err", err)
+
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return nil
+		}
+		delay = nextDelay(delay)
+	}
+}
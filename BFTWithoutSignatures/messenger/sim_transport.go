@@ -0,0 +1,495 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+	SimNetwork is an in-process network whose links delay, lose, duplicate
+	and reorder the frames, and can be partitioned. Every decision it takes
+	on a frame is drawn from a generator seeded with the seed of the network,
+	the link and the frame (its sequence number and how many times it has
+	been sent), not from the order in which the goroutines of the replicas
+	send: two runs with the same seed take the same decision on every frame
+	that both of them send. A run is not replayed, though: the replicas are
+	scheduled by the Go runtime and run on real timers, so they do not send
+	the same frames at the same times in every run, and a failing seed only
+	makes the failure likelier to come back. Every send and every delivery
+	is recorded in the trace, which tells what happened in a run.
+*/
+
+// Distribution - Draws the latency of a frame
+type Distribution func(rnd *rand.Rand) time.Duration
+
+// Constant - Every frame takes d
+func Constant(d time.Duration) Distribution {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// Uniform - A latency between min and max
+func Uniform(min time.Duration, max time.Duration) Distribution {
+	return func(rnd *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rnd.Int63n(int64(max-min)+1))
+	}
+}
+
+// Exponential - A latency with the given mean, mostly short with a long tail
+func Exponential(mean time.Duration) Distribution {
+	return func(rnd *rand.Rand) time.Duration {
+		return time.Duration(rnd.ExpFloat64() * float64(mean))
+	}
+}
+
+// Normal - A latency around mean (never negative)
+func Normal(mean time.Duration, stddev time.Duration) Distribution {
+	return func(rnd *rand.Rand) time.Duration {
+		return time.Duration(math.Max(0, rnd.NormFloat64()*float64(stddev)+float64(mean)))
+	}
+}
+
+// SimLink - How a link of a SimNetwork treats the frames (the zero value delivers them at once)
+type SimLink struct {
+	Latency      Distribution // nil for no latency
+	Drop         float64      // Probability that a frame is lost
+	Duplicate    float64      // Probability that a frame is delivered twice
+	Reorder      float64      // Probability that a frame is held back behind the ones sent after it
+	ReorderDelay time.Duration
+}
+
+// DefaultReorderDelay - How long a reordered frame is held back at most, if its link does not say
+const DefaultReorderDelay = 20 * time.Millisecond
+
+// SimEventKind - What happened to a frame
+type SimEventKind int
+
+// Kinds of the events of the trace
+const (
+	SimSend        SimEventKind = iota // Sent, and scheduled to be delivered after Delay
+	SimDuplicate                       // A second copy scheduled to be delivered after Delay
+	SimDrop                            // Lost
+	SimPartitioned                     // Lost, because the servers are in different partitions
+	SimDeliver                         // Delivered to the receiver
+)
+
+var simEventNames = []string{"send", "duplicate", "drop", "partitioned", "deliver"}
+
+func (k SimEventKind) String() string {
+	if k < SimSend || k > SimDeliver {
+		return fmt.Sprint("kind(", int(k), ")")
+	}
+	return simEventNames[k]
+}
+
+// SimEvent - An entry of the trace of a SimNetwork
+type SimEvent struct {
+	At      time.Duration // Since the network was created
+	Kind    SimEventKind
+	From    int
+	To      int
+	Seq     uint64 // Of the frame (0 for a bare acknowledgement or a handshake)
+	Ack     uint64
+	Attempt int    // How many times the frame had been sent before
+	Type    string // Of the message in the frame, if any
+	Size    int
+	Delay   time.Duration // Until the delivery (SimSend and SimDuplicate)
+}
+
+func (e SimEvent) String() string {
+	s := fmt.Sprintf("%12s %-11s %d->%d seq=%d/%d ack=%d size=%d", e.At, e.Kind, e.From, e.To, e.Seq,
+		e.Attempt, e.Ack, e.Size)
+	if e.Type != "" {
+		s += " type=" + e.Type
+	}
+	if e.Kind == SimSend || e.Kind == SimDuplicate {
+		s += fmt.Sprint(" delay=", e.Delay)
+	}
+	return s
+}
+
+// SimNetwork - An in-process network between N servers with simulated faults
+type SimNetwork struct {
+	n     int
+	seed  int64
+	start time.Time
+
+	mutex     sync.Mutex
+	settings  map[int]map[int]SimLink // from, to
+	partition map[int]int             // server -> its group (nil when the network is whole)
+	attempts  map[int]map[int]map[uint64]int
+	trace     []SimEvent
+
+	links map[int]map[int]*simLink // to, from
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewSimNetwork - Creates a simulated network between n servers, whose decisions derive from seed
+func NewSimNetwork(n int, seed int64) *SimNetwork {
+	network := &SimNetwork{
+		n:        n,
+		seed:     seed,
+		start:    time.Now(),
+		settings: make(map[int]map[int]SimLink, n),
+		attempts: make(map[int]map[int]map[uint64]int, n),
+		links:    make(map[int]map[int]*simLink, n),
+		done:     make(chan struct{}),
+	}
+	for i := 0; i < n; i++ {
+		network.settings[i] = make(map[int]SimLink, n)
+		network.attempts[i] = make(map[int]map[uint64]int, n)
+		network.links[i] = make(map[int]*simLink, n)
+	}
+	for to := 0; to < n; to++ {
+		for from := 0; from < n; from++ {
+			if from == to {
+				continue // Not myself
+			}
+			network.attempts[from][to] = make(map[uint64]int)
+			l := &simLink{network: network, out: make(chan []byte), wake: make(chan struct{}, 1)}
+			network.links[to][from] = l
+			go l.run()
+		}
+	}
+	return network
+}
+
+// Seed - The seed that the decisions of the network derive from
+func (network *SimNetwork) Seed() int64 {
+	return network.seed
+}
+
+// SetLink - Sets how the link from server `from` to server `to` treats the frames
+func (network *SimNetwork) SetLink(from int, to int, link SimLink) {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+
+	network.settings[from][to] = link
+}
+
+// SetLinks - Sets how every link treats the frames
+func (network *SimNetwork) SetLinks(link SimLink) {
+	for from := 0; from < network.n; from++ {
+		for to := 0; to < network.n; to++ {
+			if from != to {
+				network.SetLink(from, to, link)
+			}
+		}
+	}
+}
+
+// Partition - Splits the servers into groups that cannot reach each other (a server in no group is
+// cut off from all the others) until Heal
+func (network *SimNetwork) Partition(groups ...[]int) {
+	partition := make(map[int]int, network.n)
+	for i := 0; i < network.n; i++ {
+		partition[i] = -1 - i
+	}
+	for g, group := range groups {
+		for _, i := range group {
+			partition[i] = g
+		}
+	}
+
+	network.mutex.Lock()
+	network.partition = partition
+	network.mutex.Unlock()
+}
+
+// Heal - Reconnects the servers of a partition
+func (network *SimNetwork) Heal() {
+	network.mutex.Lock()
+	network.partition = nil
+	network.mutex.Unlock()
+}
+
+// Trace - The events so far, in the order they happened
+func (network *SimNetwork) Trace() []SimEvent {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+
+	return append([]SimEvent(nil), network.trace...)
+}
+
+// WriteTrace - Writes the trace, one event per line, after the seed
+func (network *SimNetwork) WriteTrace(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "seed %d\n", network.seed); err != nil {
+		return err
+	}
+	for _, e := range network.Trace() {
+		if _, err := fmt.Fprintln(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transport - Returns the transport of server id in the network
+func (network *SimNetwork) Transport(id int) Transport {
+	return &simTransport{id: id, network: network}
+}
+
+// Close - Shuts down the network (the frames in flight are lost and the sends fail)
+func (network *SimNetwork) Close() {
+	network.once.Do(func() {
+		close(network.done)
+	})
+}
+
+// record - Appends an event to the trace (with the mutex held)
+func (network *SimNetwork) record(e SimEvent) {
+	e.At = time.Since(network.start)
+	network.trace = append(network.trace, e)
+}
+
+// send - Decides what happens to a frame from server `from` to server `to`, and schedules its deliveries
+func (network *SimNetwork) send(from int, to int, data []byte) error {
+	l, in := network.links[to][from]
+	if !in {
+		return errors.New("simulated network: no link to server")
+	}
+	select {
+	case <-network.done:
+		return ErrNetworkClosed
+	default:
+	}
+
+	e := SimEvent{Kind: SimSend, From: from, To: to, Size: len(data)}
+	if frame, err := DecodeFrame(data); err == nil {
+		e.Seq, e.Ack = frame.Seq, frame.Ack
+		e.Type = messageType(frame.Payload)
+	}
+
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+
+	if e.Seq != 0 {
+		e.Attempt = network.attempts[from][to][e.Seq]
+		network.attempts[from][to][e.Seq]++
+	} else {
+		e.Attempt = network.attempts[from][to][0] // The frames without a sequence number, in turn
+		network.attempts[from][to][0]++
+	}
+	rnd := rand.New(newSplitMix(network.seed, from, to, e.Seq, e.Attempt))
+	settings := network.settings[from][to]
+
+	// The draws are always made in the same order, so that a setting does not change the others
+	drop := rnd.Float64() < settings.Drop
+	duplicate := rnd.Float64() < settings.Duplicate
+	reorder := rnd.Float64() < settings.Reorder
+	delays := [2]time.Duration{}
+	for k := range delays {
+		if settings.Latency != nil {
+			delays[k] = settings.Latency(rnd)
+		}
+		if reorder {
+			hold := settings.ReorderDelay
+			if hold <= 0 {
+				hold = DefaultReorderDelay
+			}
+			delays[k] += time.Duration(rnd.Int63n(int64(hold) + 1))
+		}
+	}
+
+	if network.partition != nil && network.partition[from] != network.partition[to] {
+		e.Kind = SimPartitioned
+		network.record(e)
+		return nil
+	}
+	if drop {
+		e.Kind = SimDrop
+		network.record(e)
+		return nil
+	}
+
+	e.Delay = delays[0]
+	network.record(e)
+	l.schedule(data, e)
+	if duplicate {
+		e.Kind, e.Delay = SimDuplicate, delays[1]
+		network.record(e)
+		l.schedule(data, e)
+	}
+	return nil
+}
+
+// messageType - The type of the encoded message in a frame ("" if it cannot be decoded)
+func messageType(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	message := new(types.Message)
+	if gob.NewDecoder(bytes.NewBuffer(payload)).Decode(message) != nil {
+		return ""
+	}
+	return message.Type
+}
+
+// newSplitMix - A generator seeded with the seed of the network and a frame of a link
+func newSplitMix(seed int64, from int, to int, seq uint64, attempt int) *splitMix {
+	s := splitMix(uint64(seed))
+	for _, v := range []uint64{uint64(from), uint64(to), seq, uint64(attempt)} {
+		s = splitMix(s.Uint64() ^ v)
+	}
+	return &s
+}
+
+// splitMix - The SplitMix64 generator (cheap to seed, as one is seeded for every frame)
+type splitMix uint64
+
+func (s *splitMix) Uint64() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 { return int64(s.Uint64() >> 1) }
+
+func (s *splitMix) Seed(seed int64) { *s = splitMix(seed) }
+
+/* -------------------------------- Links -------------------------------- */
+
+// simLink - The frames in flight from a server to another, delivered in the order of their time
+type simLink struct {
+	network *SimNetwork
+	mutex   sync.Mutex
+	flight  flightHeap
+	order   uint64        // Breaks the ties between frames due at the same time
+	out     chan []byte   // Receive of the transport
+	wake    chan struct{} // Signals run that a frame is in flight
+}
+
+// flight - A frame in flight
+type flight struct {
+	due   time.Time
+	order uint64
+	data  []byte
+	event SimEvent
+}
+
+// schedule - Puts a copy of a frame in flight (the mutex of the network is held)
+func (l *simLink) schedule(data []byte, e SimEvent) {
+	copied := make([]byte, len(data))
+	copy(copied, data)
+
+	l.mutex.Lock()
+	l.order++
+	heap.Push(&l.flight, &flight{due: time.Now().Add(e.Delay), order: l.order, data: copied, event: e})
+	l.mutex.Unlock()
+	signal(l.wake)
+}
+
+// run - Delivers the frames when they are due [go started by NewSimNetwork]
+func (l *simLink) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		l.mutex.Lock()
+		var next *flight
+		if len(l.flight) > 0 {
+			next = l.flight[0]
+		}
+		l.mutex.Unlock()
+
+		if next == nil {
+			select {
+			case <-l.wake:
+				continue
+			case <-l.network.done:
+				return
+			}
+		}
+		if wait := time.Until(next.due); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-l.wake: // An earlier frame may have been scheduled
+				if !timer.Stop() {
+					<-timer.C
+				}
+				continue
+			case <-l.network.done:
+				return
+			}
+		}
+
+		l.mutex.Lock()
+		heap.Remove(&l.flight, 0)
+		l.mutex.Unlock()
+
+		select {
+		case l.out <- next.data:
+		case <-l.network.done:
+			return
+		}
+		e := next.event
+		e.Kind, e.Delay = SimDeliver, 0
+		l.network.mutex.Lock()
+		l.network.record(e)
+		l.network.mutex.Unlock()
+	}
+}
+
+// flightHeap - The frames in flight, the next one due first
+type flightHeap []*flight
+
+func (h flightHeap) Len() int { return len(h) }
+
+func (h flightHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].order < h[j].order
+	}
+	return h[i].due.Before(h[j].due)
+}
+
+func (h flightHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *flightHeap) Push(x interface{}) { *h = append(*h, x.(*flight)) }
+
+func (h *flightHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+/* -------------------------------- Transport -------------------------------- */
+
+// simTransport - The endpoint of a server in a SimNetwork
+type simTransport struct {
+	id      int
+	network *SimNetwork
+}
+
+// Send - Hands the frame to the network, which decides when (and whether) it is delivered
+func (t *simTransport) Send(to int, message []byte) error {
+	return t.network.send(t.id, to, message)
+}
+
+// Receive - Returns the channel with the frames delivered from server `from`
+func (t *simTransport) Receive(from int) <-chan []byte {
+	l, in := t.network.links[t.id][from]
+	if !in {
+		return nil
+	}
+	return l.out
+}
+
+// Close - Closes the whole network, as every server runs in the same process
+func (t *simTransport) Close() {
+	t.network.Close()
+}
@@ -0,0 +1,30 @@
+package messenger
+
+// Transport - The link layer that moves encoded frames between the servers. It may lose, duplicate
+// or reorder them (the links of the messenger retransmit them until they are acknowledged).
+type Transport interface {
+	// Send - Transmits an encoded frame to server `to` (may block while the link is congested)
+	Send(to int, message []byte) error
+
+	// Receive - Returns the channel in which the encoded messages of server `from` arrive
+	Receive(from int) <-chan []byte
+
+	// Close - Stops the transport and releases its resources
+	Close()
+}
+
+// InitializeTransport - Sets the link layer between the servers and initializes the message channels
+// (and, in HMAC mode, the ephemeral keys of the links)
+func (msgr *Messenger) InitializeTransport(t Transport) {
+	msgr.transport = t
+	for i := 0; i < msgr.N; i++ {
+		if i == msgr.ID {
+			continue // Not myself
+		}
+		l := msgr.newLink(i, msgr.Keys)
+		msgr.MessageChannel[i] = l.queue
+		msgr.links[i] = l
+	}
+
+	msgr.initRBChannels()
+}
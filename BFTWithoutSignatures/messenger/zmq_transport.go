@@ -0,0 +1,237 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/variables"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/pebbe/zmq4"
+)
+
+// zmqTransport - Transport over 0MQ DEALER/ROUTER socket pairs (one pair per server). Frames are
+// pipelined without waiting for a reply, and a DEALER reconnects by itself when its peer restarts.
+type zmqTransport struct {
+	context   *zmq4.Context
+	addresses config.Addresses
+
+	// sendSockets - Send messages to other servers (DEALER)
+	sendSockets map[int]*zmq4.Socket
+
+	// receiveSockets - Receive messages from other servers (ROUTER)
+	receiveSockets map[int]*zmq4.Socket
+
+	// Guards the sockets, the addresses and incoming (a broken socket is replaced while Close may run,
+	// and the servers may change, see Connect)
+	mutex sync.Mutex
+
+	// incoming - Channels to put the messages received from each server in
+	incoming map[int]chan []byte
+
+	// closed - Closed when the transport is stopped (the broken links are not rebuilt anymore)
+	closed chan struct{}
+	once   sync.Once
+
+	log *logger.Logger
+}
+
+// NewZMQTransport - Creates the 0MQ sockets between this server and the other servers
+func NewZMQTransport(context *zmq4.Context, r variables.Replica, addresses config.Addresses) Transport {
+	t := &zmqTransport{
+		context:        context,
+		addresses:      addresses,
+		sendSockets:    make(map[int]*zmq4.Socket),
+		receiveSockets: make(map[int]*zmq4.Socket),
+		incoming:       make(map[int]chan []byte),
+		closed:         make(chan struct{}),
+		log:            logger.Module("transport").With("replica", r.ID),
+	}
+
+	var err error
+	for i := 0; i < r.N; i++ {
+		if i == r.ID {
+			continue // Not myself
+		}
+
+		// receiveSockets initialization to get information from other servers
+		t.receiveSockets[i], err = newSocket(context, zmq4.ROUTER, addresses.Rep[i], true)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+		t.log.Info("receive from server", "peer", i, "address", addresses.Rep[i])
+
+		// sendSockets initialization to send information to other servers
+		t.sendSockets[i], err = newSocket(context, zmq4.DEALER, addresses.Req[i], false)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+		t.log.Info("send to server", "peer", i, "address", addresses.Req[i])
+
+		t.incoming[i] = make(chan []byte)
+		go t.receive(i)
+	}
+
+	return t
+}
+
+// Send - Queues the frame in the DEALER socket without blocking. It fails when the queue of the
+// socket is full (e.g. while the peer is unreachable); the links retransmit the frame later.
+func (t *zmqTransport) Send(to int, message []byte) error {
+	if t.isClosed() {
+		return ErrNetworkClosed
+	}
+
+	t.mutex.Lock()
+	socket, in := t.sendSockets[to]
+	t.mutex.Unlock()
+	if !in {
+		return errors.New("zmq transport: no link to server " + strconv.Itoa(to))
+	}
+	_, err := socket.SendBytes(message, zmq4.DONTWAIT)
+	return err
+}
+
+// Receive - Returns the channel with the messages received from server `from`
+func (t *zmqTransport) Receive(from int) <-chan []byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.incoming[from]
+}
+
+// Connect - Creates the socket pair of a server that joins (or replaces the pair of a server that
+// moved). The channel of its frames is kept, so Receive still returns the same one.
+func (t *zmqTransport) Connect(peer int, rep string, req string) error {
+	t.Disconnect(peer)
+
+	receiveSocket, err := newSocket(t.context, zmq4.ROUTER, rep, true)
+	if err != nil {
+		return err
+	}
+	sendSocket, err := newSocket(t.context, zmq4.DEALER, req, false)
+	if err != nil {
+		receiveSocket.Close()
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isClosed() {
+		receiveSocket.Close()
+		sendSocket.Close()
+		return ErrNetworkClosed
+	}
+	t.addresses.Rep[peer] = rep
+	t.addresses.Req[peer] = req
+	t.receiveSockets[peer] = receiveSocket
+	t.sendSockets[peer] = sendSocket
+	if _, in := t.incoming[peer]; !in {
+		t.incoming[peer] = make(chan []byte)
+	}
+	t.log.Info("receive from server", "peer", peer, "address", rep)
+	t.log.Info("send to server", "peer", peer, "address", req)
+
+	go t.receive(peer)
+	return nil
+}
+
+// Disconnect - Closes the socket pair of a server that leaves
+func (t *zmqTransport) Disconnect(peer int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if socket, in := t.receiveSockets[peer]; in {
+		delete(t.receiveSockets, peer)
+		socket.Close()
+	}
+	if socket, in := t.sendSockets[peer]; in {
+		delete(t.sendSockets, peer)
+		socket.Close()
+	}
+}
+
+// Close - Closes every socket pair
+func (t *zmqTransport) Close() {
+	t.once.Do(func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+
+		close(t.closed)
+		for i := range t.sendSockets {
+			t.sendSockets[i].Close()
+		}
+		for i := range t.receiveSockets {
+			t.receiveSockets[i].Close()
+		}
+	})
+}
+
+// replace - Puts the rebuilt socket of link i in sockets, unless the transport has been closed meanwhile
+func (t *zmqTransport) replace(sockets map[int]*zmq4.Socket, i int, socket *zmq4.Socket) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isClosed() {
+		if socket != nil {
+			socket.Close()
+		}
+		return false
+	}
+	sockets[i] = socket
+	return true
+}
+
+// current - Whether socket is still the receiving socket of server peer
+func (t *zmqTransport) current(peer int, socket *zmq4.Socket) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.receiveSockets[peer] == socket
+}
+
+// isClosed - Whether the transport has been stopped
+func (t *zmqTransport) isClosed() bool {
+	select {
+	case <-t.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Gets the frames of server `from` [go started from NewZMQTransport]
+func (t *zmqTransport) receive(from int) {
+	t.mutex.Lock()
+	socket := t.receiveSockets[from]
+	incoming := t.incoming[from]
+	address := t.addresses.Rep[from]
+	t.mutex.Unlock()
+
+	for {
+		parts, err := socket.RecvMessageBytes(0)
+		if err == nil && len(parts) == 2 { // The identity of the DEALER and the frame
+			select {
+			case incoming <- parts[1]:
+			case <-t.closed:
+				return
+			}
+		}
+
+		if err != nil {
+			if t.isClosed() || !t.current(from, socket) {
+				return // Disconnected (or connected again by another goroutine)
+			}
+			t.log.Error("receive from server failed", "peer", from, "err", err)
+			socket.Close()
+			socket = retrySocket(t.log.With("peer", from), t.closed, func() (*zmq4.Socket, error) {
+				return newSocket(t.context, zmq4.ROUTER, address, true)
+			})
+			if !t.replace(t.receiveSockets, from, socket) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	A Registry keeps counters, gauges and histograms, each with a fixed set of
+	label names, and writes them in the Prometheus text exposition format
+	(version 0.0.4). A metric is registered once, and a series is created the
+	first time it is updated with its label values. The values that are only
+	known when the metrics are read (e.g. the depth of a queue) are gauges
+	whose function is called on every scrape. Listen serves the registry on
+	/metrics.
+*/
+
+// ContentType - The content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default buckets of the histograms
+var (
+	LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	RoundBuckets   = []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20}
+)
+
+// Registry - A set of metrics
+type Registry struct {
+	mutex    sync.Mutex
+	families []family // In the order they were registered
+	names    map[string]bool
+}
+
+// family - A metric and its series
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry - Creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register - Adds a family, and panics if its name is taken (a programming error)
+func (r *Registry) register(name string, f family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.names[name] {
+		panic("metrics: " + name + " is registered twice")
+	}
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// WriteText - Writes every metric in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	families := append([]family(nil), r.families...)
+	r.mutex.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(b)
+	}
+	return b.Flush()
+}
+
+// ServeHTTP - Writes the metrics as the response
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// Listen - Serves the registry on http://addr/metrics until the returned listener is closed
+func (r *Registry) Listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	go http.Serve(listener, mux)
+	return listener, nil
+}
+
+/* -------------------------------- Series -------------------------------- */
+
+// vec - The series of a metric, by label values
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+// series - The values of one combination of label values
+type series struct {
+	values []string
+	value  float64
+
+	buckets []uint64 // Only for histograms (not cumulative)
+	count   uint64
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+}
+
+// get - The series of the label values, created if it does not exist (the mutex must be held)
+func (v *vec) get(values []string, buckets int) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, in := v.series[key]
+	if !in {
+		s = &series{values: append([]string(nil), values...)}
+		if buckets > 0 {
+			s.buckets = make([]uint64, buckets)
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted - The series in the order of their label values (the mutex must be held)
+func (v *vec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, len(keys))
+	for k, key := range keys {
+		sorted[k] = v.series[key]
+	}
+	return sorted
+}
+
+// header - Writes the HELP and TYPE lines of the metric
+func (v *vec) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+// sample - Writes one line of the metric (extra is a label added to the ones of the series, e.g. le)
+func (v *vec) sample(w *bufio.Writer, suffix string, values []string, extra string, value float64) {
+	w.WriteString(v.name + suffix)
+	pairs := make([]string, 0, len(values)+1)
+	for k, l := range v.labels {
+		pairs = append(pairs, l+"="+quote(values[k]))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+// quote - A label value, escaped as the exposition format requires
+func quote(value string) string {
+	return `"` + strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// formatValue - A sample value in the exposition format
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+/* -------------------------------- Counter -------------------------------- */
+
+// Counter - A value that only increases (e.g. the messages sent)
+type Counter struct {
+	vec
+}
+
+// NewCounter - Registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+// Add - Adds a (non negative) value to the series of the label values
+func (c *Counter) Add(value float64, values ...string) {
+	if value < 0 {
+		panic("metrics: " + c.name + " cannot decrease")
+	}
+	c.mutex.Lock()
+	c.get(values, 0).value += value
+	c.mutex.Unlock()
+}
+
+// Inc - Adds 1 to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Value - The value of the series of the label values (0 if it has not been updated)
+func (c *Counter) Value(values ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.get(values, 0).value
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header(w)
+	for _, s := range c.sorted() {
+		c.sample(w, "", s.values, "", s.value)
+	}
+}
+
+/* -------------------------------- Gauge -------------------------------- */
+
+// Gauge - A value that goes up and down (e.g. the depth of a queue)
+type Gauge struct {
+	vec
+	collect func(set func(value float64, values ...string)) // Called on every scrape, if not nil
+}
+
+// NewGauge - Registers a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels)}
+	r.register(name, g)
+	return g
+}
+
+// NewGaugeFunc - Registers a gauge whose series are set by collect when the metrics are read (the
+// series that collect does not set are not written)
+func (r *Registry) NewGaugeFunc(name, help string, labels []string,
+	collect func(set func(value float64, values ...string))) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels), collect: collect}
+	r.register(name, g)
+	return g
+}
+
+// Set - Sets the series of the label values
+func (g *Gauge) Set(value float64, values ...string) {
+	g.mutex.Lock()
+	g.get(values, 0).value = value
+	g.mutex.Unlock()
+}
+
+// Add - Adds a (possibly negative) value to the series of the label values
+func (g *Gauge) Add(value float64, values ...string) {
+	g.mutex.Lock()
+	g.get(values, 0).value += value
+	g.mutex.Unlock()
+}
+
+// Value - The value of the series of the label values (collected again for a gauge function)
+func (g *Gauge) Value(values ...string) float64 {
+	g.refresh()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.get(values, 0).value
+}
+
+// refresh - Replaces the series with the ones that the function of the gauge sets
+func (g *Gauge) refresh() {
+	if g.collect == nil {
+		return
+	}
+	collected := make(map[string]*series)
+	g.collect(func(value float64, values ...string) {
+		if len(values) != len(g.labels) {
+			panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", g.name, len(g.labels), len(values)))
+		}
+		collected[strings.Join(values, "\xff")] = &series{values: append([]string(nil), values...), value: value}
+	})
+
+	g.mutex.Lock()
+	g.series = collected
+	g.mutex.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.refresh()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.header(w)
+	for _, s := range g.sorted() {
+		g.sample(w, "", s.values, "", s.value)
+	}
+}
+
+/* -------------------------------- Histogram -------------------------------- */
+
+// Histogram - Counts the observed values in buckets (e.g. the latencies)
+type Histogram struct {
+	vec
+	bounds []float64 // The upper bounds of the buckets, increasing (+Inf is implicit)
+}
+
+// NewHistogram - Registers a histogram with the given bucket bounds and label names
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	h := &Histogram{vec: newVec(name, help, "histogram", labels), bounds: bounds}
+	r.register(name, h)
+	return h
+}
+
+// Observe - Adds a value to the series of the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	k := sort.SearchFloat64s(h.bounds, value) // The first bucket whose bound is >= value
+
+	h.mutex.Lock()
+	s := h.get(values, len(h.bounds)+1)
+	s.buckets[k]++
+	s.count++
+	s.value += value
+	h.mutex.Unlock()
+}
+
+// Count - How many values the series of the label values has observed
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.get(values, len(h.bounds)+1).count
+}
+
+// Sum - The sum of the values that the series of the label values has observed
+func (h *Histogram) Sum(values ...string) float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.get(values, len(h.bounds)+1).value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.header(w)
+	for _, s := range h.sorted() {
+		cumulative := uint64(0)
+		for k, bound := range h.bounds {
+			cumulative += s.buckets[k]
+			h.sample(w, "_bucket", s.values, `le="`+formatValue(bound)+`"`, float64(cumulative))
+		}
+		h.sample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count))
+		h.sample(w, "_sum", s.values, "", s.value)
+		h.sample(w, "_count", s.values, "", float64(s.count))
+	}
+}
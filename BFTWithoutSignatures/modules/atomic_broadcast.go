@@ -2,81 +2,127 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"crypto/sha512"
 	"encoding/gob"
 	"sort"
-	"sync"
-)
-
-var (
-	aid        int
-	num        int
-	received   map[int]map[int][]byte
-	rDelivered [][]byte
-	delMutex   = sync.RWMutex{}
-
-	// VCAnswer - Channel to receive the answer from VC
-	VCAnswer = make(map[int]chan map[int][]byte)
 )
 
 // InitiateAtomicBroadcast - The method that is called to initiate the ABC module
-func InitiateAtomicBroadcast() {
-	aid = 1
-	num = 0
-	received = make(map[int]map[int][]byte, variables.N)
-	for i := 0; i < variables.N; i++ {
-		received[i] = make(map[int][]byte)
+func (node *Node) InitiateAtomicBroadcast() {
+	node.aid = node.lastDelivered + 1 // Continue after the recovered batches
+	node.num = 0
+	node.received = make(map[int]map[int][]byte, node.n())
+	for i := 0; i < node.n(); i++ {
+		node.received[i] = make(map[int][]byte)
 	}
-	rDelivered = make([][]byte, 0)
+	node.rDelivered = make([][]byte, 0)
+	node.proposed = make(map[string]int)
 
-	go ReliableBroadcastAbc()
+	node.initRbAbc() // Before AtomicBroadcast may send an INIT
+	go node.ReliableBroadcastAbc()
 
-	go abcTask1()
-	go abcTask2()
+	go node.abcTask1()
+	go node.abcTask2()
+}
+
+// consensusWindow - How many consensus instances run at a time
+func (node *Node) consensusWindow() int {
+	if node.ConsensusWindow < 1 {
+		return 1
+	}
+	return node.ConsensusWindow
 }
 
 // AtomicBroadcast - The method that is called to broadcast a new ABC value
-func AtomicBroadcast(m []byte) {
-	rbABC(num, types.NewAbcMessage(num, m))
+func (node *Node) AtomicBroadcast(m []byte) {
+	node.markBroadcast(m)
+	node.rbABC(node.num, types.NewAbcMessage(node.num, m))
 
-	delMutex.Lock()
-	rDelivered = append(rDelivered, m)
-	delMutex.Unlock()
+	node.delMutex.Lock()
+	node.rDelivered = append(node.rDelivered, m)
+	node.origins[string(m)] = append(node.origins[string(m)], abcOrigin{Process: node.ID, Num: node.num})
+	node.delMutex.Unlock()
 
-	num++
+	node.num++
 }
 
-func abcTask1() {
-	for {
-		for { // Wait until not empty R_delivered
-			delMutex.Lock()
-			if len(rDelivered) != 0 {
-				delMutex.Unlock()
+// abcInstance - A VC instance started by abcTask1, waiting to be delivered in order
+type abcInstance struct {
+	aid      int
+	answer   chan map[int][]byte
+	proposed [][]byte // The values that this replica proposed
+}
+
+// abcTask1 - Starts up to ConsensusWindow VC instances at a time, each one with the values that the
+// running instances do not carry yet; abcDeliver delivers their outputs in instance order
+func (node *Node) abcTask1() {
+	window := make(chan struct{}, node.consensusWindow()) // A slot for each running instance
+	started := make(chan abcInstance, node.consensusWindow())
+	go node.abcDeliver(started, window)
+
+	node.delMutex.RLock()
+	first := node.aid
+	node.delMutex.RUnlock()
+	for aid := first; ; aid++ {
+		window <- struct{}{} // The membership of aid is known once aid-ConsensusWindow is delivered
+		if !node.reconfigure(aid) {
+			return // Removed from the membership
+		}
+
+		var proposed [][]byte
+		for { // Wait until values of R_delivered are not proposed in the running instances
+			node.delMutex.Lock()
+			proposed = node.unproposed()
+			if len(proposed) != 0 {
+				for _, v := range proposed {
+					node.proposed[string(v)]++
+				}
+				node.delMutex.Unlock()
 				break
 			}
-			delMutex.Unlock()
+			node.delMutex.Unlock()
 		}
 
-		// Build the vector with the hashes of messages in R_delivered
-		delMutex.Lock()
-		h := hashMessages(rDelivered)
-		delMutex.Unlock()
+		// Build the vector with the hashes of the proposed messages
+		h := hashMessages(proposed)
 
-		VCAnswer[aid] = make(chan map[int][]byte, 1)
+		node.answerMutex.Lock()
+		node.VCAnswer[aid] = make(chan map[int][]byte, 1)
+		answer := node.VCAnswer[aid]
+		node.answerMutex.Unlock()
 		w := new(bytes.Buffer)
 		err := gob.NewEncoder(w).Encode(h)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		logger.OutLogger.Print(aid, ".ABC: hash-", h, " --> VC\n")
+		node.log("abc").Debug("hash --> VC", "aid", aid, "hash", h)
 
-		// Call VC and retrieve the answer
-		go VectorConsensus(aid, w.Bytes())
-		vc := <-VCAnswer[aid]
+		// Call VC, whose answer is retrieved by abcDeliver
+		go node.VectorConsensus(aid, w.Bytes())
+		started <- abcInstance{aid: aid, answer: answer, proposed: proposed}
+	}
+}
+
+// abcDeliver - Delivers the outputs of the VC instances in instance order [go started from abcTask1]
+func (node *Node) abcDeliver(started chan abcInstance, window chan struct{}) {
+	recent := make(map[int]map[string]bool) // aid -> hashes delivered (in the last instances of the window)
+
+	for instance := range started {
+		var vc map[int][]byte
+		select {
+		case vc = <-instance.answer:
+		case <-node.Messenger.Done(instance.aid): // Its batch is in a state transferred from the others
+			select {
+			case <-node.Messenger.Stopped():
+				return // Every instance is collected when the messenger closes
+			default:
+			}
+			node.skip(instance, recent)
+			<-window
+			continue
+		}
 		x := make(map[int][][]byte)
 
 		for k, v := range vc {
@@ -85,7 +131,7 @@ func abcTask1() {
 			}
 			var temp [][]byte
 			r := bytes.NewBuffer(v)
-			err = gob.NewDecoder(r).Decode(&temp)
+			err := gob.NewDecoder(r).Decode(&temp)
 			if err != nil {
 				logger.ErrLogger.Fatal(err)
 			}
@@ -93,20 +139,35 @@ func abcTask1() {
 		}
 
 		aDelivered := make([][]byte, 0)
+		delivered := make(map[string]bool)
 
-		// Wait until messages with hash in at least f+1 cells in X are in R_delivered
+		// Wait until messages with hash in at least f+1 cells in X are in R_delivered (a message that
+		// a previous instance of the window delivered is not delivered again)
 		count, dict := countHashes(x)
 		for k, v := range count {
-			if v >= (variables.F + 1) {
-				for {
-					val, in := checkIfDelivered(rDelivered, dict[k])
-					if in {
-						aDelivered = append(aDelivered, val)
-						break
-					}
+			if v < (node.f() + 1) {
+				continue
+			}
+			again := false
+			for _, hashes := range recent {
+				again = again || hashes[string(dict[k])]
+			}
+			if again {
+				continue
+			}
+			for {
+				node.delMutex.RLock()
+				val, in := checkIfDelivered(node.rDelivered, dict[k])
+				node.delMutex.RUnlock()
+				if in {
+					aDelivered = append(aDelivered, val)
+					delivered[string(dict[k])] = true
+					break
 				}
 			}
 		}
+		recent[instance.aid] = delivered
+		delete(recent, instance.aid-node.consensusWindow()+1)
 
 		// Sort messages in aDeliver and then deliver them
 		sort.Slice(aDelivered, func(i, j int) bool {
@@ -114,44 +175,131 @@ func abcTask1() {
 		})
 
 		// client response
-		Delivered <- struct {
+		node.observeDelivery("ABC", aDelivered)
+		node.recordInstance(instance.aid, instance.aid)
+		node.Delivered <- struct {
 			Id    int
 			Value [][]byte
-		}{aid, aDelivered}
+		}{instance.aid, aDelivered}
 
 		// Remove from R_delivered the values that have been already delivered
+		node.delMutex.Lock()
 		for _, b := range aDelivered {
-			delMutex.Lock()
-			for i, v := range rDelivered {
-				if bytes.Equal(b, v) {
-					rDelivered = append(rDelivered[:i], rDelivered[i+1:]...)
-					break
-				}
-			}
-			delMutex.Unlock()
+			node.removeDelivered(instance.aid, b)
 		}
+		node.unpropose(instance.proposed)
+		node.aid = instance.aid + 1
+		node.log("abc").Info("deliver", "aid", instance.aid, "values", len(aDelivered),
+			"pending", len(node.rDelivered))
+		node.delMutex.Unlock()
 
-		logger.OutLogger.Print(aid, ".ABC: aDelivered-", aDelivered, "\n")
-		logger.OutLogger.Print(aid, ".ABC: len-", len(rDelivered), " --> aid++\n")
+		<-window
+	}
+}
+
+// skip - Gives up an instance whose batch is in a state transferred from the others: the values of
+// R_delivered that the state holds are removed (and a later instance of the window does not deliver
+// them again), and the values that the instance was proposed with may be proposed again
+func (node *Node) skip(instance abcInstance, recent map[int]map[string]bool) {
+	node.delMutex.RLock()
+	pending := append([][]byte(nil), node.rDelivered...)
+	node.delMutex.RUnlock()
+	transferred := make([][]byte, 0)
+	for _, v := range pending {
+		if node.inState(v) {
+			transferred = append(transferred, v)
+		}
+	}
 
-		aid++
+	hashes := make(map[string]bool, len(transferred))
+	for _, h := range hashMessages(transferred) {
+		hashes[string(h)] = true
 	}
+	node.delMutex.Lock()
+	for _, b := range transferred {
+		node.removeDelivered(instance.aid, b)
+	}
+	node.unpropose(instance.proposed)
+	node.aid = instance.aid + 1
+	node.log("abc").Info("skip", "aid", instance.aid, "transferred", len(transferred),
+		"pending", len(node.rDelivered))
+	node.delMutex.Unlock()
+
+	recent[instance.aid] = hashes
+	delete(recent, instance.aid-node.consensusWindow()+1)
 }
 
-func abcTask2() {
-	for message := range messenger.AbcChannel {
-		if _, in := received[message.From][message.AbcMessage.Num]; in {
+// removeDelivered - Removes a copy of a delivered value from R_delivered, and remembers its RB instance,
+// to collect it after a checkpoint [delMutex must be held]
+func (node *Node) removeDelivered(aid int, b []byte) {
+	for i, v := range node.rDelivered {
+		if bytes.Equal(b, v) {
+			node.rDelivered = append(node.rDelivered[:i], node.rDelivered[i+1:]...)
+			break
+		}
+	}
+	if origins := node.origins[string(b)]; len(origins) > 0 {
+		node.abcDelivered[aid] = append(node.abcDelivered[aid], origins[0])
+		if len(origins) == 1 {
+			delete(node.origins, string(b))
+		} else {
+			node.origins[string(b)] = origins[1:]
+		}
+	}
+}
+
+// unpropose - The values that an instance was proposed with are no longer in a running instance
+// [delMutex must be held]
+func (node *Node) unpropose(values [][]byte) {
+	for _, v := range values {
+		if node.proposed[string(v)]--; node.proposed[string(v)] <= 0 {
+			delete(node.proposed, string(v))
+		}
+	}
+}
+
+// unproposed - The values of R_delivered that the running instances were not proposed with (every
+// copy of a value beyond the ones proposed) [delMutex must be held]
+func (node *Node) unproposed() [][]byte {
+	skip := make(map[string]int, len(node.proposed))
+	for v, copies := range node.proposed {
+		skip[v] = copies
+	}
+	values := make([][]byte, 0, len(node.rDelivered))
+	for _, v := range node.rDelivered {
+		if skip[string(v)] > 0 {
+			skip[string(v)]--
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func (node *Node) abcTask2() {
+	for message := range node.Messenger.AbcChannel {
+		value := message.AbcMessage.Value
+		origin := abcOrigin{Process: message.From, Num: message.AbcMessage.Num}
+		transferred := node.inState(value)
+		node.delMutex.Lock()
+		if _, in := node.received[message.From][message.AbcMessage.Num]; in {
+			node.delMutex.Unlock()
 			continue // Only one value can be received from each process
 		}
-		received[message.From][message.AbcMessage.Num] = message.AbcMessage.Value
+		node.received[message.From][message.AbcMessage.Num] = value
+		if transferred { // In a state transferred from the others: collected with the next checkpoint
+			node.abcDelivered[node.aid] = append(node.abcDelivered[node.aid], origin)
+			node.delMutex.Unlock()
+			continue
+		}
 
-		delMutex.Lock()
-		rDelivered = append(rDelivered, message.AbcMessage.Value)
-		delMutex.Unlock()
+		node.rDelivered = append(node.rDelivered, value)
+		node.origins[string(value)] = append(node.origins[string(value)], origin)
+		node.delMutex.Unlock()
 	}
 }
 
-func rbABC(id int, abcMessage types.AbcMessage) {
+func (node *Node) rbABC(id int, abcMessage types.AbcMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(abcMessage)
@@ -159,7 +307,7 @@ func rbABC(id int, abcMessage types.AbcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	msg := types.NewMessage(w.Bytes(), "ABC")
+	msg := node.Messenger.NewMessage(w.Bytes(), "ABC")
 	w = new(bytes.Buffer)
 	encoder = gob.NewEncoder(w)
 	err = encoder.Encode(msg)
@@ -167,7 +315,7 @@ func rbABC(id int, abcMessage types.AbcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	SendRBInit(id, w.Bytes())
+	node.SendRBInit(id, w.Bytes())
 }
 
 func hashMessages(rDelivered [][]byte) [][]byte {
@@ -0,0 +1,82 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/types"
+	"time"
+)
+
+/*
+	The request handler packs the client requests into batches, so that one ABC
+	(or SSABC) value carries many of them. A batch is broadcast when it reaches
+	BatchRequests requests or BatchBytes bytes, or BatchLinger after its first
+	request. While SSABC waits for the previous value to be delivered, the new
+	requests queue up and the next batch gets larger. Every value is unpacked
+	when it is delivered; a value with a single request is not packed at all.
+*/
+
+// batchQueue - How many batches of requests can wait for the batcher
+const batchQueue = 4
+
+// batcher - Packs the requests and broadcasts the batches [go started from RequestHandler]
+func (node *Node) batcher(requests <-chan []byte) {
+	pending := make([][]byte, 0, node.BatchRequests)
+	size := 0
+	var linger <-chan time.Time
+
+	flush := func() {
+		value, err := types.PackRequests(pending)
+		if err != nil {
+			node.log("batch").Error("dropped batch", "requests", len(pending), "err", err)
+		} else {
+			node.log("batch").Debug("batch", "requests", len(pending), "bytes", size)
+			node.orderValue(value)
+		}
+		pending = make([][]byte, 0, node.BatchRequests)
+		size = 0
+		linger = nil
+	}
+
+	for {
+		select {
+		case request := <-requests:
+			if len(pending) > 0 && size+len(request) > node.BatchBytes {
+				flush() // A larger request gets a batch of its own
+			}
+			pending = append(pending, request)
+			size += len(request)
+			if len(pending) == 1 {
+				linger = time.After(node.BatchLinger)
+			}
+			if len(pending) >= node.BatchRequests || size >= node.BatchBytes {
+				flush()
+			}
+
+		case <-linger:
+			flush()
+		}
+	}
+}
+
+// orderValue - Orders a value through SSABC or ABC
+func (node *Node) orderValue(value []byte) {
+	if node.RunSSABC {
+		node.SelfStabilizedAtomicBroadcast(value)
+	} else {
+		node.AtomicBroadcast(value)
+	}
+}
+
+// unpackBatches - The requests of the delivered values, in order (a value that cannot be unpacked is
+// dropped, as every correct replica drops it)
+func (node *Node) unpackBatches(values [][]byte) [][]byte {
+	requests := make([][]byte, 0, len(values))
+	for _, v := range values {
+		unpacked, err := types.UnpackRequests(v)
+		if err != nil {
+			node.log("batch").Error("dropped delivered batch", "err", err)
+			continue
+		}
+		requests = append(requests, unpacked...)
+	}
+	return requests
+}
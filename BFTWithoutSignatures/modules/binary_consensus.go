@@ -4,159 +4,181 @@ import (
 	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
-	"sync"
+	"time"
 )
 
-var (
-	binValues = make(map[int][]uint)
-	mutex     = sync.RWMutex{}
-)
+// binValuesCheck - How often BC re-checks its AUX messages while waiting for binValues to grow
+const binValuesCheck = 10 * time.Millisecond
 
 // BinaryConsensus - The method that is called to initiate the BC module
-func BinaryConsensus(bcid int, initVal uint) {
+func (node *Node) BinaryConsensus(bcid int, initVal uint) {
 	est := initVal
+	decided := false
+	done := node.Messenger.Done(messenger.RootInstance(bcid, messenger.DepthMVC))
 	for round := 1; ; round++ {
 		id := ComputeUniqueIdentifier(bcid, round)
-		logger.OutLogger.Print(id, ".BC: bcid-", bcid, " round-", round, "\n")
+		node.log("bc").Debug("round", "bcid", bcid, "round", round, "id", id)
 
 		// BV_broadcast of the est value of the round
-		go BvBroadcast(id, est)
+		go node.BvBroadcast(id, est)
 
 		for { // Wait until not empty binValues
-			mutex.Lock()
-			if len(binValues[id]) != 0 {
-				mutex.Unlock()
+			if node.isDone(done) {
+				return // The instance has been collected
+			}
+			node.mutex.Lock()
+			if len(node.binValues[id]) != 0 {
+				node.mutex.Unlock()
 				break
 			}
-			mutex.Unlock()
+			node.mutex.Unlock()
 		}
 
-		broadcast("AUX", types.NewBcMessage(id, binValues[id][0]))
+		node.mutex.Lock()
+		aux := node.binValues[id][0]
+		node.mutex.Unlock()
+		node.broadcast("AUX", types.NewBcMessage(id, aux))
 
 		// START Variables initialization
 		values := make([]uint, 0)
 		rec := make(map[int]uint)
-		rec[variables.ID] = binValues[id][0]
+		rec[node.ID] = aux
 
 		count := make(map[uint]int, 2)
 		count[0], count[1] = 0, 0
-		count[rec[variables.ID]]++
+		count[rec[node.ID]]++
 
-		messenger.BcMutex.Lock()
-		if _, in := messenger.BcChannel[id]; !in {
-			messenger.BcChannel[id] = make(chan struct {
+		node.Messenger.BcMutex.Lock()
+		if _, in := node.Messenger.BcChannel[id]; !in {
+			node.Messenger.BcChannel[id] = make(chan struct {
 				BcMessage types.BcMessage
 				From      int
 			})
 		}
-		messageChannel := messenger.BcChannel[id]
-		messenger.BcMutex.Unlock()
+		messageChannel := node.Messenger.BcChannel[id]
+		node.Messenger.BcMutex.Unlock()
 		// END Variables initialization
 
-		for message := range messageChannel {
-			if _, in := rec[message.From]; in {
-				continue // Only one value can be received from each process
-			}
-
-			rec[message.From] = message.BcMessage.Value
-			count[message.BcMessage.Value]++
-
-			// Wait until (n-t) AUX messages with the same value v
-			if (count[0] >= (variables.N-variables.F) && inList(0, binValues[id])) &&
-				(count[1] >= (variables.N-variables.F) && inList(1, binValues[id])) {
-				values = append(values, 0)
-				values = append(values, 1)
-			} else if count[0] >= (variables.N-variables.F) && inList(0, binValues[id]) {
-				values = append(values, 0)
-			} else if count[1] >= (variables.N-variables.F) && inList(1, binValues[id]) {
-				values = append(values, 1)
+		// Wait until (n-t) AUX messages whose values are in binValues (which may still grow)
+		ticker := time.NewTicker(binValuesCheck)
+		for len(values) == 0 {
+			select {
+			case message := <-messageChannel:
+				if _, in := rec[message.From]; in {
+					continue // Only one value can be received from each process
+				}
+				rec[message.From] = message.BcMessage.Value
+				count[message.BcMessage.Value]++
+			case <-ticker.C:
+			case <-done:
+				ticker.Stop()
+				return // The instance has been collected
 			}
 
-			if len(values) != 0 {
-				coin := random(id)
-				logger.OutLogger.Print(id, ".BC: vals-", values, " coin-", coin, "\n")
-
-				if len(values) == 2 {
-					est = coin
-				} else if len(values) == 1 && values[0] == coin {
-					logger.OutLogger.Print(id, ".BC: decide-", values[0], "\n")
-					decide(bcid, values[0])
-					return
-				} else if len(values) == 1 && values[0] != coin {
-					est = values[0]
+			node.mutex.Lock()
+			if total := countInList(count, node.binValues[id]); total >= (node.n() - node.f()) {
+				for _, v := range []uint{0, 1} {
+					if count[v] > 0 && inList(v, node.binValues[id]) {
+						values = append(values, v)
+					}
 				}
-
-				break
+			}
+			node.mutex.Unlock()
+		}
+		ticker.Stop()
+
+		coin := node.Coin.Toss(id)
+		node.log("bc").Debug("values", "bcid", bcid, "round", round, "values", values, "coin", coin)
+
+		if len(values) == 2 {
+			est = coin
+		} else {
+			est = values[0]
+			if values[0] == coin && decided {
+				// Every correct process has decided in this round, as they all had est == coin
+				return
+			} else if values[0] == coin {
+				node.log("bc").Debug("decide", "bcid", bcid, "round", round, "value", values[0])
+				node.Messenger.Metrics.BCRounds.Observe(float64(round))
+				node.decide(bcid, values[0])
+
+				// Keep helping the others until they decide too
+				decided = true
 			}
 		}
 	}
-
 }
 
 // BvBroadcast - Implements the BV_broadcast functionality
-func BvBroadcast(identifier int, initVal uint) {
+func (node *Node) BvBroadcast(identifier int, initVal uint) {
 	// START variables initialization
 	broadcasted := make(map[uint]bool, 2)
 	broadcasted[0], broadcasted[1] = false, false
 
-	received := make(map[int]int, (variables.N - 1))
-	for i := 0; i < variables.N; i++ {
-		if i == variables.ID {
-			continue // Not myself
-		}
-		received[i] = 0
-	}
+	received := make(map[uint]map[int]bool, 2) // value, from
+	received[0], received[1] = make(map[int]bool, node.n()), make(map[int]bool, node.n())
 
 	counter := make(map[uint]int, 2)
 	counter[0], counter[1] = 0, 0
 	counter[initVal]++
 
-	mutex.Lock()
-	binValues[identifier] = make([]uint, 0, 2)
-	mutex.Unlock()
+	node.mutex.Lock()
+	node.binValues[identifier] = make([]uint, 0, 2)
+	node.mutex.Unlock()
 	// END variables initialization
 
 	// Broadcast initial value
-	broadcast("EST", types.NewBcMessage(identifier, initVal))
+	node.broadcast("EST", types.NewBcMessage(identifier, initVal))
 	broadcasted[initVal] = true
 
-	messenger.BvbMutex.Lock()
-	if _, in := messenger.BvbChannel[identifier]; !in {
-		messenger.BvbChannel[identifier] = make(chan struct {
+	node.Messenger.BvbMutex.Lock()
+	if _, in := node.Messenger.BvbChannel[identifier]; !in {
+		node.Messenger.BvbChannel[identifier] = make(chan struct {
 			BcMessage types.BcMessage
 			From      int
 		})
 	}
-	messageChannel := messenger.BvbChannel[identifier]
-	messenger.BvbMutex.Unlock()
+	messageChannel := node.Messenger.BvbChannel[identifier]
+	node.Messenger.BvbMutex.Unlock()
+	done := node.Messenger.Done(messenger.RootInstance(identifier, messenger.DepthBC))
+
+	for {
+		var message struct {
+			BcMessage types.BcMessage
+			From      int
+		}
+		select {
+		case <-done:
+			return // The instance has been collected
+		case message = <-messageChannel:
+		}
 
-	for message := range messageChannel {
 		tag := message.BcMessage.Tag
 		val := message.BcMessage.Value
-		if received[message.From] < 2 { // Max 2 msgs can be accepted from other servers
-			received[message.From]++
-			counter[val]++
+		if val > 1 || received[val][message.From] {
+			continue // Only one message per value can be accepted from each server
 		}
+		received[val][message.From] = true
+		counter[val]++
 
-		if counter[val] >= (variables.F+1) && !broadcasted[val] {
-			broadcast("EST", types.NewBcMessage(tag, val))
+		if counter[val] >= (node.f()+1) && !broadcasted[val] {
+			node.broadcast("EST", types.NewBcMessage(tag, val))
 			broadcasted[val] = true
+			counter[val]++ // My own EST message
 		}
 
-		if counter[val] >= ((2*variables.F)+1) && !inList(val, binValues[tag]) {
-			mutex.Lock()
-			binValues[tag] = append(binValues[tag], val)
-			mutex.Unlock()
-
-			logger.OutLogger.Print(tag, ".BC: bin_values-", binValues[tag], "\n")
+		node.mutex.Lock()
+		if counter[val] >= ((2*node.f())+1) && !inList(val, node.binValues[tag]) {
+			node.binValues[tag] = append(node.binValues[tag], val)
+			node.log("bc").Debug("bin_values", "id", tag, "values", node.binValues[tag])
 		}
+		node.mutex.Unlock()
 	}
 }
 
-func broadcast(tag string, bcMessage types.BcMessage) {
+func (node *Node) broadcast(tag string, bcMessage types.BcMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(bcMessage)
@@ -165,23 +187,35 @@ func broadcast(tag string, bcMessage types.BcMessage) {
 	}
 
 	if tag == "EST" {
-		messenger.Broadcast(types.NewMessage(w.Bytes(), "BVB"))
+		err = node.Messenger.BroadcastWait(node.Messenger.NewMessage(w.Bytes(), "BVB"))
 	} else if tag == "AUX" {
-		messenger.Broadcast(types.NewMessage(w.Bytes(), "BC"))
+		err = node.Messenger.BroadcastWait(node.Messenger.NewMessage(w.Bytes(), "BC"))
+	}
+	if err != nil {
+		node.log("bc").Warn("message not sent", "err", err)
 	}
 }
 
-// TODO: implement a more Byzantine Tolerant Common-Coin algorithm
-func random(id int) uint {
-	return uint(id % 2)
-}
-
-func decide(id int, value uint) {
-	BCAnswer[id] <- value
+func (node *Node) decide(id int, value uint) {
+	node.answerMutex.RLock()
+	answer := node.BCAnswer[id]
+	node.answerMutex.RUnlock()
+	if answer != nil { // Not collected
+		answer <- value
+	}
 }
 
 /* -------------------------------- Helper Functions -------------------------------- */
 
+// Counts the received values that exist in list
+func countInList(count map[uint]int, list []uint) int {
+	total := 0
+	for _, v := range list {
+		total += count[v]
+	}
+	return total
+}
+
 // Checks if element a exists in list
 func inList(a uint, list []uint) bool {
 	for _, b := range list {
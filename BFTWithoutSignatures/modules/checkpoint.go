@@ -0,0 +1,262 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/wal"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"time"
+)
+
+/*
+	Every CheckpointInterval delivered batches, a replica broadcasts the digest
+	of its state. A checkpoint becomes stable when 2f+1 replicas report the same
+	digest for it: the snapshot replaces the older batches of the WAL, and the
+	channels and state of every consensus instance up to it are collected.
+	Replicas that fall behind a stable checkpoint catch up with state transfer:
+	a replica whose window of instances is behind the checkpoints of f+1
+	replicas asks for their state.
+*/
+
+// abcOrigin - The RB for ABC instance that broadcasted a value (sender and num)
+type abcOrigin struct {
+	Process int
+	Num     int
+}
+
+// StableCheckpoint - The last delivery index whose state 2f+1 replicas agree on
+func (node *Node) StableCheckpoint() int {
+	node.checkpointMutex.Lock()
+	defer node.checkpointMutex.Unlock()
+
+	return node.stableCheckpoint
+}
+
+// checkpoint - Keeps the snapshot of the state at index and broadcasts its digest
+func (node *Node) checkpoint(index int, snapshot []byte) {
+	digest := sha256.Sum256(snapshot)
+	vote := types.NewStateMessage("checkpoint", index, digest[:], nil)
+
+	node.checkpointMutex.Lock()
+	node.ownCheckpoints[index] = snapshot
+	node.checkpointMutex.Unlock()
+
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(vote)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	if err := node.Messenger.BroadcastWait(node.Messenger.NewMessage(w.Bytes(), "STATE")); err != nil {
+		node.log("checkpoint").Warn("vote not sent", "err", err)
+	}
+	node.log("checkpoint").Debug("checkpoint", "index", index, "digest", hex.EncodeToString(digest[:4]))
+
+	node.handleCheckpoint(vote, node.ID)
+}
+
+// handleCheckpoint - Keeps the last checkpoint of every replica and collects the instances up to the
+// latest one that 2f+1 replicas (and this one) agree on
+func (node *Node) handleCheckpoint(vote types.StateMessage, from int) {
+	node.stateMutex.Lock()
+	window := node.lastDelivered + node.consensusWindow() // The instances that this replica may run
+	node.stateMutex.Unlock()
+
+	node.checkpointMutex.Lock()
+	if node.CheckpointInterval <= 0 || vote.Index <= node.stableCheckpoint ||
+		vote.Index%node.CheckpointInterval != 0 {
+		node.checkpointMutex.Unlock()
+		return
+	}
+	if last, in := node.checkpoints[from]; in && last.Index >= vote.Index {
+		node.checkpointMutex.Unlock()
+		return // Only newer checkpoints replace the previous one
+	}
+	node.checkpoints[from] = vote
+
+	if node.aheadOf(window) >= node.f()+1 { // At least one correct replica delivered what this one cannot run yet
+		go node.behind()
+	}
+
+	stable := 0
+	var snapshot []byte
+	for index, own := range node.ownCheckpoints {
+		if index <= stable {
+			continue
+		}
+		digest := sha256.Sum256(own)
+		count := 0
+		for _, v := range node.checkpoints {
+			if v.Index == index && bytes.Equal(v.Digest, digest[:]) {
+				count++
+			}
+		}
+		if count >= ((2 * node.f()) + 1) {
+			stable, snapshot = index, own
+		}
+	}
+	if stable == 0 {
+		node.checkpointMutex.Unlock()
+		return
+	}
+
+	node.stableCheckpoint = stable
+	for index := range node.ownCheckpoints {
+		if index <= stable {
+			delete(node.ownCheckpoints, index)
+		}
+	}
+	for i, v := range node.checkpoints {
+		if v.Index <= stable {
+			delete(node.checkpoints, i)
+		}
+	}
+	root, in := node.instances[stable]
+	for index := range node.instances {
+		if index <= stable {
+			delete(node.instances, index)
+		}
+	}
+	node.checkpointMutex.Unlock()
+
+	node.log("checkpoint").Info("stable", "index", stable)
+	if node.WAL != nil {
+		err := node.WAL.SaveSnapshot(stable, snapshot)
+		if err == wal.ErrIndex {
+			node.log("checkpoint").Debug("snapshot not saved, an installed state is ahead", "index", stable)
+		} else if err != nil {
+			node.log("checkpoint").Warn("snapshot not saved", "index", stable, "err", err)
+		}
+	}
+	if in {
+		node.collect(root)
+	}
+}
+
+// aheadOf - How many replicas have a checkpoint past the given index (checkpointMutex is held)
+func (node *Node) aheadOf(index int) int {
+	ahead := 0
+	for _, v := range node.checkpoints {
+		if v.Index > index {
+			ahead++
+		}
+	}
+	return ahead
+}
+
+// isBehind - Whether the checkpoints of f+1 replicas are past the instances that this replica may run
+func (node *Node) isBehind() bool {
+	node.stateMutex.Lock()
+	window := node.lastDelivered + node.consensusWindow()
+	node.stateMutex.Unlock()
+
+	node.checkpointMutex.Lock()
+	defer node.checkpointMutex.Unlock()
+
+	return node.aheadOf(window) >= node.f()+1
+}
+
+// recordInstance - Remembers the root instance (of VC or SSVC) that delivered the batch with the given index
+func (node *Node) recordInstance(index int, root int) {
+	node.checkpointMutex.Lock()
+	node.instances[index] = root
+	node.checkpointMutex.Unlock()
+}
+
+// collect - Removes the channels and the state of every consensus instance up to root
+func (node *Node) collect(root int) {
+	node.Messenger.Collect(root)
+
+	node.answerMutex.Lock()
+	for id := range node.VCAnswer {
+		if id <= root {
+			delete(node.VCAnswer, id)
+		}
+	}
+	for id := range node.MVCAnswer {
+		if messenger.RootInstance(id, messenger.DepthMVC) <= root {
+			delete(node.MVCAnswer, id)
+		}
+	}
+	for id := range node.BCAnswer {
+		if messenger.RootInstance(id, messenger.DepthMVC) <= root {
+			delete(node.BCAnswer, id)
+		}
+	}
+	for id := range node.SSVCAnswer {
+		if id <= root {
+			delete(node.SSVCAnswer, id)
+		}
+	}
+	node.answerMutex.Unlock()
+
+	node.mutex.Lock()
+	for id := range node.binValues {
+		if messenger.RootInstance(id, messenger.DepthBC) <= root {
+			delete(node.binValues, id)
+		}
+	}
+	node.mutex.Unlock()
+
+	// The values that ABC delivered up to root
+	origins := make([]abcOrigin, 0)
+	node.delMutex.Lock()
+	for aid, delivered := range node.abcDelivered {
+		if aid <= root {
+			origins = append(origins, delivered...)
+			delete(node.abcDelivered, aid)
+		}
+	}
+	for _, o := range origins {
+		delete(node.received[o.Process], o.Num)
+	}
+	node.delMutex.Unlock()
+
+	if len(origins) > 0 {
+		node.rbAbcCollect <- origins
+	}
+}
+
+// collectRbAbc - Removes the state of the given RB for ABC instances, whose late messages are dropped from now on
+func (node *Node) collectRbAbc(origins []abcOrigin) {
+	for _, o := range origins {
+		p, num := o.Process, o.Num
+		delete(node.initial[p], num)
+		delete(node.echo[p], num)
+		delete(node.ready[p], num)
+		delete(node.sentEcho[p], num)
+		delete(node.sentReady[p], num)
+		delete(node.accepted[p], num)
+
+		if node.rbCollected[p] == nil {
+			node.rbCollected[p] = make(map[int]bool)
+		}
+		node.rbCollected[p][num] = true
+		for node.rbCollected[p][node.rbLow[p]] { // Every num below rbLow has been collected
+			delete(node.rbCollected[p], node.rbLow[p])
+			node.rbLow[p]++
+		}
+	}
+}
+
+// busyWaitPause - How long the waits of the modules pause between two checks of their condition
+const busyWaitPause = time.Millisecond
+
+// isDone - Pauses a wait for busyWaitPause (so that the waits do not starve the other goroutines)
+// and returns whether the done channel of its instance has been closed (it has been collected)
+func (node *Node) isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(busyWaitPause):
+		return false
+	}
+}
+
+// isRbAbcCollected - Whether the RB for ABC instance (p, num) has been collected
+func (node *Node) isRbAbcCollected(p int, num int) bool {
+	return num < node.rbLow[p] || node.rbCollected[p][num]
+}
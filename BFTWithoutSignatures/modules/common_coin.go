@@ -0,0 +1,110 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"math/big"
+	"strconv"
+)
+
+// CommonCoin - The coin that BC tosses at the end of every round
+type CommonCoin interface {
+	// Toss - Returns the coin of round id (the same value in every correct replica)
+	Toss(id int) uint
+}
+
+// deterministicCoin - A coin that everyone knows ahead of time (only for tests)
+type deterministicCoin struct{}
+
+// thresholdCoin - A coin that is revealed only when f+1 replicas have sent their share
+type thresholdCoin struct {
+	node *Node
+}
+
+// NewDeterministicCoin - Creates the predictable coin (id % 2)
+func NewDeterministicCoin() CommonCoin {
+	return deterministicCoin{}
+}
+
+// NewThresholdCoin - Creates the threshold coin of the node (uses the coin keys of its messenger)
+func NewThresholdCoin(node *Node) CommonCoin {
+	return &thresholdCoin{node: node}
+}
+
+// Toss - Returns the parity of the round id
+func (coin deterministicCoin) Toss(id int) uint {
+	return uint(id % 2)
+}
+
+// Toss - Broadcasts the share of this replica and combines the first f+1 valid shares
+func (coin *thresholdCoin) Toss(id int) uint {
+	node := coin.node
+	keys := node.Messenger.CurrentKeys().Coin
+	name := []byte("COIN-" + strconv.Itoa(id))
+
+	share, err := keys.NewShare(name)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	shares := make(map[int]threshenc.CoinShare, node.f()+1)
+	shares[node.ID] = share
+
+	coin.broadcastShare(types.NewCoinMessage(id, share.Value.Bytes(), share.Challenge.Bytes(),
+		share.Response.Bytes()))
+
+	node.Messenger.CoinMutex.Lock()
+	if _, in := node.Messenger.CoinChannel[id]; !in {
+		node.Messenger.CoinChannel[id] = make(chan struct {
+			CoinMessage types.CoinMessage
+			From        int
+		})
+	}
+	messageChannel := node.Messenger.CoinChannel[id]
+	node.Messenger.CoinMutex.Unlock()
+	done := node.Messenger.Done(messenger.RootInstance(id, messenger.DepthBC))
+
+	for len(shares) < (node.f() + 1) {
+		var message struct {
+			CoinMessage types.CoinMessage
+			From        int
+		}
+		select {
+		case <-done:
+			return 0 // The instance has been collected (nobody waits for the coin)
+		case message = <-messageChannel:
+		}
+		if _, in := shares[message.From]; in {
+			continue // Only one share can be received from each process
+		}
+
+		s := threshenc.CoinShare{
+			Value:     new(big.Int).SetBytes(message.CoinMessage.Value),
+			Challenge: new(big.Int).SetBytes(message.CoinMessage.Challenge),
+			Response:  new(big.Int).SetBytes(message.CoinMessage.Response),
+		}
+		if !keys.VerifyShare(name, s, message.From) {
+			node.log("coin").Warn("invalid share", "id", id, "peer", message.From)
+			continue
+		}
+		shares[message.From] = s
+	}
+
+	return threshenc.CombineShares(shares)
+}
+
+func (coin *thresholdCoin) broadcastShare(coinMessage types.CoinMessage) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(coinMessage)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	if err := coin.node.Messenger.BroadcastWait(coin.node.Messenger.NewMessage(w.Bytes(), "COIN")); err != nil {
+		coin.node.log("coin").Warn("share not sent", "err", err)
+	}
+}
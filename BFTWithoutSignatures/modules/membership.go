@@ -0,0 +1,449 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"crypto/rsa"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	The replicas change without a restart. A replica proposes a reconfiguration
+	by ordering it through ABC (or SSABC), signed with its key. Once f+1
+	replicas have proposed the same change in the same configuration, the change
+	is scheduled ReconfigurationDelay batches after the one that completed it
+	(plus the ConsensusWindow-1 instances that may already run): every correct
+	replica starts that instance with the new membership (N and F, the links
+	and the keys), as the handler has delivered the scheduling batch before the
+	instance starts. The ordered membership is part of the replicated
+	state, so it survives the WAL and is transferred with the snapshots.
+
+	The ids stay contiguous: a replica joins with the next id and only the last
+	one can leave, and there are never fewer than MinReplicas (n > 3f, f >= 1).
+	A joining replica is started once its addition has taken effect, and catches
+	up with state transfer. A removed (or replaced) replica retires as soon as it
+	delivers the batch that schedules the change (it may never receive what the
+	others send after they switch), so until the change takes effect they run
+	without it, as with a crashed replica. The instances that are still running
+	at that point continue with the new thresholds.
+*/
+
+const (
+	// ReconfigurationDelay - How many batches after the one that schedules it a reconfiguration takes effect
+	ReconfigurationDelay = 2
+
+	// MinReplicas - The smallest membership that tolerates a Byzantine replica
+	MinReplicas = 4
+)
+
+// configuration - The membership as ordered by ABC (guarded by stateMutex, part of the replicated state)
+type configuration struct {
+	Members   []types.Member    // Ordered by id
+	Version   int               // The number of reconfigurations applied to Members
+	CoinKeys  string            // The folder of the coin keys of Members (empty for the initial ones)
+	Scheduled *scheduledChange  // The change that does not have effect yet (at most one)
+	Proposals []proposalSupport // The changes that f+1 replicas have not proposed yet
+}
+
+// scheduledChange - A reconfiguration and the delivery index from which it has effect
+type scheduledChange struct {
+	Index           int
+	Reconfiguration types.Reconfiguration
+}
+
+// proposalSupport - The replicas that proposed a reconfiguration
+type proposalSupport struct {
+	Reconfiguration types.Reconfiguration
+	Proposers       []int
+}
+
+// defaultMembers - The members 0 to n-1, without addresses (the replicas that run in one process)
+func defaultMembers(n int) []types.Member {
+	members := make([]types.Member, n)
+	for i := range members {
+		members[i] = types.Member{ID: i}
+	}
+	return members
+}
+
+// SetMembers - Sets where the replicas run and their keys (before Recover, CatchUp and ABC or SSABC)
+func (node *Node) SetMembers(members []types.Member) {
+	node.stateMutex.Lock()
+	node.membership.Members = append([]types.Member(nil), members...)
+	node.stateMutex.Unlock()
+
+	node.membershipMutex.Lock()
+	node.members = append([]types.Member(nil), members...)
+	node.membershipMutex.Unlock()
+}
+
+// Members - The replicas that this replica runs with
+func (node *Node) Members() []types.Member {
+	node.membershipMutex.RLock()
+	defer node.membershipMutex.RUnlock()
+
+	return append([]types.Member(nil), node.members...)
+}
+
+// n - The number of replicas
+func (node *Node) n() int {
+	node.membershipMutex.RLock()
+	defer node.membershipMutex.RUnlock()
+
+	return node.N
+}
+
+// f - The number of Byzantine replicas tolerated
+func (node *Node) f() int {
+	node.membershipMutex.RLock()
+	defer node.membershipMutex.RUnlock()
+
+	return node.F
+}
+
+// Propose - Checks a reconfiguration against the membership (and that this replica can load the coin keys
+// that it deals), signs it and orders it through ABC (or SSABC). It takes effect once f+1 replicas have
+// proposed it.
+func (node *Node) Propose(r types.Reconfiguration) error {
+	node.stateMutex.Lock()
+	members, version, coinKeys := node.membership.projected()
+	node.stateMutex.Unlock()
+
+	err := checkReconfiguration(members, r)
+	if err != nil {
+		return err
+	}
+	if n := len(applyReconfiguration(members, r)); r.CoinKeys != "" && r.CoinKeys != coinKeys && node.ID < n {
+		// The replica will load these keys when the change has effect
+		if _, err := node.LoadCoinKeys(r.CoinKeys, node.ID, n); err != nil {
+			return fmt.Errorf("cannot load the coin keys of %d replicas from %q: %w", n, r.CoinKeys, err)
+		}
+	}
+	data, err := proposalMessage(r, version)
+	if err != nil {
+		return err
+	}
+	proposal := types.ReconfigurationProposal{Reconfiguration: r, Version: version,
+		Signature: node.Messenger.CurrentKeys().SignMessage(data)}
+
+	w := new(bytes.Buffer)
+	err = gob.NewEncoder(w).Encode(proposal)
+	if err != nil {
+		return err
+	}
+	command := w.Bytes()
+	w = new(bytes.Buffer)
+	err = gob.NewEncoder(w).Encode(types.NewClientMessage(types.ReplicaCid(node.ID),
+		int(time.Now().UnixNano()), types.OpReconfigure, command))
+	if err != nil {
+		return err
+	}
+
+	node.log("membership").Info("proposed", "op", r.Op, "member", r.Member.ID)
+	node.orderValue(w.Bytes())
+	return nil
+}
+
+// order - Counts a delivered proposal and schedules its change when f+1 replicas have proposed it
+// (called from deliver, with stateMutex held)
+func (node *Node) order(m types.ClientMessage, index int) {
+	proposer := types.ReplicaOf(m.Cid)
+	c := &node.membership
+	if proposer < 0 || proposer >= len(c.Members) {
+		node.log("membership").Warn("proposal of a process that is not a replica", "index", index, "proposer", proposer)
+		return
+	}
+
+	var proposal types.ReconfigurationProposal
+	err := gob.NewDecoder(bytes.NewBuffer(m.Command)).Decode(&proposal)
+	if err != nil {
+		node.log("membership").Warn("invalid proposal", "index", index, "proposer", proposer, "err", err)
+		return
+	}
+	r := proposal.Reconfiguration
+	data, err := proposalMessage(r, proposal.Version)
+	if err != nil || !node.verifyProposal(data, proposal.Signature, proposer) {
+		node.log("membership").Warn("proposal not signed by its proposer", "index", index, "proposer", proposer)
+		return
+	}
+	if proposal.Version != c.Version || c.Scheduled != nil {
+		node.log("membership").Info("stale proposal", "index", index, "proposer", proposer)
+		return
+	}
+	err = checkReconfiguration(c.Members, r)
+	if err != nil {
+		node.log("membership").Warn("invalid proposal", "index", index, "proposer", proposer, "err", err)
+		return
+	}
+
+	// The last proposal of a replica replaces its previous one
+	support := -1
+	for k := 0; k < len(c.Proposals); k++ {
+		p := &c.Proposals[k]
+		for i, id := range p.Proposers {
+			if id == proposer {
+				p.Proposers = append(p.Proposers[:i], p.Proposers[i+1:]...)
+				break
+			}
+		}
+		if sameReconfiguration(p.Reconfiguration, r) {
+			support = k
+		}
+	}
+	if support < 0 {
+		c.Proposals = append(c.Proposals, proposalSupport{Reconfiguration: r})
+		support = len(c.Proposals) - 1
+	}
+	c.Proposals[support].Proposers = append(c.Proposals[support].Proposers, proposer)
+
+	if len(c.Proposals[support].Proposers) >= (len(c.Members)-1)/3+1 {
+		c.Scheduled = &scheduledChange{Index: index + ReconfigurationDelay + node.consensusWindow() - 1,
+			Reconfiguration: r}
+		c.Proposals = nil
+		node.log("membership").Info("scheduled", "index", index, "op", r.Op, "member", r.Member.ID,
+			"at", c.Scheduled.Index)
+		if r.Member.ID == node.ID && r.Op != types.AddReplica {
+			node.retire(index)
+		}
+	}
+}
+
+// retire - Stops this replica, which is not a member anymore: ABC (or SSABC) starts no more instances
+// and Retired is closed
+func (node *Node) retire(index int) {
+	node.membershipMutex.Lock()
+	if node.retired {
+		node.membershipMutex.Unlock()
+		return
+	}
+	node.retired = true
+	node.membershipMutex.Unlock()
+
+	close(node.Retired)
+	node.log("membership").Info("retired", "index", index)
+}
+
+// advance - Applies the scheduled change if it has effect at index (called from deliver)
+func (c *configuration) advance(index int) {
+	if c.Scheduled == nil || c.Scheduled.Index > index {
+		return
+	}
+	c.Members = applyReconfiguration(c.Members, c.Scheduled.Reconfiguration)
+	c.Version++
+	if c.Scheduled.Reconfiguration.CoinKeys != "" {
+		c.CoinKeys = c.Scheduled.Reconfiguration.CoinKeys
+	}
+	c.Scheduled = nil
+}
+
+// at - The members, version and coin keys in effect at index (with the scheduled change)
+func (c *configuration) at(index int) ([]types.Member, int, string) {
+	if c.Scheduled == nil || c.Scheduled.Index > index {
+		return c.Members, c.Version, c.CoinKeys
+	}
+	coinKeys := c.CoinKeys
+	if c.Scheduled.Reconfiguration.CoinKeys != "" {
+		coinKeys = c.Scheduled.Reconfiguration.CoinKeys
+	}
+	return applyReconfiguration(c.Members, c.Scheduled.Reconfiguration), c.Version + 1, coinKeys
+}
+
+// projected - The members, version and coin keys once the scheduled change has effect
+func (c *configuration) projected() ([]types.Member, int, string) {
+	if c.Scheduled == nil {
+		return c.Members, c.Version, c.CoinKeys
+	}
+	return c.at(c.Scheduled.Index)
+}
+
+// reconfigure - Switches the modules and the messenger to the membership in effect at instance aid,
+// before the instance starts. Returns false if this replica is not a member anymore.
+func (node *Node) reconfigure(aid int) bool {
+	node.stateMutex.Lock()
+	target, version, coinKeys := node.membership.at(aid)
+	node.stateMutex.Unlock()
+
+	node.membershipMutex.Lock()
+	if version == node.version || node.retired {
+		node.membershipMutex.Unlock()
+		return !node.retired
+	}
+
+	current := make(map[int]types.Member, len(node.members))
+	for _, member := range node.members {
+		current[member.ID] = member
+	}
+	removed, added := make([]int, 0), make([]types.Member, 0)
+	for id, member := range current {
+		if id >= len(target) || !sameMember(member, target[id]) {
+			removed = append(removed, id)
+		}
+	}
+	for _, member := range target {
+		if old, in := current[member.ID]; !in || !sameMember(old, member) {
+			added = append(added, member)
+		}
+	}
+
+	self, in := current[node.ID]
+	if node.ID >= len(target) || (in && !sameMember(self, target[node.ID])) {
+		node.membershipMutex.Unlock()
+		node.retire(aid)
+		return false
+	}
+
+	node.members = append([]types.Member(nil), target...)
+	node.version = version
+	node.N = len(target)
+	node.F = (node.N - 1) / 3
+	n := node.N
+	loadCoin := coinKeys != node.coinKeys
+	node.coinKeys = coinKeys
+	node.membershipMutex.Unlock()
+
+	// The state of the new processes, before their messages arrive
+	node.initRbAbc()
+	node.delMutex.Lock()
+	if node.received != nil {
+		for i := 0; i < n; i++ {
+			if node.received[i] == nil {
+				node.received[i] = make(map[int][]byte)
+			}
+		}
+	}
+	node.delMutex.Unlock()
+
+	keys := *node.Messenger.CurrentKeys()
+	keys.VerificationKeys = make(map[int]*rsa.PublicKey, n)
+	for id, key := range node.Messenger.CurrentKeys().VerificationKeys {
+		if id < n {
+			keys.VerificationKeys[id] = key
+		}
+	}
+	for _, member := range added {
+		if member.PublicKey == nil {
+			continue
+		}
+		key, err := threshenc.ParseVerificationKey(member.PublicKey)
+		if err != nil {
+			node.log("membership").Error("invalid key", "aid", aid, "member", member.ID, "err", err)
+			continue
+		}
+		keys.VerificationKeys[member.ID] = key
+	}
+	if loadCoin && coinKeys != "" {
+		coin, err := node.LoadCoinKeys(coinKeys, node.ID, n)
+		if err != nil {
+			// The proposers could load them: this replica keeps its coin keys, and the others reject its
+			// shares as the ones of a faulty replica
+			node.log("membership").Error("cannot load the coin keys", "aid", aid, "folder", coinKeys, "err", err)
+		} else {
+			keys.Coin = coin
+		}
+	}
+	node.Messenger.Reconfigure(n, &keys, target[node.ID], removed, added)
+
+	node.log("membership").Info("reconfigured", "aid", aid, "n", n, "f", (n-1)/3, "removed", removed,
+		"added", len(added))
+	return true
+}
+
+// checkReconfiguration - Whether a reconfiguration can be applied to the members
+func checkReconfiguration(members []types.Member, r types.Reconfiguration) error {
+	n := len(members)
+	id := r.Member.ID
+	switch r.Op {
+	case types.AddReplica:
+		if id != n {
+			return fmt.Errorf("replica %d cannot join, the next id is %d", id, n)
+		}
+		if r.Member.PublicKey == nil {
+			return errors.New("replica " + strconv.Itoa(id) + " joins without a public key")
+		}
+	case types.RemoveReplica:
+		if id != n-1 {
+			return fmt.Errorf("replica %d cannot leave, only the last one (%d) can", id, n-1)
+		}
+		if n-1 < MinReplicas {
+			return fmt.Errorf("%d replicas would not tolerate a Byzantine one", n-1)
+		}
+	case types.ReplaceReplica:
+		if id < 0 || id >= n {
+			return fmt.Errorf("replica %d is not a member", id)
+		}
+	default:
+		return errors.New("unknown membership operation " + strconv.Itoa(int(r.Op)))
+	}
+	if r.Member.PublicKey != nil {
+		if _, err := threshenc.ParseVerificationKey(r.Member.PublicKey); err != nil {
+			return fmt.Errorf("replica %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// applyReconfiguration - The members after a (checked) reconfiguration
+func applyReconfiguration(members []types.Member, r types.Reconfiguration) []types.Member {
+	result := append(make([]types.Member, 0, len(members)+1), members...)
+	switch r.Op {
+	case types.AddReplica:
+		result = append(result, r.Member)
+	case types.RemoveReplica:
+		result = result[:len(result)-1]
+	case types.ReplaceReplica:
+		member := r.Member
+		if member.PublicKey == nil {
+			member.PublicKey = result[member.ID].PublicKey // It keeps its key
+		}
+		result[member.ID] = member
+	}
+	return result
+}
+
+// proposalMessage - What the signature of a proposal covers (valid only in the configuration version)
+func proposalMessage(r types.Reconfiguration, version int) ([]byte, error) {
+	data, err := r.GobEncode()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("RECONFIGURE|"+strconv.Itoa(version)+"|"), data...), nil
+}
+
+// verifyProposal - Verifies the signature of a proposal with the key of the proposer in the ordered
+// membership (or, for the initial replicas, the key that every replica has)
+func (node *Node) verifyProposal(data []byte, signature []byte, proposer int) bool {
+	keys := node.Messenger.CurrentKeys()
+	if key := node.membership.Members[proposer].PublicKey; key != nil {
+		publicKey, err := threshenc.ParseVerificationKey(key)
+		if err != nil {
+			return false
+		}
+		keys = &threshenc.Keys{VerificationKeys: map[int]*rsa.PublicKey{proposer: publicKey}}
+	}
+	if keys.VerificationKeys[proposer] == nil {
+		return false
+	}
+	return keys.VerifyMessage(data, signature, proposer)
+}
+
+// sameReconfiguration - Whether two proposals ask for the same change
+func sameReconfiguration(a types.Reconfiguration, b types.Reconfiguration) bool {
+	return a.Op == b.Op && a.CoinKeys == b.CoinKeys && sameMember(a.Member, b.Member)
+}
+
+// sameMember - Whether two members run at the same place with the same key
+func sameMember(a types.Member, b types.Member) bool {
+	return a.ID == b.ID && a.Host == b.Host && a.PeerPort == b.PeerPort && a.ClientPort == b.ClientPort &&
+		bytes.Equal(a.PublicKey, b.PublicKey)
+}
+
+// readCoinKeys - Reads the coin keys of server id for n servers from a folder
+func readCoinKeys(folder string, id int, n int) (*threshenc.CoinKeys, error) {
+	return threshenc.LoadCoinKeys(strings.TrimSuffix(folder, "/")+"/", id, n)
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"time"
+)
+
+/*
+	The modules add their metrics to the registry of the messenger (see
+	messenger/metrics.go). The delivery latency of a value is measured by the
+	replica that broadcast it, from AtomicBroadcast (or
+	SelfStabilizedAtomicBroadcast) to its delivery, and the latency of a request
+	by the replicas that received it from the client, from its reception to its
+	execution.
+*/
+
+// registerMetrics - Registers the gauges of the node [called from NewNode]
+func (node *Node) registerMetrics() {
+	node.Messenger.Metrics.Registry.NewGaugeFunc("bft_pending_values",
+		"Values reliably delivered to ABC that are not delivered yet.", nil,
+		func(set func(float64, ...string)) {
+			node.delMutex.RLock()
+			defer node.delMutex.RUnlock()
+			set(float64(len(node.rDelivered)))
+		})
+}
+
+// markBroadcast - Remembers when this replica broadcast a value
+func (node *Node) markBroadcast(value []byte) {
+	node.metricsMutex.Lock()
+	defer node.metricsMutex.Unlock()
+
+	if _, in := node.broadcastAt[string(value)]; !in {
+		node.broadcastAt[string(value)] = time.Now()
+	}
+}
+
+// observeDelivery - Observes the delivery latency of the values that this replica broadcast
+func (node *Node) observeDelivery(protocol string, values [][]byte) {
+	node.metricsMutex.Lock()
+	defer node.metricsMutex.Unlock()
+
+	for _, v := range values {
+		if at, in := node.broadcastAt[string(v)]; in {
+			node.Messenger.Metrics.DeliveryLatency.Observe(time.Since(at).Seconds(), protocol)
+			delete(node.broadcastAt, string(v))
+		}
+	}
+}
+
+// markRequest - Remembers when this replica received the request id ("cid num") from its client
+func (node *Node) markRequest(id string) {
+	node.metricsMutex.Lock()
+	defer node.metricsMutex.Unlock()
+
+	if _, in := node.requestedAt[id]; !in {
+		node.requestedAt[id] = time.Now()
+	}
+}
+
+// observeRequest - Observes the latency of the request id, if this replica received it from its client
+func (node *Node) observeRequest(id string) {
+	node.metricsMutex.Lock()
+	defer node.metricsMutex.Unlock()
+
+	if at, in := node.requestedAt[id]; in {
+		node.Messenger.Metrics.RequestLatency.Observe(time.Since(at).Seconds())
+		delete(node.requestedAt, id)
+	}
+}
@@ -10,39 +10,41 @@ import (
 	"sync"
 )
 
-var (
-	// BCAnswer - Channel to receive the answer from BC
-	BCAnswer = make(map[int]chan uint)
-)
-
 // MultiValuedConsensus - The method that is called to initiate the MVC module
-func MultiValuedConsensus(mvcid int, v []byte) {
+func (node *Node) MultiValuedConsensus(mvcid int, v []byte) {
 	// START Variables initialization
 	init := make(map[int][]byte)
 	vect := make(map[int][]byte)
 	initMutex := sync.RWMutex{}
 	vectMutex := sync.RWMutex{}
-	BCAnswer[mvcid] = make(chan uint, 1)
-
-	messenger.MvcMutex.Lock()
-	if _, in := messenger.MvcChannel[mvcid]; !in {
-		messenger.MvcChannel[mvcid] = make(chan struct {
+	node.answerMutex.Lock()
+	answer := make(chan uint, 1)
+	node.BCAnswer[mvcid] = answer
+	node.answerMutex.Unlock()
+	done := node.Messenger.Done(messenger.RootInstance(mvcid, messenger.DepthMVC))
+
+	node.Messenger.MvcMutex.Lock()
+	if _, in := node.Messenger.MvcChannel[mvcid]; !in {
+		node.Messenger.MvcChannel[mvcid] = make(chan struct {
 			MvcMessage types.MvcMessage
 			From       int
 		})
 	}
-	messageChannel := messenger.MvcChannel[mvcid]
-	messenger.MvcMutex.Unlock()
+	messageChannel := node.Messenger.MvcChannel[mvcid]
+	node.Messenger.MvcMutex.Unlock()
 	// END Variables initialization
 
 	/* ----------------------------------- Task 1 ----------------------------------- */
 	go func() {
-		init[variables.ID] = v
-		rbMVC(ComputeUniqueIdentifier(mvcid, 1), types.NewMvcMessage(mvcid, "INIT", v, nil))
+		init[node.ID] = v
+		node.rbMVC(ComputeUniqueIdentifier(mvcid, 1), types.NewMvcMessage(mvcid, "INIT", v, nil))
 
 		for { // Wait until at least (n-f) INIT messages
+			if node.isDone(done) {
+				return // The instance has been collected
+			}
 			initMutex.Lock()
-			if len(init) >= (variables.N - variables.F) {
+			if len(init) >= (node.n() - node.f()) {
 				initMutex.Unlock()
 				break
 			}
@@ -51,17 +53,20 @@ func MultiValuedConsensus(mvcid int, v []byte) {
 
 		// Fill vector with values in init else DEFAULT and calculate w value
 		initMutex.Lock()
-		vector := fillVector(init)
+		vector := node.fillVector(init)
 		initMutex.Unlock()
-		w := calculateW(vector)
-		logger.OutLogger.Print(mvcid, ".MVC: vector-", vector, " --> ", w, "\n")
+		w := node.calculateW(vector)
+		node.log("mvc").Debug("vector", "mvcid", mvcid, "vector", vector, "w", w)
 
-		vect[variables.ID] = w
-		rbMVC(ComputeUniqueIdentifier(mvcid, 2), types.NewMvcMessage(mvcid, "VECT", w, vector))
+		vect[node.ID] = w
+		node.rbMVC(ComputeUniqueIdentifier(mvcid, 2), types.NewMvcMessage(mvcid, "VECT", w, vector))
 
 		for { // Wait until at least (n-f) valid VECT messages
+			if node.isDone(done) {
+				return // The instance has been collected
+			}
 			vectMutex.Lock()
-			if len(vect) >= (variables.N - variables.F) {
+			if len(vect) >= (node.n() - node.f()) {
 				vectMutex.Unlock()
 				break
 			}
@@ -70,27 +75,38 @@ func MultiValuedConsensus(mvcid int, v []byte) {
 
 		// Fill vectorW with values in vect else DEFAULT and calculate BC input value
 		vectMutex.Lock()
-		vectorW := fillVector(vect)
+		vectorW := node.fillVector(vect)
 		vectMutex.Unlock()
-		bVal := calculateBinaryValue(vectorW)
-		logger.OutLogger.Print(mvcid, ".MVC: vectorW-", vectorW, " --> ", bVal, "\n")
-
-		go BinaryConsensus(mvcid, bVal)
-		c := <-BCAnswer[mvcid]
+		bVal := node.calculateBinaryValue(vectorW)
+		node.log("mvc").Debug("vectorW", "mvcid", mvcid, "vectorW", vectorW, "value", bVal)
+
+		go node.BinaryConsensus(mvcid, bVal)
+		var c uint
+		select {
+		case c = <-answer:
+		case <-done:
+			return // The instance has been collected
+		}
 
 		if c == 0 {
-			logger.OutLogger.Print(mvcid, ".MVC: decide-", variables.DEFAULT, "\n")
-			MVCAnswer[mvcid] <- variables.DEFAULT
+			node.log("mvc").Debug("decide default", "mvcid", mvcid)
+			node.decideMVC(mvcid, variables.DEFAULT)
 
 			return
 		}
 
 		for {
-			counter, dict := findOccurrences(fillVector(vect))
+			if node.isDone(done) {
+				return // The instance has been collected
+			}
+			vectMutex.Lock()
+			vector := node.fillVector(vect)
+			vectMutex.Unlock()
+			counter, dict := findOccurrences(vector)
 			for k, v := range counter {
-				if v >= (variables.N - (2 * variables.F)) {
-					logger.OutLogger.Print(mvcid, ".MVC: decide-", dict[k], "\n")
-					MVCAnswer[mvcid] <- dict[k]
+				if v >= (node.n() - (2 * node.f())) {
+					node.log("mvc").Debug("decide", "mvcid", mvcid, "value", dict[k])
+					node.decideMVC(mvcid, dict[k])
 
 					return
 				}
@@ -100,31 +116,74 @@ func MultiValuedConsensus(mvcid int, v []byte) {
 
 	/* ----------------------------------- Task 2 ----------------------------------- */
 	go func() {
-		for message := range messageChannel {
+		// The VECT messages that are not valid with the INIT values received so far (they are checked
+		// again with every INIT value, as the ones they carry may not have been delivered yet)
+		pending := make(map[int]types.MvcMessage)
+		accept := func(from int, message types.MvcMessage) {
+			initMutex.RLock()
+			valid := node.checkVectValidity(message, init)
+			initMutex.RUnlock()
+			if !valid { // Accept only valid VECT msgs
+				pending[from] = message
+				return
+			}
+			delete(pending, from)
+			vectMutex.Lock()
+			vect[from] = message.Value
+			vectMutex.Unlock()
+		}
+
+		for {
+			var message struct {
+				MvcMessage types.MvcMessage
+				From       int
+			}
+			select {
+			case <-done:
+				return // The instance has been collected
+			case message = <-messageChannel:
+			}
+
 			if message.MvcMessage.Type == "INIT" {
-				if _, in := init[message.From]; in {
-					continue // Only one value can be received from each process
-				}
 				initMutex.Lock()
-				init[message.From] = message.MvcMessage.Value
+				_, in := init[message.From]
+				if !in { // Only one value can be received from each process
+					init[message.From] = message.MvcMessage.Value
+				}
 				initMutex.Unlock()
+				if !in {
+					for from, m := range pending {
+						accept(from, m)
+					}
+				}
 
 			} else if message.MvcMessage.Type == "VECT" {
-				if _, in := vect[message.From]; in {
+				vectMutex.Lock()
+				_, in := vect[message.From]
+				vectMutex.Unlock()
+				if in {
 					continue // Only one value can be received from each process
 				}
 
-				if checkVectValidity(message.MvcMessage, init) { // Accept only valid VECT msgs
-					vectMutex.Lock()
-					vect[message.From] = message.MvcMessage.Value
-					vectMutex.Unlock()
+				if _, in := pending[message.From]; !in {
+					accept(message.From, message.MvcMessage)
 				}
 			}
 		}
 	}()
 }
 
-func rbMVC(id int, mvcMessage types.MvcMessage) {
+// decideMVC - Sends the decision of MVC to the VC (or SSVC) instance that waits for it, unless it has been collected
+func (node *Node) decideMVC(mvcid int, value []byte) {
+	node.answerMutex.RLock()
+	answer := node.MVCAnswer[mvcid]
+	node.answerMutex.RUnlock()
+	if answer != nil {
+		answer <- value
+	}
+}
+
+func (node *Node) rbMVC(id int, mvcMessage types.MvcMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(mvcMessage)
@@ -132,7 +191,7 @@ func rbMVC(id int, mvcMessage types.MvcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	msg := types.NewMessage(w.Bytes(), "MVC")
+	msg := node.Messenger.NewMessage(w.Bytes(), "MVC")
 	w = new(bytes.Buffer)
 	encoder = gob.NewEncoder(w)
 	err = encoder.Encode(msg)
@@ -140,12 +199,12 @@ func rbMVC(id int, mvcMessage types.MvcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	go ReliableBroadcast(id, "MVC", w.Bytes())
+	go node.ReliableBroadcast(id, "MVC", w.Bytes())
 }
 
 /* -------------------------------- Helper Functions -------------------------------- */
 
-func checkVectValidity(message types.MvcMessage, init map[int][]byte) bool {
+func (node *Node) checkVectValidity(message types.MvcMessage, init map[int][]byte) bool {
 	for key, val := range message.Vector {
 		if bytes.Equal(val, variables.DEFAULT) {
 			continue
@@ -155,14 +214,14 @@ func checkVectValidity(message types.MvcMessage, init map[int][]byte) bool {
 		}
 	}
 
-	val := calculateW(message.Vector)
+	val := node.calculateW(message.Vector)
 
 	return bytes.Equal(val, message.Value)
 }
 
-func fillVector(array map[int][]byte) map[int][]byte {
-	vector := make(map[int][]byte, variables.N)
-	for i := 0; i < variables.N; i++ {
+func (node *Node) fillVector(array map[int][]byte) map[int][]byte {
+	vector := make(map[int][]byte, node.n())
+	for i := 0; i < node.n(); i++ {
 		if _, in := array[i]; in {
 			vector[i] = array[i]
 		} else {
@@ -172,19 +231,19 @@ func fillVector(array map[int][]byte) map[int][]byte {
 	return vector
 }
 
-func calculateW(vector map[int][]byte) []byte {
+func (node *Node) calculateW(vector map[int][]byte) []byte {
 	counter, dict := findOccurrences(vector)
 
 	w := variables.DEFAULT
 	count := 0
 	for k, v := range counter {
-		if v >= (variables.N-(2*variables.F)) && count == 0 {
+		if v >= (node.n()-(2*node.f())) && count == 0 {
 			w = dict[k]
 			count = v
-		} else if v >= (variables.N-(2*variables.F)) && v > count {
+		} else if v >= (node.n()-(2*node.f())) && v > count {
 			w = dict[k]
 			count = v
-		} else if v >= (variables.N-(2*variables.F)) && v == count &&
+		} else if v >= (node.n()-(2*node.f())) && v == count &&
 			bytes.Compare(w, dict[k]) == -1 {
 			w = dict[k]
 		}
@@ -192,14 +251,14 @@ func calculateW(vector map[int][]byte) []byte {
 	return w
 }
 
-func calculateBinaryValue(vector map[int][]byte) uint {
+func (node *Node) calculateBinaryValue(vector map[int][]byte) uint {
 	counter, _ := findOccurrences(vector)
 
 	if len(counter) > 1 {
 		return 0
 	}
 
-	if counter[0] >= (variables.N - (2 * variables.F)) {
+	if counter[0] >= (node.n() - (2 * node.f())) {
 		return 1
 	}
 	return 0
@@ -0,0 +1,220 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/variables"
+	"BFTWithoutSignatures/wal"
+	"sync"
+	"time"
+)
+
+// Node - A replica that owns its configuration, messenger and the state of every module
+type Node struct {
+	variables.Replica
+	config.Options
+
+	// RunSSABC - Use SelfStabilizedAtomicBroadcast or not
+	RunSSABC bool
+
+	// Messenger - The links of the replica to the other servers and the clients
+	Messenger *messenger.Messenger
+
+	/* ------------------------------ Request Handler ------------------------------ */
+
+	// Delivered - Channel to receive delivered messages from ABC
+	Delivered chan struct {
+		Id    int
+		Value [][]byte
+	}
+	Aid int
+
+	// StateMachine - The application to which the delivered requests are applied (set before RequestHandler)
+	StateMachine StateMachine
+
+	applied *appliedRequests // The requests applied from every client
+
+	// WAL - The durable log of the delivered batches (nil if the replica does not persist them)
+	WAL *wal.Log
+
+	lastDelivered int        // Index of the last delivered batch
+	stateMutex    sync.Mutex // Guards the state of the request handler (applied, lastDelivered, StateMachine)
+
+	/* ------------------------------ Membership ----------------------------------- */
+
+	// LoadCoinKeys - Reads the coin keys of replica id for n replicas from a folder, when a reconfiguration
+	// deals new ones (threshenc.LoadCoinKeys by default): a change whose keys it cannot read is not proposed
+	LoadCoinKeys func(folder string, id int, n int) (*threshenc.CoinKeys, error)
+
+	// Retired - Closed when this replica is removed from the membership
+	Retired chan struct{}
+
+	membership      configuration  // The membership as ordered by ABC (guarded by stateMutex)
+	members         []types.Member // The membership that the modules run with (see reconfigure)
+	version         int            // The version of the ordered membership in members
+	coinKeys        string         // The folder of the coin keys in use
+	retired         bool
+	membershipMutex sync.RWMutex // Guards N, F, members, version, coinKeys and retired
+
+	/* ------------------------------ State Transfer ------------------------------- */
+
+	stateReplies chan struct {
+		StateMessage types.StateMessage
+		From         int
+	}
+	catchUpMutex sync.Mutex // Held while the replica catches up (one at a time, see CatchUp)
+
+	/* ------------------------------ Checkpoints ---------------------------------- */
+
+	stableCheckpoint int                        // The last delivery index whose state 2f+1 replicas agree on
+	checkpoints      map[int]types.StateMessage // from -> last checkpoint
+	ownCheckpoints   map[int][]byte             // index -> snapshot
+	instances        map[int]int                // index -> root instance that delivered it
+	checkpointMutex  sync.Mutex
+
+	// Guards the answer channels of the modules (VCAnswer, MVCAnswer, BCAnswer, SSVCAnswer)
+	answerMutex sync.RWMutex
+
+	logs map[string]*logger.Logger // module -> its logger, with the field of the replica
+
+	/* ------------------------------ Metrics -------------------------------------- */
+
+	broadcastAt  map[string]time.Time // value -> when this replica broadcast it
+	requestedAt  map[string]time.Time // "cid num" -> when this replica received it from the client
+	metricsMutex sync.Mutex
+
+	/* ------------------------------ Atomic Broadcast ----------------------------- */
+
+	aid          int // The next instance to deliver (guarded by delMutex, SSABC uses it in its loop only)
+	num          int
+	received     map[int]map[int][]byte
+	rDelivered   [][]byte
+	proposed     map[string]int         // value -> copies proposed in the running VC instances
+	origins      map[string][]abcOrigin // value -> RB instances that broadcasted it
+	abcDelivered map[int][]abcOrigin    // aid -> RB instances of the delivered values
+	delMutex     sync.RWMutex
+
+	// VCAnswer - Channel to receive the answer from VC
+	VCAnswer map[int]chan map[int][]byte
+
+	/* ------------------------------ RB for ABC ----------------------------------- */
+
+	initial   map[int]map[int][]byte         // instance, num
+	echo      map[int]map[int]map[int][]byte // instance, num, from
+	ready     map[int]map[int]map[int][]byte
+	sentEcho  map[int]map[int]bool
+	sentReady map[int]map[int]bool
+	accepted  map[int]map[int]bool
+
+	rbAbcMutex   sync.Mutex           // Guards the state of RB for ABC
+	rbAbcCollect chan []abcOrigin     // Instances to collect
+	rbCollected  map[int]map[int]bool // instance, num (collected above rbLow)
+	rbLow        map[int]int          // instance -> every num below it has been collected
+
+	/* ------------------------------ Vector Consensus ----------------------------- */
+
+	// MVCAnswer - Channel to receive the answer from MVC
+	MVCAnswer map[int]chan []byte
+
+	/* ------------------------------ Multi-valued Consensus ----------------------- */
+
+	// BCAnswer - Channel to receive the answer from BC
+	BCAnswer map[int]chan uint
+
+	/* ------------------------------ Binary Consensus ----------------------------- */
+
+	// Coin - The common coin tossed at the end of every BC round
+	Coin CommonCoin
+
+	binValues map[int][]uint
+	mutex     sync.RWMutex
+
+	/* ------------------------------ Self Stabilized Atomic Broadcast ------------- */
+
+	// SSVCAnswer - Channel to receive the answer from SSVC
+	SSVCAnswer                    map[int]chan map[int][]byte
+	getValue                      ssabcMT
+	readRequest, handleNewRequest chan bool
+	ssnum                         uint32
+	ssepoch                       uint32 // The epoch of ssnum (see ssabcReset)
+
+	// SSABCFaults - Functions that SSABC applies to its epoch and counter between two steps (to run
+	// tests with transient faults - not part of the algorithm)
+	SSABCFaults chan func(epoch, num *uint32)
+}
+
+// NewNode - Creates replica r, which communicates through the given messenger
+func NewNode(r variables.Replica, options config.Options, runSSABC bool,
+	msgr *messenger.Messenger) *Node {
+	node := &Node{
+		Replica:   r,
+		Options:   options,
+		RunSSABC:  runSSABC,
+		Messenger: msgr,
+		Delivered: make(chan struct {
+			Id    int
+			Value [][]byte
+		}),
+		Aid:            1,
+		StateMachine:   NewRuneArray(),
+		applied:        newAppliedRequests(),
+		VCAnswer:       make(map[int]chan map[int][]byte),
+		initial:        make(map[int]map[int][]byte, r.N),
+		echo:           make(map[int]map[int]map[int][]byte, r.N),
+		ready:          make(map[int]map[int]map[int][]byte, r.N),
+		sentEcho:       make(map[int]map[int]bool, r.N),
+		sentReady:      make(map[int]map[int]bool, r.N),
+		accepted:       make(map[int]map[int]bool, r.N),
+		MVCAnswer:      make(map[int]chan []byte),
+		BCAnswer:       make(map[int]chan uint),
+		SSVCAnswer:     make(map[int]chan map[int][]byte),
+		binValues:      make(map[int][]uint),
+		checkpoints:    make(map[int]types.StateMessage, r.N),
+		ownCheckpoints: make(map[int][]byte),
+		instances:      make(map[int]int),
+		origins:        make(map[string][]abcOrigin),
+		abcDelivered:   make(map[int][]abcOrigin),
+		rbAbcCollect:   make(chan []abcOrigin, 16),
+		rbCollected:    make(map[int]map[int]bool, r.N),
+		rbLow:          make(map[int]int, r.N),
+		stateReplies: make(chan struct {
+			StateMessage types.StateMessage
+			From         int
+		}, 2*r.N),
+		broadcastAt:  make(map[string]time.Time),
+		requestedAt:  make(map[string]time.Time),
+		LoadCoinKeys: readCoinKeys,
+		Retired:      make(chan struct{}),
+		membership:   configuration{Members: defaultMembers(r.N)},
+		members:      defaultMembers(r.N),
+	}
+
+	node.logs = make(map[string]*logger.Logger, len(logModules))
+	for _, module := range logModules {
+		node.logs[module] = logger.Module(module).With("replica", r.ID)
+	}
+
+	if options.DeterministicCoin {
+		node.Coin = NewDeterministicCoin()
+	} else {
+		node.Coin = NewThresholdCoin(node)
+	}
+	node.registerMetrics()
+
+	return node
+}
+
+// logModules - The modules whose loggers NewNode creates
+var logModules = []string{"abc", "rb", "vc", "mvc", "bc", "coin", "ssabc", "ssvc", "reqh", "membership",
+	"state", "checkpoint", "recovery", "batch"}
+
+// log - The logger of a module of the replica
+func (node *Node) log(module string) *logger.Logger {
+	if l, in := node.logs[module]; in {
+		return l
+	}
+	return logger.Module(module).With("replica", node.ID)
+}
@@ -0,0 +1,37 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/wal"
+)
+
+// Recover - Restores the last snapshot and replays the batches of the write-ahead log to rebuild the
+// StateMachine and the duplicate-suppression table, and keeps logging the next batches in it.
+// It has to be called before ABC or SSABC is initiated.
+func (node *Node) Recover(walLog *wal.Log) error {
+	node.WAL = walLog
+
+	_, snapshot, err := walLog.Snapshot()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		_, err = node.restore(snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	batches := 0
+	err = walLog.Replay(func(index int, batch [][]byte) {
+		if node.deliver(index, batch, false) {
+			node.Aid++
+		}
+		batches++
+	})
+	if err != nil {
+		return err
+	}
+
+	node.log("recovery").Info("recovered", "batches", batches, "index", node.lastDelivered)
+	return nil
+}
@@ -4,53 +4,68 @@ import (
 	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
 )
 
 // ReliableBroadcast - The method that is called to initiate the RB module
-func ReliableBroadcast(rbid int, mType string, initVal []byte) {
+func (node *Node) ReliableBroadcast(rbid int, mType string, initVal []byte) {
 	// START Variables initialization
-	initial := make(map[int][]byte, variables.N)
-	echo := make(map[int]map[int][]byte, variables.N)
-	ready := make(map[int]map[int][]byte, variables.N)
-	sentEcho := make(map[int]bool, variables.N)
-	sentReady := make(map[int]bool, variables.N)
-	accepted := make(map[int]bool, variables.N)
-	for i := 0; i < variables.N; i++ {
-		echo[i] = make(map[int][]byte, variables.N)
-		ready[i] = make(map[int][]byte, variables.N)
+	initial := make(map[int][]byte, node.n())
+	echo := make(map[int]map[int][]byte, node.n())
+	ready := make(map[int]map[int][]byte, node.n())
+	sentEcho := make(map[int]bool, node.n())
+	sentReady := make(map[int]bool, node.n())
+	accepted := make(map[int]bool, node.n())
+	for i := 0; i < node.n(); i++ {
+		echo[i] = make(map[int][]byte, node.n())
+		ready[i] = make(map[int][]byte, node.n())
 		sentEcho[i] = false
 		sentReady[i] = false
 		accepted[i] = false
 	}
 
-	messenger.RbMutex.Lock()
-	if _, in := messenger.RbChannel[mType][rbid]; !in {
-		messenger.RbChannel[mType][rbid] = make(chan struct {
+	node.Messenger.RbMutex.Lock()
+	if _, in := node.Messenger.RbChannel[mType][rbid]; !in {
+		node.Messenger.RbChannel[mType][rbid] = make(chan struct {
 			RbMessage types.RbMessage
 			From      int
 		})
 	}
-	messageChannel := messenger.RbChannel[mType][rbid]
-	messenger.RbMutex.Unlock()
+	messageChannel := node.Messenger.RbChannel[mType][rbid]
+	node.Messenger.RbMutex.Unlock()
+
+	depth := messenger.DepthVC
+	if mType == "MVC" {
+		depth = messenger.DepthBC
+	}
+	done := node.Messenger.Done(messenger.RootInstance(rbid, depth))
 	// END Variables initialization
 
 	// Step 0
-	sendToAll(types.NewRbMessage(rbid, "INIT", mType, variables.ID, initVal))
-	sendToAll(types.NewRbMessage(rbid, "ECHO", mType, variables.ID, initVal))
+	node.sendToAll(types.NewRbMessage(rbid, "INIT", mType, node.ID, initVal))
+	node.sendToAll(types.NewRbMessage(rbid, "ECHO", mType, node.ID, initVal))
+
+	initial[node.ID] = initVal
+	echo[node.ID][node.ID] = initVal
+	sentEcho[node.ID] = true
+	ready[node.ID][node.ID] = initVal
+	accepted[node.ID] = true
 
-	initial[variables.ID] = initVal
-	echo[variables.ID][variables.ID] = initVal
-	sentEcho[variables.ID] = true
-	ready[variables.ID][variables.ID] = initVal
-	accepted[variables.ID] = true
+	node.log("rb").Debug("INIT", "type", mType, "rbid", rbid, "process", node.ID)
+	node.log("rb").Debug("INIT->ECHO", "type", mType, "rbid", rbid, "process", node.ID)
 
-	logger.OutLogger.Print(rbid, ".RB-", mType, ": INIT ", variables.ID, "\n")
-	logger.OutLogger.Print(rbid, ".RB-", mType, ": INIT->ECHO ", variables.ID, "\n")
+	for {
+		var message struct {
+			RbMessage types.RbMessage
+			From      int
+		}
+		select {
+		case <-done:
+			return // The instance has been collected
+		case message = <-messageChannel:
+		}
 
-	for message := range messageChannel {
 		tag := message.RbMessage.Tag
 		instance := message.RbMessage.Process
 		if tag == "INIT" {
@@ -58,11 +73,11 @@ func ReliableBroadcast(rbid int, mType string, initVal []byte) {
 				continue // Only one value can be received from each process
 			}
 			initial[instance] = message.RbMessage.Value
-			sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, initial[instance]))
+			node.sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, initial[instance]))
 
-			echo[instance][variables.ID] = initial[instance]
+			echo[instance][node.ID] = initial[instance]
 			sentEcho[instance] = true
-			logger.OutLogger.Print(rbid, ".RB-", mType, ": INIT->ECHO ", instance, "\n")
+			node.log("rb").Debug("INIT->ECHO", "type", mType, "rbid", rbid, "process", instance)
 
 		} else if tag == "ECHO" {
 			if _, in := echo[instance][message.From]; in {
@@ -72,19 +87,19 @@ func ReliableBroadcast(rbid int, mType string, initVal []byte) {
 
 			counter, dict := CountMessages(echo[instance])
 			for k, v := range counter {
-				if v >= ((variables.N+variables.F)/2) && !sentEcho[instance] { // Step 1
-					sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, dict[k]))
+				if v >= ((node.n()+node.f())/2) && !sentEcho[instance] { // Step 1
+					node.sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, dict[k]))
 
-					echo[instance][variables.ID] = dict[k]
+					echo[instance][node.ID] = dict[k]
 					sentEcho[instance] = true
-					logger.OutLogger.Print(rbid, ".RB-", mType, ": ECHO->ECHO ", instance, "\n")
+					node.log("rb").Debug("ECHO->ECHO", "type", mType, "rbid", rbid, "process", instance)
 
-				} else if v >= ((variables.N+variables.F)/2) && !sentReady[instance] { // Step 2
-					sendToAll(types.NewRbMessage(rbid, "READY", mType, instance, dict[k]))
+				} else if v >= ((node.n()+node.f())/2) && !sentReady[instance] { // Step 2
+					node.sendToAll(types.NewRbMessage(rbid, "READY", mType, instance, dict[k]))
 
-					ready[instance][variables.ID] = dict[k]
+					ready[instance][node.ID] = dict[k]
 					sentReady[instance] = true
-					logger.OutLogger.Print(rbid, ".RB-", mType, ": ECHO->READY ", instance, "\n")
+					node.log("rb").Debug("ECHO->READY", "type", mType, "rbid", rbid, "process", instance)
 				}
 			}
 
@@ -96,31 +111,31 @@ func ReliableBroadcast(rbid int, mType string, initVal []byte) {
 
 			counter, dict := CountMessages(ready[instance])
 			for k, v := range counter {
-				if v >= ((2*variables.F)+1) && !accepted[instance] { // Step 3 - Accept v
-					go messenger.HandleMessage(dict[k])
+				if v >= ((2*node.f())+1) && !accepted[instance] { // Step 3 - Accept v
+					go node.acceptRb(dict[k], instance)
 					accepted[instance] = true
-					logger.OutLogger.Print(rbid, ".RB-", mType, ": accept-", instance, "\n")
+					node.log("rb").Debug("accept", "type", mType, "rbid", rbid, "process", instance)
 
-				} else if v >= (variables.F+1) && !sentEcho[instance] { // Step 1
-					sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, dict[k]))
+				} else if v >= (node.f()+1) && !sentEcho[instance] { // Step 1
+					node.sendToAll(types.NewRbMessage(rbid, "ECHO", mType, instance, dict[k]))
 
-					echo[instance][variables.ID] = dict[k]
+					echo[instance][node.ID] = dict[k]
 					sentEcho[instance] = true
-					logger.OutLogger.Print(rbid, ".RB-", mType, ": READY->ECHO ", instance, "\n")
+					node.log("rb").Debug("READY->ECHO", "type", mType, "rbid", rbid, "process", instance)
 
-				} else if v >= (variables.F+1) && !sentReady[instance] { // Step 2
-					sendToAll(types.NewRbMessage(rbid, "READY", mType, instance, dict[k]))
+				} else if v >= (node.f()+1) && !sentReady[instance] { // Step 2
+					node.sendToAll(types.NewRbMessage(rbid, "READY", mType, instance, dict[k]))
 
-					ready[instance][variables.ID] = dict[k]
+					ready[instance][node.ID] = dict[k]
 					sentReady[instance] = true
-					logger.OutLogger.Print(rbid, ".RB-", mType, ": READY->READY ", instance, "\n")
+					node.log("rb").Debug("READY->READY", "type", mType, "rbid", rbid, "process", instance)
 				}
 			}
 		}
 	}
 }
 
-func sendToAll(rbMessage types.RbMessage) {
+func (node *Node) sendToAll(rbMessage types.RbMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(rbMessage)
@@ -128,8 +143,10 @@ func sendToAll(rbMessage types.RbMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	message := types.NewMessage(w.Bytes(), "RB")
-	messenger.Broadcast(message)
+	message := node.Messenger.NewMessage(w.Bytes(), "RB")
+	if err := node.Messenger.BroadcastWait(message); err != nil {
+		node.log("rb").Warn("message not sent", "err", err)
+	}
 }
 
 // CountMessages - Counts the messages received from RB
@@ -150,3 +167,12 @@ func CountMessages(vector map[int][]byte) (map[int]int, map[int][]byte) {
 
 	return counter, dict
 }
+
+// acceptRb - Handles the message that the RB instance of process delivered (a malformed one is
+// attributed to the process that broadcasted it)
+func (node *Node) acceptRb(message []byte, process int) {
+	err := node.Messenger.HandleMessage(message, process)
+	if err != nil {
+		node.Messenger.ReportMalformed(process, err)
+	}
+}
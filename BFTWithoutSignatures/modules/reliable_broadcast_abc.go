@@ -2,142 +2,163 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
 )
 
-var (
-	initial   = make(map[int]map[int][]byte, variables.N)         // instance, num
-	echo      = make(map[int]map[int]map[int][]byte, variables.N) // instance, num, from
-	ready     = make(map[int]map[int]map[int][]byte, variables.N)
-	sentEcho  = make(map[int]map[int]bool, variables.N)
-	sentReady = make(map[int]map[int]bool, variables.N)
-	accepted  = make(map[int]map[int]bool, variables.N)
-)
-
 // SendRBInit - Sends the INIT message
-func SendRBInit(num int, initVal []byte) {
-	broadcastAll(types.NewRbMessage(num, "INIT", "ABC", variables.ID, initVal))
-	broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", variables.ID, initVal))
+func (node *Node) SendRBInit(num int, initVal []byte) {
+	node.broadcastAll(types.NewRbMessage(num, "INIT", "ABC", node.ID, initVal))
+	node.broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", node.ID, initVal))
 
-	initial[variables.ID][num] = initVal
+	node.rbAbcMutex.Lock()
+	defer node.rbAbcMutex.Unlock()
 
-	echo[variables.ID][num] = make(map[int][]byte)
-	echo[variables.ID][num][variables.ID] = initVal
-	sentEcho[variables.ID][num] = true
+	node.initial[node.ID][num] = initVal
 
-	ready[variables.ID][num] = make(map[int][]byte)
-	ready[variables.ID][num][variables.ID] = initVal
-	accepted[variables.ID][num] = true
+	node.echo[node.ID][num] = make(map[int][]byte)
+	node.echo[node.ID][num][node.ID] = initVal
+	node.sentEcho[node.ID][num] = true
 
-	logger.OutLogger.Print(num, ".RB-ABC: INIT ", variables.ID, "\n")
-	logger.OutLogger.Print(num, ".RB-ABC: INIT->ECHO ", variables.ID, "\n")
+	node.ready[node.ID][num] = make(map[int][]byte)
+	node.ready[node.ID][num][node.ID] = initVal
+	node.accepted[node.ID][num] = true
+
+	node.log("rb").Debug("INIT", "type", "ABC", "rbid", num, "process", node.ID)
+	node.log("rb").Debug("INIT->ECHO", "type", "ABC", "rbid", num, "process", node.ID)
 }
 
 // ReliableBroadcastAbc - The method that is called to initiate the RB module for ABC
-func ReliableBroadcastAbc() {
-	for i := 0; i < variables.N; i++ {
-		initial[i] = make(map[int][]byte)
-		echo[i] = make(map[int]map[int][]byte)
-		ready[i] = make(map[int]map[int][]byte)
-		sentEcho[i] = make(map[int]bool)
-		sentReady[i] = make(map[int]bool)
-		accepted[i] = make(map[int]bool)
+func (node *Node) ReliableBroadcastAbc() {
+	node.initRbAbc()
+
+	for {
+		select {
+		case origins := <-node.rbAbcCollect: // A checkpoint became stable
+			node.rbAbcMutex.Lock()
+			node.collectRbAbc(origins)
+			node.rbAbcMutex.Unlock()
+		case message := <-node.Messenger.RbAbcChannel:
+			node.handleRbAbc(message.RbMessage, message.From)
+		}
 	}
+}
 
-	for message := range messenger.RbAbcChannel {
-		tag := message.RbMessage.Tag
-		instance := message.RbMessage.Process
-		num := message.RbMessage.Rbid
-		if tag == "INIT" {
-			if _, in := initial[instance][num]; message.From != instance || in {
-				continue // Only one value can be received from each process
-			}
-			if echo[instance][num] == nil {
-				echo[instance][num] = make(map[int][]byte)
-			}
+// initRbAbc - Initializes the state of the RB for ABC instances of every process (once)
+func (node *Node) initRbAbc() {
+	node.rbAbcMutex.Lock()
+	defer node.rbAbcMutex.Unlock()
 
-			initial[instance][num] = message.RbMessage.Value
-			broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, initial[instance][num]))
+	for i := 0; i < node.n(); i++ {
+		if node.initial[i] != nil {
+			continue
+		}
+		node.initial[i] = make(map[int][]byte)
+		node.echo[i] = make(map[int]map[int][]byte)
+		node.ready[i] = make(map[int]map[int][]byte)
+		node.sentEcho[i] = make(map[int]bool)
+		node.sentReady[i] = make(map[int]bool)
+		node.accepted[i] = make(map[int]bool)
+	}
+}
 
-			echo[instance][num][variables.ID] = initial[instance][num]
-			sentEcho[instance][num] = true
-			logger.OutLogger.Print(num, ".RB-ABC: INIT->ECHO ", instance, "\n")
+// handleRbAbc - Handles an INIT, ECHO or READY message of an RB for ABC instance
+func (node *Node) handleRbAbc(rbMessage types.RbMessage, from int) {
+	node.rbAbcMutex.Lock()
+	defer node.rbAbcMutex.Unlock()
 
-		} else if tag == "ECHO" {
-			if _, in := echo[instance][num][message.From]; in {
-				continue // Only one value can be received from each process
-			}
-			if echo[instance][num] == nil {
-				echo[instance][num] = make(map[int][]byte)
-			}
-			if ready[instance][num] == nil {
-				ready[instance][num] = make(map[int][]byte)
-			}
+	tag := rbMessage.Tag
+	instance := rbMessage.Process
+	num := rbMessage.Rbid
+	if node.isRbAbcCollected(instance, num) {
+		return // Late message of a collected instance
+	}
+	if tag == "INIT" {
+		if _, in := node.initial[instance][num]; from != instance || in {
+			return // Only one value can be received from each process
+		}
+		if node.echo[instance][num] == nil {
+			node.echo[instance][num] = make(map[int][]byte)
+		}
+
+		node.initial[instance][num] = rbMessage.Value
+		node.broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, node.initial[instance][num]))
 
-			echo[instance][num][message.From] = message.RbMessage.Value
+		node.echo[instance][num][node.ID] = node.initial[instance][num]
+		node.sentEcho[instance][num] = true
+		node.log("rb").Debug("INIT->ECHO", "type", "ABC", "rbid", num, "process", instance)
 
-			counter, dict := CountMessages(echo[instance][num])
-			for k, v := range counter {
-				if v >= ((variables.N+variables.F)/2) && !sentEcho[instance][num] { // Step 1
-					broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, dict[k]))
+	} else if tag == "ECHO" {
+		if _, in := node.echo[instance][num][from]; in {
+			return // Only one value can be received from each process
+		}
+		if node.echo[instance][num] == nil {
+			node.echo[instance][num] = make(map[int][]byte)
+		}
+		if node.ready[instance][num] == nil {
+			node.ready[instance][num] = make(map[int][]byte)
+		}
 
-					echo[instance][num][variables.ID] = dict[k]
-					sentEcho[instance][num] = true
-					logger.OutLogger.Print(num, ".RB-ABC: ECHO->ECHO ", instance, "\n")
+		node.echo[instance][num][from] = rbMessage.Value
 
-				} else if v >= ((variables.N+variables.F)/2) && !sentReady[instance][num] { // Step 2
-					broadcastAll(types.NewRbMessage(num, "READY", "ABC", instance, dict[k]))
+		counter, dict := CountMessages(node.echo[instance][num])
+		for k, v := range counter {
+			if v >= ((node.n()+node.f())/2) && !node.sentEcho[instance][num] { // Step 1
+				node.broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, dict[k]))
 
-					ready[instance][num][variables.ID] = dict[k]
-					sentReady[instance][num] = true
-					logger.OutLogger.Print(num, ".RB-ABC: ECHO->READY ", instance, "\n")
-				}
-			}
+				node.echo[instance][num][node.ID] = dict[k]
+				node.sentEcho[instance][num] = true
+				node.log("rb").Debug("ECHO->ECHO", "type", "ABC", "rbid", num, "process", instance)
 
-		} else if tag == "READY" {
-			if _, in := ready[instance][num][message.From]; in {
-				continue // Only one value can be received from each process
-			}
-			if echo[instance][num] == nil {
-				echo[instance][num] = make(map[int][]byte)
-			}
-			if ready[instance][num] == nil {
-				ready[instance][num] = make(map[int][]byte)
+			} else if v >= ((node.n()+node.f())/2) && !node.sentReady[instance][num] { // Step 2
+				node.broadcastAll(types.NewRbMessage(num, "READY", "ABC", instance, dict[k]))
+
+				node.ready[instance][num][node.ID] = dict[k]
+				node.sentReady[instance][num] = true
+				node.log("rb").Debug("ECHO->READY", "type", "ABC", "rbid", num, "process", instance)
 			}
+		}
 
-			ready[instance][num][message.From] = message.RbMessage.Value
+	} else if tag == "READY" {
+		if _, in := node.ready[instance][num][from]; in {
+			return // Only one value can be received from each process
+		}
+		if node.echo[instance][num] == nil {
+			node.echo[instance][num] = make(map[int][]byte)
+		}
+		if node.ready[instance][num] == nil {
+			node.ready[instance][num] = make(map[int][]byte)
+		}
+
+		node.ready[instance][num][from] = rbMessage.Value
 
-			counter, dict := CountMessages(ready[instance][num])
-			for k, v := range counter {
-				if v >= ((2*variables.F)+1) && !accepted[instance][num] { // Step 3 - Accept v
-					go messenger.HandleMessage(dict[k])
-					accepted[instance][num] = true
-					logger.OutLogger.Print(num, ".RB-ABC: accept-", instance, "\n")
+		counter, dict := CountMessages(node.ready[instance][num])
+		for k, v := range counter {
+			if v >= ((2*node.f())+1) && !node.accepted[instance][num] { // Step 3 - Accept v
+				go node.acceptRb(dict[k], instance)
+				node.accepted[instance][num] = true
+				node.log("rb").Debug("accept", "type", "ABC", "rbid", num, "process", instance)
 
-				} else if v >= (variables.F+1) && !sentEcho[instance][num] { // Step 1
-					broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, dict[k]))
+			} else if v >= (node.f()+1) && !node.sentEcho[instance][num] { // Step 1
+				node.broadcastAll(types.NewRbMessage(num, "ECHO", "ABC", instance, dict[k]))
 
-					echo[instance][num][variables.ID] = dict[k]
-					sentEcho[instance][num] = true
-					logger.OutLogger.Print(num, ".RB-ABC: READY->ECHO ", instance, "\n")
+				node.echo[instance][num][node.ID] = dict[k]
+				node.sentEcho[instance][num] = true
+				node.log("rb").Debug("READY->ECHO", "type", "ABC", "rbid", num, "process", instance)
 
-				} else if v >= (variables.F+1) && !sentReady[instance][num] { // Step 2
-					broadcastAll(types.NewRbMessage(num, "READY", "ABC", instance, dict[k]))
+			} else if v >= (node.f()+1) && !node.sentReady[instance][num] { // Step 2
+				node.broadcastAll(types.NewRbMessage(num, "READY", "ABC", instance, dict[k]))
 
-					ready[instance][num][variables.ID] = dict[k]
-					sentReady[instance][num] = true
-					logger.OutLogger.Print(num, ".RB-ABC: READY->READY ", instance, "\n")
-				}
+				node.ready[instance][num][node.ID] = dict[k]
+				node.sentReady[instance][num] = true
+				node.log("rb").Debug("READY->READY", "type", "ABC", "rbid", num, "process", instance)
 			}
 		}
 	}
 }
 
-func broadcastAll(rbMessage types.RbMessage) {
+func (node *Node) broadcastAll(rbMessage types.RbMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(rbMessage)
@@ -145,6 +166,8 @@ func broadcastAll(rbMessage types.RbMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	message := types.NewMessage(w.Bytes(), "RB_ABC")
-	messenger.Broadcast(message)
+	message := node.Messenger.NewMessage(w.Bytes(), "RB_ABC")
+	if err := node.Messenger.BroadcastWait(message); err != nil {
+		node.log("rb").Warn("message not sent", "err", err)
+	}
 }
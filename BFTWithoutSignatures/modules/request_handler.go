@@ -2,94 +2,225 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
-	"log"
+	"sort"
 	"strconv"
 )
 
-var (
-	// Delivered - Channel to receive delivered messages from ABC
-	Delivered = make(chan struct {
-		Id    int
-		Value [][]byte
-	})
-	Aid = 1
-
-	// Array - The array that has to be in consensus
-	Array = make([]rune, 0)
-
-	cidNum = make([]string, 0)
-)
-
 // RequestHandler - The module that handles requests received from clients and replies to them
-func RequestHandler() {
+func (node *Node) RequestHandler() {
+	requests := make(chan []byte, batchQueue*node.BatchRequests)
+	go node.batcher(requests)
+	node.Messenger.Metrics.Registry.NewGaugeFunc("bft_batch_queue_depth",
+		"Client requests that wait to be packed into a batch.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(len(requests)))
+		})
 
-	// Accepts the requests from the clients and calls ABC
+	// Accepts the requests from the clients and passes them to the batcher, which calls ABC
 	go func() {
-		for message := range messenger.RequestChannel {
+		for message := range node.Messenger.RequestChannel {
 			var m types.ClientMessage
 			buffer := bytes.NewBuffer(message)
 			decoder := gob.NewDecoder(buffer)
 			err := decoder.Decode(&m)
 			if err != nil {
-				logger.ErrLogger.Fatal(err)
+				node.log("reqh").Error("dropped client request", "err", err)
+				continue
+			}
+			if !m.Op.Valid() { // no need to order it
+				go node.Messenger.ReplyClient(types.NewReplyMessage(node.ID, m.Num, types.StatusBadRequest, nil), m.Cid)
+				continue
 			}
 
-			id := (strconv.Itoa(m.Cid) + " " + strconv.Itoa(m.Num))
-			if notStringInSlice(id, cidNum) {
-				if variables.RunSSABC { // run SSABC
-					SelfStabilizedAtomicBroadcast(message)
-				} else { // run ABC
-					AtomicBroadcast(message)
-				}
+			node.stateMutex.Lock()
+			isNew := !node.applied.contains(m.Cid, m.Num)
+			node.stateMutex.Unlock()
+			if isNew {
+				node.markRequest(strconv.Itoa(m.Cid) + " " + strconv.Itoa(m.Num))
+				requests <- message
 			}
 		}
 	}()
 
-	// Gets the delivered result from ABC, appends it in the Array and replies to the client
+	// Gets the delivered result from ABC, logs it, applies it to the StateMachine and replies to the client
 	go func() {
-		for message := range Delivered {
-			willSend := false
-			for _, v := range message.Value {
-				var m types.ClientMessage
-				buffer := bytes.NewBuffer(v)
-				decoder := gob.NewDecoder(buffer)
-				err := decoder.Decode(&m)
-				if err != nil {
-					logger.ErrLogger.Printf(err.Error()," fault occurred")
-				} else {
-					//willSend = true
-					id := (strconv.Itoa(m.Cid) + " " + strconv.Itoa(m.Num))
-					if notStringInSlice(id, cidNum) {
-						willSend = true
-						cidNum = append(cidNum, id)
-						Array = append(Array, m.Value)
-						go func(){
-							messenger.ReplyClient(types.NewReplyMessage(m.Num), m.Cid)
-						}()
-					}
-				}
+		for message := range node.Delivered {
+			if node.deliver(message.Id, message.Value, true) {
+				node.log("reqh").Debug("applied", "aid", node.Aid, "id", message.Id)
+				node.Aid++
 			}
+		}
+	}()
+}
+
+// deliver - Applies the new requests of a delivered batch (whose values are packed requests, see
+// batching.go) to the StateMachine. A live batch (not replayed) is also logged in the WAL and replied
+// to the clients, and every CheckpointInterval batches a checkpoint is taken. Returns whether some
+// request was new.
+func (node *Node) deliver(id int, batch [][]byte, live bool) bool {
+	node.stateMutex.Lock()
+	defer node.stateMutex.Unlock()
 
-			if willSend {
-				//Aid = message.Id
-				logger.OutLogger.Printf("%d.REQH: array-%c\n", Aid, Array)
-				log.Printf("%d | %d.REQH: array (%d) - %c\n", variables.ID, Aid, len(Array), Array)
-				Aid++
+	if id <= node.lastDelivered {
+		return false // Already in the state (transferred from the others)
+	}
+	if live && node.WAL != nil {
+		err := node.WAL.Append(id, batch)
+		if err != nil {
+			logger.ErrLogger.Fatal(err)
+		}
+	}
+	node.lastDelivered = id
+	node.membership.advance(id)
+
+	willSend := false
+	for _, v := range node.unpackBatches(batch) {
+		var m types.ClientMessage
+		buffer := bytes.NewBuffer(v)
+		decoder := gob.NewDecoder(buffer)
+		err := decoder.Decode(&m)
+		if err != nil {
+			node.log("reqh").Warn("undecodable request", "err", err)
+		} else {
+			if !node.applied.contains(m.Cid, m.Num) {
+				willSend = true
+				node.applied.add(m.Cid, m.Num)
+				if m.Op == types.OpReconfigure {
+					node.order(m, node.lastDelivered)
+					continue
+				}
+				status, result := node.execute(m)
+				if live {
+					node.observeRequest(strconv.Itoa(m.Cid) + " " + strconv.Itoa(m.Num))
+				}
+				if live && m.Cid >= 0 { // Not a client otherwise
+					go func(m types.ClientMessage) {
+						node.Messenger.ReplyClient(types.NewReplyMessage(node.ID, m.Num, status, result), m.Cid)
+					}(m)
+				}
 			}
 		}
-	}()
+	}
+
+	if live && node.CheckpointInterval > 0 && node.lastDelivered%node.CheckpointInterval == 0 {
+		_, snapshot := node.encodeState()
+		go node.checkpoint(node.lastDelivered, snapshot)
+	}
+
+	return willSend
 }
 
-func notStringInSlice(a string, list []string) bool {
-	for _, b := range list {
-		if b == a {
+// inState - Whether every request of a value (a packed batch of requests) is in the state of the
+// request handler
+func (node *Node) inState(value []byte) bool {
+	requests := node.unpackBatches([][]byte{value})
+
+	node.stateMutex.Lock()
+	defer node.stateMutex.Unlock()
+
+	for _, r := range requests {
+		var m types.ClientMessage
+		if gob.NewDecoder(bytes.NewBuffer(r)).Decode(&m) != nil || !node.applied.contains(m.Cid, m.Num) {
 			return false
 		}
 	}
-	return true
+	return len(requests) > 0
+}
+
+// execute - Executes a delivered request on the StateMachine (deterministically on every replica)
+func (node *Node) execute(m types.ClientMessage) (types.Status, []byte) {
+	switch m.Op {
+	case types.OpWrite:
+		return types.StatusOK, node.StateMachine.Apply(m.Command)
+	case types.OpRead:
+		if querier, ok := node.StateMachine.(Querier); ok {
+			return types.StatusOK, querier.Query(m.Command)
+		}
+		return types.StatusUnsupported, nil
+	default:
+		return types.StatusBadRequest, nil
+	}
+}
+
+// appliedRequests - The requests applied from every client (the duplicate-suppression table): the nums
+// of a client below its watermark (clients number their requests from 1), and the other ones it holds
+type appliedRequests struct {
+	next  map[int]int          // cid -> every num from 1 up to next-1 has been applied
+	above map[int]map[int]bool // cid -> the other applied nums
+}
+
+// clientRequests - The requests applied from a client, as a snapshot holds them (sorted, so that every
+// replica encodes the same table alike)
+type clientRequests struct {
+	Cid   int
+	Next  int
+	Above []int
+}
+
+// newAppliedRequests - A table without any applied request
+func newAppliedRequests() *appliedRequests {
+	return &appliedRequests{next: make(map[int]int), above: make(map[int]map[int]bool)}
+}
+
+// watermark - Every num of the client below it has been applied
+func (a *appliedRequests) watermark(cid int) int {
+	if next, in := a.next[cid]; in {
+		return next
+	}
+	return 1
+}
+
+// contains - Whether request num of client cid has been applied
+func (a *appliedRequests) contains(cid int, num int) bool {
+	return (num >= 1 && num < a.watermark(cid)) || a.above[cid][num]
+}
+
+// add - Marks request num of client cid as applied, and moves the watermark of the client past it
+func (a *appliedRequests) add(cid int, num int) {
+	if a.above[cid] == nil {
+		a.above[cid] = make(map[int]bool)
+	}
+	a.above[cid][num] = true
+	next := a.watermark(cid)
+	for a.above[cid][next] {
+		delete(a.above[cid], next)
+		next++
+	}
+	a.next[cid] = next
+	if len(a.above[cid]) == 0 {
+		delete(a.above, cid)
+	}
+}
+
+// list - The table as a snapshot holds it
+func (a *appliedRequests) list() []clientRequests {
+	clients := make([]clientRequests, 0, len(a.next))
+	for cid, next := range a.next {
+		c := clientRequests{Cid: cid, Next: next}
+		for num := range a.above[cid] {
+			c.Above = append(c.Above, num)
+		}
+		sort.Ints(c.Above)
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Cid < clients[j].Cid })
+	return clients
+}
+
+// appliedFrom - The table that a snapshot holds
+func appliedFrom(clients []clientRequests) *appliedRequests {
+	a := newAppliedRequests()
+	for _, c := range clients {
+		a.next[c.Cid] = c.Next
+		if len(c.Above) > 0 {
+			a.above[c.Cid] = make(map[int]bool, len(c.Above))
+			for _, num := range c.Above {
+				a.above[c.Cid][num] = true
+			}
+		}
+	}
+	return a
 }
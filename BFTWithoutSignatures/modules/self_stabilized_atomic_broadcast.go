@@ -2,7 +2,7 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/tupleset"
 	"BFTWithoutSignatures/types"
 	"BFTWithoutSignatures/variables"
 	"bytes"
@@ -10,12 +10,10 @@ import (
 	"encoding/json"
   "sort"
 	"math"
-	"strconv"
 	"time"
 
 	"fmt"
 	"BFTWithoutSignatures/faults"
-	"BFTWithoutSignatures/config"
 )
 
 type ssabcMT = types.SSABCMessageTuple
@@ -28,44 +26,70 @@ type ssabcMessageID struct{
 	Num uint32
 }
 
-var (
-  // SSVCAnswer - Channel to receive the answer from SSVC
-	SSVCAnswer map[int]chan map[int][]byte
-	getValue ssabcMT
-	readRequest, handleNewRequest chan bool
-	ssnum uint32
-)
+/*
+	Global reset: a replica numbers the values it broadcasts in its epoch,
+	and every tuple carries that epoch. Once the last number (SSABCNumLimit-1)
+	of f+1 replicas is delivered, every correct replica sees in the same
+	batch that their counters are exhausted (see the delivery history), so
+	they all ask for a reset: they gossip it, and add a vote for it to what
+	they propose to SSVC (a replica that sees f+1 others ask for a reset asks
+	too). The first SSVC decision with f+1 votes moves every correct replica
+	to the next epoch after its batch, i.e. at the same index: the counter,
+	the delivered history and the messages are emptied, and a value that is
+	not delivered yet is broadcast again in the new epoch. The tuples of
+	another epoch are flushed, and a replica whose epoch is more than one
+	away from the one that 2f+1 replicas gossip (a corrupted epoch) adopts
+	theirs.
+	A corrupted counter (not above the last number that the replica used in
+	its epoch, or beyond the limit) is moved past that number at once, so the
+	replica never numbers two values alike, and the replica asks for a reset
+	until it moves to the next epoch. As with exhausted counters, f+1
+	replicas that ask make every correct replica vote, so corrupted counters
+	at f+1 replicas lead to a reset; one replica alone does not (a Byzantine
+	one could ask all the time), and the local repair is all it needs.
+*/
+
+// ssabcResetValue - The value of the tuple with which a replica votes for a global reset in its proposals
+var ssabcResetValue = []byte("RESET")
 
 // InitiateAtomicBroadcast - The method that is called to initiate the ABC module
-func InitiateSelfStabilizedAtomicBroadcast() {
+func (node *Node) InitiateSelfStabilizedAtomicBroadcast() {
 	// init channels and getValue
-	readRequest = make(chan bool)
-	handleNewRequest = make(chan bool)
-	ssnum = 0
-	getValue = ssabcMT{Sender: -1, Num: math.MaxUint32, Value: variables.DEFAULT}
-	SSVCAnswer = make(map[int]chan map[int][]byte)
-
-	go ssabcAlgorithm()
+	node.readRequest = make(chan bool)
+	node.handleNewRequest = make(chan bool)
+	node.ssnum = 0
+	node.ssepoch = 0
+	node.getValue = ssabcMT{Sender: -1, Num: math.MaxUint32, Value: variables.DEFAULT}
+	node.answerMutex.Lock()
+	node.SSVCAnswer = make(map[int]chan map[int][]byte)
+	node.answerMutex.Unlock()
+
+	go node.ssabcAlgorithm()
 }
 
 // SelfStabilizedAtomicBroadcast - The method that is called to broadcast a new SSABC value
-func SelfStabilizedAtomicBroadcast(m []byte){
-	<- handleNewRequest
-	getValue = ssabcMT{Sender: variables.ID, Num: ssnum, Value: m}	// add new request
-	ssnum++
-	readRequest <- true
+func (node *Node) SelfStabilizedAtomicBroadcast(m []byte){
+	<- node.handleNewRequest
+	node.markBroadcast(m)
+	node.getValue = ssabcMT{Sender: node.ID, Num: node.ssnum, Epoch: node.ssepoch, Value: m}	// add new request
+	node.ssnum++
+	node.readRequest <- true
+}
+
+// ssabcInstance - An SSVC instance whose decision is not delivered yet
+type ssabcInstance struct {
+	id int
+	answer chan map[int][]byte
+	proposed *tupleset.SSABCSet
 }
 
 // Execution of the SSABC algorithm
-func ssabcAlgorithm() {
+func (node *Node) ssabcAlgorithm() {
 
 	// START Variables initialization
-	msg := make(map[int]map[string][]ssabcMT)	// received messages
-	for i:=0; i < variables.N; i++ {	// initialize maps of messages for every process
-		msg = flushProcessSSABCMsg(msg, i)
-	}
+	msg := tupleset.NewSSABCMessages(node.n())	// received messages of every process
 
-	aDeliveredTotal := []ssabcMT{}	// every message that was AB delivered
+	history := SSABCHistory{}	// what was AB delivered in this epoch
 	rDelivered := []ssabcMT{}			// RB delivered messages
 	aDeliveredMT := []ssabcMT{}			// AB delivered messages
 	aDelivered := [][]byte{}			// RB delivered messages
@@ -74,6 +98,11 @@ func ssabcAlgorithm() {
 	readyMsgs := []ssabcMT{}
 	noReq := true
 
+	epochs := make(map[int]uint32)	// the epoch that every process gossiped last
+	resets := make(map[int]bool)	// if every process asked for a reset of that epoch
+	corrupted := false	// if the counter was corrupted in this epoch (see Global reset)
+	voting := false	// if this process votes for a reset of its epoch (see Global reset)
+
 	// channels to coordinate algorithm execution and message receipt
 	startReadingMessages := make(chan bool)
 	pauseReadingMessages := make(chan bool)
@@ -81,145 +110,274 @@ func ssabcAlgorithm() {
 
 	// incoming messages
 	messageQueue := []ssabcmsg{}
+	queued := make(map[ssabcQueueKey][]ssabcmsg)	// the messages in messageQueue, by key
+	received := make(chan bool, 1)	// a message was queued
+
+	// gossip (see Anti-entropy gossip)
+	gossip := newSSGossip()
+	views := tupleset.NewSSABCMessages(node.n())	// the messages of every process, as it gossiped them
+	sent := tupleset.NewSSABCMessages(node.n())	// the messages of this process, as the others have them
 
-	aid = 1	// just to indicate the sequence - not part of the algorithm
+	node.aid = node.lastDelivered + 1	// just to indicate the sequence - not part of the algorithm
 	ssvcid := 0	// to get the results from SSVC
+	running := []ssabcInstance{}	// the SSVC instances whose decision is not delivered yet, in order
 
 	/****************************************************************************/
 	// to run tests with transient faults - not part of the algorithm
-	transientFaults := config.Transient
+	transientFaults := node.Transient
 	/****************************************************************************/
 	// END Variables initialization
 
+	// reset - Moves to the given epoch (see Global reset)
+	reset := func(epoch uint32, cause string) {
+		node.log("ssabc").Info("reset", "epoch", epoch, "cause", cause)
+		node.Messenger.Metrics.SSABCResets.Inc(cause)
+		node.ssepoch, node.ssnum = epoch, 0
+		corrupted, voting = false, false	// the gossip that waits for the next decision must not ask again
+		history = SSABCHistory{}
+		for _, i := range msg.Processes() {
+			msg.Flush(i)
+		}
+	}
 
 	/* ---------------------------Execute Algorithm----------------------------- */
 	go func (){
 		for {
 
-			// handle new request if exists
-			if areSSABCMessagesEqual(getValue, ssabcMT{Sender: -1, Num: math.MaxUint32,
-				 Value: variables.DEFAULT}) {
+			// the replica shuts down
+			select{
+			case <- node.Messenger.Stopped():
+				quitReadingMessages <- true
+				return
+			default:
+			}
+
+			// switch to the membership of the next instance
+			if !node.reconfigure(node.aid) {
+				return
+			}
+			for i:=0; i < node.n(); i++ {	// the processes that joined
+				if !msg.Has(i) {
+					msg.Flush(i)
+				}
+				if !views.Has(i) {
+					views.Flush(i)
+				}
+			}
+
+			/************************************************************************/
+			// TRANSIENT TESTS - NOT PART OF THE ALGORITHM (can be removed)
+			select{
+			case fault := <- node.SSABCFaults:
+				fault(&node.ssepoch, &node.ssnum)
+			default:
+			}
+			/************************************************************************/
+
+			// A corrupted counter continues after the last number of this replica in its epoch (it
+			// starts again only with a global reset, so a number is never used twice), and the replica
+			// asks for that reset (before the counter numbers a new request)
+			if last, in := node.lastSSABCNum(msg, history); (in && node.ssnum <= last) ||
+				node.ssnum > node.ssabcNumLimit() {
+				node.ssnum = 0
+				if in {
+					node.ssnum = last + 1
+				}
+				corrupted = true
+				node.Messenger.Metrics.Flushes.Inc("SSABC", "num")
+			}
+
+			// handle new request if exists (and the counter is not exhausted)
+			if areSSABCMessagesEqual(node.getValue, ssabcMT{Sender: -1, Num: math.MaxUint32,
+				 Value: variables.DEFAULT}) && node.ssnum < node.ssabcNumLimit() {
 				select{
-				case handleNewRequest <- true:
-					<- readRequest
+				case node.handleNewRequest <- true:
+					<- node.readRequest
 				default:
 				}
 			}
 
 			// handle new received messages
 			pauseReadingMessages <- true
-			msg = handleNewSSABCMessages(messageQueue, msg)
+			for _, message := range messageQueue {
+				if message.SSABCMessage.Gossip != types.GossipPull {
+					epochs[message.From] = message.SSABCMessage.Epoch
+					resets[message.From] = message.SSABCMessage.Reset
+				}
+			}
+			msg = node.handleNewSSABCMessages(node.receiveSSABCGossip(gossip, messageQueue, views), msg)
 			messageQueue = []ssabcmsg{}
+			queued = make(map[ssabcQueueKey][]ssabcmsg)
 			startReadingMessages <- true
 
+			// A corrupted epoch is more than one away from the one that 2f+1 processes gossip
+			if epoch, count := ssabcCommonEpoch(epochs, node.ID); count >= (2*node.f() + 1) &&
+				ssabcEpochDistance(epoch, node.ssepoch) > 1 {
+				reset(epoch, "adopted")
+			}
+			msg = flushOtherSSABCEpochs(msg, node.ssepoch)
+
 			// Consistency checks
-			if sendersCorrect,_ := areSSABCSendersCorrect(msg, variables.ID); !sendersCorrect ||
-				!isSSABCReadyConsistent(msg, variables.ID) || !isSSABCEchoConsistent(msg, variables.ID) ||
-				!isNumConsistent(msg, ssnum) || ssnum >= math.MaxUint32{
-				msg = flushProcessSSABCMsg(msg, variables.ID) // empty every message type set
-				ssnum = 0
+			if sendersCorrect,_ := node.areSSABCSendersCorrect(msg, node.ID); !sendersCorrect ||
+				!node.isSSABCReadyConsistent(msg, node.ID) || !isSSABCEchoConsistent(msg, node.ID) {
+				msg.Flush(node.ID) // empty every message type set
+				node.Messenger.Metrics.Flushes.Inc("SSABC", "all")
 			}
 
+			history.Check(node.n())
+
 			// no request received yet
-			noReq = getValue.Sender == -1 && getValue.Num == math.MaxUint32 &&
-			 bytes.Equal(getValue.Value, variables.DEFAULT)
+			noReq = node.getValue.Sender == -1 && node.getValue.Num == math.MaxUint32 &&
+			 bytes.Equal(node.getValue.Value, variables.DEFAULT)
 
-			if ssnum == 0 && !noReq{	// transient fault was detected
-				getValue.Num = ssnum
-				ssnum++
+			if node.ssnum == 0 && !noReq{	// transient fault was detected, or a global reset
+				node.getValue.Num = node.ssnum
+				node.getValue.Epoch = node.ssepoch
+				node.ssnum++
 			}
 
 			// RBcast
-			if _, in := containsSSABCMessage(msg[variables.ID]["init"], getValue); !in && !noReq{
-				msg[variables.ID]["init"] = append(msg[variables.ID]["init"], getValue)
+			if !noReq{
+				msg.Of(node.ID, "init").Add(node.getValue)
 			}
 
-			for i:=0; i < variables.N; i++ {	// for every process
-				correctSenders, incorrectTypes := areSSABCSendersCorrect(msg,i)
-				conflict, conflictTypes := ssabcMessageConflictExists (msg[i])
+			for i:=0; i < node.n(); i++ {	// for every process
+				correctSenders, incorrectTypes := node.areSSABCSendersCorrect(msg,i)
+				conflict, conflictTypes := ssabcMessageConflictExists (msg.Content(i))
 
-				if !isSSABCInitCorrect(msg[i]["init"], i) {	// empty messages by i
-					msg = flushProcessSSABCMsg(msg, i)
+				if !isSSABCInitCorrect(msg.Of(i, "init").Tuples(), i) {	// empty messages by i
+					msg.Flush(i)
+					node.Messenger.Metrics.Flushes.Inc("SSABC", "process")
 				} else if !correctSenders || conflict {	// flush certain types only
 					flushTypes := append(incorrectTypes, conflictTypes...)
+					node.Messenger.Metrics.Flushes.Add(float64(len(flushTypes)), "SSABC", "types")
 
 					for _, ftype := range flushTypes {
-						msg[i][ftype] = []ssabcMT{}
+						msg.Of(i, ftype).Clear()
 					}
 				}
 
-				// send echo message for every init
-				for _, initm := range msg[i]["init"] {
-					if _, in := containsSSABCMessage(msg[variables.ID]["echo"], initm); !in {	// only 1 echo per init
-						msg[variables.ID]["echo"] = append(msg[variables.ID]["echo"], initm)
-					}
+				// send echo message for every init (only 1 echo per init)
+				for _, initm := range msg.Of(i, "init").Tuples() {
+					msg.Of(node.ID, "echo").Add(initm)
 				}
 			}
 
 			// send ready message based on echo and ready messages
 			// calculate echo/ready with enough support
-			echoMsgs = ssabcValueThresholdOccurence(msg, int(math.Ceil(float64(variables.N + variables.F)/2)), "echo")
-			readyMsgs = ssabcValueThresholdOccurence(msg, variables.F + 1, "ready")
+			echoMsgs = msg.Threshold(int(math.Ceil(float64(node.n() + node.f())/2)), "echo")
+			readyMsgs = msg.Threshold(node.f() + 1, "ready")
 
 			for _,  m := range echoMsgs {
-				if _, in := containsSSABCMessage(msg[variables.ID]["ready"], m); !in { // only 1 ready per init
-					msg[variables.ID]["ready"] = append(msg[variables.ID]["ready"], m)
-				}
+				msg.Of(node.ID, "ready").Add(m) // only 1 ready per init
 			}
 			for _, m := range readyMsgs {
-				if _, in := containsSSABCMessage(msg[variables.ID]["ready"], m); !in { // only 1 ready per init
-					msg[variables.ID]["ready"] = append(msg[variables.ID]["ready"], m)
-				}
+				msg.Of(node.ID, "ready").Add(m) // only 1 ready per init
 			}
 
 
 			/************************************************************************/
 			// TRANSIENT TESTS - NOT PART OF THE ALGORITHM (can be removed)
-			if transientFaults && (len(msg[variables.ID]["ready"])>0) {
+			if transientFaults && (msg.Of(node.ID, "ready").Len()>0) {
 				transientFaults=false
 				// transient (initFault, echoFault, readyFault, senderFault,valueFault, numFault
-				msg = faults.CreateSSABCTransientMsg(true, true, true, true, true, true,
-					msg , config.TransientProbability)
+				msg = tupleset.SSABCMessagesFrom(faults.CreateSSABCTransientMsg(node.Replica, true, true, true,
+					true, true, true, msg.Slices(), node.TransientProbability))
 			}
 			/************************************************************************/
 
-	    broadcastSSABCMessage(types.SSABCMessage{Content: msg[variables.ID]})
+			// vote for a global reset once f+1 counters are exhausted, or f+1 other processes ask for one
+			// (a corrupted counter only asks for it)
+			asking := 0
+			for p, r := range resets {
+				if r && epochs[p] == node.ssepoch && p != node.ID {
+					asking++
+				}
+			}
+			voting = history.Exhausted(node.ssabcNumLimit(), node.f() + 1) || asking >= (node.f() + 1)
+
+			node.gossipSSABC(gossip, msg, sent, node.ssepoch, voting || corrupted)
 
 			// calculate ready with enough support for RB_deliver
-			rDelivered = ssabcValueThresholdOccurence(msg, 2*variables.F + 1, "ready")
-			rDelivered = removeABDelivered(rDelivered, aDeliveredTotal)
+			rDelivered = msg.Threshold(2*node.f() + 1, "ready")
+			rDelivered = history.Remove(rDelivered)
+			if voting {
+				rDelivered = append(rDelivered, ssabcMT{Sender: node.ID, Num: math.MaxUint32, Epoch: node.ssepoch,
+					Value: ssabcResetValue})
+			}
 
-			if len(rDelivered) > 0 {	// something to propose
+			// propose what the running instances were not proposed with (and the vote for a reset)
+			proposal := unproposedSSABC(rDelivered, running)
+			proposing := len(proposal) > 0 && len(running) < node.consensusWindow()
+			if proposing {
 
 				// Convert vector to bytes
-				SSVCAnswer[ssvcid] = make(chan map[int][]byte, 1)
-				w, err := json.Marshal(rDelivered)
+				node.answerMutex.Lock()
+				answer := make(chan map[int][]byte, 1)
+				node.SSVCAnswer[ssvcid] = answer
+				node.answerMutex.Unlock()
+				w, err := json.Marshal(proposal)
 				if err != nil {
 					logger.ErrLogger.Fatal(err)
 				}
 
-				go SelfStabilizedVectorConsensus(ssvcid, w)
-				quit := make(chan bool)
-				go func(){
-					for {
-						select{
-						case <- quit:
-							return
-						default:
-							time.Sleep(time.Second/5)
-							broadcastSSABCMessage(types.SSABCMessage{Content: msg[variables.ID]})
+				go node.SelfStabilizedVectorConsensus(ssvcid, w)
+				running = append(running, ssabcInstance{id: ssvcid, answer: answer, proposed: tupleset.NewSSABCSet(proposal...)})
+				ssvcid++
+			}
+
+			// deliver the decisions in instance order (wait for the oldest one if nothing else can be done)
+			for len(running) > 0 {
+				var v map[int][]byte
+				if !proposing || len(running) == node.consensusWindow() {
+					quit, done := make(chan bool), make(chan bool)
+					go func(){
+						defer close(done)	// the loop goes on with the maps of the gossip once it returns
+						for {
+							select{
+							case <- quit:
+								return
+							default:
+								time.Sleep(ssGossipMinPeriod)
+								node.gossipSSABC(gossip, msg, sent, node.ssepoch, voting || corrupted)
+							}
 						}
+					}()
+					select{
+					case v = <-running[0].answer:
+					case <- node.Messenger.Stopped():	// SSVC stops without a decision
 					}
-				}()
-				v := <-SSVCAnswer[ssvcid]
-				quit <- true
-				ssvcid++
+					close(quit)
+					<- done
+					if v == nil {
+						break
+					}
+				} else {
+					select {
+					case v = <-running[0].answer:
+					default:
+					}
+					if v == nil {
+						break
+					}
+				}
+				instance := running[0]
+				running = running[1:]
+				proposing = true // only the first decision is waited for
 
-				vector, errorOccurred := transformVector(v)
+				vector, errorOccurred := node.transformVector(v)
 
 				if !errorOccurred {	// SSVC did not return bottom or transient error
 
-					aDelivered, aDeliveredMT = getABDelivered(vector)
+					// what an earlier instance of the window delivered is not delivered again, and neither
+					// are the votes and the tuples of another epoch
+					_, aDeliveredMT = node.getABDelivered(vector)
+					aDeliveredMT = history.Remove(aDeliveredMT)
+					aDeliveredMT = inSSABCEpoch(aDeliveredMT, node.ssepoch)
+					aDelivered = [][]byte{}
+					for _, m := range aDeliveredMT {
+						aDelivered = append(aDelivered, m.Value)
+					}
 
 					// Sort messages in aDeliver and then deliver them
 					sort.Slice(aDelivered, func(i, j int) bool {
@@ -227,49 +385,60 @@ func ssabcAlgorithm() {
 					})
 
 					// client response
-					Delivered <- struct {
+					node.observeDelivery("SSABC", aDelivered)
+					node.recordInstance(node.aid, instance.id)
+					node.Delivered <- struct {
 						Id    int
 						Value [][]byte
-					}{aid, aDelivered}
+					}{node.aid, aDelivered}
 
 
-					aDeliveredTotal = append(aDeliveredTotal, aDeliveredMT...)
+					for _, m := range aDeliveredMT {
+						history.Add(m)
+					}
 					// read new request if the currect has been AB delivered
-					if _, in := containsSSABCMessage(aDeliveredMT, getValue); in {
-						getValue = ssabcMT{Sender: -1, Num: math.MaxUint32, Value: variables.DEFAULT}
+					if tupleset.NewSSABCSet(aDeliveredMT...).Contains(node.getValue) {
+						node.getValue = ssabcMT{Sender: -1, Num: math.MaxUint32, Value: variables.DEFAULT}
 					}
 
-					logger.OutLogger.Print(".SSABC: ssaDelivered-", aDelivered, "\n")
-					logger.OutLogger.Print(".SSABC: len-", len(aDelivered), "\n")
+					node.log("ssabc").Info("deliver", "values", len(aDelivered))
 
 					// remove delivered items from msg
 					msg = removeDeliveredItems(msg, aDeliveredMT)
-					aid++
+					node.aid++
+
+					// every correct process moves to the next epoch after this batch
+					if countSSABCResetVotes(vector, node.ssepoch) >= (node.f() + 1) {
+						reset(node.ssepoch+1, "agreed")
+					}
 				}
 			}
+
+			// wait for new messages, or for the next gossip
+			select{
+			case <- received:
+			case <- time.After(ssGossipMinPeriod):
+			}
 		}
 	}()
 
 	/* ---------------------------Receive Messages----------------------------- */
 	go func(){
-		msgChannel := messenger.SSABCChannel
+		msgChannel := node.Messenger.SSABCChannel
 		for {
-			select{	// check if must quit receiving
-			case <- quitReadingMessages:
+			select{
+			case <- quitReadingMessages:	// must quit receiving
 				return
-			default:
-				select{	// pause receiving
-				case <- pauseReadingMessages:
-					<- startReadingMessages	// wait for read signal
+			case <- pauseReadingMessages:	// pause receiving
+				<- startReadingMessages	// wait for read signal
+			case message := <- msgChannel:	// read message
+				if key := newSSABCQueueKey(message); !isSSABCMessageQueued(queued[key], message) {
+					queued[key] = append(queued[key], message)
+					messageQueue = append(messageQueue, message)
+				}
+				select{
+				case received <- true:
 				default:
-					select{	// read message
-					case message := <- msgChannel:
-
-						if !isSSABCMessageInQueue(messageQueue, message){
-							messageQueue = append(messageQueue, message)
-						}
-					default:
-					}
 				}
 			}
 		}
@@ -277,13 +446,13 @@ func ssabcAlgorithm() {
 }
 
 
-func ssabcPrintmsg(msg map[int]map[string][]ssabcMT){
-	for p := range msg{
+func ssabcPrintmsg(msg *tupleset.SSABCMessages){
+	for _, p := range msg.Processes(){
 		fmt.Println(p)
 		for _,t := range []string{"init", "echo", "ready"} {
 			fmt.Println(t)
-			for _, m := range msg[p][t]{
-				fmt.Print("(s:",m.Sender," n:",m.Num," v:"+
+			for _, m := range msg.Of(p, t).Tuples(){
+				fmt.Print("(s:",m.Sender," n:",m.Num," e:",m.Epoch," v:"+
 				string(m.Value[:])+"), ")
 			}
 			fmt.Println()
@@ -292,15 +461,11 @@ func ssabcPrintmsg(msg map[int]map[string][]ssabcMT){
 }
 
 // Remove AB delivered items from msg
-func removeDeliveredItems(msg map[int]map[string][]ssabcMT, aDelivered []ssabcMT) map[int]map[string][]ssabcMT{
+func removeDeliveredItems(msg *tupleset.SSABCMessages, aDelivered []ssabcMT) *tupleset.SSABCMessages{
 	for _, abm := range aDelivered {
-		for p,_ := range msg {
+		for _, p := range msg.Processes() {
 			for _, mtype := range []string{"init", "echo", "ready"} {
-				if i, in := containsSSABCMessage(msg[p][mtype], abm); in { // remove from msg
-					size := len(msg[p][mtype])
-					msg[p][mtype][i] = msg[p][mtype][size-1]
-					msg[p][mtype] = msg[p][mtype][:size-1]
-				}
+				msg.Of(p, mtype).Remove(abm)
 			}
 		}
 	}
@@ -311,44 +476,40 @@ func removeDeliveredItems(msg map[int]map[string][]ssabcMT, aDelivered []ssabcMT
 // Returns whether 2 ssabc messages are equal or not
 func areSSABCMessagesEqual(m, t ssabcMT) bool {
 	return t.Sender == m.Sender && bytes.Equal(m.Value, t.Value) &&
-		t.Num == m.Num
+		t.Num == m.Num && t.Epoch == m.Epoch
 }
 
-// Remove messages from RBdelivered that was previously ABdelivered
-func removeABDelivered(rDelivered, aDelivered []ssabcMT) []ssabcMT {
-	for _, b := range aDelivered {
-		if i, in := containsSSABCMessage(rDelivered, b); in {
-			rDelivered[i] = rDelivered[len(rDelivered)-1]
-    	rDelivered = rDelivered[:len(rDelivered)-1]
+// The RB delivered messages that no running SSVC instance was proposed with
+func unproposedSSABC(rDelivered []ssabcMT, running []ssabcInstance) []ssabcMT {
+	proposal := []ssabcMT{}
+	for _, m := range rDelivered {
+		proposed := false
+		for _, instance := range running {
+			if instance.proposed.Contains(m) {
+				proposed = true
+			}
+		}
+		if !proposed {
+			proposal = append(proposal, m)
 		}
 	}
-
-	return rDelivered
+	return proposal
 }
 
 // Get messages to be AB delivered (>F occurence) based on SSVC decision
-func getABDelivered(vector map[int][]ssabcMT) ([][]byte, []ssabcMT) {
-	messages := []ssabcMT{}
-	messagesCounts := []int{}
-
-	for _, values := range vector {
+func (node *Node) getABDelivered(vector map[int][]ssabcMT) ([][]byte, []ssabcMT) {
+	entries := tupleset.NewSSABCMessages(0)	// the messages of every entry, counted
+	for k, values := range vector {
+		entries.Flush(k)
 		for _, m := range values {
-			if index, in := containsSSABCMessage(messages, m); in {
-				messagesCounts[index]++
-			} else{
-				messages = append(messages, m)
-				messagesCounts = append(messagesCounts, 1)
-			}
+			entries.Of(k, "init").Add(m)
 		}
 	}
 
 	aDelivered := [][]byte{}
-	aDeliveredMT := []ssabcMT{}
-	for i := range messages {
-		if messagesCounts[i] > variables.F {
-			aDelivered = append(aDelivered, messages[i].Value)
-			aDeliveredMT = append(aDeliveredMT, messages[i])
-		}
+	aDeliveredMT := entries.Threshold(node.f() + 1, "init")
+	for _, m := range aDeliveredMT {
+		aDelivered = append(aDelivered, m.Value)
 	}
 
 	return aDelivered, aDeliveredMT
@@ -356,7 +517,7 @@ func getABDelivered(vector map[int][]ssabcMT) ([][]byte, []ssabcMT) {
 
 // Transforms a vector of bytes (SSVC decision) to a vector of []ssabcMT messages
 // Returns the vector of []ssabcMT and whether bottom of PSI was returned from SSVC
-func transformVector(ssvcVect map[int][]byte) (map[int][]ssabcMT, bool) {
+func (node *Node) transformVector(ssvcVect map[int][]byte) (map[int][]ssabcMT, bool) {
 	vector := make(map[int][]ssabcMT)
 	errorCount := 0
 	for k, v := range ssvcVect {
@@ -366,34 +527,103 @@ func transformVector(ssvcVect map[int][]byte) (map[int][]ssabcMT, bool) {
 			temp := make([]ssabcMT, 0)
 			err := json.Unmarshal(v, &temp)
 			if err != nil {
-				logger.ErrLogger.Printf(err.Error()," Byzantine message")
+				node.log("ssabc").Warn("Byzantine message", "err", err)
 				temp = []ssabcMT{}
 			}
 			vector[k] = temp
 		}
 	}
-	errorOccurred := errorCount == variables.N
+	errorOccurred := errorCount == node.n()
 	return vector, errorOccurred
 }
 
-// Check if messages from me have num smaller than my num counter
-func isNumConsistent(msg map[int]map[string][]ssabcMT, num uint32) bool {
+// ssabcNumLimit - How many values this replica broadcasts in an epoch
+func (node *Node) ssabcNumLimit() uint32 {
+	if node.SSABCNumLimit == 0 {
+		return math.MaxUint32
+	}
+	return node.SSABCNumLimit
+}
+
+// lastSSABCNum - The last number of this replica in its epoch, in its messages or in what was delivered
+// (false if there is none)
+func (node *Node) lastSSABCNum(msg *tupleset.SSABCMessages, history SSABCHistory) (uint32, bool) {
+	last, found := history.Last(node.ID)
 	for _, mtype := range []string{"init", "echo", "ready"} {
-		for _, m := range msg[variables.ID][mtype] {
-			if m.Sender == variables.ID && m.Num >= num {return false}
+		for _, m := range msg.Of(node.ID, mtype).Tuples() {
+			if m.Sender == node.ID && m.Epoch == node.ssepoch && m.Num != math.MaxUint32 && (!found || m.Num > last) {
+				last, found = m.Num, true
+			}
 		}
 	}
-	return true
+	return last, found
+}
+
+// Remove the tuples of another epoch from msg
+func flushOtherSSABCEpochs(msg *tupleset.SSABCMessages, epoch uint32) *tupleset.SSABCMessages {
+	for _, p := range msg.Processes() {
+		for _, mtype := range []string{"init", "echo", "ready"} {
+			msg.Of(p, mtype).Filter(func(m ssabcMT) bool {
+				return m.Epoch == epoch && m.Num != math.MaxUint32
+			})
+		}
+	}
+	return msg
+}
+
+// The tuples of the given epoch, without the votes for a reset
+func inSSABCEpoch(messages []ssabcMT, epoch uint32) []ssabcMT {
+	kept := []ssabcMT{}
+	for _, m := range messages {
+		if m.Epoch == epoch && m.Num != math.MaxUint32 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// countSSABCResetVotes - How many processes voted for a reset of the epoch in their entry of an SSVC decision
+func countSSABCResetVotes(vector map[int][]ssabcMT, epoch uint32) int {
+	votes := 0
+	for k, values := range vector {
+		vote := ssabcMT{Sender: k, Num: math.MaxUint32, Epoch: epoch, Value: ssabcResetValue}
+		if tupleset.NewSSABCSet(values...).Contains(vote) {
+			votes++
+		}
+	}
+	return votes
 }
 
-// Perform the required checks on new SSABC messages
-func handleNewSSABCMessages(messageQueue []ssabcmsg, msg map[int]map[string][]ssabcMT) map[int]map[string][]ssabcMT{
+// ssabcCommonEpoch - The epoch that most of the other processes gossiped last, and how many of them did
+func ssabcCommonEpoch(epochs map[int]uint32, id int) (uint32, int) {
+	counts := make(map[uint32]int)
+	var common uint32
+	for p, epoch := range epochs {
+		if p == id {
+			continue
+		}
+		if counts[epoch]++; counts[epoch] > counts[common] {
+			common = epoch
+		}
+	}
+	return common, counts[common]
+}
+
+// ssabcEpochDistance - How far apart two epochs are (they wrap around)
+func ssabcEpochDistance(a, b uint32) uint32 {
+	if a-b < b-a {
+		return a - b
+	}
+	return b - a
+}
+
+func (node *Node) handleNewSSABCMessages(messageQueue []ssabcmsg, msg *tupleset.SSABCMessages) *tupleset.SSABCMessages{
 
 	processesWithReqs := make(map[int]ssabcMT)
 	everySender := make(map[int]bool)
-	everySender[variables.ID] = true
-	if len(msg[variables.ID]["init"]) > 0 && msg[variables.ID]["init"][0].Sender == variables.ID{
-		processesWithReqs[variables.ID]=msg[variables.ID]["init"][0]
+	everySender[node.ID] = true
+	if init := msg.Of(node.ID, "init").Tuples(); len(init) > 0 && init[0].Sender == node.ID{
+		processesWithReqs[node.ID]=init[0]
 	}
 
 	for i:=0; i < len(messageQueue); i++{
@@ -401,120 +631,89 @@ func handleNewSSABCMessages(messageQueue []ssabcmsg, msg map[int]map[string][]ss
 		message := messageQueue[i]
 		sender := message.From
 		content := message.SSABCMessage.Content
+		if !msg.Has(sender) {
+			continue	// not a server
+		}
 
 		everySender[sender] = true
 		if len(content["init"]) > 0 && content["init"][0].Sender == sender {
 			processesWithReqs[sender] = content["init"][0]
 		}
 
-		var copied int
-		pmsg := map[string][]ssabcMT{}	// sender's msg copy
-
-		for _, mtype := range []string{"echo", "ready"} {
-			// copy echo/ready
-			pmsg[mtype] = make([]ssabcMT, len(msg[sender][mtype]))
-			copied = copy(pmsg[mtype], msg[sender][mtype])
-			if copied != len(msg[sender][mtype]) {
-				fmt.Println("copied "+strconv.Itoa(copied)+" len "+strconv.Itoa(len(msg[sender][mtype])))
-				panic("Copied less "+mtype+" elements")
-			}
-		}
-
 		for _, mtype := range []string{"echo", "ready"} {
-			// add new echo and ready messages
+			// add new echo and ready messages (only 1 time)
 			for _, m := range content[mtype] {
-				if _, in := containsSSABCMessage(msg[sender][mtype], m); !in { // add message only 1 time
-					msg[sender][mtype] = append(msg[sender][mtype], m)
-					pmsg[mtype] = append(pmsg[mtype], m)
-				}
+				msg.Of(sender, mtype).Add(m)
 			}
 		}
 
 		// add init if no conflicting echo/ready values
-		if conflict, _ := ssabcMessageConflictExists (pmsg); !conflict {
+		if conflict, _ := ssabcMessageConflictExists (msg.Content(sender)); !conflict {
 			for _, m := range content["init"] {
-				if _, in := containsSSABCMessage(msg[sender]["init"], m); !in { // add init only 1 time
-					msg[sender]["init"] = append(msg[sender]["init"], m)
-				}
+				msg.Of(sender, "init").Add(m) // add init only 1 time
 			}
 		}
 	}
 
 	// remove messages from processes that didn't send a request
-	for pid,_ := range everySender {
-		req, in := processesWithReqs[pid]
-		for id,_ := range msg {
-			for _, mtype := range []string{"init", "echo", "ready"} {
-				temp := []ssabcMT{}
-				for _, m := range msg[id][mtype] {
-					if m.Sender != pid || (in && areSSABCMessagesEqual(m, req)){
-						 temp = append(temp, m)
-					 }
-				}
-				msg[id][mtype] = temp
-			}
+	for _, id := range msg.Processes() {
+		for _, mtype := range []string{"init", "echo", "ready"} {
+			msg.Of(id, mtype).Filter(func(m ssabcMT) bool {
+				req, in := processesWithReqs[m.Sender]
+				return !everySender[m.Sender] || (in && areSSABCMessagesEqual(m, req))
+			})
 		}
 	}
 
 	return msg
 }
 
-// Check if received message already exists in message queue
-func isSSABCMessageInQueue(messageQueue []ssabcmsg, newmsg ssabcmsg) bool {
-
-	for _, msg := range messageQueue{
-		if msg.From == newmsg.From { // for every message in queue
-			m := msg.SSABCMessage.Content
-			equalMessages := true
-			for i:=0; i < 3 && equalMessages; i++{ // for every type
-				mtype := []string{"init", "echo", "ready"}[i]
-				newm := newmsg.SSABCMessage.Content[mtype]
-				if len(m[mtype]) == len(newm) {
-					for _, nm := range newm { // every msg in type
-						if _, in := containsSSABCMessage(m[mtype], nm); !in {
-							equalMessages = false
-							break
-						}
-					}
-				} else {
-					equalMessages = false
-					break
-				}
-			}
-			if equalMessages {return equalMessages}	// found an identical message
-		}
-	}
-	return false
+// ssabcQueueKey - What tells received messages apart without comparing their tuples
+type ssabcQueueKey struct {
+	from int
+	epoch uint32
+	reset bool
+	gossip types.Gossip
+	digest types.Digest
+	content, removed int	// how many tuples the message carries
 }
 
-// Check if a message is contained in slice
-// Return true, the position of its appearance in the slice, otherwise false,-1
-func containsSSABCMessage(messages []ssabcMT, m ssabcMT) (int, bool) {
-	for i, t := range messages {
-		if areSSABCMessagesEqual(t, m) { return i, true }
+// newSSABCQueueKey - The key of a received message
+func newSSABCQueueKey(message ssabcmsg) ssabcQueueKey {
+	m := message.SSABCMessage
+	key := ssabcQueueKey{from: message.From, epoch: m.Epoch, reset: m.Reset, gossip: m.Gossip, digest: m.Digest}
+	for _, mtype := range tupleset.Types {
+		key.content += len(m.Content[mtype])
+		key.removed += len(m.Removed[mtype])
 	}
-	return -1, false
+	return key
 }
 
-// Flush (Initialize) message (msg) maps of process p if not initialized
-func flushProcessSSABCMsg(msg map[int]map[string][]ssabcMT, p int) map[int]map[string][]ssabcMT {
-	if _, in := msg[p]; !in {
-		msg[p] = map[string][]ssabcMT{}
+// Check if a message is identical to one of the queued messages with its key (their tuples are compared
+// only then, through their digests)
+func isSSABCMessageQueued(queued []ssabcmsg, message ssabcmsg) bool {
+	if len(queued) == 0 {
+		return false
 	}
-	msg[p]["init"] = []ssabcMT{}
-	msg[p]["echo"] = []ssabcMT{}
-	msg[p]["ready"] = []ssabcMT{}
-	return msg
+	content := tupleset.SSABCContentDigest(message.SSABCMessage.Content)
+	removed := tupleset.SSABCContentDigest(message.SSABCMessage.Removed)
+	for _, q := range queued {
+		if tupleset.SSABCContentDigest(q.SSABCMessage.Content) == content &&
+			tupleset.SSABCContentDigest(q.SSABCMessage.Removed) == removed {
+			return true	// found an identical message
+		}
+	}
+	return false
 }
 
 // Checks if sender IDs of messages are correct (between [0,N))
-func areSSABCSendersCorrect(msg map[int]map[string][]ssabcMT, p int) (bool, []string) {
+func (node *Node) areSSABCSendersCorrect(msg *tupleset.SSABCMessages, p int) (bool, []string) {
 	correct := true
 	incorrectTypes := []string{}
 
 	for _, mtype := range []string{"init","echo","ready"} {
-		for _, m := range msg[p][mtype] {
-			if m.Sender < 0 || m.Sender >= variables.N {
+		for _, m := range msg.Of(p, mtype).Tuples() {
+			if m.Sender < 0 || m.Sender >= node.n() {
 				incorrectTypes = append(incorrectTypes, mtype)
 				correct = false
 				break
@@ -526,32 +725,24 @@ func areSSABCSendersCorrect(msg map[int]map[string][]ssabcMT, p int) (bool, []st
 }
 
 // Checks if for every echo message of process p, an init message exists
-// Only for msg[variables.ID] entries
-func isSSABCEchoConsistent (msg map[int]map[string][]ssabcMT, p int) bool {
-	pEchoMsgs := msg[p]["echo"]	// echo messages of process p
-
-	for _, m := range pEchoMsgs {
-		if _, sin := msg[m.Sender]; sin {	// check existance of correct sender
-			if _, in := containsSSABCMessage(msg[m.Sender]["init"], m); !in { // corresponding init check
-				return false
-			}
-		} else { return false }
+// Only for msg[node.ID] entries
+func isSSABCEchoConsistent (msg *tupleset.SSABCMessages, p int) bool {
+	for _, m := range msg.Of(p, "echo").Tuples() {	// echo messages of process p
+		// check existance of correct sender, and the corresponding init
+		if !msg.Has(m.Sender) || !msg.Of(m.Sender, "init").Contains(m) {
+			return false
+		}
 	}
 	return true
 }
 
 // Checks if ready messages of process p are consistent (either f+1 have seen a
 // ready message or (n+f)/2 have seen an echo one)
-// Only for msg[variables.ID] entries
-func isSSABCReadyConsistent (msg map[int]map[string][]ssabcMT, p int) bool {
-	pReadyMsgs := msg[p]["ready"]	// ready messages of process p
-
-	echoMsgs := ssabcValueThresholdOccurence(msg, int(math.Ceil(float64(variables.N + variables.F)/2)), "echo")
-	readyMsgs := ssabcValueThresholdOccurence(msg, variables.F + 1, "ready")
-
-	for _, m := range pReadyMsgs {
-		_, inThresholdEcho := containsSSABCMessage(echoMsgs, m)
-		_, inThresholdReady := containsSSABCMessage(readyMsgs, m)
+// Only for msg[node.ID] entries
+func (node *Node) isSSABCReadyConsistent(msg *tupleset.SSABCMessages, p int) bool {
+	for _, m := range msg.Of(p, "ready").Tuples() {	// ready messages of process p
+		inThresholdEcho := msg.Count("echo", m) >= int(math.Ceil(float64(node.n() + node.f())/2))
+		inThresholdReady := msg.Count("ready", m) >= node.f() + 1
 		if !(inThresholdEcho || inThresholdReady) {
 			return false
 		}
@@ -596,44 +787,8 @@ func ssabcMessageConflictExists (messages map[string][]ssabcMT) (bool, []string)
 	return conflict, conflictTypes
 }
 
-// Returns every value v that has been send as a messageType message from
-// >=s processes (at least s)
-// messageType must be either echo or ready
-func ssabcValueThresholdOccurence (msg map[int]map[string][]ssabcMT, s int,
-	messageType string) []ssabcMT {
-		if !(messageType == "echo" || messageType == "ready") {
-			panic("Module self_stabilized_vector_consensus Function"+
-				"ssabcValueThresholdOccurence: messageType must be either echo or ready")
-		}
-
-		values := []ssabcMT{}	// values appearing >=s times (with their sender)
-		messages := []ssabcMT{}	// every message of type messageType sent
-		messageCounts := []int{}	// counter for every message in messages
-
-		//	count the occurence of each message
-		for process := range msg {
-			for _, message := range msg[process][messageType] {
-				if index, in := containsSSABCMessage(messages, message); in {
-					messageCounts[index]++
-				} else {
-					messages = append(messages, message)
-					messageCounts = append(messageCounts, 1)
-				}
-			}
-		}
-
-		//	return the messages which occur at least s times
-		for i := 0; i < len(messages); i++ {
-			if messageCounts[i] >= s {
-				values = append(values, messages[i])
-			}
-		}
-
-		return values
-}
-
-// Send every message from myself to every process
-func broadcastSSABCMessage(ssabcm types.SSABCMessage) {
+// Send every message from myself to every process (an error if some queues are full)
+func (node *Node) broadcastSSABCMessage(ssabcm types.SSABCMessage) error {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(ssabcm)
@@ -641,6 +796,6 @@ func broadcastSSABCMessage(ssabcm types.SSABCMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	message := types.NewMessage(w.Bytes(), "SSABC")
-	messenger.Broadcast(message)
+	message := node.Messenger.NewMessage(w.Bytes(), "SSABC")
+	return node.Messenger.Broadcast(message)
 }
@@ -0,0 +1,125 @@
+package modules
+
+import "math"
+
+/*
+	The delivery history of SSABC keeps, for every sender, a window of the
+	numbers of the current epoch: every number below Next was delivered, and
+	bit k of Above tells if Next+k was. A correct sender has one value in
+	flight at a time and numbers its values in order, so its window only
+	moves forward; a number beyond the window moves it past the numbers that
+	were skipped. The history therefore takes one window per replica, i.e.
+	at most n*(4+8) bytes, however long the replica runs, and looking a tuple
+	up takes constant time. Every correct replica updates it with the same
+	batches, so the histories stay the same. A global reset empties the
+	history; the windows of the senders that are not replicas are dropped.
+	The windows of f+1 senders at the end of the counters ask for that reset:
+	one of them at least is the window of a correct replica, so a Byzantine
+	sender that numbers a value at the end of the counter cannot force a
+	reset in every epoch on its own.
+*/
+
+// SSABCWindowSize - How many numbers a window covers from its first number that was not delivered
+const SSABCWindowSize = 64
+
+// SSABCWindow - The numbers of one sender that were delivered
+type SSABCWindow struct {
+	Next  uint32 // Every number below was delivered, Next was not
+	Above uint64 // Bit k: Next+k was delivered
+}
+
+// SSABCHistory - The windows of the senders, in the current epoch
+type SSABCHistory map[int]SSABCWindow
+
+// Delivered - If the tuple was delivered
+func (h SSABCHistory) Delivered(m ssabcMT) bool {
+	w := h[m.Sender]
+	if m.Num < w.Next {
+		return true
+	}
+	if k := uint64(m.Num) - uint64(w.Next); k < SSABCWindowSize {
+		return w.Above&(1<<k) != 0
+	}
+	return false
+}
+
+// Add - Records that the tuple was delivered
+func (h SSABCHistory) Add(m ssabcMT) {
+	if h.Delivered(m) {
+		return
+	}
+	w := h[m.Sender]
+	k := uint64(m.Num) - uint64(w.Next)
+	if k >= SSABCWindowSize { // move the window up to the number (past the numbers that were skipped)
+		shift := k - (SSABCWindowSize - 1)
+		w.Next += uint32(shift)
+		if shift < SSABCWindowSize {
+			w.Above >>= shift
+		} else {
+			w.Above = 0
+		}
+		k = SSABCWindowSize - 1
+	}
+	w.Above |= 1 << k
+	h[m.Sender] = w.normalized()
+}
+
+// Remove - The tuples that were not delivered
+func (h SSABCHistory) Remove(messages []ssabcMT) []ssabcMT {
+	kept := []ssabcMT{}
+	for _, m := range messages {
+		if !h.Delivered(m) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// Last - The highest number of the sender that was delivered (false if there is none)
+func (h SSABCHistory) Last(sender int) (uint32, bool) {
+	w, in := h[sender]
+	if !in {
+		return 0, false
+	}
+	for k := SSABCWindowSize - 1; k >= 0; k-- {
+		if w.Above&(1<<uint(k)) != 0 {
+			return w.Next + uint32(k), true
+		}
+	}
+	if w.Next > 0 {
+		return w.Next - 1, true
+	}
+	return 0, false
+}
+
+// Exhausted - If the last number (limit-1) of at least quorum senders was delivered
+func (h SSABCHistory) Exhausted(limit uint32, quorum int) bool {
+	count := 0
+	for sender := range h {
+		if last, in := h.Last(sender); in && last >= limit-1 {
+			count++
+		}
+	}
+	return count >= quorum
+}
+
+// Check - Drops the windows of the senders that are not among the n replicas, and moves the windows
+// whose first number was delivered (both only after a transient fault)
+func (h SSABCHistory) Check(n int) {
+	for sender, w := range h {
+		if sender < 0 || sender >= n {
+			delete(h, sender)
+		} else {
+			h[sender] = w.normalized()
+		}
+	}
+}
+
+// normalized - The window that starts at its first number that was not delivered
+func (w SSABCWindow) normalized() SSABCWindow {
+	for w.Above&1 != 0 && w.Next < math.MaxUint32 {
+		w.Next++
+		w.Above >>= 1
+	}
+	return w
+}
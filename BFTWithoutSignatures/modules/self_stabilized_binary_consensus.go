@@ -0,0 +1,379 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/faults"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"time"
+)
+
+/*
+	Self Stabilizing Binary Consensus, which SSMVC runs. It follows BC (the
+	EST messages of BV_broadcast, the AUX messages and the common coin of
+	every round), but every replica gossips its state in its last rounds
+	(the values it has EST broadcast and its AUX value) and its decision,
+	and keeps only the latest state that each replica gossiped. binValues
+	is derived from that state at every step instead of being stored. The
+	rounds are bounded: a replica keeps the rounds of the window around its
+	own, a state of a round outside [1, ssbcMaxRound] or with values other
+	than 0 and 1 is flushed, and a replica whose own round is out of bounds
+	starts again. A replica that lags behind the window decides the value
+	that f+1 replicas report, as at least one of them is correct. As in
+	SSMVC, a replica that has decided gossips mostly to the replicas that
+	have not reported a decision. The coin of a round is tossed aside, so the
+	replica keeps gossiping while it waits for the shares of the others.
+*/
+
+const (
+	// ssbcWindow - How many of its last rounds a replica keeps and gossips in SSBC
+	ssbcWindow = 4
+
+	// ssbcMaxRound - The bound of the rounds of SSBC (a round above it comes from a corrupted state)
+	ssbcMaxRound = 1 << 10
+)
+
+// SelfStabilizedBinaryConsensus - The method that is called to initiate the SSBC module. The decision
+// is sent on the returned channel (nothing is sent if the instance is collected first).
+func (node *Node) SelfStabilizedBinaryConsensus(ssbcid int, initVal uint) <-chan uint {
+	// START Variables initialization
+	answer := make(chan uint, 1)
+	done := node.Messenger.Done(messenger.RootInstance(ssbcid, messenger.DepthVC))
+
+	node.Messenger.SSBCMutex.Lock()
+	if _, in := node.Messenger.SSBCChannel[ssbcid]; !in {
+		node.Messenger.SSBCChannel[ssbcid] = make(chan struct {
+			SSBCMessage types.SSBCMessage
+			From        int
+		}, messenger.SSGossipBuffer)
+	}
+	messageChannel := node.Messenger.SSBCChannel[ssbcid]
+	node.Messenger.SSBCMutex.Unlock()
+
+	rounds := make(map[int]map[int]types.SSBCRound, node.n()) // process -> round -> its state
+	for i := 0; i < node.n(); i++ {
+		rounds[i] = make(map[int]types.SSBCRound)
+	}
+	decisions := make(map[int]uint) // process -> the decision it reports
+	coins := make(map[int]uint)     // round -> its coin
+	tossing := make(map[int]bool)   // the rounds whose coin is being tossed
+	tossed := make(chan ssbcCoin, ssbcWindow)
+
+	round, est := 1, initVal
+	var decision []uint // at most one value
+	halted := false     // if this replica stopped moving to the next rounds
+
+	/****************************************************************************/
+	// to run tests with transient faults - not part of the algorithm
+	transientFaults := node.Transient && node.TestExecution
+	/****************************************************************************/
+	// END Variables initialization
+
+	decide := func(v uint) {
+		if decision == nil {
+			answer <- v
+		}
+		decision = []uint{v}
+	}
+
+	// step - Checks the state, applies the rules of the current round, and returns whether this replica
+	// moved to the next round
+	step := func() bool {
+		// Consistency checks
+		if round < 1 || round > ssbcMaxRound || est > 1 {
+			node.log("bc").Warn("ss restart", "ssbcid", ssbcid, "round", round)
+			round, est = 1, initVal
+			rounds[node.ID] = make(map[int]types.SSBCRound)
+			node.Messenger.Metrics.Flushes.Inc("SSBC", "all")
+		}
+		for i := 0; i < node.n(); i++ {
+			for r, state := range rounds[i] {
+				if r <= round-ssbcWindow || r > round+ssbcWindow || (i == node.ID && r > round) {
+					delete(rounds[i], r) // out of the window
+				}
+				if !isSSBCRoundValid(r, state) {
+					delete(rounds[i], r)
+					node.Messenger.Metrics.Flushes.Inc("SSBC", "round")
+				}
+			}
+		}
+		for i, v := range decisions {
+			if v > 1 {
+				delete(decisions, i)
+				node.Messenger.Metrics.Flushes.Inc("SSBC", "process")
+			}
+		}
+
+		// The decision that f+1 replicas report was decided by a correct one, and the one that 2f+1
+		// report replaces a corrupted decision of this replica
+		reports := make(map[uint]int, 2)
+		for _, v := range decisions {
+			reports[v]++
+		}
+		for _, v := range []uint{0, 1} {
+			if decision == nil && reports[v] >= (node.f()+1) {
+				node.log("bc").Debug("ss adopt", "ssbcid", ssbcid, "round", round, "value", v)
+				decide(v)
+				halted = true
+			} else if decision != nil && decision[0] != v && reports[v] >= (2*node.f()+1) {
+				decision = []uint{v}
+				node.Messenger.Metrics.Flushes.Inc("SSBC", "process")
+			}
+		}
+
+		// BV_broadcast: EST broadcast the own value and the values with f+1 EST messages
+		state := rounds[node.ID][round]
+		state.Round = round
+		if !inList(est, state.Est) {
+			state.Est = append(state.Est, est)
+		}
+		rounds[node.ID][round] = state
+		for r, state := range rounds[node.ID] {
+			for _, v := range []uint{0, 1} {
+				if !inList(v, state.Est) && countSSBCEst(rounds, r, v) >= (node.f()+1) {
+					state.Est = append(state.Est, v)
+				}
+			}
+			rounds[node.ID][r] = state
+		}
+
+		// AUX broadcast the first value in binValues
+		binValues := node.ssbcBinValues(rounds, round)
+		state = rounds[node.ID][round]
+		if len(state.Aux) == 0 && len(binValues) > 0 {
+			state.Aux = []uint{binValues[0]}
+			rounds[node.ID][round] = state
+		}
+
+		/************************************************************************/
+		// TRANSIENT TESTS - NOT PART OF THE ALGORITHM (can be removed)
+		if transientFaults && len(state.Aux) > 0 {
+			transientFaults = false
+			rounds = faults.CreateSSBCTransientState(node.Replica, rounds, node.TransientProbability)
+			return false // the next step flushes what is corrupted
+		}
+		/************************************************************************/
+
+		if halted || len(state.Aux) == 0 {
+			return false
+		}
+
+		// Wait until (n-f) AUX messages whose values are in binValues
+		values := make([]uint, 0, 2)
+		count := 0
+		for i := 0; i < node.n(); i++ {
+			if aux := rounds[i][round].Aux; len(aux) == 1 && inList(aux[0], binValues) {
+				count++
+				if !inList(aux[0], values) {
+					values = append(values, aux[0])
+				}
+			}
+		}
+		if count < (node.n() - node.f()) {
+			return false
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		coin, in := coins[round]
+		if !in {
+			if !tossing[round] {
+				tossing[round] = true
+				go func(r int) {
+					c := node.Coin.Toss(ComputeUniqueIdentifier(ComputeUniqueIdentifier(ssbcid, 0), r))
+					select {
+					case tossed <- ssbcCoin{round: r, coin: c}:
+					case <-done:
+					}
+				}(round)
+			}
+			return false // The next step after the coin
+		}
+		node.log("bc").Debug("ss values", "ssbcid", ssbcid, "round", round, "values", values, "coin", coin)
+
+		if len(values) == 2 {
+			est = coin
+		} else {
+			est = values[0]
+			if values[0] == coin && decision != nil {
+				// Every correct process has decided in this round, as they all had est == coin
+				halted = true
+				return false
+			} else if values[0] == coin {
+				node.log("bc").Debug("ss decide", "ssbcid", ssbcid, "round", round, "value", values[0])
+				node.Messenger.Metrics.BCRounds.Observe(float64(round))
+				decide(values[0])
+			}
+		}
+		round++
+		delete(coins, round-ssbcWindow)
+		delete(tossing, round-ssbcWindow)
+		return true
+	}
+
+	go func() {
+		ticker := time.NewTicker(ssGossipPeriod)
+		defer ticker.Stop()
+		var sent types.SSBCMessage // the state that was gossiped last
+		var everyone time.Time     // when the state was gossiped to every process last
+
+		for {
+			gossip := false
+			select {
+			case <-done:
+				return // The instance has been collected
+			case message := <-messageChannel:
+				node.mergeSSBCMessage(rounds, decisions, message.From, message.SSBCMessage)
+			case c := <-tossed:
+				if c.round > round-ssbcWindow {
+					coins[c.round] = c.coin
+				}
+			case <-ticker.C:
+				gossip = true
+			}
+
+			for step() {
+			}
+
+			message := types.NewSSBCMessage(ssbcid, ssbcRounds(rounds[node.ID]), decision)
+			if gossip || !sameSSBCMessage(message, sent) {
+				to := node.ssGossipTo(decision != nil, func(p int) bool {
+					_, in := decisions[p]
+					return in
+				}, everyone)
+				if len(message.Decided) != len(sent.Decided) {
+					to = nil // Every process learns the decision once
+				}
+				if node.gossipSSBCMessage(message, to) == nil {
+					sent = message // Otherwise it is gossiped again in the next step
+					if to == nil {
+						everyone = time.Now()
+					}
+				}
+			}
+		}
+	}()
+
+	return answer
+}
+
+// ssbcCoin - The coin of a round of SSBC, once it is tossed
+type ssbcCoin struct {
+	round int
+	coin  uint
+}
+
+// mergeSSBCMessage - Keeps the state of the rounds and the decision that a replica gossiped
+func (node *Node) mergeSSBCMessage(rounds map[int]map[int]types.SSBCRound, decisions map[int]uint, from int,
+	message types.SSBCMessage) {
+	if from < 0 || from >= node.n() || from == node.ID {
+		return
+	}
+	for _, state := range message.Rounds {
+		rounds[from][state.Round] = state
+	}
+	if len(message.Decided) == 1 {
+		decisions[from] = message.Decided[0]
+	} else {
+		delete(decisions, from)
+	}
+}
+
+// ssbcBinValues - The values that 2f+1 replicas have EST broadcast in round r
+func (node *Node) ssbcBinValues(rounds map[int]map[int]types.SSBCRound, r int) []uint {
+	binValues := make([]uint, 0, 2)
+	for _, v := range []uint{0, 1} {
+		if countSSBCEst(rounds, r, v) >= (2*node.f() + 1) {
+			binValues = append(binValues, v)
+		}
+	}
+	return binValues
+}
+
+// countSSBCEst - How many replicas have EST broadcast v in round r
+func countSSBCEst(rounds map[int]map[int]types.SSBCRound, r int, v uint) int {
+	count := 0
+	for _, states := range rounds {
+		if inList(v, states[r].Est) {
+			count++
+		}
+	}
+	return count
+}
+
+// isSSBCRoundValid - Checks that the state of round r is in bounds and holds only binary values, each
+// once, and at most one AUX value
+func isSSBCRoundValid(r int, state types.SSBCRound) bool {
+	if state.Round != r || r < 1 || r > ssbcMaxRound || len(state.Est) > 2 || len(state.Aux) > 1 {
+		return false
+	}
+	for k, v := range state.Est {
+		if v > 1 || inList(v, state.Est[:k]) {
+			return false
+		}
+	}
+	return len(state.Aux) == 0 || state.Aux[0] <= 1
+}
+
+// ssbcRounds - The states of the rounds, in order
+func ssbcRounds(states map[int]types.SSBCRound) []types.SSBCRound {
+	list := make([]types.SSBCRound, 0, len(states))
+	for _, state := range states {
+		list = append(list, state)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Round < list[j].Round })
+	return list
+}
+
+// sameSSBCMessage - Checks if two SSBC messages hold the same state
+func sameSSBCMessage(a types.SSBCMessage, b types.SSBCMessage) bool {
+	if len(a.Rounds) != len(b.Rounds) || len(a.Decided) != len(b.Decided) {
+		return false
+	}
+	for k := range a.Decided {
+		if a.Decided[k] != b.Decided[k] {
+			return false
+		}
+	}
+	for k := range a.Rounds {
+		x, y := a.Rounds[k], b.Rounds[k]
+		if x.Round != y.Round || len(x.Est) != len(y.Est) || len(x.Aux) != len(y.Aux) {
+			return false
+		}
+		for j := range x.Est {
+			if x.Est[j] != y.Est[j] {
+				return false
+			}
+		}
+		for j := range x.Aux {
+			if x.Aux[j] != y.Aux[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Send the state of this replica in SSBC to the given processes (every process if to is nil), and
+// return an error if some queues are full
+func (node *Node) gossipSSBCMessage(ssbcm types.SSBCMessage, to []int) error {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssbcm)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	message := node.Messenger.NewMessage(w.Bytes(), "SSBC")
+	if to == nil {
+		return node.Messenger.Broadcast(message)
+	}
+	for _, p := range to {
+		if e := node.Messenger.SendMessage(message, p); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/tupleset"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+/*
+	Anti-entropy gossip of SSVC and SSABC: instead of all of its messages in
+	every step, a replica sends the tuples that it added and removed since
+	its previous message (a delta) when its messages change, and only their
+	digest (see tupleset) when they do not. Every message carries the digest
+	of all the messages of the sender, and the receivers rebuild them in a
+	view of the sender: a receiver whose view does not match the digest (it
+	missed or reordered a delta, or the view was corrupted) pulls all the
+	messages from the sender, and only a view that matches is handed to the
+	module, as if the sender had sent all of its messages.
+	The digests follow a change at the shortest period, which doubles up to
+	the longest one while nothing changes, and every ssGossipFullEvery-th of
+	them is replaced by all the messages: a replica still sends its whole
+	state again and again, whatever the others hold of it.
+*/
+
+const (
+	ssGossipMinPeriod = time.Second / 20 // Between two digests after a change
+	ssGossipMaxPeriod = time.Second      // Between two digests while nothing changes
+	ssGossipFullEvery = 4                // Digests between two whole states
+)
+
+// gossipKinds - The kinds of gossip, as the metrics name them
+var gossipKinds = map[types.Gossip]string{types.GossipFull: "full", types.GossipDelta: "delta",
+	types.GossipDigest: "digest", types.GossipPull: "pull"}
+
+// ssGossip - When a module gossips its messages, and who asked for all of them
+type ssGossip struct {
+	period  time.Duration     // Between two digests
+	next    time.Time         // When the next digest is due
+	digests int               // Digests sent since all the messages were sent last
+	tag     uint64            // What the messages carry besides the tuples (the epoch and reset of SSABC)
+	pulls   map[int]bool      // The processes that asked for all the messages
+	pulled  map[int]time.Time // When all the messages of every process were asked for last
+}
+
+func newSSGossip() *ssGossip {
+	return &ssGossip{period: ssGossipMinPeriod, pulls: make(map[int]bool), pulled: make(map[int]time.Time)}
+}
+
+// changed - The messages changed (and a delta is sent): the digests start again at the shortest period
+func (g *ssGossip) changed(now time.Time) {
+	g.period = ssGossipMinPeriod
+	g.next = now.Add(g.period)
+}
+
+// missed - Some queues were full for the last message: all the messages are sent in the next step
+func (g *ssGossip) missed(now time.Time) {
+	g.digests, g.next = ssGossipFullEvery-1, now
+}
+
+// due - What is due now: a digest, all the messages (instead of every ssGossipFullEvery-th digest),
+// or nothing (false)
+func (g *ssGossip) due(now time.Time) (types.Gossip, bool) {
+	if g.period < ssGossipMinPeriod || g.period > ssGossipMaxPeriod || g.next.Sub(now) > ssGossipMaxPeriod {
+		g.period, g.next = ssGossipMinPeriod, now // After a transient fault
+	}
+	if now.Before(g.next) {
+		return 0, false
+	}
+	if g.period *= 2; g.period > ssGossipMaxPeriod {
+		g.period = ssGossipMaxPeriod
+	}
+	g.next = now.Add(g.period)
+	if g.digests++; g.digests < ssGossipFullEvery {
+		return types.GossipDigest, true
+	}
+	g.digests = 0
+	return types.GossipFull, true
+}
+
+// pull - If all the messages of process p can be asked for now (once in the shortest period)
+func (g *ssGossip) pull(p int, now time.Time) bool {
+	if last, in := g.pulled[p]; in && now.After(last) && now.Sub(last) < ssGossipMinPeriod {
+		return false
+	}
+	g.pulled[p] = now
+	return true
+}
+
+// gossipSSABC - Gossips the messages of this replica, with its epoch and if it asks for a reset (sent
+// holds what the others have of the messages, as the ones of this replica)
+func (node *Node) gossipSSABC(g *ssGossip, msg, sent *tupleset.SSABCMessages, epoch uint32, reset bool) {
+	now := time.Now()
+	full := types.NewSSABCMessage(msg.Content(node.ID), epoch, reset)
+	full.Digest = msg.Digest(node.ID)
+	for p := range g.pulls {
+		node.Messenger.Metrics.Gossip.Inc("SSABC", gossipKinds[types.GossipFull])
+		if node.sendSSABCMessage(full, p) == nil {
+			delete(g.pulls, p) // Otherwise it is sent again in the next step
+		}
+	}
+
+	tag := uint64(epoch) << 1
+	if reset {
+		tag |= 1
+	}
+	message := types.SSABCMessage{Epoch: epoch, Reset: reset, Digest: full.Digest}
+	added, removed := msg.Diff(node.ID, sent, node.ID)
+	if len(added) > 0 || len(removed) > 0 || tag != g.tag {
+		message.Gossip, message.Content, message.Removed = types.GossipDelta, added, removed
+		sent.Apply(node.ID, added, removed)
+		g.tag = tag
+		g.changed(now)
+	} else if kind, due := g.due(now); !due {
+		return
+	} else if kind == types.GossipFull {
+		message = full
+	} else {
+		message.Gossip = kind
+	}
+	node.Messenger.Metrics.Gossip.Inc("SSABC", gossipKinds[message.Gossip])
+	if node.broadcastSSABCMessage(message) != nil {
+		g.missed(now)
+	}
+}
+
+// receiveSSABCGossip - Rebuilds in views the messages of the senders of the gossip in messageQueue, and
+// returns all of them for the senders whose views match their digests (the others are pulled)
+func (node *Node) receiveSSABCGossip(g *ssGossip, messageQueue []ssabcmsg,
+	views *tupleset.SSABCMessages) []ssabcmsg {
+	latest := make(map[int]types.SSABCMessage) // The last message of every sender
+	senders := []int{}
+	for _, message := range messageQueue {
+		p, m := message.From, message.SSABCMessage
+		if !views.Has(p) {
+			continue // Not a server
+		}
+		switch m.Gossip {
+		case types.GossipPull:
+			g.pulls[p] = true
+			continue
+		case types.GossipFull:
+			views.Replace(p, m.Content)
+		case types.GossipDelta:
+			views.Apply(p, m.Content, m.Removed)
+		}
+		if _, in := latest[p]; !in {
+			senders = append(senders, p)
+		}
+		latest[p] = m
+	}
+
+	now := time.Now()
+	states := []ssabcmsg{}
+	for _, p := range senders {
+		m := latest[p]
+		if views.Digest(p) != m.Digest {
+			if g.pull(p, now) {
+				node.Messenger.Metrics.Gossip.Inc("SSABC", gossipKinds[types.GossipPull])
+				if node.sendSSABCMessage(types.SSABCMessage{Gossip: types.GossipPull}, p) != nil {
+					delete(g.pulled, p) // Asked again in the next step
+				}
+			}
+			continue
+		}
+		states = append(states, ssabcmsg{SSABCMessage: types.NewSSABCMessage(views.Content(p), m.Epoch, m.Reset),
+			From: p})
+	}
+	return states
+}
+
+// gossipSSVC - Gossips the messages of this replica in SSVC instance ssvcid (sent holds what the others
+// have of them, as the ones of this replica)
+func (node *Node) gossipSSVC(g *ssGossip, ssvcid int, msg, sent *tupleset.SSVCMessages) {
+	now := time.Now()
+	full := types.NewSSVCMessage(ssvcid, msg.Content(node.ID))
+	full.Digest = msg.Digest(node.ID)
+	for p := range g.pulls {
+		node.Messenger.Metrics.Gossip.Inc("SSVC", gossipKinds[types.GossipFull])
+		if node.sendSSVCMessage(full, p) == nil {
+			delete(g.pulls, p) // Otherwise it is sent again in the next step
+		}
+	}
+
+	message := types.SSVCMessage{SSVCid: ssvcid, Digest: full.Digest}
+	added, removed := msg.Diff(node.ID, sent, node.ID)
+	if len(added) > 0 || len(removed) > 0 {
+		message.Gossip, message.Content, message.Removed = types.GossipDelta, added, removed
+		sent.Apply(node.ID, added, removed)
+		g.changed(now)
+	} else if kind, due := g.due(now); !due {
+		return
+	} else if kind == types.GossipFull {
+		message = full
+	} else {
+		message.Gossip = kind
+	}
+	node.Messenger.Metrics.Gossip.Inc("SSVC", gossipKinds[message.Gossip])
+	if node.broadcastSSVCMessage(message) != nil {
+		g.missed(now)
+	}
+}
+
+// receiveSSVCGossip - Rebuilds in views the messages of the senders of the gossip in messageQueue, and
+// returns all of them for the senders whose views match their digests (the others are pulled)
+func (node *Node) receiveSSVCGossip(g *ssGossip, ssvcid int, messageQueue []ssvcmsg,
+	views *tupleset.SSVCMessages) []ssvcmsg {
+	latest := make(map[int]types.SSVCMessage) // The last message of every sender
+	senders := []int{}
+	for _, message := range messageQueue {
+		p, m := message.From, message.SSVCMessage
+		if !views.Has(p) {
+			continue // Not a server
+		}
+		switch m.Gossip {
+		case types.GossipPull:
+			g.pulls[p] = true
+			continue
+		case types.GossipFull:
+			views.Replace(p, m.Content)
+		case types.GossipDelta:
+			views.Apply(p, m.Content, m.Removed)
+		}
+		if _, in := latest[p]; !in {
+			senders = append(senders, p)
+		}
+		latest[p] = m
+	}
+
+	now := time.Now()
+	states := []ssvcmsg{}
+	for _, p := range senders {
+		if views.Digest(p) != latest[p].Digest {
+			if g.pull(p, now) {
+				node.Messenger.Metrics.Gossip.Inc("SSVC", gossipKinds[types.GossipPull])
+				if node.sendSSVCMessage(types.SSVCMessage{SSVCid: ssvcid, Gossip: types.GossipPull}, p) != nil {
+					delete(g.pulled, p) // Asked again in the next step
+				}
+			}
+			continue
+		}
+		states = append(states, ssvcmsg{SSVCMessage: types.NewSSVCMessage(ssvcid, views.Content(p)), From: p})
+	}
+	return states
+}
+
+// sendSSABCMessage - Sends an SSABC message to process p only (an error if its queue is full)
+func (node *Node) sendSSABCMessage(ssabcm types.SSABCMessage, p int) error {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssabcm)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	return node.Messenger.SendMessage(node.Messenger.NewMessage(w.Bytes(), "SSABC"), p)
+}
+
+// sendSSVCMessage - Sends an SSVC message to process p only (an error if its queue is full)
+func (node *Node) sendSSVCMessage(ssvcm types.SSVCMessage, p int) error {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssvcm)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	return node.Messenger.SendMessage(node.Messenger.NewMessage(w.Bytes(), "SSVC"), p)
+}
@@ -0,0 +1,260 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/faults"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/tupleset"
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+)
+
+/*
+	Self Stabilizing Multi-valued Consensus, which SSVC runs on its vector.
+	It follows MVC, but the INIT and the VECT values are broadcast with the
+	self-stabilizing reliable broadcast of SSVC (see ssrbStep), and every
+	replica gossips its whole state periodically instead of sending each
+	message once. What the replica derives from that state (the delivered
+	INIT and VECT values and its own VECT value) is checked against it at
+	every step, so a corrupted entry is flushed and rebuilt from the gossip
+	of the others. It decides with SSBC, and both run with the identifier of
+	their SSVC instance, so no identifier grows with the instances below it.
+	Once a replica has decided, it gossips only to the replicas that have not
+	reported a decision, and to every replica once in ssDecidedGossipPeriod
+	(a replica may have lost its decision to a transient fault after it
+	reported it), so an instance is almost quiet once every replica decided.
+*/
+
+const (
+	// ssGossipPeriod - How often SSMVC and SSBC gossip their state when it does not change
+	ssGossipPeriod = time.Second / 5
+
+	// ssDecidedGossipPeriod - How often a replica that has decided still gossips to every replica
+	ssDecidedGossipPeriod = 10 * ssGossipPeriod
+)
+
+// ssmvcVect - The value of a VECT message of SSMVC
+type ssmvcVect struct {
+	W      []byte
+	Vector map[int][]byte
+}
+
+// SelfStabilizedMultiValuedConsensus - The method that is called to initiate the SSMVC module. The
+// decision is sent on the returned channel, which is closed if the instance is collected first.
+func (node *Node) SelfStabilizedMultiValuedConsensus(ssmvcid int, v []byte) <-chan []byte {
+	// START Variables initialization
+	answer := make(chan []byte, 1)
+	done := node.Messenger.Done(messenger.RootInstance(ssmvcid, messenger.DepthVC))
+
+	node.Messenger.SSMVCMutex.Lock()
+	if _, in := node.Messenger.SSMVCChannel[ssmvcid]; !in {
+		node.Messenger.SSMVCChannel[ssmvcid] = make(chan struct {
+			SSMVCMessage types.SSMVCMessage
+			From         int
+		}, messenger.SSGossipBuffer)
+	}
+	messageChannel := node.Messenger.SSMVCChannel[ssmvcid]
+	node.Messenger.SSMVCMutex.Unlock()
+
+	initMsg := tupleset.NewSSVCMessages(node.n()) // the SSRB messages of the INIT values
+	vectMsg := tupleset.NewSSVCMessages(node.n()) // the SSRB messages of the VECT values
+	own := MT{Sender: node.ID, Value: v}
+	var ownVect *MT // set once n-f INIT values are delivered
+
+	var bc <-chan uint
+	var bcValue uint
+	bcDecided, decided := false, false
+	reported := make(map[int]bool, node.n()) // the processes that reported a decision
+
+	/****************************************************************************/
+	// to run tests with transient faults - not part of the algorithm
+	transientFaults := node.Transient && node.TestExecution
+	/****************************************************************************/
+	// END Variables initialization
+
+	go func() {
+		ticker := time.NewTicker(ssGossipPeriod)
+		defer ticker.Stop()
+		sent, sentDecided := -1, false // the size of the state that was gossiped last, and if it was decided
+		var everyone time.Time         // when the state was gossiped to every process last
+
+		for {
+			gossip := false
+			select {
+			case <-done:
+				if !decided {
+					close(answer)
+				}
+				return // The instance has been collected
+			case message := <-messageChannel:
+				initMsg = mergeSSRBMessages(initMsg, message.From, message.SSMVCMessage.Init)
+				vectMsg = mergeSSRBMessages(vectMsg, message.From, message.SSMVCMessage.Vect)
+				reported[message.From] = message.SSMVCMessage.Decided
+			case bcValue = <-bc:
+				bcDecided = true
+			case <-ticker.C:
+				gossip = true
+			}
+
+			// INIT values
+			initMsg = node.ssrbStep(initMsg, &own, "SSMVC")
+			init := ssrbDelivered(initMsg, node.f())
+
+			// Own VECT value, which must agree with the INIT values delivered
+			if ownVect != nil && !node.isSSMVCVectValid(ownVect.Value, init) {
+				ownVect = nil
+				vectMsg.Flush(node.ID)
+				node.Messenger.Metrics.Flushes.Inc("SSMVC", "vect")
+			}
+			if ownVect == nil && len(init) >= (node.n()-node.f()) {
+				vector := node.fillVector(init)
+				w := node.calculateW(vector)
+				value, err := json.Marshal(ssmvcVect{W: w, Vector: vector})
+				if err != nil {
+					logger.ErrLogger.Fatal(err)
+				}
+				ownVect = &MT{Sender: node.ID, Value: value}
+				node.log("mvc").Debug("ss vector", "ssmvcid", ssmvcid, "vector", vector, "w", w)
+			}
+
+			// VECT values (only the valid ones)
+			vectMsg = node.ssrbStep(vectMsg, ownVect, "SSMVC")
+			vect := make(map[int][]byte)
+			for sender, value := range ssrbDelivered(vectMsg, node.f()) {
+				if node.isSSMVCVectValid(value, init) {
+					vect[sender] = decodeSSMVCVect(value).W
+				}
+			}
+
+			/************************************************************************/
+			// TRANSIENT TESTS - NOT PART OF THE ALGORITHM (can be removed)
+			if transientFaults && vectMsg.Of(node.ID, "ready").Len() > 0 {
+				transientFaults = false
+				initMsg = tupleset.SSVCMessagesFrom(faults.CreateSSMVCTransientMsg(node.Replica, initMsg.Slices(),
+					node.TransientProbability))
+				vectMsg = tupleset.SSVCMessagesFrom(faults.CreateSSMVCTransientMsg(node.Replica, vectMsg.Slices(),
+					node.TransientProbability))
+			}
+			/************************************************************************/
+
+			if size := initMsg.Size(node.ID) + vectMsg.Size(node.ID); gossip || size != sent || decided != sentDecided {
+				to := node.ssGossipTo(decided, func(p int) bool { return reported[p] }, everyone)
+				if decided != sentDecided {
+					to = nil // Every process learns the decision once
+				}
+				message := types.NewSSMVCMessage(ssmvcid, initMsg.Content(node.ID), vectMsg.Content(node.ID), decided)
+				if node.gossipSSMVCMessage(message, to) == nil {
+					sent, sentDecided = size, decided // Otherwise it is gossiped again in the next step
+					if to == nil {
+						everyone = time.Now()
+					}
+				}
+			}
+
+			if bc == nil && len(vect) >= (node.n()-node.f()) {
+				vectorW := node.fillVector(vect)
+				bVal := node.calculateBinaryValue(vectorW)
+				node.log("mvc").Debug("ss vectorW", "ssmvcid", ssmvcid, "vectorW", vectorW, "value", bVal)
+				bc = node.SelfStabilizedBinaryConsensus(ssmvcid, bVal)
+			}
+
+			if !bcDecided || decided {
+				continue // Keep gossiping for the others
+			}
+			if bcValue == 0 {
+				node.log("mvc").Debug("ss decide default", "ssmvcid", ssmvcid)
+				decided = true
+				answer <- variables.DEFAULT
+				continue
+			}
+			counter, dict := findOccurrences(node.fillVector(vect))
+			for k, c := range counter {
+				if c >= (node.n() - (2 * node.f())) {
+					node.log("mvc").Debug("ss decide", "ssmvcid", ssmvcid, "value", dict[k])
+					decided = true
+					answer <- dict[k]
+					break
+				}
+			}
+		}
+	}()
+
+	return answer
+}
+
+// isSSMVCVectValid - Checks that a VECT value of SSMVC is well formed and agrees with the INIT values delivered
+func (node *Node) isSSMVCVectValid(value []byte, init map[int][]byte) bool {
+	vect := decodeSSMVCVect(value)
+	if vect.Vector == nil {
+		return false
+	}
+	for key := range vect.Vector {
+		if key < 0 || key >= node.n() {
+			return false
+		}
+	}
+	return node.checkVectValidity(types.NewMvcMessage(0, "VECT", vect.W, vect.Vector), init)
+}
+
+// decodeSSMVCVect - The VECT value in value (its vector is nil if it is not well formed)
+func decodeSSMVCVect(value []byte) ssmvcVect {
+	var vect ssmvcVect
+	if json.Unmarshal(value, &vect) != nil {
+		return ssmvcVect{}
+	}
+	return vect
+}
+
+// ssrbDelivered - The values that the self-stabilizing reliable broadcast in msg delivered, by sender
+// (the ones with 2f+1 ready messages; the smallest one if a corrupted state holds more for a sender)
+func ssrbDelivered(msg *tupleset.SSVCMessages, f int) map[int][]byte {
+	delivered := make(map[int][]byte)
+	for _, m := range msg.Threshold(2*f+1, "ready") {
+		if value, in := delivered[m.Sender]; !in || bytes.Compare(m.Value, value) < 0 {
+			delivered[m.Sender] = m.Value
+		}
+	}
+	return delivered
+}
+
+// ssGossipTo - The processes that the state of an SSMVC or SSBC instance is sent to: nil (every process)
+// until this replica decides, and then the others that have not reported a decision, except once in
+// ssDecidedGossipPeriod after everyone (when the state was sent to every process last)
+func (node *Node) ssGossipTo(decided bool, reported func(p int) bool, everyone time.Time) []int {
+	if !decided || time.Since(everyone) >= ssDecidedGossipPeriod {
+		return nil
+	}
+	to := []int{}
+	for p := 0; p < node.n(); p++ {
+		if p != node.ID && !reported(p) {
+			to = append(to, p)
+		}
+	}
+	return to
+}
+
+// Send the state of this replica in SSMVC to the given processes (every process if to is nil), and
+// return an error if some queues are full
+func (node *Node) gossipSSMVCMessage(ssmvcm types.SSMVCMessage, to []int) error {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssmvcm)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+
+	message := node.Messenger.NewMessage(w.Bytes(), "SSMVC")
+	if to == nil {
+		return node.Messenger.Broadcast(message)
+	}
+	for _, p := range to {
+		if e := node.Messenger.SendMessage(message, p); e != nil {
+			err = e
+		}
+	}
+	return err
+}
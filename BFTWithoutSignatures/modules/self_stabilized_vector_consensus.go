@@ -2,7 +2,7 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/tupleset"
 	"BFTWithoutSignatures/types"
 	"BFTWithoutSignatures/variables"
 	"bytes"
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"BFTWithoutSignatures/faults"
-	"BFTWithoutSignatures/config"
 	"fmt"
 )
 
@@ -26,27 +25,22 @@ type ssvcmsg struct {
 
 
 // SelfStabilizedVectorConsensus - The method that is called to initiate the SSVC module
-func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
+func (node *Node) SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 
 	// START Variables initialization
-	getValue := MT{Sender: variables.ID, Value:initVal}
-	msg := make(map[int]map[string][]MT)	// received messages
-	for i:=0; i < variables.N; i++ {	// initialize maps of messages for every process
-		msg = flushProcessMsg(msg, i)
-	}
-	msg[variables.ID]["init"] = append(msg[variables.ID]["init"],
-																				MT{Sender: variables.ID,Value: initVal})
-	round := 0	// to run non SS MVC for tests
+	getValue := MT{Sender: node.ID, Value:initVal}
+	msg := tupleset.NewSSVCMessages(node.n())	// received messages of every process
+	msg.Of(node.ID, "init").Add(MT{Sender: node.ID,Value: initVal})
 
-	messenger.SSVCMutex.Lock()
-	if _, in := messenger.SSVCChannel[ssvcid]; !in {
-		messenger.SSVCChannel[ssvcid] = make(chan struct {
+	node.Messenger.SSVCMutex.Lock()
+	if _, in := node.Messenger.SSVCChannel[ssvcid]; !in {
+		node.Messenger.SSVCChannel[ssvcid] = make(chan struct {
 			SSVCMessage types.SSVCMessage
 			From      int
 		})
 	}
-	msgChannel := messenger.SSVCChannel[ssvcid]
-	messenger.SSVCMutex.Unlock()
+	msgChannel := node.Messenger.SSVCChannel[ssvcid]
+	node.Messenger.SSVCMutex.Unlock()
 
 	// channels to coordinate algorithm execution and message receipt
 	startReadingMessages := make(chan bool)
@@ -55,33 +49,54 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 	// processes' decisions - for testing purposes
 	ssvcDecisions := make(map[int]map[int][]byte)
 
-	messenger.SSVCDecisionsMutex.Lock()
-	if _, in := messenger.SSVCDecisionsChannel[ssvcid]; !in {
-		messenger.SSVCDecisionsChannel[ssvcid] = make(chan struct {
+	node.Messenger.SSVCDecisionsMutex.Lock()
+	if _, in := node.Messenger.SSVCDecisionsChannel[ssvcid]; !in {
+		node.Messenger.SSVCDecisionsChannel[ssvcid] = make(chan struct {
 			Vector map[int][]byte
 			From      int
 		})
 	}
-	decisionsChannel := messenger.SSVCDecisionsChannel[ssvcid]
-	messenger.SSVCDecisionsMutex.Unlock()
+	decisionsChannel := node.Messenger.SSVCDecisionsChannel[ssvcid]
+	node.Messenger.SSVCDecisionsMutex.Unlock()
 
 	// incoming messages
 	messageQueue := []ssvcmsg{}
+	queued := make(map[ssvcQueueKey][]ssvcmsg)	// the messages in messageQueue, by key
+	received := make(chan bool, 1)	// signals that messageQueue is not empty
+	received <- true
+
+	// gossip (see Anti-entropy gossip)
+	gossip := newSSGossip()
+	views := tupleset.NewSSVCMessages(node.n())	// the messages of every process, as it gossiped them
+	sent := tupleset.NewSSVCMessages(node.n())	// the messages of this process, as the others have them
 
 	/****************************************************************************/
 	// to run tests with transient faults - not part of the algorithm
-	transientFaults := config.Transient && config.TestExecution
+	transientFaults := node.Transient && node.TestExecution
 	/****************************************************************************/
 	// END Variables initialization
 
 	/* ---------------------------Execute Algorithm----------------------------- */
 	go func (){
+		ticker := time.NewTicker(ssGossipMinPeriod)
+		defer ticker.Stop()
+
 		for {
 
+			// wait for new messages, or for the next gossip
+			select{
+			case <- received:
+			case <- ticker.C:
+			case <- node.Messenger.Stopped():	// the replica shuts down
+				quitReadingMessages <- true
+				return
+			}
+
 			// handle new received messages
 			pauseReadingMessages <- true
-			msg = handleNewSSVCMessages(messageQueue, msg)
+			msg = handleNewSSVCMessages(node.receiveSSVCGossip(gossip, ssvcid, messageQueue, views), msg)
 			messageQueue = []ssvcmsg{}
+			queued = make(map[ssvcQueueKey][]ssvcmsg)
 			startReadingMessages <- true
 
 			// check if at least F + 1 processes have decided the same value
@@ -90,23 +105,23 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 				select{
 				case dmessage := <- decisionsChannel:
 					ssvcDecisions[dmessage.From] = dmessage.Vector
-					if len(ssvcDecisions) > variables.F {
+					if len(ssvcDecisions) > node.f() {
 						for _, d := range ssvcDecisions {
 							occurence:=0
 							for _, v := range ssvcDecisions {
 								if areVectorsEqual(d, v) {
 									occurence++
-									if occurence > variables.F {	// decide this value
+									if occurence > node.f() {	// decide this value
 										vect := make(map[int][]byte)
 										for k,v := range d{
 											vect[k] = make([]byte, len(v))
 											copy(vect[k], v)
 										}
 
-										logger.OutLogger.Print("SSVC: decide-", vect, "\n")
-										SSVCAnswer[ssvcid] <- vect
+										node.log("ssvc").Debug("decide", "ssvcid", ssvcid, "vector", vect)
+										node.decideSSVC(ssvcid, vect)
 										quitReadingMessages <- true
-										broadcastSSVCDecision(vect, ssvcid)
+										node.broadcastSSVCDecision(vect, ssvcid)
 
 										return
 									}
@@ -119,99 +134,50 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 				}
 			}
 
-			// Consistency checks
-			if sendersCorrect,_ := areSendersCorrect(msg, variables.ID); !sendersCorrect ||
-				!isReadyConsistent(msg, variables.ID) || !isEchoConsistent(msg, variables.ID) {
-				msg = flushProcessMsg(msg, variables.ID) // empty every message type set
-			}
-
-			if _, in := containsMessage(msg[variables.ID]["init"], getValue); !in {
-				msg[variables.ID]["init"] = append(msg[variables.ID]["init"], getValue)
-			}
-
-			for i:=0; i < variables.N; i++ {	// for every process
-				correctSenders, incorrectTypes := areSendersCorrect(msg,i)
-				conflict, conflictTypes := messageConflictExists (msg[i])
-				if !isInitCorrect(msg[i]["init"], i) {	// empty messages by i
-					msg = flushProcessMsg(msg, i)
-				}
-				if !correctSenders || conflict {	// flush certain types only
-					flushTypes := append(incorrectTypes, conflictTypes...)
-
-					for _, ftype := range flushTypes {
-						msg[i][ftype] = []MT{}
-					}
-				}
-
-				// send echo message for every init
-				for _, initm := range msg[i]["init"] {
-					if _, in := containsMessage(msg[variables.ID]["echo"], initm); !in {	// only 1 echo per init
-						msg[variables.ID]["echo"] = append(msg[variables.ID]["echo"], initm)
-					}
-				}
-			}
-
-			// send ready message based on echo and ready messages
-			// calculate echo/ready with enough support
-			echoMsgs := valueThresholdOccurence(msg, int(math.Ceil(float64(variables.N + variables.F)/2)), "echo")
-			readyMsgs := valueThresholdOccurence(msg, variables.F + 1, "ready")
-
-			for _,  m := range echoMsgs {
-				if _, in := containsMessage(msg[variables.ID]["ready"], m); !in { // only 1 ready per init
-					msg[variables.ID]["ready"] = append(msg[variables.ID]["ready"], m)
-				}
-			}
-			for _, m := range readyMsgs {
-				if _, in := containsMessage(msg[variables.ID]["ready"], m); !in { // only 1 ready per init
-					msg[variables.ID]["ready"] = append(msg[variables.ID]["ready"], m)
-				}
-			}
+			// Consistency checks, echo and ready messages
+			msg = node.ssrbStep(msg, &getValue, "SSVC")
 
 			/************************************************************************/
 			// TRANSIENT TESTS - NOT PART OF THE ALGORITHM (can be removed)
-			if transientFaults && (len(msg[variables.ID]["ready"])>0) {
+			if transientFaults && (msg.Of(node.ID, "ready").Len()>0) {
 				transientFaults=false
 
 				// createTransientMsg(initFault, echoFault, readyFault, senderFault,valueFault
-				msg = faults.CreateSSVCTransientMsg(true, true, true, true,true, msg,
-					 ssvcid, config.TransientProbability)
+				msg = tupleset.SSVCMessagesFrom(faults.CreateSSVCTransientMsg(node.Replica, true, true, true,
+					true,true, msg.Slices(), ssvcid, node.TransientProbability))
 
 			}
 			/************************************************************************/
 
-			broadcastSSVCMessage(types.SSVCMessage{SSVCid: ssvcid, Content: msg[variables.ID]})
+			node.gossipSSVC(gossip, ssvcid, msg, sent)
 
 			// Built the vector with the values received
-			vector := make(map[int][]byte, variables.N)
+			vector := make(map[int][]byte, node.n())
 			// initialize vector with DEFAULT values
-			for i := 0; i < variables.N; i++ {
+			for i := 0; i < node.n(); i++ {
 				vector[i] = variables.DEFAULT
 			}
 
 			// calculate ready with enough support for RB_deliver
-			readyMsgs = valueThresholdOccurence(msg, 2*variables.F + 1, "ready")
+			readyMsgs := msg.Threshold(2*node.f() + 1, "ready")
 
 			// fill vector with 2f+1 supported ready messages in sender's position
 			for _, m := range readyMsgs {
 				vector[m.Sender] = m.Value
 			}
 
-			if isVectorPopulated(vector) {	// n-f non default entries
-
-				// Compute the MVC identifier and convert vector to bytes
-				mvcid := ComputeUniqueIdentifier(ssvcid, round)
-				round++	// for debugging purposes
+			if node.isVectorPopulated(vector) {	// n-f non default entries
 
 				// Convert vector to bytes
-				MVCAnswer[mvcid] = make(chan []byte, 1)
 				w, err := json.Marshal(vector)
 				if err != nil {
 					logger.ErrLogger.Fatal(err)
 				}
 
-				logger.OutLogger.Print("SSVC: deliver len-", len(readyMsgs), " vector-", vector, " --> MVC\n")
+				node.log("ssvc").Debug("vector --> SSMVC", "ssvcid", ssvcid, "ready", len(readyMsgs), "vector", vector)
 
-				go MultiValuedConsensus(mvcid, w)
+				// SSMVC (and the SSBC that it runs) uses the identifier of this instance
+				answer := node.SelfStabilizedMultiValuedConsensus(ssvcid, w)
 				quit := make(chan bool)
 				go func(){
 					for {
@@ -219,23 +185,27 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 						case <- quit:
 							return
 						default:
-							time.Sleep(time.Second/5)
-							broadcastSSVCMessage(types.SSVCMessage{SSVCid: ssvcid, Content: msg[variables.ID]})
+							time.Sleep(ssGossipMinPeriod)
+							node.gossipSSVC(gossip, ssvcid, msg, sent)
 						}
 					}
 				}()
-				v := <-MVCAnswer[mvcid]
+				v, decided := <-answer
 				quit <- true
+				if !decided {	// the instance has been collected
+					quitReadingMessages <- true
+					return
+				}
 
 				//var vect map[int][]byte
 				vect := make(map[int][]byte)
 
 				if bytes.Equal(v, variables.PSI){	// transient error
-					for i := 0; i < variables.N; i++ {
+					for i := 0; i < node.n(); i++ {
 						vect[i] = variables.PSI
 					}
-				} else if bytes.Equal(v, variables.DEFAULT) || len(msg[variables.ID]["init"])==0{
-					for i := 0; i < variables.N; i++ {
+				} else if bytes.Equal(v, variables.DEFAULT) || msg.Of(node.ID, "init").Len()==0{
+					for i := 0; i < node.n(); i++ {
 						vect[i] = variables.DEFAULT
 					}
 				} else {
@@ -245,11 +215,11 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 					}
 				}
 
-				logger.OutLogger.Print("SSVC: decide-", vect, "\n")
-				SSVCAnswer[ssvcid] <- vect
+				node.log("ssvc").Debug("decide", "ssvcid", ssvcid, "vector", vect)
+				node.decideSSVC(ssvcid, vect)
 
 				quitReadingMessages <- true
-				broadcastSSVCDecision(vect, ssvcid)
+				node.broadcastSSVCDecision(vect, ssvcid)
 
 				return
 			}
@@ -259,20 +229,17 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 	/* ---------------------------Receive Messages----------------------------- */
 	go func(){
 		for {
-			select{	// check if must quit receiving
-			case <- quitReadingMessages:
+			select{
+			case <- quitReadingMessages:	// must quit receiving
 				return
-			default:
-				select{	// pause receiving
-				case <- pauseReadingMessages:
-					<- startReadingMessages	// wait for read signal
-				default:
-					select{	// read message
-					case message := <- msgChannel:
-
-						if !isSSVCMessageInQueue(messageQueue, message){
-							messageQueue = append(messageQueue, message)
-						}
+			case <- pauseReadingMessages:	// pause receiving
+				<- startReadingMessages	// wait for read signal
+			case message := <- msgChannel:	// read message
+				if key := newSSVCQueueKey(message); !isSSVCMessageQueued(queued[key], message) {
+					queued[key] = append(queued[key], message)
+					messageQueue = append(messageQueue, message)
+					select{
+					case received <- true:
 					default:
 					}
 				}
@@ -282,12 +249,12 @@ func SelfStabilizedVectorConsensus(ssvcid int, initVal []byte) {
 }
 
 
-func printmsg(msg map[int]map[string][]MT){
-	for p := range msg{
+func printmsg(msg *tupleset.SSVCMessages){
+	for _, p := range msg.Processes(){
 		fmt.Println(p)
 		for _,t := range []string{"init", "echo", "ready"} {
 			fmt.Println(t)
-			for _, m := range msg[p][t]{
+			for _, m := range msg.Of(p, t).Tuples(){
 				fmt.Print("("+strconv.Itoa(m.Sender)+" "+string(m.Value[:])+"), ")
 			}
 			fmt.Println()
@@ -305,102 +272,137 @@ func areVectorsEqual (a,b map[int][]byte) bool {
 }
 
 // Perform the required checks on new SSVC messages
-func handleNewSSVCMessages(messageQueue []ssvcmsg, msg map[int]map[string][]MT) map[int]map[string][]MT{
+func handleNewSSVCMessages(messageQueue []ssvcmsg, msg *tupleset.SSVCMessages) *tupleset.SSVCMessages{
 
 	for i:=0; i < len(messageQueue); i++{
 		message := messageQueue[i]
-		sender := message.From
-		var copied int
-		pmsg := map[string][]MT{}	// sender's msg copy
-
-		for _, mtype := range []string{"echo", "ready"} {
-			// copy echo/ready
-			pmsg[mtype] = make([]MT, len(msg[sender][mtype]))
-			copied = copy(pmsg[mtype], msg[sender][mtype])
-			if copied != len(msg[sender][mtype]) {
-				fmt.Println("copied "+strconv.Itoa(copied)+" len "+strconv.Itoa(len(msg[sender][mtype])))
-				panic("Copied less "+mtype+" elements")
-			}
-		}
+		msg = mergeSSRBMessages(msg, message.From, message.SSVCMessage.Content)
+	}
+	return msg
+}
 
-		for _, mtype := range []string{"echo", "ready"} {
-			// add new echo and ready messages
-			for _, m := range message.SSVCMessage.Content[mtype] {
-				if _, in := containsMessage(msg[sender][mtype], m); !in { // add message only 1 time
-					msg[sender][mtype] = append(msg[sender][mtype], m)
-					pmsg[mtype] = append(pmsg[mtype], m)
-				}
-			}
+// mergeSSRBMessages - Adds the init, echo and ready messages that sender sent (content) to msg (its
+// init only if they do not conflict with its echo/ready messages)
+func mergeSSRBMessages(msg *tupleset.SSVCMessages, sender int,
+	content map[string][]MT) *tupleset.SSVCMessages {
+	if !msg.Has(sender) {
+		return msg	// not a server
+	}
+
+	for _, mtype := range []string{"echo", "ready"} {
+		// add new echo and ready messages (only 1 time)
+		for _, m := range content[mtype] {
+			msg.Of(sender, mtype).Add(m)
 		}
+	}
 
-		// add init if no conflicting echo/ready values
-		if conflict, _ := messageConflictExists (pmsg); !conflict {
-			for _, m := range message.SSVCMessage.Content["init"] {
-				if _, in := containsMessage(msg[sender]["init"], m); !in { // add init only 1 time
-					msg[sender]["init"] = append(msg[sender]["init"], m)
-				}
-			}
+	// add init if no conflicting echo/ready values
+	if conflict, _ := messageConflictExists (msg.Content(sender)); !conflict {
+		for _, m := range content["init"] {
+			msg.Of(sender, "init").Add(m) // add init only 1 time
 		}
 	}
 	return msg
 }
 
-// Check if received message already exists in message queue
-func isSSVCMessageInQueue(messageQueue []ssvcmsg, newmsg ssvcmsg) bool {
-
-	for _, msg := range messageQueue{
-		if msg.From == newmsg.From { // for every message in queue
-			m := msg.SSVCMessage.Content
-			equalMessages := true
-			for i:=0; i < 3 && equalMessages; i++{ // for every type
-				mtype := []string{"init", "echo", "ready"}[i]
-				newm := newmsg.SSVCMessage.Content[mtype]
-				if len(m[mtype]) == len(newm) {
-					for _, nm := range newm { // every msg in type
-						if _, in := containsMessage(m[mtype], nm); !in {
-							equalMessages = false
-							break
-						}
-					}
-				} else {
-					equalMessages = false
-					break
-				}
+// ssrbStep - A step of the self-stabilizing reliable broadcast of the init messages in msg (SSVC and
+// SSMVC run one): flushes the inconsistent messages, adds own (if it is not nil) as the init message
+// of this process and sends the echo and ready messages of the values with enough support
+func (node *Node) ssrbStep(msg *tupleset.SSVCMessages, own *MT, protocol string) *tupleset.SSVCMessages {
+	// Consistency checks
+	if sendersCorrect,_ := node.areSendersCorrect(msg, node.ID); !sendersCorrect ||
+		!node.isReadyConsistent(msg, node.ID) || !isEchoConsistent(msg, node.ID) {
+		msg.Flush(node.ID) // empty every message type set
+		node.Messenger.Metrics.Flushes.Inc(protocol, "all")
+	}
+
+	if own != nil {
+		msg.Of(node.ID, "init").Add(*own)
+	}
+
+	for i:=0; i < node.n(); i++ {	// for every process
+		if !msg.Has(i) {	// a process that joined
+			msg.Flush(i)
+		}
+		correctSenders, incorrectTypes := node.areSendersCorrect(msg,i)
+		conflict, conflictTypes := messageConflictExists (msg.Content(i))
+		if !isInitCorrect(msg.Of(i, "init").Tuples(), i) {	// empty messages by i
+			msg.Flush(i)
+			node.Messenger.Metrics.Flushes.Inc(protocol, "process")
+		}
+		if !correctSenders || conflict {	// flush certain types only
+			flushTypes := append(incorrectTypes, conflictTypes...)
+			node.Messenger.Metrics.Flushes.Add(float64(len(flushTypes)), protocol, "types")
+
+			for _, ftype := range flushTypes {
+				msg.Of(i, ftype).Clear()
 			}
-			if equalMessages {return equalMessages}	// found an identical message
+		}
+
+		// send echo message for every init (only 1 echo per init)
+		for _, initm := range msg.Of(i, "init").Tuples() {
+			msg.Of(node.ID, "echo").Add(initm)
 		}
 	}
-	return false
+
+	// send ready message based on echo and ready messages
+	// calculate echo/ready with enough support
+	echoMsgs := msg.Threshold(int(math.Ceil(float64(node.n() + node.f())/2)), "echo")
+	readyMsgs := msg.Threshold(node.f() + 1, "ready")
+
+	for _,  m := range echoMsgs {
+		msg.Of(node.ID, "ready").Add(m) // only 1 ready per init
+	}
+	for _, m := range readyMsgs {
+		msg.Of(node.ID, "ready").Add(m) // only 1 ready per init
+	}
+	return msg
+}
+
+// ssvcQueueKey - What tells received messages apart without comparing their tuples
+type ssvcQueueKey struct {
+	from int
+	gossip types.Gossip
+	digest types.Digest
+	content, removed int	// how many tuples the message carries
 }
 
-// Check if a message is contained in slice
-// Return true, the position of its appearance in the slice, otherwise false,-1
-func containsMessage(messages []MT, m MT) (int, bool) {
-	for i, t := range messages {
-		if t.Sender == m.Sender && bytes.Equal(m.Value, t.Value) {return i, true}
+// newSSVCQueueKey - The key of a received message
+func newSSVCQueueKey(message ssvcmsg) ssvcQueueKey {
+	m := message.SSVCMessage
+	key := ssvcQueueKey{from: message.From, gossip: m.Gossip, digest: m.Digest}
+	for _, mtype := range tupleset.Types {
+		key.content += len(m.Content[mtype])
+		key.removed += len(m.Removed[mtype])
 	}
-	return -1, false
+	return key
 }
 
-// Flush (Initialize) message (msg) maps of process p if not initialized
-func flushProcessMsg(msg map[int]map[string][]MT, p int) map[int]map[string][]MT {
-	if _, in := msg[p]; !in {
-		msg[p] = map[string][]MT{}
+// Check if a message is identical to one of the queued messages with its key (their tuples are compared
+// only then, through their digests)
+func isSSVCMessageQueued(queued []ssvcmsg, message ssvcmsg) bool {
+	if len(queued) == 0 {
+		return false
 	}
-	msg[p]["init"] = []MT{}
-	msg[p]["echo"] = []MT{}
-	msg[p]["ready"] = []MT{}
-	return msg
+	content := tupleset.SSVCContentDigest(message.SSVCMessage.Content)
+	removed := tupleset.SSVCContentDigest(message.SSVCMessage.Removed)
+	for _, q := range queued {
+		if tupleset.SSVCContentDigest(q.SSVCMessage.Content) == content &&
+			tupleset.SSVCContentDigest(q.SSVCMessage.Removed) == removed {
+			return true	// found an identical message
+		}
+	}
+	return false
 }
 
 // Checks if sender IDs of messages are correct (between [0,N))
-func areSendersCorrect(msg map[int]map[string][]MT, p int) (bool, []string) {
+func (node *Node) areSendersCorrect(msg *tupleset.SSVCMessages, p int) (bool, []string) {
 	correct := true
 	incorrectTypes := []string{}
 
 	for _, mtype := range []string{"init","echo","ready"} {
-		for _, m := range msg[p][mtype] {
-			if m.Sender < 0 || m.Sender >= variables.N {
+		for _, m := range msg.Of(p, mtype).Tuples() {
+			if m.Sender < 0 || m.Sender >= node.n() {
 				incorrectTypes = append(incorrectTypes, mtype)
 				correct = false
 				break
@@ -412,32 +414,24 @@ func areSendersCorrect(msg map[int]map[string][]MT, p int) (bool, []string) {
 }
 
 // Checks if for every echo message of process p, an init message exists
-// Only for msg[variables.ID] entries
-func isEchoConsistent (msg map[int]map[string][]MT, p int) bool {
-	pEchoMsgs := msg[p]["echo"]	// echo messages of process p
-
-	for _, m := range pEchoMsgs {
-		if _, sin := msg[m.Sender]; sin {	// check existance of correct sender
-			if _, in := containsMessage(msg[m.Sender]["init"], m); !in { // corresponding init check
-				return false
-			}
-		} else { return false }
+// Only for msg[node.ID] entries
+func isEchoConsistent (msg *tupleset.SSVCMessages, p int) bool {
+	for _, m := range msg.Of(p, "echo").Tuples() {	// echo messages of process p
+		// check existance of correct sender, and the corresponding init
+		if !msg.Has(m.Sender) || !msg.Of(m.Sender, "init").Contains(m) {
+			return false
+		}
 	}
 	return true
 }
 
 // Checks if ready messages of process p are consistent (either f+1 have seen a
 // ready message or (n+f)/2 have seen an echo one)
-// Only for msg[variables.ID] entries
-func isReadyConsistent (msg map[int]map[string][]MT, p int) bool {
-	pReadyMsgs := msg[p]["ready"]	// ready messages of process p
-
-	echoMsgs := valueThresholdOccurence(msg, int(math.Ceil(float64(variables.N + variables.F)/2)), "echo")
-	readyMsgs := valueThresholdOccurence(msg, variables.F + 1, "ready")
-
-	for _, m := range pReadyMsgs {
-		_, inThresholdEcho := containsMessage(echoMsgs, m)
-		_, inThresholdReady := containsMessage(readyMsgs, m)
+// Only for msg[node.ID] entries
+func (node *Node) isReadyConsistent(msg *tupleset.SSVCMessages, p int) bool {
+	for _, m := range msg.Of(p, "ready").Tuples() {	// ready messages of process p
+		inThresholdEcho := msg.Count("echo", m) >= int(math.Ceil(float64(node.n() + node.f())/2))
+		inThresholdReady := msg.Count("ready", m) >= node.f() + 1
 		if !(inThresholdEcho || inThresholdReady) {
 			return false
 		}
@@ -482,54 +476,18 @@ func messageConflictExists (messages map[string][]MT) (bool, []string) {
 }
 
 // Checks if vector created contains at least n-f non DEFAULT values
-func isVectorPopulated (vector map[int][]byte) bool{
+func (node *Node) isVectorPopulated(vector map[int][]byte) bool{
 	nonDefaultCounter := 0
-	for i := 0; i < variables.N; i++ {
+	for i := 0; i < node.n(); i++ {
 		if !bytes.Equal(vector[i], variables.DEFAULT) {
 			nonDefaultCounter++
 		}
 	}
-	return nonDefaultCounter >= variables.N - variables.F
-}
-
-// Returns every value v that has been send as a messageType message from
-// >=s processes (at least s)
-// messageType must be either echo or ready
-func valueThresholdOccurence (msg map[int]map[string][]MT, s int,
-	messageType string) []MT {
-		if !(messageType == "echo" || messageType == "ready") {
-			panic("Module self_stabilized_vector_consensus Function"+
-				"valueThresholdOccurence: messageType must be either echo or ready")
-		}
-
-		values := []MT{}	// values appearing >=s times (with their sender)
-		messages := []MT{}	// every message of type messageType sent
-		messageCounts := []int{}	// counter for every message in messages
-
-		//	count the occurence of each message
-		for process := range msg {
-			for _, message := range msg[process][messageType] {
-				if index, in := containsMessage(messages, message); in {
-					messageCounts[index]++
-				} else {
-					messages = append(messages, message)
-					messageCounts = append(messageCounts, 1)
-				}
-			}
-		}
-
-		//	return the messages which occur at least s times
-		for i := 0; i < len(messages); i++ {
-			if messageCounts[i] >= s {
-				values = append(values, messages[i])
-			}
-		}
-
-		return values
+	return nonDefaultCounter >= node.n() - node.f()
 }
 
-// Send every message from myself to every process
-func broadcastSSVCMessage(ssvcm types.SSVCMessage) {
+// Send every message from myself to every process (an error if some queues are full)
+func (node *Node) broadcastSSVCMessage(ssvcm types.SSVCMessage) error {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(ssvcm)
@@ -537,12 +495,12 @@ func broadcastSSVCMessage(ssvcm types.SSVCMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	message := types.NewMessage(w.Bytes(), "SSVC")
-	messenger.Broadcast(message)
+	message := node.Messenger.NewMessage(w.Bytes(), "SSVC")
+	return node.Messenger.Broadcast(message)
 }
 
 // Send every message from myself to every process
-func broadcastSSVCDecision(vector map[int][]byte, ssvcid int) {
+func (node *Node) broadcastSSVCDecision(vector map[int][]byte, ssvcid int) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(struct{
@@ -552,6 +510,18 @@ func broadcastSSVCDecision(vector map[int][]byte, ssvcid int) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	message := types.NewMessage(w.Bytes(), "SSVCDS")
-	messenger.Broadcast(message)
+	message := node.Messenger.NewMessage(w.Bytes(), "SSVCDS")
+	if err := node.Messenger.BroadcastWait(message); err != nil {
+		node.log("ssvc").Warn("decision not sent", "err", err)
+	}
+}
+
+// decideSSVC - Sends the decision of SSVC to SSABC, unless the instance has been collected
+func (node *Node) decideSSVC(ssvcid int, vect map[int][]byte) {
+	node.answerMutex.RLock()
+	answer := node.SSVCAnswer[ssvcid]
+	node.answerMutex.RUnlock()
+	if answer != nil {
+		answer <- vect
+	}
 }
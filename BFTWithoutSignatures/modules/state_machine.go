@@ -0,0 +1,74 @@
+package modules
+
+import (
+	"strconv"
+	"sync"
+)
+
+// StateMachine - The replicated application, to which the delivered commands are applied in total order
+type StateMachine interface {
+	// Apply - Executes a command and returns the result that is sent back to the client
+	Apply(cmd []byte) []byte
+
+	// Snapshot - Returns the encoded state of the application
+	Snapshot() []byte
+
+	// Restore - Replaces the state of the application with the given snapshot
+	Restore(snapshot []byte) error
+}
+
+// Querier - A StateMachine that also executes read-only commands (OpRead requests)
+type Querier interface {
+	// Query - Executes a command without modifying the state and returns its result
+	Query(cmd []byte) []byte
+}
+
+// RuneArray - The default application, an array of characters that has to be in consensus
+type RuneArray struct {
+	array []rune
+	mutex sync.RWMutex
+}
+
+// NewRuneArray - Creates an empty RuneArray
+func NewRuneArray() *RuneArray {
+	return &RuneArray{array: make([]rune, 0)}
+}
+
+// Apply - Appends the characters of the command and returns the new length of the array
+func (ra *RuneArray) Apply(cmd []byte) []byte {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	ra.array = append(ra.array, []rune(string(cmd))...)
+	return []byte(strconv.Itoa(len(ra.array)))
+}
+
+// Query - Returns the whole array (the command is ignored)
+func (ra *RuneArray) Query(cmd []byte) []byte {
+	return ra.Snapshot()
+}
+
+// Snapshot - Returns the array encoded in UTF-8
+func (ra *RuneArray) Snapshot() []byte {
+	ra.mutex.RLock()
+	defer ra.mutex.RUnlock()
+
+	return []byte(string(ra.array))
+}
+
+// Restore - Replaces the array with a snapshot taken by Snapshot
+func (ra *RuneArray) Restore(snapshot []byte) error {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	ra.array = []rune(string(snapshot))
+	return nil
+}
+
+// Array - Returns a copy of the array
+func (ra *RuneArray) Array() []rune {
+	ra.mutex.RLock()
+	defer ra.mutex.RUnlock()
+
+	return append([]rune(nil), ra.array...)
+}
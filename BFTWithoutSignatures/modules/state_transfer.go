@@ -0,0 +1,290 @@
+package modules
+
+import (
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+const (
+	// stateRetry - How often a replica that catches up asks again for the state of the others
+	stateRetry = time.Second
+
+	// stateTimeout - How long a replica that is behind at runtime waits for f+1 matching states
+	stateTimeout = 5 * stateRetry
+)
+
+// ErrNoMatchingStates - CatchUp did not learn before its timeout whether the replica is up to date
+var ErrNoMatchingStates = errors.New("no f+1 matching states before the timeout")
+
+// replicaState - The state of the request handler at some delivery index (what is transferred)
+type replicaState struct {
+	Index      int
+	Applied    []clientRequests // The duplicate-suppression table
+	Machine    []byte           // The snapshot of the StateMachine
+	Membership configuration    // The replicas as ordered up to Index (see membership.go)
+}
+
+// StateTransfer - The module that answers the state requests of the other replicas and collects their
+// checkpoint votes [started from main]
+func (node *Node) StateTransfer() {
+	go func() {
+		for message := range node.Messenger.StateChannel {
+			switch message.StateMessage.Tag {
+			case "request":
+				index, snapshot := node.snapshot()
+				digest := sha256.Sum256(snapshot)
+				if index <= message.StateMessage.Index {
+					snapshot = nil // It only needs the digest
+				}
+				node.log("state").Debug("reply", "index", index, "peer", message.From)
+				node.sendState(types.NewStateMessage("reply", index, digest[:], snapshot), message.From)
+
+			case "reply":
+				select {
+				case node.stateReplies <- message:
+				default: // Not catching up
+				}
+
+			case "checkpoint":
+				node.handleCheckpoint(message.StateMessage, message.From)
+			}
+		}
+	}()
+}
+
+// CatchUp - Asks the other replicas for their state and installs a state ahead of the local one that
+// f+1 of them report. The replica is up to date once n-f replicas (itself included) report a state
+// that is not ahead of its own, as the others are too few to agree on one. It is called before ABC or
+// SSABC is initiated (see behind for the replicas that fall behind later), and returns
+// ErrNoMatchingStates if neither happens before the timeout, or the error of the installation.
+func (node *Node) CatchUp(timeout time.Duration) error {
+	node.catchUpMutex.Lock()
+	defer node.catchUpMutex.Unlock()
+
+	return node.catchUp(timeout)
+}
+
+// behind - Catches up at runtime, if the checkpoints of f+1 replicas still show after stateRetry that
+// this replica is behind: it may never deliver the instances that they have collected, so ABC skips the
+// ones of the installed state (SSABC does not deliver them again either) [go started from handleCheckpoint]
+func (node *Node) behind() {
+	if !node.catchUpMutex.TryLock() {
+		return // Already catching up
+	}
+	defer node.catchUpMutex.Unlock()
+
+	select {
+	case <-time.After(stateRetry):
+	case <-node.Messenger.Stopped():
+		return
+	}
+	if !node.isBehind() {
+		return // It delivered the instances on its own
+	}
+
+	err := node.catchUp(stateTimeout)
+	if err != nil {
+		node.log("state").Error("not caught up", "err", err)
+		return
+	}
+	if !node.RunSSABC {
+		node.stateMutex.Lock()
+		index := node.lastDelivered
+		node.stateMutex.Unlock()
+		node.collect(index) // The root instance of every batch of ABC is its index
+	}
+}
+
+// catchUp - Same as CatchUp, for callers that hold catchUpMutex
+func (node *Node) catchUp(timeout time.Duration) error {
+	type report struct {
+		Index  int
+		Digest string
+	}
+	reports := make(map[int]report)
+	snapshots := make(map[string][]byte) // digest -> snapshot
+
+	ticker := time.NewTicker(stateRetry)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	node.requestState()
+	for {
+		select {
+		case message := <-node.stateReplies:
+			sm := message.StateMessage
+			reports[message.From] = report{Index: sm.Index, Digest: string(sm.Digest)}
+			if sm.Snapshot != nil {
+				digest := sha256.Sum256(sm.Snapshot)
+				if bytes.Equal(digest[:], sm.Digest) {
+					snapshots[string(sm.Digest)] = sm.Snapshot
+				}
+			}
+
+			node.stateMutex.Lock()
+			local := node.lastDelivered
+			node.stateMutex.Unlock()
+
+			notAhead := 1 // This replica
+			for _, r := range reports {
+				if r.Index <= local {
+					notAhead++
+				}
+			}
+			if notAhead >= node.n()-node.f() {
+				node.log("state").Info("up to date", "index", local)
+				return nil
+			}
+
+			// Look for a state ahead that f+1 replicas (so at least one correct) agree on
+			for _, r := range reports {
+				if r.Index <= local {
+					continue
+				}
+				count := 0
+				for _, other := range reports {
+					if other == r {
+						count++
+					}
+				}
+				if count < node.f()+1 {
+					continue
+				}
+				if snapshot, in := snapshots[r.Digest]; in {
+					err := node.install(snapshot)
+					if err != nil {
+						return err
+					}
+					node.log("state").Info("installed snapshot", "index", r.Index)
+					return nil
+				}
+			}
+
+		case <-ticker.C:
+			node.requestState() // The others may have moved on
+
+		case <-deadline:
+			node.log("state").Warn("no f+1 matching states before the timeout")
+			return ErrNoMatchingStates
+		}
+	}
+}
+
+// requestState - Asks every other replica for its state
+func (node *Node) requestState() {
+	node.stateMutex.Lock()
+	index := node.lastDelivered
+	node.stateMutex.Unlock()
+
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(types.NewStateMessage("request", index, nil, nil))
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	if err := node.Messenger.BroadcastWait(node.Messenger.NewMessage(w.Bytes(), "STATE")); err != nil {
+		node.log("state").Warn("request not sent", "err", err)
+	}
+}
+
+// sendState - Sends a state message to server to
+func (node *Node) sendState(stateMessage types.StateMessage, to int) {
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(stateMessage)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	if err := node.Messenger.SendMessageWait(node.Messenger.NewMessage(w.Bytes(), "STATE"), to); err != nil {
+		node.log("state").Warn("state not sent", "err", err)
+	}
+}
+
+// snapshot - Encodes the state of the request handler at the last delivery index
+func (node *Node) snapshot() (int, []byte) {
+	node.stateMutex.Lock()
+	defer node.stateMutex.Unlock()
+
+	return node.encodeState()
+}
+
+// encodeState - Same as snapshot, for callers that already hold stateMutex
+func (node *Node) encodeState() (int, []byte) {
+	state := replicaState{
+		Index:      node.lastDelivered,
+		Applied:    node.applied.list(),
+		Machine:    node.StateMachine.Snapshot(),
+		Membership: node.membership,
+	}
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(state)
+	if err != nil {
+		logger.ErrLogger.Fatal(err)
+	}
+	return state.Index, w.Bytes()
+}
+
+// restore - Replaces the state of the request handler with a snapshot, unless the snapshot is not ahead
+// of it (returns whether it did)
+func (node *Node) restore(snapshot []byte) (bool, error) {
+	var state replicaState
+	err := gob.NewDecoder(bytes.NewBuffer(snapshot)).Decode(&state)
+	if err != nil {
+		return false, err
+	}
+
+	node.stateMutex.Lock()
+	defer node.stateMutex.Unlock()
+
+	if state.Index <= node.lastDelivered {
+		return false, nil // Delivered meanwhile
+	}
+	err = node.StateMachine.Restore(state.Machine)
+	if err != nil {
+		return false, err
+	}
+	node.applied = appliedFrom(state.Applied)
+	node.lastDelivered = state.Index
+	if len(state.Membership.Members) > 0 { // Not a snapshot of an older version
+		node.membership = state.Membership
+	}
+	return true, nil
+}
+
+// install - Restores a snapshot received from the other replicas, makes it the stable checkpoint and
+// saves it in the WAL
+func (node *Node) install(snapshot []byte) error {
+	restored, err := node.restore(snapshot)
+	if err != nil || !restored {
+		return err
+	}
+
+	node.stateMutex.Lock()
+	index := node.lastDelivered
+	node.stateMutex.Unlock()
+
+	// The checkpoints up to the installed state are of no use anymore (f+1 replicas are past them)
+	node.checkpointMutex.Lock()
+	if index > node.stableCheckpoint {
+		node.stableCheckpoint = index
+	}
+	for i := range node.ownCheckpoints {
+		if i <= index {
+			delete(node.ownCheckpoints, i)
+		}
+	}
+	for i, v := range node.checkpoints {
+		if v.Index <= index {
+			delete(node.checkpoints, i)
+		}
+	}
+	node.checkpointMutex.Unlock()
+
+	if node.WAL == nil {
+		return nil
+	}
+	return node.WAL.SaveSnapshot(index, snapshot)
+}
@@ -2,7 +2,6 @@ package modules
 
 import (
 	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/messenger"
 	"BFTWithoutSignatures/types"
 	"BFTWithoutSignatures/variables"
 	"bytes"
@@ -10,47 +9,53 @@ import (
 	"encoding/json"
 )
 
-var (
-	// MVCAnswer - Channel to receive the answer from MVC
-	MVCAnswer = make(map[int]chan []byte)
-)
-
 // VectorConsensus - The method that is called to initiate the VC module
-func VectorConsensus(vcid int, initVal []byte) {
+func (node *Node) VectorConsensus(vcid int, initVal []byte) {
 	// START Variables initialization
 	received := make(map[int][]byte)
-	received[variables.ID] = initVal
+	received[node.ID] = initVal
 
-	messenger.VcMutex.Lock()
-	if _, in := messenger.VcChannel[vcid]; !in {
-		messenger.VcChannel[vcid] = make(chan struct {
+	node.Messenger.VcMutex.Lock()
+	if _, in := node.Messenger.VcChannel[vcid]; !in {
+		node.Messenger.VcChannel[vcid] = make(chan struct {
 			VcMessage types.VcMessage
 			From      int
 		})
 	}
-	messageChannel := messenger.VcChannel[vcid]
-	messenger.VcMutex.Unlock()
+	messageChannel := node.Messenger.VcChannel[vcid]
+	node.Messenger.VcMutex.Unlock()
+	done := node.Messenger.Done(vcid)
 	// END Variables initialization
 
 	// Reliable Broadcast the given value
-	rbVC(vcid, types.NewVcMessage(vcid, initVal))
+	node.rbVC(vcid, types.NewVcMessage(vcid, initVal))
 
 	for round := 0; ; round++ {
-		for message := range messageChannel {
+		for {
+			var message struct {
+				VcMessage types.VcMessage
+				From      int
+			}
+			select {
+			case <-done:
+				return // The instance has been collected
+			case message = <-messageChannel:
+			}
+
 			if _, in := received[message.From]; in {
 				continue // Only one value can be received from each process
 			}
 			received[message.From] = message.VcMessage.Value
 
 			// Wait until at least ((n-f)+r) INIT messages
-			if len(received) == ((variables.N - variables.F) + round) {
+			if len(received) == ((node.n() - node.f()) + round) {
 				break
 			}
 		}
 
 		// Built the vector with the values received
-		vector := make(map[int][]byte, variables.N)
-		for i := 0; i < variables.N; i++ {
+		vector := make(map[int][]byte, node.n())
+		for i := 0; i < node.n(); i++ {
 			if _, in := received[i]; in {
 				vector[i] = received[i]
 			} else {
@@ -60,16 +65,24 @@ func VectorConsensus(vcid int, initVal []byte) {
 
 		// Compute the MVC identifier and convert vector to bytes
 		mvcid := ComputeUniqueIdentifier(vcid, round)
-		MVCAnswer[mvcid] = make(chan []byte, 1)
+		node.answerMutex.Lock()
+		answer := make(chan []byte, 1)
+		node.MVCAnswer[mvcid] = answer
+		node.answerMutex.Unlock()
 		w, err := json.Marshal(vector)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
 
-		logger.OutLogger.Print(vcid, ".VC: len-", len(received), " vector-", vector, " --> MVC\n")
+		node.log("vc").Debug("vector --> MVC", "vcid", vcid, "received", len(received), "vector", vector)
 
-		go MultiValuedConsensus(mvcid, w)
-		v := <-MVCAnswer[mvcid]
+		go node.MultiValuedConsensus(mvcid, w)
+		var v []byte
+		select {
+		case v = <-answer:
+		case <-done:
+			return // The instance has been collected
+		}
 
 		// If MVC answer != DEFAULT, then decide this value, else go to next the round
 		if !bytes.Equal(v, variables.DEFAULT) {
@@ -79,14 +92,19 @@ func VectorConsensus(vcid int, initVal []byte) {
 				logger.ErrLogger.Fatal(err)
 			}
 
-			logger.OutLogger.Print(vcid, ".VC: decide-", vect, "\n")
-			VCAnswer[vcid] <- vect
+			node.log("vc").Debug("decide", "vcid", vcid, "vector", vect)
+			node.answerMutex.RLock()
+			vcAnswer := node.VCAnswer[vcid]
+			node.answerMutex.RUnlock()
+			if vcAnswer != nil { // Not collected
+				vcAnswer <- vect
+			}
 			return
 		}
 	}
 }
 
-func rbVC(id int, vcMessage types.VcMessage) {
+func (node *Node) rbVC(id int, vcMessage types.VcMessage) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(vcMessage)
@@ -94,7 +112,7 @@ func rbVC(id int, vcMessage types.VcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	msg := types.NewMessage(w.Bytes(), "VC")
+	msg := node.Messenger.NewMessage(w.Bytes(), "VC")
 	w = new(bytes.Buffer)
 	encoder = gob.NewEncoder(w)
 	err = encoder.Encode(msg)
@@ -102,5 +120,5 @@ func rbVC(id int, vcMessage types.VcMessage) {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	go ReliableBroadcast(id, "VC", w.Bytes())
+	go node.ReliableBroadcast(id, "VC", w.Bytes())
 }
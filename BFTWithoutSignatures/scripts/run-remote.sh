@@ -2,12 +2,8 @@
 
 MACHINE=$1
 
-N=10
-CLIENTS=25
-SCEN=0
-
-TRANSIENT=0
-SSABC=1 # SSABC == 0 -> run ABC algorithm | SSABC == 1 -> run SSABC algorithm
+CLUSTER=~/go/src/BFTWithoutSignatures/config/cluster-remote.json # Replace machine0-7 with the hosts of the machines
+N=10	# Number of replicas in the cluster file
 
 if [ $MACHINE -eq 2 ]
 then
@@ -18,7 +14,7 @@ fi
 
 ID=$MACHINE
 echo "STARTED $ID"
-go run BFTWithoutSignatures $ID $N $CLIENTS $SCEN 1 $TRANSIENT $SSABC &
+go run BFTWithoutSignatures $CLUSTER $ID &
 
 if [ $MACHINE -eq 2 ] || [ $MACHINE -eq 3 ]
 then
@@ -26,6 +22,6 @@ then
 	for (( ID=(($TEMP+8)); ID<$N; ((ID+=2)) ))
 	do
 		echo "STARTED $ID"
-		go run BFTWithoutSignatures $ID $N $CLIENTS $SCEN 1 $TRANSIENT $SSABC &
+		go run BFTWithoutSignatures $CLUSTER $ID &
 	done
 fi
@@ -1,5 +1,7 @@
 #!/bin/bash
 rm ~/go/src/tests/out/*
+rm ~/go/src/tests/trace/*  # TestABroadcast and TestSSABroadcast record their deliveries, checked (after kill-test.sh) by
+                           # go test -v -run TestCheckTraces /home/csdeptucy/go/src/BFTWithoutSignatures/tests -args $N $SCEN
 TEST=TestSSVConsensus  # TestSSVConsensus, TestSSABroadcast, TestMVConsensus , TestVConsensus, TestABroadcast, TestBConsensus, TestBvBroadcast
 
 N=7
@@ -1,12 +1,7 @@
 #!/bin/bash
 
-N=4
-CLIENTS=5
-REM=0
-
-SCEN=0	# SCENARIO
-TRANSIENT=0	# TRANSIENT PROBABILITY
-SSABC=0 # SSABC == 0 -> run ABC algorithm | SSABC == 1 -> run SSABC algorithm
+CLUSTER=~/go/src/BFTWithoutSignatures/config/cluster.json # Replicas, clients and protocol options
+N=4	# Number of replicas in the cluster file
 
 go install BFTWithoutSignatures
 
@@ -14,5 +9,5 @@ BFTWithoutSignatures generate_keys $N
 
 for (( ID=0; ID<$N; ID++ ))
 do
-	BFTWithoutSignatures $ID $N $CLIENTS $SCEN $REM $TRANSIENT $SSABC &
+	BFTWithoutSignatures $CLUSTER $ID &
 done
@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// With HMAC links the messages are not signed, and the replicas still agree on the same order
+func TestHMACLinks(t *testing.T) {
+	n := 4
+	options := config.InitializeScenario(0, -1)
+	options.Auth = config.AuthHMAC
+	nodes, network := newTestCluster(t, n, options, false, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	if message := nodes[0].Messenger.NewMessage([]byte("payload"), "BC"); message.Signature != nil {
+		t.Error("Message signed in HMAC mode")
+	}
+
+	for _, node := range nodes {
+		node.InitiateAtomicBroadcast()
+	}
+	for _, node := range nodes {
+		go func(node *modules.Node) {
+			node.AtomicBroadcast([]byte{byte('A' + node.ID)})
+		}(node)
+	}
+
+	delivered := make(map[int][][]byte, n)
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *modules.Node) {
+			defer wg.Done()
+			for count := 0; count < n; {
+				message := <-node.Delivered
+				count += len(message.Value)
+				mutex.Lock()
+				delivered[node.ID] = append(delivered[node.ID], message.Value...)
+				mutex.Unlock()
+			}
+		}(node)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		mutex.Lock()
+		t.Fatalf("Replicas did not deliver %d values: %v", n, delivered)
+	}
+	for i := 1; i < n; i++ {
+		for k := range delivered[0] {
+			if !bytes.Equal(delivered[0][k], delivered[i][k]) {
+				t.Errorf("Replicas 0 and %d disagree at position %d: %q != %q",
+					i, k, delivered[0][k], delivered[i][k])
+			}
+		}
+	}
+
+	// A frame with a wrong MAC and a Hello that server 3 did not sign are counted against it
+	frames := []messenger.Frame{
+		{Seq: 1, Payload: []byte("payload"), MAC: []byte("mac")},
+		{Hello: &messenger.Hello{Public: []byte("public"), Signature: []byte("signature")}},
+	}
+	for _, frame := range frames {
+		data, err := messenger.EncodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := network.Transport(3).Send(0, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for nodes[0].Messenger.MalformedCount(3) < len(frames) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d malformed frames counted instead of %d", nodes[0].Messenger.MalformedCount(3), len(frames))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	/*** End Testing ***/
+}
+
+// A Hello of an earlier incarnation of a server that is replayed does not replace the session keys of
+// its link, and the link goes on with the keys of the server as it is now
+func TestHMACHelloReplay(t *testing.T) {
+	n := 4
+	discardLogs()
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	recorder := &helloRecorder{Transport: network.Transport(3)}
+	start := func(i int, transport messenger.Transport) *messenger.Messenger {
+		msgr := messenger.NewMessenger(variables.NewReplica(i, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[i])
+		msgr.Auth = config.AuthHMAC
+		msgr.InitializeTransport(transport)
+		msgr.Subscribe()
+		msgr.TransmitMessages()
+		return msgr
+	}
+	msgrs := make([]*messenger.Messenger, n)
+	for i := 0; i < n-1; i++ {
+		msgrs[i] = start(i, network.Transport(i))
+	}
+	msgrs[3] = start(3, recorder)
+	expect := func(k int) {
+		if err := msgrs[3].SendMessage(msgrs[3].NewMessage(encodeState(t, k), "STATE"), 0); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case message := <-msgrs[0].StateChannel:
+			if message.StateMessage.Index != k {
+				t.Errorf("Server 0 received %d instead of %d", message.StateMessage.Index, k)
+			}
+		case <-time.After(20 * time.Second):
+			t.Fatalf("Server 0 did not receive %d", k)
+		}
+	}
+
+	/*** Start Testing ***/
+
+	expect(0)
+	old := recorder.hello()
+	if old == nil {
+		t.Fatal("Server 3 sent no Hello to server 0")
+	}
+
+	msgrs[3].Close()
+	msgrs[3] = start(3, &restartableTransport{network.Transport(3)})
+	expect(1)
+
+	if err := network.Transport(3).Send(0, old); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for msgrs[0].MalformedCount(3) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Replayed Hello was not rejected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expect(2)
+
+	/*** End Testing ***/
+}
+
+// helloRecorder - A transport that keeps the first frame with a Hello that its server sends to server 0
+type helloRecorder struct {
+	messenger.Transport
+	first []byte
+	mutex sync.Mutex
+}
+
+func (transport *helloRecorder) Send(to int, message []byte) error {
+	frame, err := messenger.DecodeFrame(message)
+	if err == nil && frame.Hello != nil && to == 0 {
+		transport.mutex.Lock()
+		if transport.first == nil {
+			transport.first = message
+		}
+		transport.mutex.Unlock()
+	}
+	return transport.Transport.Send(to, message)
+}
+
+func (transport *helloRecorder) Close() {}
+
+// hello - The recorded frame (nil if there is none yet)
+func (transport *helloRecorder) hello() []byte {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	return transport.first
+}
+
+// Throughput of the links when every message is signed with RSA
+func BenchmarkAuthRSA(b *testing.B) {
+	benchmarkAuth(b, config.AuthRSA)
+}
+
+// Throughput of the links when the frames carry HMACs with session keys
+func BenchmarkAuthHMAC(b *testing.B) {
+	benchmarkAuth(b, config.AuthHMAC)
+}
+
+// benchmarkAuth - Broadcasts b.N messages from server 0 and waits until every other server receives them
+func benchmarkAuth(b *testing.B, auth string) {
+	n := 4
+	discardLogs()
+	keys := generateTestKeys(b, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	msgrs := make([]*messenger.Messenger, n)
+	for i := 0; i < n; i++ {
+		msgrs[i] = messenger.NewMessenger(variables.NewReplica(i, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[i])
+		msgrs[i].Auth = auth
+		msgrs[i].QueueSize = b.N // Nothing is dropped because of backpressure
+		msgrs[i].InitializeTransport(network.Transport(i))
+		msgrs[i].Subscribe()
+		msgrs[i].TransmitMessages()
+	}
+	payload := encodeState(b, 0)
+
+	wg := sync.WaitGroup{}
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < b.N; k++ {
+				<-msgrs[i].StateChannel
+			}
+		}(i)
+	}
+
+	/*** Start Testing ***/
+
+	b.ResetTimer()
+	for k := 0; k < b.N; k++ {
+		if err := msgrs[0].Broadcast(msgrs[0].NewMessage(payload, "STATE")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	wg.Wait()
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msgs/s")
+
+	/*** End Testing ***/
+}
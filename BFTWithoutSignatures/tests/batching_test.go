@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Client requests are packed into batches, and every request is applied once on delivery
+func TestBatching(t *testing.T) {
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	options.BatchRequests = 10
+	options.BatchLinger = 10 * time.Second // Only full batches are broadcast
+	nodes, network := newTestCluster(t, 4, options, false, nil)
+	defer network.Close()
+	for _, node := range nodes {
+		node.InitiateAtomicBroadcast()
+		node.RequestHandler()
+	}
+
+	/*** Start Testing ***/
+
+	requests := [][]byte{encodeRequest(t, 0, 1, "A"), encodeRequest(t, 0, 2, "B")}
+	packed, err := types.PackRequests(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := types.UnpackRequests(packed)
+	if err != nil || len(unpacked) != 2 || !bytes.Equal(unpacked[0], requests[0]) ||
+		!bytes.Equal(unpacked[1], requests[1]) {
+		t.Errorf("Batch of 2 requests was unpacked as %q (%v)", unpacked, err)
+	}
+	single, _ := types.PackRequests(requests[:1])
+	if types.IsBatch(requests[0]) || !bytes.Equal(single, requests[0]) {
+		t.Error("A single request is packed")
+	}
+
+	// As a client does, every request is sent to f+1 replicas
+	for num := 1; num <= 40; num++ {
+		request := encodeRequest(t, 1, num, "x")
+		nodes[0].Messenger.RequestChannel <- request
+		nodes[1].Messenger.RequestChannel <- request
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for _, node := range nodes {
+		for strings.Count(string(node.StateMachine.Snapshot()), "x") != 40 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Replica %d applied %q instead of 40 requests", node.ID, node.StateMachine.Snapshot())
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// 8 batches of 10 requests (4 from each replica) were broadcast
+	if delivered := nodes[0].Aid - 1; delivered > 8 {
+		t.Errorf("40 requests were delivered in %d batches", delivered)
+	}
+
+	/*** End Testing ***/
+}
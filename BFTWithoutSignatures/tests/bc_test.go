@@ -7,6 +7,7 @@ import (
 	"BFTWithoutSignatures/modules"
 	"BFTWithoutSignatures/threshenc"
 	"BFTWithoutSignatures/variables"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -16,7 +17,11 @@ import (
 )
 
 func TestBvBroadcast(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
+	}
 	if len(args) == 5 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -24,18 +29,18 @@ func TestBvBroadcast(t *testing.T) {
 		scenario, _ := strconv.Atoi(args[3])
 		remote, _ := strconv.Atoi(args[4])
 
-		initializeForTestBc(id, n, clients, scenario, remote)
+		node = initializeForTestBc(id, n, clients, scenario, remote)
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
 	}
 
 	/*** Start Testing ***/
 
-	go modules.BvBroadcast(1, 0)
+	go node.BvBroadcast(1, 0)
 
-	go modules.BvBroadcast(2, 1)
+	go node.BvBroadcast(2, 1)
 
-	go modules.BvBroadcast(3, uint(variables.ID%2))
+	go node.BvBroadcast(3, uint(node.ID%2))
 
 	/*** End Testing ***/
 
@@ -44,7 +49,11 @@ func TestBvBroadcast(t *testing.T) {
 }
 
 func TestBConsensus(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
+	}
 	if len(args) == 5 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -52,18 +61,18 @@ func TestBConsensus(t *testing.T) {
 		scenario, _ := strconv.Atoi(args[3])
 		remote, _ := strconv.Atoi(args[4])
 
-		initializeForTestBc(id, n, clients, scenario, remote)
+		node = initializeForTestBc(id, n, clients, scenario, remote)
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
 	}
 
 	/*** Start Testing ***/
 
-	go modules.BinaryConsensus(1, 0)
+	go node.BinaryConsensus(1, 0)
 
-	go modules.BinaryConsensus(2, 1)
+	go node.BinaryConsensus(2, 1)
 
-	go modules.BinaryConsensus(3, uint(variables.ID%2))
+	go node.BinaryConsensus(3, uint(node.ID%2))
 
 	/*** End Testing ***/
 
@@ -72,32 +81,34 @@ func TestBConsensus(t *testing.T) {
 }
 
 // Initializes the environment for the test
-func initializeForTestBc(id int, n int, clients int, scenario int, rem int) {
-	variables.Initialize(id, n, clients, rem)
+func initializeForTestBc(id int, n int, clients int, scenario int, rem int) *modules.Node {
+	replica := variables.NewReplica(id, n, clients, rem)
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal( err )
 	}
 	user_dirname = user_dirname + "/go/src"
-	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/")
+	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/", replica.ID)
 
-	if variables.Remote {
-		config.InitializeIP()
+	var addresses config.Addresses
+	if replica.Remote {
+		addresses = config.InitializeIP(replica)
 	} else {
-		config.InitializeLocal()
+		addresses = config.InitializeLocal(replica)
 	}
-	config.InitializeScenario(scenario, -1)
+	options := config.InitializeScenario(scenario, -1)
 
 	logger.OutLogger.Print(
-		"ID:", variables.ID, " | N:", variables.N, " | F:", variables.F, " | Clients:",
-		variables.Clients, " | Scenario:", config.Scenario, " | Remote:", variables.Remote, "\n\n",
+		"ID:", replica.ID, " | N:", replica.N, " | F:", replica.F, " | Clients:",
+		replica.Clients, " | Scenario:", options.Scenario, " | Remote:", replica.Remote, "\n\n",
 	)
 
-	threshenc.ReadKeys(user_dirname+"/tests/keys/")
+	keys := threshenc.ReadKeys(user_dirname+"/tests/keys/", replica.ID, replica.N)
 
-	messenger.InitializeMessenger()
-	messenger.Subscribe()
-	messenger.TransmitMessages()
+	msgr := messenger.NewMessenger(replica, options.Scenario, keys)
+	msgr.InitializeMessenger(addresses)
+	msgr.Subscribe()
+	msgr.TransmitMessages()
 
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate,
@@ -108,19 +119,10 @@ func initializeForTestBc(id int, n int, clients int, scenario int, rem int) {
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			for i := 0; i < variables.N; i++ {
-				if i == variables.ID {
-					continue // Not myself
-				}
-				messenger.ReceiveSockets[i].Close()
-				messenger.SendSockets[i].Close()
-			}
-
-			for i := 0; i < variables.Clients; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
+			msgr.Close()
 			os.Exit(0)
 		}
 	}()
+
+	return modules.NewNode(replica, options, false, msgr)
 }
@@ -0,0 +1,222 @@
+package tests
+
+// go test -v -run TestCheckTraces /home/csdeptucy/go/src/BFTWithoutSignatures/tests -args 4 1
+
+import (
+	"BFTWithoutSignatures/checker"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Checks the traces that the replicas of TestABroadcast or TestSSABroadcast recorded, once they are
+// killed (the Byzantine replicas of the scenario are not checked)
+func TestCheckTraces(t *testing.T) {
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<n> <scenario>'")
+	}
+	if len(args) != 2 {
+		log.Fatal("Arguments should be '<n> <scenario>'")
+	}
+	n, _ := strconv.Atoi(args[0])
+	scenario, _ := strconv.Atoi(args[1])
+
+	correct := make([]int, 0, n)
+	for id := 0; id < n; id++ {
+		if scenario == 0 || !variables.NewReplica(id, n, 0, 0).Byzantine {
+			correct = append(correct, id)
+		}
+	}
+	c := checker.New(correct...)
+
+	/*** Start Testing ***/
+
+	if err := c.ReadFolder(traceFolder()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+	for _, id := range correct {
+		t.Logf("Replica %d delivered %d batches", id, len(c.Delivered(id)))
+	}
+
+	/*** End Testing ***/
+}
+
+// Every property is violated by a run built for it, and the violation points at the divergence
+func TestCheckerViolations(t *testing.T) {
+	v := func(s ...string) [][]byte {
+		values := make([][]byte, len(s))
+		for k := range s {
+			values[k] = []byte(s[k])
+		}
+		return values
+	}
+	// run - A checker of replicas 0 to 2 (3 is Byzantine) after every replica broadcast its value and
+	// delivered the first batches
+	run := func() *checker.Checker {
+		c := checker.New(0, 1, 2)
+		for id, value := range []string{"a", "b", "c", "z"} {
+			c.Broadcast(id, []byte(value))
+		}
+		for id := 0; id <= 3; id++ {
+			c.Deliver(id, 1, v("a", "b"))
+		}
+		return c
+	}
+
+	/*** Start Testing ***/
+
+	c := run()
+	c.Deliver(3, 2, v("z", "a")) // Byzantine replicas are not checked
+	for id := 0; id <= 2; id++ {
+		c.Deliver(id, 2, v("c"))
+	}
+	if err := c.Check(); err != nil {
+		t.Errorf("A correct run violates %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		run      func(c *checker.Checker)
+		safety   bool // If CheckSafety finds the violation
+		property string
+		replica  int
+		index    int
+		context  string
+	}{
+		{"divergence", func(c *checker.Checker) {
+			c.Deliver(0, 2, v("c"))
+			c.Deliver(1, 2, v("c"))
+			c.Deliver(2, 2, v("c", "z"))
+		}, true, checker.TotalOrder, 2, 2, `replica 2, index 2: ["c" "z"]`},
+		{"out of order", func(c *checker.Checker) {
+			c.Deliver(1, 1, v("c"))
+		}, true, checker.TotalOrder, 1, 1, `replica 1, index 1: ["a" "b"]`},
+		{"duplicate", func(c *checker.Checker) {
+			for id := 0; id <= 2; id++ {
+				c.Deliver(id, 2, v("c"))
+				c.Deliver(id, 3, v("a"))
+			}
+		}, true, checker.Integrity, 0, 3, `"a" was delivered 2 times but broadcast 1 times (last at index 1)`},
+		{"not broadcast", func(c *checker.Checker) {
+			for id := 0; id <= 2; id++ {
+				c.Deliver(id, 2, v("c", "forged"))
+			}
+		}, true, checker.Integrity, 0, 2, `"forged" was not broadcast`},
+		{"not delivered", func(c *checker.Checker) {
+			for id := 0; id <= 2; id++ {
+				c.Deliver(id, 2, v("z"))
+			}
+		}, false, checker.Validity, 0, 0, `"c", which replica 2 broadcast, was not delivered`},
+		{"behind", func(c *checker.Checker) {
+			for id := 0; id <= 2; id++ {
+				c.Deliver(id, 2, v("c"))
+			}
+			c.Deliver(0, 3, v("z"))
+			c.Deliver(1, 3, v("z"))
+		}, false, checker.Agreement, 2, 3, `"z" was delivered 0 times instead of 1`},
+	}
+	for _, test := range cases {
+		c := run()
+		test.run(c)
+
+		err := c.CheckSafety()
+		if !test.safety {
+			if err != nil {
+				t.Errorf("%s: a safe run violates %v", test.name, err)
+			}
+			err = c.Check()
+		}
+		violation, ok := err.(*checker.Violation)
+		if !ok {
+			t.Errorf("%s: the run is checked as %v", test.name, err)
+			continue
+		}
+		if violation.Property != test.property || violation.Replica != test.replica ||
+			violation.Index != test.index {
+			t.Errorf("%s: %s at replica %d, index %d is reported instead of %s at replica %d, index %d",
+				test.name, violation.Property, violation.Replica, violation.Index, test.property,
+				test.replica, test.index)
+		}
+		if !strings.Contains(err.Error(), test.context) {
+			t.Errorf("%s: the violation does not show %s:\n%v", test.name, test.context, err)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// A run that the replicas recorded in their traces is checked as if it was recorded by the checker
+func TestCheckerTraces(t *testing.T) {
+	folder := t.TempDir()
+
+	/*** Start Testing ***/
+
+	for id := 0; id < 3; id++ {
+		recorder, err := checker.CreateRecorder(folder, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Broadcast([]byte{byte(id), 0xff})
+		recorder.Deliver(1, [][]byte{{0, 0xff}, {1, 0xff}})
+		if id != 2 {
+			recorder.Deliver(2, [][]byte{{2, 0xff}})
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := checker.New(0, 1, 2)
+	if err := c.ReadFolder(folder); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckSafety(); err != nil {
+		t.Error(err)
+	}
+	if batches := c.Delivered(1); len(batches) != 2 || !bytes.Equal(batches[1].Values[0], []byte{2, 0xff}) {
+		t.Errorf("The trace of replica 1 is read as %v", batches)
+	}
+	err := c.Check()
+	if v, ok := err.(*checker.Violation); !ok || v.Property != checker.Validity || v.Replica != 2 {
+		t.Errorf("The missing delivery of replica 2 is checked as %v", err)
+	}
+
+	if err = checker.New(0).Read(strings.NewReader(`{"replica":0,"event":"propose"}`)); err == nil {
+		t.Error("An unknown event was read")
+	}
+	if err = checker.New(0).ReadFolder(t.TempDir()); err == nil {
+		t.Error("A folder without traces was read")
+	}
+
+	/*** End Testing ***/
+}
+
+// traceFolder - Where the replicas of the multi-process tests record their traces
+func traceFolder() string {
+	user_dirname, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return user_dirname + "/go/src" + "/tests/trace/"
+}
+
+// createTestRecorder - Records the trace of replica id in traceFolder
+func createTestRecorder(id int) *checker.Recorder {
+	if err := os.MkdirAll(traceFolder(), 0755); err != nil {
+		log.Fatal(err)
+	}
+	recorder, err := checker.CreateRecorder(traceFolder(), id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return recorder
+}
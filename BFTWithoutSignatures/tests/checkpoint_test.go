@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The identifiers of the instances lead back to the VC instance they were derived from
+func TestRootInstance(t *testing.T) {
+	/*** Start Testing ***/
+
+	for vcid := 1; vcid < 50; vcid++ {
+		for round := 0; round < 4; round++ {
+			mvcid := modules.ComputeUniqueIdentifier(vcid, round)
+			if root := messenger.RootInstance(mvcid, messenger.DepthMVC); root != vcid {
+				t.Fatalf("Root of MVC %d is %d instead of %d", mvcid, root, vcid)
+			}
+			for r := 1; r < 4; r++ {
+				id := modules.ComputeUniqueIdentifier(mvcid, r)
+				if root := messenger.RootInstance(id, messenger.DepthBC); root != vcid {
+					t.Fatalf("Root of BC round %d is %d instead of %d", id, root, vcid)
+				}
+			}
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// The root of huge identifiers (of long runs, or sent by a Byzantine server) is found at once
+func TestRootInstanceHugeIds(t *testing.T) {
+	/*** Start Testing ***/
+
+	for _, vcid := range []int{60000, 65000} {
+		mvcid := modules.ComputeUniqueIdentifier(vcid, 3)
+		id := modules.ComputeUniqueIdentifier(mvcid, 3)
+		if root := messenger.RootInstance(id, messenger.DepthBC); root != vcid {
+			t.Errorf("Root of BC round %d is %d instead of %d", id, root, vcid)
+		}
+	}
+
+	hostile := []int{math.MaxInt64, math.MaxInt64 - 1, 1 << 62, 1<<61 + 12345, 1618033988749894848}
+	done := make(chan bool)
+	go func() {
+		for _, id := range hostile {
+			for depth := messenger.DepthMVC; depth <= messenger.DepthBC; depth++ {
+				if root := messenger.RootInstance(id, depth); root < 0 || root > id {
+					t.Errorf("Root of %d at depth %d is %d", id, depth, root)
+				}
+			}
+		}
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RootInstance does not return for huge identifiers")
+	}
+
+	/*** End Testing ***/
+}
+
+// Replicas agree on checkpoints while ABC delivers, and collect the instances before them
+func TestCheckpoints(t *testing.T) {
+	n := 4
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	for _, node := range nodes {
+		node.CheckpointInterval = 1
+		node.StateTransfer()
+		node.RequestHandler()
+		node.InitiateAtomicBroadcast()
+	}
+
+	/*** Start Testing ***/
+
+	for round := 1; round <= 3; round++ {
+		for _, node := range nodes {
+			go node.AtomicBroadcast([]byte(strconv.Itoa(node.ID) + "-" + strconv.Itoa(round)))
+		}
+
+		// Every replica waits until the batch of this round is part of a stable checkpoint
+		deadline := time.Now().Add(30 * time.Second)
+		for _, node := range nodes {
+			for node.StableCheckpoint() < round {
+				if time.Now().After(deadline) {
+					t.Fatalf("Replica %d is at checkpoint %d instead of %d", node.ID, node.StableCheckpoint(), round)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if !node.Messenger.IsCollected(1) {
+			t.Errorf("Replica %d did not collect the instance of the first checkpoint", node.ID)
+		}
+		select {
+		case <-node.Messenger.Done(1):
+		default:
+			t.Errorf("Replica %d did not release the instance of the first checkpoint", node.ID)
+		}
+
+		node.Messenger.VcMutex.Lock()
+		_, in := node.Messenger.VcChannel[1]
+		node.Messenger.VcMutex.Unlock()
+		if in {
+			t.Errorf("Replica %d kept the channel of a collected VC instance", node.ID)
+		}
+	}
+
+	/*** End Testing ***/
+}
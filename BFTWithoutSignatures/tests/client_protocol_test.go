@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestClientProtocol(t *testing.T) {
+
+	/*** Start Testing ***/
+
+	// Requests and replies keep their opaque payloads
+	request := types.NewClientMessage(3, 7, types.OpRead, []byte{0, 1, 2, 255})
+	var decodedRequest types.ClientMessage
+	if err := encodeDecode(request, &decodedRequest); err != nil {
+		t.Fatal(err)
+	}
+	if decodedRequest.Cid != 3 || decodedRequest.Num != 7 || decodedRequest.Op != types.OpRead ||
+		!bytes.Equal(decodedRequest.Command, request.Command) {
+		t.Errorf("Request %+v was decoded as %+v", request, decodedRequest)
+	}
+
+	reply := types.NewReplyMessage(1, 7, types.StatusUnsupported, []byte("result"))
+	var decodedReply types.Reply
+	if err := encodeDecode(reply, &decodedReply); err != nil {
+		t.Fatal(err)
+	}
+	if decodedReply.From != 1 || decodedReply.Num != 7 || decodedReply.Status != types.StatusUnsupported ||
+		!bytes.Equal(decodedReply.Result, reply.Result) {
+		t.Errorf("Reply %+v was decoded as %+v", reply, decodedReply)
+	}
+
+	// Messages of another version are rejected instead of being misinterpreted
+	request.Version = types.ProtocolVersion + 1
+	if err := encodeDecode(request, &decodedRequest); err != types.ErrProtocolVersion {
+		t.Errorf("Request of version %d was decoded with error %v", request.Version, err)
+	}
+	reply.Version = types.ProtocolVersion + 1
+	if err := encodeDecode(reply, &decodedReply); err != types.ErrProtocolVersion {
+		t.Errorf("Reply of version %d was decoded with error %v", reply.Version, err)
+	}
+
+	if types.Operation(42).Valid() {
+		t.Error("Unknown operation is considered valid")
+	}
+
+	/*** End Testing ***/
+}
+
+// encodeDecode - Encodes a message as it is sent on the wire and decodes it into out
+func encodeDecode(in interface{}, out interface{}) error {
+	w := new(bytes.Buffer)
+	if err := gob.NewEncoder(w).Encode(in); err != nil {
+		return err
+	}
+	return gob.NewDecoder(w).Decode(out)
+}
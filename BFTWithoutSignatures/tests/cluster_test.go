@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/threshenc"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The cluster files of the repository are valid, and the sockets of every pair of replicas meet
+func TestClusterFile(t *testing.T) {
+	for _, file := range []string{"../config/cluster.json", "../config/cluster-remote.json"} {
+		cluster, err := config.LoadCluster(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		/*** Start Testing ***/
+
+		n := len(cluster.Replicas)
+		for i := 0; i < n; i++ {
+			from := cluster.Addresses(i)
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				to := cluster.Addresses(j)
+				bound := strings.Replace(to.Rep[i], "*", cluster.Replicas[j].Host, 1)
+				if from.Req[j] != bound {
+					t.Errorf("%s: replica %d sends to %s but replica %d receives on %s", file, i, from.Req[j], j, bound)
+				}
+			}
+		}
+		if options := cluster.Options(); options.Scenario != cluster.Protocol.Scenario ||
+			options.Auth != cluster.Protocol.Auth || options.BatchRequests != config.DefaultBatchRequests {
+			t.Errorf("%s: options %+v do not match the protocol %+v", file, options, cluster.Protocol)
+		}
+
+		// The coin keys are dealt for the replicas of the file: any f+1 of them toss the same coin
+		f := (n - 1) / 3
+		name := []byte("coin")
+		shares := make(map[int]threshenc.CoinShare, n)
+		for i := 0; i < n; i++ {
+			keys, err := threshenc.LoadCoinKeys(cluster.CoinKeysFolder()+"/", i, n)
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			shares[i], err = keys.NewShare(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !keys.VerifyShare(name, shares[i], i) {
+				t.Errorf("%s: share of replica %d does not verify", file, i)
+			}
+		}
+		first, last := make(map[int]threshenc.CoinShare), make(map[int]threshenc.CoinShare)
+		for i := 0; i <= f; i++ {
+			first[i], last[n-1-i] = shares[i], shares[n-1-i]
+		}
+		if threshenc.CombineShares(first) != threshenc.CombineShares(last) {
+			t.Errorf("%s: coin keys are not dealt for %d replicas", file, n)
+		}
+
+		/*** End Testing ***/
+	}
+}
+
+// Every problem of an invalid cluster file is reported at once
+func TestInvalidClusterFile(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "verification_0.key"), []byte("key"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]struct {
+		content  string
+		problems []string
+	}{
+		"unknown field": {`{"replica": []}`, []string{`unknown field "replica"`}},
+		"invalid": {`{
+			"clients": 2,
+			"keys": "missing/",
+			"protocol": {"scenario": "SOMETIMES", "algorithm": "XBC", "transient_probability": 2, "auth": "none",
+				"batch_requests": -1, "consensus_window": -2},
+			"replicas": [
+				{"id": 0, "host": "localhost", "peer_port": 4000, "client_port": 4002, "public_key": "verification_0.key"},
+				{"id": 0, "host": "", "peer_port": 0, "client_port": 70000, "public_key": "verification_1.key"},
+				{"id": 5, "host": "localhost", "peer_port": 5000, "client_port": 6000, "metrics_port": -1}
+			]
+		}`, []string{
+			"replica 0: listed more than once",
+			"replica 0: no host",
+			"peer_port 0",
+			"client_port 70000",
+			"verification_1.key",
+			"replicas[2]: id 5 is not in [0, 2]",
+			"replica 5: no public_key",
+			"replica 5: metrics_port -1",
+			"replica 0 peer ports [4000-4002] overlap with replica 0 client ports [4002-4005] on localhost",
+			"keys:",
+			`unknown scenario "SOMETIMES"`,
+			`algorithm is "XBC"`,
+			"transient_probability 2",
+			`auth is "none"`,
+			"batch_requests",
+			"consensus_window is -2",
+		}},
+	}
+
+	/*** Start Testing ***/
+
+	for name, f := range files {
+		file := filepath.Join(dir, strings.Replace(name, " ", "_", -1)+".json")
+		err := ioutil.WriteFile(file, []byte(f.content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = config.LoadCluster(file)
+		clusterError, ok := err.(*config.ClusterError)
+		if !ok {
+			t.Errorf("%s: LoadCluster returned %v", name, err)
+			continue
+		}
+		all := strings.Join(clusterError.Problems, "\n")
+		for _, problem := range f.problems {
+			if !strings.Contains(all, problem) {
+				t.Errorf("%s: %q is not reported in:\n%s", name, problem, all)
+			}
+		}
+	}
+
+	/*** End Testing ***/
+}
@@ -0,0 +1,424 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// coinFixture - The coin keys of 4 servers, dealt once for the tests of the threshold coin
+var coinFixture struct {
+	once sync.Once
+	keys map[int]*threshenc.CoinKeys
+}
+
+// testCoinKeys - The coin keys of the fixture
+func testCoinKeys() map[int]*threshenc.CoinKeys {
+	coinFixture.once.Do(func() { coinFixture.keys = threshenc.NewCoinKeys(4) })
+	return coinFixture.keys
+}
+
+// newShares - The shares of the given servers for the coin with some name
+func newShares(t *testing.T, keys map[int]*threshenc.CoinKeys, name []byte,
+	servers ...int) map[int]threshenc.CoinShare {
+	shares := make(map[int]threshenc.CoinShare, len(servers))
+	for _, i := range servers {
+		share, err := keys[i].NewShare(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[i] = share
+	}
+	return shares
+}
+
+// The shares of a coin are verified, every set of f+1 or more of them reveals the same coin, and the
+// coin takes both values
+func TestThresholdCoinShares(t *testing.T) {
+	keys := testCoinKeys()
+	n := len(keys)
+	f := (n - 1) / 3
+
+	/*** Start Testing ***/
+
+	name := []byte("COIN-0")
+	shares := newShares(t, keys, name, 0, 1, 2, 3)
+	for i, share := range shares {
+		if !keys[(i+1)%n].VerifyShare(name, share, i) {
+			t.Fatalf("Valid share of %d was rejected", i)
+		}
+	}
+
+	// Every subset of f+1 or more shares (bits of the mask) reveals the same coin
+	coin := threshenc.CombineShares(shares)
+	for mask := 1; mask < 1<<n; mask++ {
+		subset := make(map[int]threshenc.CoinShare, n)
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 {
+				subset[i] = shares[i]
+			}
+		}
+		if len(subset) < f+1 {
+			continue
+		}
+		if c := threshenc.CombineShares(subset); c != coin {
+			t.Errorf("Shares %04b reveal coin %d instead of %d", mask, c, coin)
+		}
+	}
+
+	// Tossed until it takes both values (a fair coin does so within 32 tosses but with probability 2^-31)
+	seen := map[uint]bool{coin: true}
+	for id := 1; id < 32 && len(seen) < 2; id++ {
+		seen[threshenc.CombineShares(newShares(t, keys, []byte("COIN-"+strconv.Itoa(id)), id%n, (id+1)%n))] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Coin was %d in all 32 tosses", coin)
+	}
+
+	/*** End Testing ***/
+}
+
+// Every share that was not computed by its server for the coin is rejected
+func TestThresholdCoinInvalidShares(t *testing.T) {
+	keys := testCoinKeys()
+	n := len(keys)
+
+	/*** Start Testing ***/
+
+	name := []byte("COIN-0")
+	shares := newShares(t, keys, name, 0, 1)
+	valid := shares[0]
+	other := newShares(t, keys, []byte("COIN-other"), 0)[0]
+	add := func(x *big.Int, y int64) *big.Int { return new(big.Int).Add(x, big.NewInt(y)) }
+	share := func(value, challenge, response *big.Int) threshenc.CoinShare {
+		return threshenc.CoinShare{Value: value, Challenge: challenge, Response: response}
+	}
+
+	cases := []struct {
+		name   string
+		share  threshenc.CoinShare
+		server int // The server it is claimed to come from
+	}{
+		{"of another server", shares[1], 0},
+		{"claimed by another server", valid, 1},
+		{"of an unknown server", valid, n},
+		{"of another coin", other, 0},
+		{"with the value of another server", share(shares[1].Value, valid.Challenge, valid.Response), 0},
+		{"with the value of another coin", share(other.Value, valid.Challenge, valid.Response), 0},
+		{"with a modified value", share(add(valid.Value, 1), valid.Challenge, valid.Response), 0},
+		{"with a modified challenge", share(valid.Value, add(valid.Challenge, 1), valid.Response), 0},
+		{"with a modified response", share(valid.Value, valid.Challenge, add(valid.Response, 1)), 0},
+		{"with a negative response", share(valid.Value, valid.Challenge, new(big.Int).Neg(valid.Response)), 0},
+		{"with the proof of another coin", share(valid.Value, other.Challenge, other.Response), 0},
+		{"with value 0", share(big.NewInt(0), valid.Challenge, valid.Response), 0},
+		{"with value 1", share(big.NewInt(1), valid.Challenge, valid.Response), 0},
+		{"with a value out of the group", share(new(big.Int).Lsh(big.NewInt(1), 2048), valid.Challenge, valid.Response), 0},
+		{"without value", share(nil, valid.Challenge, valid.Response), 0},
+		{"without challenge", share(valid.Value, nil, valid.Response), 0},
+		{"without response", share(valid.Value, valid.Challenge, nil), 0},
+	}
+
+	if !keys[1].VerifyShare(name, valid, 0) {
+		t.Fatal("Valid share of 0 was rejected")
+	}
+	for _, c := range cases {
+		if keys[(c.server+1)%n].VerifyShare(name, c.share, c.server) {
+			t.Errorf("Share %s was accepted", c.name)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// BC with the threshold coin against a Byzantine replica and a scheduler that predict the coin (see
+// newAdversarialBC)
+func TestBConsensusAdversarialScheduler(t *testing.T) {
+	n := 4
+	instances := 8
+	nodes, coins, adversary := newAdversarialBC(t, n, false)
+
+	/*** Start Testing ***/
+	totalRounds := 0
+	for bcid := 1; bcid <= instances; bcid++ {
+		for _, node := range nodes {
+			node.BCAnswer[bcid] = make(chan uint, 1)
+			coins[node.ID].reset()
+		}
+		adversary.instance(bcid)
+		coinAwareByzantine(t, nodes[0], bcid, 40, n-1)
+		for _, node := range nodes[1:] {
+			go node.BinaryConsensus(bcid, uint(node.ID%2))
+		}
+
+		decisions := make(map[int]uint, n)
+		timeout := time.After(60 * time.Second)
+		for _, node := range nodes[1:] {
+			select {
+			case decisions[node.ID] = <-node.BCAnswer[bcid]:
+			case <-timeout:
+				t.Fatalf("BC %d did not terminate at replica %d", bcid, node.ID)
+			}
+		}
+
+		rounds := 0
+		for id, d := range decisions {
+			if d != decisions[nodes[n-1].ID] {
+				t.Fatalf("BC %d: replicas disagree: %v", bcid, decisions)
+			}
+			if coins[id].count() > rounds {
+				rounds = coins[id].count()
+			}
+		}
+		totalRounds += rounds
+	}
+
+	// A fair, unpredictable coin lets BC decide in an expected constant number of rounds
+	// (counted until the last correct replica stops, including the rounds it helps the others)
+	if average := float64(totalRounds) / float64(instances); average > 8 {
+		t.Errorf("BC needed %.1f rounds on average", average)
+	}
+
+	/*** End Testing ***/
+}
+
+// The same adversary keeps BC with the predictable coin from deciding: in every round, the target
+// replica sees both values and the others only the one that is not the coin
+func TestBConsensusAdversarialSchedulerDeterministicCoin(t *testing.T) {
+	n := 4
+	rounds := 20 // Clearly more than the threshold coin needs on average
+	nodes, coins, adversary := newAdversarialBC(t, n, true)
+
+	/*** Start Testing ***/
+
+	bcid := 1
+	for _, node := range nodes {
+		node.BCAnswer[bcid] = make(chan uint, 1)
+	}
+	adversary.instance(bcid)
+	coinAwareByzantine(t, nodes[0], bcid, rounds+5, n-1)
+	for _, node := range nodes[1:] {
+		go node.BinaryConsensus(bcid, uint(node.ID%2))
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for {
+		least := -1
+		for _, node := range nodes[1:] {
+			select {
+			case decision := <-node.BCAnswer[bcid]:
+				t.Fatalf("Replica %d decided %d after %d rounds", node.ID, decision, coins[node.ID].count())
+			default:
+			}
+			if count := coins[node.ID].count(); least < 0 || count < least {
+				least = count
+			}
+		}
+		if least >= rounds {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The correct replicas ran %d rounds instead of %d", least, rounds)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	/*** End Testing ***/
+}
+
+// newAdversarialBC - Initializes n replicas whose messages go through a coinAwareScheduler that targets
+// the last one, and wraps their coins to count the rounds of BC. Replica 0 does not run BC: the tests
+// send its messages with coinAwareByzantine.
+//
+// The adversary predicts that the coin of round id is id % 2 (it is with the deterministic coin). The
+// other correct replicas only get the messages with the coin once they have tossed it, so they all see
+// the other value alone and send it in AUX. The target only gets the other value from them once it has
+// sent its AUX: the coin comes first in its bin_values, and with the AUX of the Byzantine replica it
+// sees both values and takes the coin as its estimate, while the others keep the other value. With the
+// predictable coin nobody decides, and the correct replicas start every round with both values again.
+func newAdversarialBC(t *testing.T, n int, deterministic bool) ([]*modules.Node, map[int]*countingCoin,
+	*coinAdversary) {
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = deterministic
+	adversary := &coinAdversary{target: n - 1, coins: make(map[int]*countingCoin, n),
+		aux: make(map[int]bool), maxHold: 300 * time.Millisecond}
+	from := 0
+	nodes, network := newTestCluster(t, n, options, false,
+		func(transport messenger.Transport) messenger.Transport {
+			from++
+			return &coinAwareScheduler{Transport: transport, from: from - 1, adversary: adversary}
+		})
+	t.Cleanup(network.Close)
+
+	for _, node := range nodes {
+		adversary.coins[node.ID] = &countingCoin{CommonCoin: node.Coin, tossed: make(map[int]bool)}
+		node.Coin = adversary.coins[node.ID]
+	}
+	return nodes, adversary.coins, adversary
+}
+
+// coinAwareByzantine - Sends the BC messages of a Byzantine replica for the first rounds of instance
+// bcid: both values in BV_broadcast, and in AUX the predicted coin to the target and the other value
+// to the other replicas
+func coinAwareByzantine(t *testing.T, node *modules.Node, bcid int, rounds int, target int) {
+	send := func(Type string, id int, value uint, to int) {
+		w := new(bytes.Buffer)
+		if err := gob.NewEncoder(w).Encode(types.NewBcMessage(id, value)); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.Messenger.SendMessage(node.Messenger.NewMessage(w.Bytes(), Type), to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for round := 1; round <= rounds; round++ {
+		id := modules.ComputeUniqueIdentifier(bcid, round)
+		coin := uint(id % 2)
+		for to := 0; to < node.Messenger.N; to++ {
+			if to == node.ID {
+				continue
+			}
+			send("BVB", id, 0, to)
+			send("BVB", id, 1, to)
+			if to == target {
+				send("BC", id, coin, to)
+			} else {
+				send("BC", id, 1-coin, to)
+			}
+		}
+	}
+}
+
+// countingCoin - Counts how many times BC tossed the coin (one toss per round), and remembers the rounds
+// whose coin it tossed
+type countingCoin struct {
+	modules.CommonCoin
+	tosses int
+	tossed map[int]bool
+	mutex  sync.Mutex
+}
+
+func (coin *countingCoin) Toss(id int) uint {
+	coin.mutex.Lock()
+	coin.tosses++
+	coin.tossed[id] = true
+	coin.mutex.Unlock()
+	return coin.CommonCoin.Toss(id)
+}
+
+func (coin *countingCoin) count() int {
+	coin.mutex.Lock()
+	defer coin.mutex.Unlock()
+	return coin.tosses
+}
+
+func (coin *countingCoin) reset() {
+	coin.mutex.Lock()
+	coin.tosses = 0
+	coin.mutex.Unlock()
+}
+
+// hasTossed - Whether BC has tossed the coin of round id (it has counted the AUX messages of the round)
+func (coin *countingCoin) hasTossed(id int) bool {
+	coin.mutex.Lock()
+	defer coin.mutex.Unlock()
+	return coin.tossed[id]
+}
+
+// coinAdversary - What the coinAwareSchedulers of the servers share
+type coinAdversary struct {
+	target  int
+	coins   map[int]*countingCoin // Of every server (filled once the servers are initialized)
+	maxHold time.Duration         // How long a message is held at most
+
+	bcid  int          // The instance that the correct replicas run
+	aux   map[int]bool // The rounds whose AUX the target has sent
+	mutex sync.Mutex
+}
+
+// instance - Targets the rounds of instance bcid
+func (adversary *coinAdversary) instance(bcid int) {
+	adversary.mutex.Lock()
+	adversary.bcid = bcid
+	adversary.mutex.Unlock()
+}
+
+// sentAux - Whether the target has sent its AUX of round id
+func (adversary *coinAdversary) sentAux(id int) bool {
+	adversary.mutex.Lock()
+	defer adversary.mutex.Unlock()
+	return adversary.aux[id]
+}
+
+// targets - Whether id identifies a round of the targeted instance
+func (adversary *coinAdversary) targets(id int) bool {
+	adversary.mutex.Lock()
+	bcid := adversary.bcid
+	adversary.mutex.Unlock()
+
+	round := 1
+	for modules.ComputeUniqueIdentifier(bcid, round) < id {
+		round++
+	}
+	return modules.ComputeUniqueIdentifier(bcid, round) == id
+}
+
+// coinAwareScheduler - Holds the BC messages of server `from` as an adversary that predicts the coin of
+// round id to be id % 2 (see newAdversarialBC)
+type coinAwareScheduler struct {
+	messenger.Transport
+	from      int
+	adversary *coinAdversary
+}
+
+func (scheduler *coinAwareScheduler) Send(to int, message []byte) error {
+	frame, err := messenger.DecodeFrame(message)
+	if err != nil || frame.Seq == 0 {
+		return scheduler.Transport.Send(to, message)
+	}
+	msg := new(types.Message)
+	err = gob.NewDecoder(bytes.NewBuffer(frame.Payload)).Decode(&msg)
+	if err != nil || (msg.Type != "BVB" && msg.Type != "BC") {
+		return scheduler.Transport.Send(to, message)
+	}
+	bcMessage := new(types.BcMessage)
+	if err := gob.NewDecoder(bytes.NewBuffer(msg.Payload)).Decode(&bcMessage); err != nil {
+		return scheduler.Transport.Send(to, message)
+	}
+
+	adversary := scheduler.adversary
+	id, coin := bcMessage.Tag, bcMessage.Value == uint(bcMessage.Tag%2)
+	if msg.Type == "BC" && scheduler.from == adversary.target {
+		adversary.mutex.Lock()
+		adversary.aux[id] = true
+		adversary.mutex.Unlock()
+	}
+	var released func() bool
+	switch {
+	case !adversary.targets(id):
+		return scheduler.Transport.Send(to, message)
+	case coin && to != adversary.target:
+		released = func() bool { return adversary.coins[to].hasTossed(id) }
+	case !coin && msg.Type == "BVB" && to == adversary.target && scheduler.from != 0 &&
+		scheduler.from != adversary.target:
+		released = func() bool { return adversary.sentAux(id) }
+	default:
+		return scheduler.Transport.Send(to, message)
+	}
+
+	go func() {
+		deadline := time.Now().Add(adversary.maxHold)
+		for !released() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		scheduler.Transport.Send(to, message)
+	}()
+	return nil
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/checker"
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/modules"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Several VC instances run at a time, and their values are still delivered once, in the same order on
+// every replica
+func TestConsensusWindow(t *testing.T) {
+	n, values := 4, 10
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	options.ConsensusWindow = 3
+	nodes, network := newTestCluster(t, n, options, false, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	for _, node := range nodes {
+		node.InitiateAtomicBroadcast()
+	}
+	c := checker.New(0, 1, 2, 3)
+	for _, node := range nodes {
+		go func(node *modules.Node) {
+			for k := 0; k < values; k++ {
+				value := []byte(strconv.Itoa(node.ID) + "-" + strconv.Itoa(k))
+				c.Broadcast(node.ID, value)
+				node.AtomicBroadcast(value)
+				time.Sleep(10 * time.Millisecond) // Spread over several instances
+			}
+		}(node)
+	}
+
+	collectDeliveries(t, nodes, c, n*values, 60*time.Second)
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+
+	/*** End Testing ***/
+}
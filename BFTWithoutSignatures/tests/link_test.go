@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Messages that the transport loses are retransmitted until they are acknowledged (and delivered once)
+func TestLinkRetransmission(t *testing.T) {
+	n := 4
+	discardLogs()
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	msgrs := make([]*messenger.Messenger, n)
+	for i := 0; i < n; i++ {
+		msgrs[i] = messenger.NewMessenger(variables.NewReplica(i, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[i])
+		msgrs[i].InitializeTransport(&lossyTransport{Transport: network.Transport(i), drop: 3})
+		msgrs[i].Subscribe()
+		msgrs[i].TransmitMessages()
+	}
+
+	/*** Start Testing ***/
+
+	// Every other frame of the first ones is lost
+	count := 20
+	for k := 0; k < count; k++ {
+		if err := msgrs[0].Broadcast(msgrs[0].NewMessage(encodeState(t, k), "STATE")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i < n; i++ {
+		received := make(map[int]bool)
+		timeout := time.After(20 * time.Second)
+		for len(received) < count {
+			select {
+			case message := <-msgrs[i].StateChannel:
+				if received[message.StateMessage.Index] {
+					t.Errorf("Server %d received %d twice", i, message.StateMessage.Index)
+				}
+				received[message.StateMessage.Index] = true
+			case <-timeout:
+				t.Fatalf("Server %d received %d of %d messages", i, len(received), count)
+			}
+		}
+	}
+	msgrs[0].MsgMutex.RLock()
+	defer msgrs[0].MsgMutex.RUnlock()
+	if msgrs[0].Retransmissions == 0 {
+		t.Error("No message was retransmitted")
+	}
+
+	/*** End Testing ***/
+}
+
+// A server that restarts numbers its messages from 1 again, and the links to it start again: nothing
+// that it sends or that is sent to it after the restart is dropped as a duplicate
+func TestLinkRestart(t *testing.T) {
+	n := 4
+	discardLogs()
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	start := func(i int) *messenger.Messenger {
+		msgr := messenger.NewMessenger(variables.NewReplica(i, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[i])
+		msgr.InitializeTransport(&restartableTransport{network.Transport(i)})
+		msgr.Subscribe()
+		msgr.TransmitMessages()
+		return msgr
+	}
+	msgrs := make([]*messenger.Messenger, n)
+	for i := 0; i < n; i++ {
+		msgrs[i] = start(i)
+	}
+	broadcast := func(from int, first int, count int) {
+		for k := first; k < first+count; k++ {
+			if err := msgrs[from].Broadcast(msgrs[from].NewMessage(encodeState(t, k), "STATE")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	send := func(from int, to int, first int, count int) {
+		for k := first; k < first+count; k++ {
+			if err := msgrs[from].SendMessage(msgrs[from].NewMessage(encodeState(t, k), "STATE"), to); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expect := func(to int, first int, count int) {
+		received := make(map[int]bool)
+		timeout := time.After(20 * time.Second)
+		for len(received) < count {
+			select {
+			case message := <-msgrs[to].StateChannel:
+				k := message.StateMessage.Index
+				if k < first || k >= first+count || received[k] {
+					t.Errorf("Server %d received %d, which it does not wait for", to, k)
+				}
+				received[k] = true
+			case <-timeout:
+				t.Fatalf("Server %d received %d of %d messages", to, len(received), count)
+			}
+		}
+	}
+
+	/*** Start Testing ***/
+
+	count := 10
+	broadcast(0, 0, count)
+	send(1, 0, 0, count)
+	for i := 1; i < n; i++ {
+		expect(i, 0, count)
+	}
+	expect(0, 0, count)
+
+	msgrs[0].Close()
+	msgrs[0] = start(0)
+
+	broadcast(0, count, count) // Numbered from 1 again
+	for i := 1; i < n; i++ {
+		expect(i, count, count)
+	}
+	send(1, 0, count, count) // Numbered after the messages that the earlier server 0 received
+	expect(0, count, count)
+
+	/*** End Testing ***/
+}
+
+// A server that does not keep up fills its queue, and the sender is told instead of being blocked
+func TestLinkBackpressure(t *testing.T) {
+	n := 4
+	discardLogs()
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	msgr := messenger.NewMessenger(variables.NewReplica(0, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[0])
+	msgr.QueueSize = 4
+	msgr.InitializeTransport(network.Transport(0))
+	msgr.TransmitMessages() // Nobody receives
+
+	/*** Start Testing ***/
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		for k := 0; k < 10; k++ { // At most QueueSize+1 messages leave the queue of a server
+			err = msgr.Broadcast(msgr.NewMessage(encodeState(t, k), "STATE"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Broadcast blocked on full queues")
+	}
+
+	backpressure, ok := err.(*messenger.BackpressureError)
+	if !ok {
+		t.Fatalf("Broadcast returned %v", err)
+	}
+	if len(backpressure.Peers) != n-1 || backpressure.Type != "STATE" {
+		t.Errorf("Backpressure reported for %v (%s)", backpressure.Peers, backpressure.Type)
+	}
+
+	/*** End Testing ***/
+}
+
+// A message sent only once waits for room in a full queue instead of being lost, for a bounded time
+func TestLinkBackpressureWait(t *testing.T) {
+	n := 3
+	discardLogs()
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	msgrs := make([]*messenger.Messenger, n)
+	for i := 0; i < n; i++ {
+		msgrs[i] = messenger.NewMessenger(variables.NewReplica(i, n, 0, 0), config.InitializeScenario(0, -1).Scenario, keys[i])
+		msgrs[i].QueueSize = 4
+		msgrs[i].InitializeTransport(network.Transport(i))
+	}
+	fill := func(k int) int {
+		for {
+			err := msgrs[0].Broadcast(msgrs[0].NewMessage(encodeState(t, k), "STATE"))
+			if _, full := err.(*messenger.BackpressureError); full {
+				return k
+			}
+			k++
+		}
+	}
+
+	/*** Start Testing ***/
+
+	// Nothing leaves the queues until server 0 transmits
+	k := fill(0)
+
+	// The message waits until server 1 receives
+	last := k
+	sent := make(chan error, 1)
+	go func() {
+		sent <- msgrs[0].SendMessageWait(msgrs[0].NewMessage(encodeState(t, last), "STATE"), 1)
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("SendMessageWait returned %v while the queue is full", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	msgrs[0].TransmitMessages() // Server 2 does not receive
+	msgrs[1].Subscribe()
+	msgrs[1].TransmitMessages()
+	if err := <-sent; err != nil {
+		t.Fatalf("SendMessageWait returned %v", err)
+	}
+	timeout := time.After(20 * time.Second)
+	for received := false; !received; {
+		select {
+		case message := <-msgrs[1].StateChannel:
+			received = message.StateMessage.Index == last
+		case <-timeout:
+			t.Fatal("The message that waited for room was lost")
+		}
+	}
+
+	// The wait is bounded: server 2 never receives (the transmitter of its link has taken one message and
+	// blocks on it)
+	msgrs[0].SendTimeout = 200 * time.Millisecond
+	time.Sleep(100 * time.Millisecond)
+	last = fill(last + 1)
+	err := msgrs[0].BroadcastWait(msgrs[0].NewMessage(encodeState(t, last+1), "STATE"))
+	if backpressure, full := err.(*messenger.BackpressureError); !full || len(backpressure.Peers) != 1 ||
+		backpressure.Peers[0] != 2 {
+		t.Errorf("BroadcastWait returned %v instead of the backpressure of server 2", err)
+	}
+
+	/*** End Testing ***/
+}
+
+// lossyTransport - Loses every other one of the first frames that carry a message, on every link
+type lossyTransport struct {
+	messenger.Transport
+	drop  int
+	sent  map[int]int
+	mutex sync.Mutex
+}
+
+func (transport *lossyTransport) Send(to int, message []byte) error {
+	frame, err := messenger.DecodeFrame(message)
+	if err == nil && frame.Seq != 0 {
+		transport.mutex.Lock()
+		if transport.sent == nil {
+			transport.sent = make(map[int]int)
+		}
+		transport.sent[to]++
+		lost := transport.sent[to] <= 2*transport.drop && transport.sent[to]%2 == 1
+		transport.mutex.Unlock()
+		if lost {
+			return nil
+		}
+	}
+	return transport.Transport.Send(to, message)
+}
+
+// restartableTransport - A transport that can be closed without closing the network, so that a server
+// can be started again on it
+type restartableTransport struct {
+	messenger.Transport
+}
+
+func (transport *restartableTransport) Close() {}
+
+// encodeState - Encodes a state message that carries index k
+func encodeState(t testing.TB, k int) []byte {
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(types.NewStateMessage("test", k, []byte(strconv.Itoa(k)), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Bytes()
+}
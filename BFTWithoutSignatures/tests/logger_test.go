@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/logger"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// The records below the level of their module are dropped, the others carry the fields of the logger
+func TestLoggerLevels(t *testing.T) {
+	sink := logger.NewMemorySink()
+	logger.SetSink(sink)
+	defer discardLogs()
+	if err := logger.SetLevels("warn,bc=debug"); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.SetLevels("info,bc=info")
+
+	bc := logger.Module("bc").With("replica", 1)
+	msgr := logger.Module("messenger").With("replica", 1)
+
+	/*** Start Testing ***/
+
+	if !bc.Enabled(logger.LevelDebug) || msgr.Enabled(logger.LevelInfo) || !msgr.Enabled(logger.LevelWarn) {
+		t.Error("The levels of the modules are not the ones that were set")
+	}
+
+	bc.Debug("decide", "bcid", 3, "round", 2)
+	msgr.Info("sent", "type", "BC")
+	msgr.Warn("dropped", "peer", 2, "odd")
+	logger.OutLogger.Print("legacy\n")
+	logger.ErrLogger.Println("legacy error")
+
+	records := sink.Records()
+	if len(records) != 3 {
+		t.Fatalf("%d records were written instead of 3: %v", len(records), records)
+	}
+	if r := records[0]; r.Module != "bc" || r.Level != logger.LevelDebug || r.Msg != "decide" {
+		t.Errorf("The first record is %+v", r)
+	}
+	for key, value := range map[string]interface{}{"replica": 1, "bcid": 3, "round": 2} {
+		if v, in := records[0].Get(key); !in || v != value {
+			t.Errorf("Field %s of the first record is %v instead of %v", key, v, value)
+		}
+	}
+	if v, _ := records[1].Get("odd"); v != "MISSING" {
+		t.Errorf("A key without a value has the value %v", v)
+	}
+	if r := records[2]; r.Module != "main" || r.Level != logger.LevelError || r.Msg != "legacy error" {
+		t.Errorf("The record of ErrLogger is %+v", r)
+	}
+
+	if err := logger.SetLevels("info,bc=loud"); err == nil {
+		t.Error("An unknown level was accepted")
+	}
+
+	/*** End Testing ***/
+}
+
+// A writer sink encodes the records in logfmt or JSON, one per line
+func TestLoggerFormats(t *testing.T) {
+	record := logger.Record{
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Level:  logger.LevelWarn,
+		Module: "messenger",
+		Msg:    "dropped message",
+		Fields: []logger.Field{
+			{Key: "replica", Value: 0},
+			{Key: "peer", Value: 2},
+			{Key: "type", Value: "BC"},
+			{Key: "err", Value: errors.New(`bad "mac"`)},
+			{Key: "latency", Value: 1500 * time.Millisecond},
+		},
+	}
+
+	/*** Start Testing ***/
+
+	expected := map[logger.Format]string{
+		logger.FormatLogfmt: `time=2020-01-02T03:04:05.000006Z level=warn module=messenger msg="dropped message" ` +
+			`replica=0 peer=2 type=BC err="bad \"mac\"" latency=1.5s` + "\n",
+		logger.FormatJSON: `{"time":"2020-01-02T03:04:05.000006Z","level":"warn","module":"messenger",` +
+			`"msg":"dropped message","replica":0,"peer":2,"type":"BC","err":"bad \"mac\"","latency":"1.5s"}` + "\n",
+	}
+	for format, line := range expected {
+		var b bytes.Buffer
+		if err := logger.NewWriterSink(&b, format).Write(record); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != line {
+			t.Errorf("The record is written as\n%s\ninstead of\n%s", b.String(), line)
+		}
+	}
+
+	for _, name := range []string{"logfmt", "JSON"} {
+		if _, err := logger.ParseFormat(name); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := logger.ParseFormat("xml"); err == nil {
+		t.Error("An unknown format was accepted")
+	}
+
+	/*** End Testing ***/
+}
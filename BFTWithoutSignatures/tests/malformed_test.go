@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+)
+
+// Malformed messages of a Byzantine server are dropped and attributed to it, instead of crashing the replica
+func TestMalformedMessages(t *testing.T) {
+	n := 4
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	byzantine := nodes[3].Messenger
+	encode := func(v interface{}) []byte {
+		w := new(bytes.Buffer)
+		if err := gob.NewEncoder(w).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		return w.Bytes()
+	}
+
+	forged := byzantine.NewMessage([]byte("payload"), "BC")
+	forged.Payload = []byte("forged") // Not what 3 signed
+	impersonated := byzantine.NewMessage([]byte("payload"), "BC")
+	impersonated.From = 1 // Sent by 3
+
+	messages := map[string]struct {
+		message []byte
+		from    int // The sender it is attributed to
+	}{
+		"garbage":        {[]byte{0xde, 0xad, 0xbe, 0xef}, -1},
+		"payload":        {encode(byzantine.NewMessage([]byte{1, 2, 3}, "BC")), 3},
+		"signature":      {encode(forged), -1},
+		"impersonation":  {encode(impersonated), -1},
+		"unknown sender": {encode(types.NewMessage(nil, "BC", 42, nil)), -1},
+		"unknown type":   {encode(byzantine.NewMessage(nil, "XYZ")), 3},
+		"negative id":    {encode(byzantine.NewMessage(encode(types.NewBcMessage(-1, 0)), "BVB")), 3},
+		"rb process": {encode(byzantine.NewMessage(
+			encode(types.NewRbMessage(1, "INIT", "VC", 9, []byte("v"))), "RB")), 3},
+		"rb type": {encode(byzantine.NewMessage(
+			encode(types.NewRbMessage(1, "INIT", "XYZ", 3, []byte("v"))), "RB")), 3},
+	}
+
+	/*** Start Testing ***/
+
+	for name, m := range messages {
+		err := nodes[0].Messenger.HandleMessage(m.message, 3)
+		malformed, ok := err.(*messenger.MalformedError)
+		if !ok {
+			t.Errorf("%s: HandleMessage returned %v", name, err)
+			continue
+		}
+		if malformed.From != m.from {
+			t.Errorf("%s: attributed to %d instead of %d", name, malformed.From, m.from)
+		}
+	}
+
+	// The messages that arrive on the link of server 3 are counted against it (as well as a broken frame)
+	link := network.Transport(3)
+	seq := uint64(1) // Server 3 sends nothing else in this test
+	for _, m := range messages {
+		frame, err := messenger.EncodeFrame(messenger.Frame{Seq: seq, Payload: m.message})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := link.Send(0, frame); err != nil {
+			t.Fatal(err)
+		}
+		seq++
+	}
+	if err := link.Send(0, []byte{0xde, 0xad}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for nodes[0].Messenger.MalformedCount(3) < len(messages)+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d malformed messages counted instead of %d", nodes[0].Messenger.MalformedCount(3), len(messages)+1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if count := nodes[0].Messenger.MalformedCount(i); count != 0 {
+			t.Errorf("%d malformed messages attributed to correct server %d", count, i)
+		}
+	}
+
+	/*** End Testing ***/
+}
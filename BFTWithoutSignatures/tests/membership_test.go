@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/variables"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reconfigurationTimeout - How long the replicas may take to apply a request (only to fail instead of
+// hanging: every wait is for a state that the replicas reach)
+const reconfigurationTimeout = 2 * time.Minute
+
+// A replica joins a running cluster of 4 and leaves it again, while the others keep ordering requests
+func TestReconfiguration(t *testing.T) {
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	discardLogs()
+
+	// The keys of 5 replicas, but the first 4 only know their own public keys
+	keys := generateTestKeys(t, 5)
+	coinKeys := threshenc.NewCoinKeys(4)
+	for i := 0; i < 4; i++ {
+		delete(keys[i].VerificationKeys, 4)
+		keys[i].Coin = coinKeys[i]
+	}
+	publicKey, err := threshenc.EncodeVerificationKey(&keys[4].SecretKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joiner := types.Member{ID: 4, PublicKey: publicKey}
+	newCoinKeys := threshenc.NewCoinKeys(5)
+	loadCoinKeys := func(folder string, id int, n int) (*threshenc.CoinKeys, error) {
+		if folder != "coin-5" || n != 5 {
+			return nil, fmt.Errorf("no coin keys of %d replicas in %q", n, folder)
+		}
+		return newCoinKeys[id], nil
+	}
+
+	network := messenger.NewMemoryNetwork(5)
+	defer network.Close()
+	nodes := make([]*modules.Node, 5)
+	defer func() {
+		for _, node := range nodes {
+			if node != nil {
+				node.Messenger.Close()
+			}
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		nodes[i] = newTestNode(variables.NewReplica(i, 4, 0, 0), options, keys[i], network.Transport(i), false)
+		nodes[i].LoadCoinKeys = loadCoinKeys
+		nodes[i].StateTransfer()
+		nodes[i].InitiateAtomicBroadcast()
+		nodes[i].RequestHandler()
+	}
+
+	/*** Start Testing ***/
+
+	invalid := map[string]types.Reconfiguration{
+		"id of a new replica":  {Op: types.AddReplica, Member: types.Member{ID: 5, PublicKey: publicKey}},
+		"key of a new replica": {Op: types.AddReplica, Member: types.Member{ID: 4}},
+		"below 4 replicas":     {Op: types.RemoveReplica, Member: types.Member{ID: 3}},
+		"not the last replica": {Op: types.RemoveReplica, Member: types.Member{ID: 1}},
+		"coin keys":            {Op: types.AddReplica, Member: joiner, CoinKeys: "coin-4"},
+	}
+	for name, r := range invalid {
+		if err := nodes[0].Propose(r); err == nil {
+			t.Errorf("Proposal with a wrong %s accepted", name)
+		}
+	}
+
+	// f+1 replicas propose to add replica 4, and it has effect while the others keep writing
+	add := types.Reconfiguration{Op: types.AddReplica, Member: joiner, CoinKeys: "coin-5"}
+	for i := 0; i < 2; i++ {
+		if err := nodes[i].Propose(add); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written := writeUntilMembers(t, nodes[:4], 5, 1000) // The state is transferred only when replica 4 starts
+
+	for i := 0; i < 4; i++ {
+		current := nodes[i].Messenger.CurrentKeys()
+		if current.VerificationKeys[4] == nil || current.Coin != newCoinKeys[i] {
+			t.Errorf("Replica %d did not load the keys of the new membership", i)
+		}
+	}
+
+	// Replica 4 gets the state of the others and takes part in the next instances
+	nodes[4] = newTestNode(variables.NewReplica(4, 5, 0, 0), options, keys[4], network.Transport(4), false)
+	nodes[4].LoadCoinKeys = loadCoinKeys
+	nodes[4].SetMembers(nodes[0].Members())
+	nodes[4].StateTransfer()
+	if err := nodes[4].CatchUp(20 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	nodes[4].InitiateAtomicBroadcast()
+	nodes[4].RequestHandler()
+
+	nodes[4].AtomicBroadcast(encodeRequest(t, 4, 1, "J"))
+	waitState(t, nodes, "J")
+
+	// Replica 4 leaves
+	remove := types.Reconfiguration{Op: types.RemoveReplica, Member: types.Member{ID: 4}, CoinKeys: "coin-5"}
+	for i := 0; i < 2; i++ {
+		if err := nodes[i].Propose(remove); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written += writeUntilMembers(t, nodes[:4], 4, 2000)
+	select {
+	case <-nodes[4].Retired:
+	case <-time.After(reconfigurationTimeout):
+		t.Fatal("Replica 4 did not retire")
+	}
+	waitWritten(t, nodes[:4], written)
+
+	nodes[0].AtomicBroadcast(encodeRequest(t, 0, 0, "K"))
+	waitState(t, nodes[:4], "K")
+
+	/*** End Testing ***/
+}
+
+// writeUntilMembers - The first replica writes requests (numbered from first) one at a time, each once
+// every replica has applied the previous one, until they all run with n replicas: every request is
+// delivered in a batch of its own, so the change has effect after a known number of them. Returns the
+// number of requests written.
+func writeUntilMembers(t *testing.T, nodes []*modules.Node, n int, first int) int {
+	// The batches of the proposals, then the ones until the change has effect (with the window of
+	// instances that started before), and the one whose instance starts with the change
+	limit := 2 + modules.ReconfigurationDelay + nodes[0].ConsensusWindow + 1
+	written := strings.Count(string(nodes[0].StateMachine.Snapshot()), ".")
+	for k := 0; ; k++ {
+		changed := true
+		for _, node := range nodes {
+			changed = changed && len(node.Members()) == n
+		}
+		if changed {
+			return k
+		}
+		if k == limit {
+			t.Fatalf("Replicas do not run with %d replicas after %d batches", n, limit)
+		}
+		nodes[0].AtomicBroadcast(encodeRequest(t, nodes[0].ID, first+k, "."))
+		written++
+		waitWritten(t, nodes, written)
+	}
+}
+
+// waitWritten - Waits until every replica has applied the given number of written requests
+func waitWritten(t *testing.T, nodes []*modules.Node, written int) {
+	deadline := time.Now().Add(reconfigurationTimeout)
+	for _, node := range nodes {
+		for strings.Count(string(node.StateMachine.Snapshot()), ".") != written {
+			if time.Now().After(deadline) {
+				t.Fatalf("Replica %d did not apply the %d written requests", node.ID, written)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
+
+// waitState - Waits until every replica has applied the command and they all have the same state
+func waitState(t *testing.T, nodes []*modules.Node, command string) {
+	deadline := time.Now().Add(reconfigurationTimeout)
+	for {
+		states := make([]string, len(nodes))
+		same := true
+		for i, node := range nodes {
+			states[i] = string(node.StateMachine.Snapshot())
+			same = same && strings.Contains(states[i], command) && states[i] == states[0]
+		}
+		if same {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Replicas did not apply %q to the same state: %q", command, states)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
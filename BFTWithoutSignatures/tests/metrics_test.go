@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/metrics"
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The metrics are written in the Prometheus text exposition format
+func TestMetricsRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+	counter := r.NewCounter("test_messages_total", "Messages.", "type", "peer")
+	gauge := r.NewGaugeFunc("test_queue_depth", "Depth.", []string{"queue"},
+		func(set func(float64, ...string)) {
+			set(3, "a")
+			set(0.5, `q"1\`)
+		})
+	histogram := r.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1})
+
+	/*** Start Testing ***/
+
+	counter.Inc("BC", "1")
+	counter.Add(2, "BC", "1")
+	counter.Inc("RB", "2")
+	histogram.Observe(0.05)
+	histogram.Observe(0.1)
+	histogram.Observe(5)
+
+	if v := counter.Value("BC", "1"); v != 3 {
+		t.Errorf("Counter is %g instead of 3", v)
+	}
+	if v := gauge.Value("a"); v != 3 {
+		t.Errorf("Gauge function is %g instead of 3", v)
+	}
+
+	var text bytes.Buffer
+	if err := r.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_messages_total Messages.
+# TYPE test_messages_total counter
+test_messages_total{type="BC",peer="1"} 3
+test_messages_total{type="RB",peer="2"} 1
+# HELP test_queue_depth Depth.
+# TYPE test_queue_depth gauge
+test_queue_depth{queue="a"} 3
+test_queue_depth{queue="q\"1\\"} 0.5
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 5.15
+test_latency_seconds_count 3
+`
+	if text.String() != expected {
+		t.Errorf("The metrics are written as\n%s\ninstead of\n%s", text.String(), expected)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("A metric was registered twice")
+			}
+		}()
+		r.NewCounter("test_messages_total", "Again.")
+	}()
+
+	/*** End Testing ***/
+}
+
+// A replica serves the messages, the BC rounds, the latencies and the queues over HTTP
+func TestMetricsEndpoint(t *testing.T) {
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes, network := newTestCluster(t, 4, options, false, nil)
+	defer network.Close()
+	for _, node := range nodes {
+		node.InitiateAtomicBroadcast()
+		node.RequestHandler()
+	}
+
+	listener, err := nodes[0].Messenger.Metrics.Registry.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	/*** Start Testing ***/
+
+	for num := 1; num <= 4; num++ {
+		request := encodeRequest(t, 1, num, "m")
+		nodes[0].Messenger.RequestChannel <- request
+		nodes[1].Messenger.RequestChannel <- request
+	}
+	deadline := time.Now().Add(60 * time.Second)
+	for _, node := range nodes {
+		for strings.Count(string(node.StateMachine.Snapshot()), "m") != 4 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Replica %d applied %q instead of 4 requests", node.ID, node.StateMachine.Snapshot())
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// A message whose signature does not verify
+	forged := nodes[3].Messenger.NewMessage([]byte("payload"), "BC")
+	forged.Payload = []byte("forged")
+	w := new(bytes.Buffer)
+	if err := gob.NewEncoder(w).Encode(forged); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].Messenger.ReportMalformed(3, nodes[0].Messenger.HandleMessage(w.Bytes(), 3))
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := response.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type is %q", ct)
+	}
+
+	value := func(series string) float64 {
+		match := regexp.MustCompile("(?m)^" + regexp.QuoteMeta(series) + ` (\S+)$`).FindSubmatch(body)
+		if match == nil {
+			t.Errorf("%s is not served", series)
+			return 0
+		}
+		v, _ := strconv.ParseFloat(string(match[1]), 64)
+		return v
+	}
+	for _, series := range []string{
+		`bft_messages_sent_total{type="BVB",peer="1"}`,
+		`bft_bytes_sent_total{type="BVB",peer="1"}`,
+		`bft_messages_received_total{type="BC",peer="2"}`,
+		`bft_bytes_received_total{type="BC",peer="2"}`,
+		`bft_verification_failures_total{peer="3",kind="signature"}`,
+		`bft_bc_rounds_count`,
+		`bft_delivery_latency_seconds_count{protocol="ABC"}`,
+		`bft_request_latency_seconds_count`,
+	} {
+		if value(series) <= 0 {
+			t.Errorf("%s is not positive", series)
+		}
+	}
+	for _, series := range []string{
+		`bft_link_queue_depth{peer="1"}`,
+		`bft_link_unacked{peer="3"}`,
+		`bft_request_queue_depth`,
+		`bft_batch_queue_depth`,
+		`bft_pending_values`,
+	} {
+		value(series)
+	}
+
+	/*** End Testing ***/
+}
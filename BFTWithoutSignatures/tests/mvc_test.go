@@ -9,6 +9,7 @@ import (
 	"BFTWithoutSignatures/modules"
 	"BFTWithoutSignatures/threshenc"
 	"BFTWithoutSignatures/variables"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -18,7 +19,11 @@ import (
 )
 
 func TestMVConsensus(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
+	}
 	if len(args) == 5 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -26,22 +31,22 @@ func TestMVConsensus(t *testing.T) {
 		scenario, _ := strconv.Atoi(args[3])
 		remote, _ := strconv.Atoi(args[4])
 
-		initializeForTestMvc(id, n, clients, scenario, remote)
+		node = initializeForTestMvc(id, n, clients, scenario, remote)
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
 	}
 
 	/*** Start Testing ***/
 
-	go modules.MultiValuedConsensus(1, []byte("AEK"))
+	go node.MultiValuedConsensus(1, []byte("AEK"))
 
-	if (variables.ID % 2) == 0 {
-		go modules.MultiValuedConsensus(2, []byte("LFC"))
+	if (node.ID % 2) == 0 {
+		go node.MultiValuedConsensus(2, []byte("LFC"))
 	} else {
-		go modules.MultiValuedConsensus(2, []byte("lfc"))
+		go node.MultiValuedConsensus(2, []byte("lfc"))
 	}
 
-	go modules.MultiValuedConsensus(3, []byte("aek"))
+	go node.MultiValuedConsensus(3, []byte("aek"))
 
 	/*** End Testing ***/
 
@@ -50,32 +55,34 @@ func TestMVConsensus(t *testing.T) {
 }
 
 // Initializes the environment for the test
-func initializeForTestMvc(id int, n int, clients int, scenario int, rem int) {
-	variables.Initialize(id, n, clients, rem)
+func initializeForTestMvc(id int, n int, clients int, scenario int, rem int) *modules.Node {
+	replica := variables.NewReplica(id, n, clients, rem)
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal( err )
 	}
 	user_dirname = user_dirname + "/go/src"
-	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/")
+	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/", replica.ID)
 
-	if variables.Remote {
-		config.InitializeIP()
+	var addresses config.Addresses
+	if replica.Remote {
+		addresses = config.InitializeIP(replica)
 	} else {
-		config.InitializeLocal()
+		addresses = config.InitializeLocal(replica)
 	}
-	config.InitializeScenario(scenario, -1)
+	options := config.InitializeScenario(scenario, -1)
 
 	logger.OutLogger.Print(
-		"ID:", variables.ID, " | N:", variables.N, " | F:", variables.F, " | Clients:",
-		variables.Clients, " | Scenario:", config.Scenario, " | Remote:", variables.Remote, "\n\n",
+		"ID:", replica.ID, " | N:", replica.N, " | F:", replica.F, " | Clients:",
+		replica.Clients, " | Scenario:", options.Scenario, " | Remote:", replica.Remote, "\n\n",
 	)
 
-	threshenc.ReadKeys(user_dirname+"/tests/keys/")
+	keys := threshenc.ReadKeys(user_dirname+"/tests/keys/", replica.ID, replica.N)
 
-	messenger.InitializeMessenger()
-	messenger.Subscribe()
-	messenger.TransmitMessages()
+	msgr := messenger.NewMessenger(replica, options.Scenario, keys)
+	msgr.InitializeMessenger(addresses)
+	msgr.Subscribe()
+	msgr.TransmitMessages()
 
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate,
@@ -86,19 +93,10 @@ func initializeForTestMvc(id int, n int, clients int, scenario int, rem int) {
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			for i := 0; i < variables.N; i++ {
-				if i == variables.ID {
-					continue // Not myself
-				}
-				messenger.ReceiveSockets[i].Close()
-				messenger.SendSockets[i].Close()
-			}
-
-			for i := 0; i < variables.Clients; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
+			msgr.Close()
 			os.Exit(0)
 		}
 	}()
+
+	return modules.NewNode(replica, options, false, msgr)
 }
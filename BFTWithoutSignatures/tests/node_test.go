@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/checker"
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/logger"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/threshenc"
+	"BFTWithoutSignatures/variables"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Several replicas run side by side in one process, connected through a MemoryNetwork
+func TestNodesSideBySide(t *testing.T) {
+	n := 4
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+	for _, node := range nodes {
+		node.InitiateAtomicBroadcast()
+	}
+
+	c := checker.New(0, 1, 2, 3)
+	for _, node := range nodes {
+		value := []byte("A")
+		if (node.ID % 2) == 1 {
+			value = []byte("B")
+		}
+		c.Broadcast(node.ID, value)
+		go node.AtomicBroadcast(value)
+	}
+
+	// Every replica must deliver the same values in the same order
+	collectDeliveries(t, nodes, c, n, 30*time.Second)
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+
+	/*** End Testing ***/
+}
+
+// Initializes n replicas of the same process that communicate through a MemoryNetwork
+// (scheduler, if not nil, wraps the transport of every replica)
+func initializeForTestCluster(t *testing.T, n int, scenario int, transientProb float64,
+	runSSABC bool, scheduler func(messenger.Transport) messenger.Transport) ([]*modules.Node,
+	*messenger.MemoryNetwork) {
+	return newTestCluster(t, n, config.InitializeScenario(scenario, transientProb), runSSABC, scheduler)
+}
+
+// Initializes n replicas with the given options that communicate through a MemoryNetwork
+func newTestCluster(t testing.TB, n int, options config.Options, runSSABC bool,
+	scheduler func(messenger.Transport) messenger.Transport) ([]*modules.Node, *messenger.MemoryNetwork) {
+	discardLogs()
+
+	keys := generateTestKeys(t, n)
+	network := messenger.NewMemoryNetwork(n)
+
+	nodes := make([]*modules.Node, n)
+	for i := 0; i < n; i++ {
+		transport := network.Transport(i)
+		if scheduler != nil {
+			transport = scheduler(transport)
+		}
+		nodes[i] = newTestNode(variables.NewReplica(i, n, 0, 0), options, keys[i], transport, runSSABC)
+	}
+	t.Cleanup(func() { // stops the modules, which would otherwise keep running in the next tests
+		for _, node := range nodes {
+			node.Messenger.Close()
+		}
+	})
+
+	return nodes, network
+}
+
+// Initializes replica r with its messenger on the given transport
+func newTestNode(r variables.Replica, options config.Options, keys *threshenc.Keys,
+	transport messenger.Transport, runSSABC bool) *modules.Node {
+	msgr := messenger.NewMessenger(r, options.Scenario, keys)
+	msgr.Auth = options.Auth
+	msgr.InitializeTransport(transport)
+	msgr.Subscribe()
+	msgr.TransmitMessages()
+
+	return modules.NewNode(r, options, runSSABC, msgr)
+}
+
+// Records in c the batches that the replicas deliver until every one of them delivered the given
+// number of values (the test fails if they do not within timeout)
+func collectDeliveries(t testing.TB, nodes []*modules.Node, c *checker.Checker, values int,
+	timeout time.Duration) {
+	wg := sync.WaitGroup{}
+	quit := make(chan struct{})
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *modules.Node) {
+			defer wg.Done()
+			for count := 0; count < values; {
+				select {
+				case message := <-node.Delivered:
+					count += len(message.Value)
+					c.Deliver(node.ID, message.Id, message.Value)
+				case <-quit:
+					return
+				}
+			}
+		}(node)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		close(quit)
+		<-done
+		counts := make([]string, len(nodes))
+		for k, node := range nodes {
+			delivered := 0
+			for _, b := range c.Delivered(node.ID) {
+				delivered += len(b.Values)
+			}
+			counts[k] = fmt.Sprintf("%d: %d", node.ID, delivered)
+		}
+		if err := c.CheckSafety(); err != nil {
+			t.Error(err)
+		}
+		t.Fatalf("Replicas did not deliver %d values (%s)", values, strings.Join(counts, ", "))
+	}
+}
+
+// Discards the logs of the replicas that run in the tests
+func discardLogs() {
+	logger.SetSink(logger.Discard)
+}
+
+// Generates the keys of n servers in memory (small keys, only for tests)
+func generateTestKeys(t testing.TB, n int) map[int]*threshenc.Keys {
+	secretKeys := make(map[int]*rsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		secretKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secretKeys[i] = secretKey
+	}
+
+	coinKeys := threshenc.NewCoinKeys(n)
+
+	keys := make(map[int]*threshenc.Keys, n)
+	for i := 0; i < n; i++ {
+		keys[i] = &threshenc.Keys{
+			SecretKey:        secretKeys[i],
+			VerificationKeys: make(map[int]*rsa.PublicKey, n),
+			Coin:             coinKeys[i],
+		}
+		for j := 0; j < n; j++ {
+			keys[i].VerificationKeys[j] = &secretKeys[j].PublicKey
+		}
+	}
+	return keys
+}
@@ -9,6 +9,7 @@ import (
 	"BFTWithoutSignatures/modules"
 	"BFTWithoutSignatures/threshenc"
 	"BFTWithoutSignatures/variables"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -19,7 +20,11 @@ import (
 )
 
 func TestRBroadcast(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
+	}
 	if len(args) == 5 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -27,22 +32,22 @@ func TestRBroadcast(t *testing.T) {
 		scenario, _ := strconv.Atoi(args[3])
 		remote, _ := strconv.Atoi(args[4])
 
-		initializeForTestRb(id, n, clients, scenario, remote)
+		node = initializeForTestRb(id, n, clients, scenario, remote)
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
 	}
 
 	/*** Start Testing ***/
 
-	go modules.ReliableBroadcast(1, "MVC", []byte("AEK"))
+	go node.ReliableBroadcast(1, "MVC", []byte("AEK"))
 
-	if (variables.ID % 2) == 0 {
-		go modules.ReliableBroadcast(2, "MVC", []byte("LFC"))
+	if (node.ID % 2) == 0 {
+		go node.ReliableBroadcast(2, "MVC", []byte("LFC"))
 	} else {
-		go modules.ReliableBroadcast(2, "MVC", []byte("lfc"))
+		go node.ReliableBroadcast(2, "MVC", []byte("lfc"))
 	}
 
-	go modules.ReliableBroadcast(3, "MVC", []byte("aek"))
+	go node.ReliableBroadcast(3, "MVC", []byte("aek"))
 
 	/*** End Testing ***/
 
@@ -51,7 +56,11 @@ func TestRBroadcast(t *testing.T) {
 }
 
 func TestAbcRBroadcast(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
+	}
 	if len(args) == 5 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -59,25 +68,25 @@ func TestAbcRBroadcast(t *testing.T) {
 		scenario, _ := strconv.Atoi(args[3])
 		remote, _ := strconv.Atoi(args[4])
 
-		initializeForTestRb(id, n, clients, scenario, remote)
+		node = initializeForTestRb(id, n, clients, scenario, remote)
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote>'")
 	}
 
 	/*** Start Testing ***/
 
-	go modules.ReliableBroadcastAbc()
+	go node.ReliableBroadcastAbc()
 
 	time.Sleep(2 * time.Second)
 
-	modules.SendRBInit(1, []byte("LFC"))
+	node.SendRBInit(1, []byte("LFC"))
 
-	if variables.ID == 0 {
-		modules.SendRBInit(2, []byte("AEK"))
+	if node.ID == 0 {
+		node.SendRBInit(2, []byte("AEK"))
 	}
 
-	if variables.ID == 1 {
-		modules.SendRBInit(2, []byte("TEST"))
+	if node.ID == 1 {
+		node.SendRBInit(2, []byte("TEST"))
 	}
 
 	/*** End Testing ***/
@@ -87,32 +96,34 @@ func TestAbcRBroadcast(t *testing.T) {
 }
 
 // Initializes the environment for the test
-func initializeForTestRb(id int, n int, clients int, scenario int, rem int) {
-	variables.Initialize(id, n, clients, rem)
+func initializeForTestRb(id int, n int, clients int, scenario int, rem int) *modules.Node {
+	replica := variables.NewReplica(id, n, clients, rem)
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal( err )
 	}
 	user_dirname = user_dirname + "/go/src"
-	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/")
+	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/", replica.ID)
 
-	if variables.Remote {
-		config.InitializeIP()
+	var addresses config.Addresses
+	if replica.Remote {
+		addresses = config.InitializeIP(replica)
 	} else {
-		config.InitializeLocal()
+		addresses = config.InitializeLocal(replica)
 	}
-	config.InitializeScenario(scenario, -1)
+	options := config.InitializeScenario(scenario, -1)
 
 	logger.OutLogger.Print(
-		"ID:", variables.ID, " | N:", variables.N, " | F:", variables.F, " | Clients:",
-		variables.Clients, " | Scenario:", config.Scenario, " | Remote:", variables.Remote, "\n\n",
+		"ID:", replica.ID, " | N:", replica.N, " | F:", replica.F, " | Clients:",
+		replica.Clients, " | Scenario:", options.Scenario, " | Remote:", replica.Remote, "\n\n",
 	)
 
-	threshenc.ReadKeys(user_dirname+"/tests/keys/")
+	keys := threshenc.ReadKeys(user_dirname+"/tests/keys/", replica.ID, replica.N)
 
-	messenger.InitializeMessenger()
-	messenger.Subscribe()
-	messenger.TransmitMessages()
+	msgr := messenger.NewMessenger(replica, options.Scenario, keys)
+	msgr.InitializeMessenger(addresses)
+	msgr.Subscribe()
+	msgr.TransmitMessages()
 
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate,
@@ -123,19 +134,10 @@ func initializeForTestRb(id int, n int, clients int, scenario int, rem int) {
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			for i := 0; i < variables.N; i++ {
-				if i == variables.ID {
-					continue // Not myself
-				}
-				messenger.ReceiveSockets[i].Close()
-				messenger.SendSockets[i].Close()
-			}
-
-			for i := 0; i < variables.Clients; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
+			msgr.Close()
 			os.Exit(0)
 		}
 	}()
+
+	return modules.NewNode(replica, options, false, msgr)
 }
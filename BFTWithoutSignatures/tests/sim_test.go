@@ -0,0 +1,463 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/variables"
+	"bytes"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The simulated network takes the same decision on every frame in two runs with the same seed
+func TestSimNetworkSeededDecisions(t *testing.T) {
+	n := 4
+	run := func(seed int64) []messenger.SimEvent {
+		network := messenger.NewSimNetwork(n, seed)
+		defer network.Close()
+		network.SetLinks(messenger.SimLink{Latency: messenger.Uniform(0, 5*time.Millisecond), Drop: 0.2,
+			Duplicate: 0.2, Reorder: 0.2, ReorderDelay: 5 * time.Millisecond})
+		sendFrames(t, network, n, 20)
+
+		fates := make([]messenger.SimEvent, 0)
+		for _, e := range network.Trace() {
+			if e.Kind != messenger.SimDeliver {
+				e.At = 0
+				fates = append(fates, e)
+			}
+		}
+		// The senders run side by side, so only the decisions are compared, not their order
+		sort.Slice(fates, func(i, j int) bool {
+			a, b := fates[i], fates[j]
+			if a.From != b.From || a.To != b.To {
+				return a.From < b.From || (a.From == b.From && a.To < b.To)
+			}
+			if a.Seq != b.Seq {
+				return a.Seq < b.Seq
+			}
+			return a.Kind < b.Kind
+		})
+		return fates
+	}
+
+	/*** Start Testing ***/
+
+	first, second, other := run(7), run(7), run(8)
+	if len(first) != len(second) {
+		t.Fatalf("The runs with the same seed have %d and %d events", len(first), len(second))
+	}
+	for k := range first {
+		if first[k] != second[k] {
+			t.Fatalf("The runs with the same seed differ: %v != %v", first[k], second[k])
+		}
+	}
+
+	differ := len(first) != len(other)
+	for k := 0; !differ && k < len(first); k++ {
+		differ = first[k] != other[k]
+	}
+	if !differ {
+		t.Error("The runs with different seeds took the same decisions")
+	}
+
+	kinds := make(map[messenger.SimEventKind]int)
+	for _, e := range first {
+		kinds[e.Kind]++
+	}
+	for _, kind := range []messenger.SimEventKind{messenger.SimSend, messenger.SimDrop, messenger.SimDuplicate} {
+		if kinds[kind] == 0 {
+			t.Errorf("No frame was traced as %s", kind)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// The servers in different groups of a partition do not reach each other until it heals
+func TestSimNetworkPartition(t *testing.T) {
+	n := 4
+	network := messenger.NewSimNetwork(n, 1)
+	defer network.Close()
+	network.SetLinks(messenger.SimLink{Latency: messenger.Constant(time.Millisecond)})
+
+	/*** Start Testing ***/
+
+	network.Partition([]int{0, 1}, []int{2, 3})
+	if err := network.Transport(0).Send(2, []byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.Transport(0).Send(1, []byte("same side")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-network.Transport(1).Receive(0):
+		if string(m) != "same side" {
+			t.Errorf("Server 1 received %q", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("A frame was not delivered inside a partition")
+	}
+
+	network.Heal()
+	if err := network.Transport(0).Send(2, []byte("healed")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-network.Transport(2).Receive(0):
+		if string(m) != "healed" {
+			t.Errorf("Server 2 received %q, which was sent across the partition", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("A frame was not delivered after the partition healed")
+	}
+
+	time.Sleep(50 * time.Millisecond) // The deliveries are traced once the receiver took the frames
+	kinds := make([]string, 0)
+	for _, e := range network.Trace() {
+		kinds = append(kinds, e.Kind.String())
+	}
+	sort.Strings(kinds)
+	if expected := "deliver deliver partitioned send send"; strings.Join(kinds, " ") != expected {
+		t.Errorf("The trace has the events %q instead of %q", strings.Join(kinds, " "), expected)
+	}
+
+	network.Close()
+	if err := network.Transport(0).Send(1, []byte("closed")); err != messenger.ErrNetworkClosed {
+		t.Errorf("Sending through a closed network returned %v", err)
+	}
+
+	/*** End Testing ***/
+}
+
+// simFaults - Links that delay, lose, duplicate and reorder the frames
+var simFaults = messenger.SimLink{Latency: messenger.Exponential(2 * time.Millisecond), Drop: 0.1,
+	Duplicate: 0.1, Reorder: 0.1}
+
+// BC and VC (on top of MVC and RB) terminate with agreement over faulty links (SIM_SEED=<the seed of a
+// failing run> draws the same decisions on its frames)
+func TestSimConsensus(t *testing.T) {
+	n := 4
+	network := newSimNetwork(t, n)
+	defer network.Close()
+	network.SetLinks(simFaults)
+
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes := newSimCluster(t, n, network, options, false)
+
+	/*** Start Testing ***/
+
+	// Every module runs in its own root instance (BC at the depth of the BC that MVC runs)
+	for root := 1; root <= 3; root++ {
+		bcid := modules.ComputeUniqueIdentifier(root, 1)
+		for _, node := range nodes {
+			node.BCAnswer[bcid] = make(chan uint, 1)
+		}
+		for _, node := range nodes {
+			go node.BinaryConsensus(bcid, uint((node.ID+bcid)%2))
+		}
+		decisions := make(map[int]uint, n)
+		for _, node := range nodes {
+			select {
+			case decisions[node.ID] = <-node.BCAnswer[bcid]:
+			case <-time.After(60 * time.Second):
+				t.Fatalf("BC %d did not terminate at replica %d", root, node.ID)
+			}
+			if decisions[node.ID] != decisions[0] {
+				t.Fatalf("BC %d: replicas disagree: %v", root, decisions)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		node.VCAnswer[4] = make(chan map[int][]byte, 1)
+	}
+	for _, node := range nodes {
+		go node.VectorConsensus(4, []byte("VC"+strconv.Itoa(node.ID)))
+	}
+	checkVectors(t, "VC", nodes, func(node *modules.Node) chan map[int][]byte { return node.VCAnswer[4] },
+		60*time.Second)
+
+	types := make(map[string]bool)
+	for _, e := range network.Trace() {
+		if e.Kind == messenger.SimDeliver {
+			types[e.Type] = true
+		}
+	}
+	for _, mType := range []string{"BVB", "BC", "RB"} {
+		if !types[mType] {
+			t.Errorf("No %s message was delivered", mType)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// SSVC (on top of MVC) terminates with agreement over faulty links
+func TestSimSelfStabilizedVectorConsensus(t *testing.T) {
+	n := 4
+	network := newSimNetwork(t, n)
+	defer network.Close()
+	network.SetLinks(simFaults)
+
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes := newSimCluster(t, n, network, options, false)
+
+	/*** Start Testing ***/
+
+	for _, node := range nodes {
+		node.SSVCAnswer[1] = make(chan map[int][]byte, 1)
+	}
+	for _, node := range nodes {
+		go node.SelfStabilizedVectorConsensus(1, []byte("SSVC"+strconv.Itoa(node.ID)))
+	}
+	checkVectors(t, "SSVC", nodes, func(node *modules.Node) chan map[int][]byte { return node.SSVCAnswer[1] },
+		60*time.Second)
+
+	/*** End Testing ***/
+}
+
+// SSBC terminates with agreement over faulty links, and decides the value that every replica proposes
+func TestSimSelfStabilizedBinaryConsensus(t *testing.T) {
+	n := 4
+	network := newSimNetwork(t, n)
+	defer network.Close()
+	network.SetLinks(simFaults)
+
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes := newSimCluster(t, n, network, options, false)
+
+	/*** Start Testing ***/
+
+	proposals := map[int]func(id int) uint{
+		1: func(id int) uint { return uint(id % 2) },
+		2: func(id int) uint { return 1 },
+		3: func(id int) uint { return uint((id + 1) % 2) },
+	}
+	for ssbcid := 1; ssbcid <= 3; ssbcid++ {
+		answers := make(map[int]<-chan uint, n)
+		for _, node := range nodes {
+			answers[node.ID] = node.SelfStabilizedBinaryConsensus(ssbcid, proposals[ssbcid](node.ID))
+		}
+		decisions := make(map[int]uint, n)
+		deadline := time.After(60 * time.Second)
+		for _, node := range nodes {
+			select {
+			case decisions[node.ID] = <-answers[node.ID]:
+			case <-deadline:
+				t.Fatalf("SSBC %d did not terminate at replica %d", ssbcid, node.ID)
+			}
+			if decisions[node.ID] != decisions[0] {
+				t.Fatalf("SSBC %d: replicas disagree: %v", ssbcid, decisions)
+			}
+		}
+		if ssbcid == 2 && decisions[0] != 1 {
+			t.Errorf("SSBC %d decided %d, which no replica proposed", ssbcid, decisions[0])
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// SSMVC terminates with agreement over faulty links, and decides the value that every replica proposes
+func TestSimSelfStabilizedMultiValuedConsensus(t *testing.T) {
+	n := 4
+	network := newSimNetwork(t, n)
+	defer network.Close()
+	network.SetLinks(simFaults)
+
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes := newSimCluster(t, n, network, options, false)
+
+	/*** Start Testing ***/
+
+	for ssmvcid, same := range map[int]bool{1: true, 2: false} {
+		answers := make(map[int]<-chan []byte, n)
+		for _, node := range nodes {
+			value := []byte("SSMVC")
+			if !same {
+				value = []byte("SSMVC" + strconv.Itoa(node.ID))
+			}
+			answers[node.ID] = node.SelfStabilizedMultiValuedConsensus(ssmvcid, value)
+		}
+		decisions := make(map[int][]byte, n)
+		deadline := time.After(60 * time.Second)
+		for _, node := range nodes {
+			select {
+			case decisions[node.ID] = <-answers[node.ID]:
+			case <-deadline:
+				t.Fatalf("SSMVC %d did not terminate at replica %d", ssmvcid, node.ID)
+			}
+			if !bytes.Equal(decisions[node.ID], decisions[0]) {
+				t.Fatalf("SSMVC %d: replicas %d and 0 decided %q and %q", ssmvcid, node.ID, decisions[node.ID], decisions[0])
+			}
+		}
+		if same && string(decisions[0]) != "SSMVC" {
+			t.Errorf("SSMVC %d decided %q instead of the value of every replica", ssmvcid, decisions[0])
+		}
+		if !same && len(decisions[0]) != 0 {
+			t.Errorf("SSMVC %d decided %q, which a single replica proposed", ssmvcid, decisions[0])
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// SSVC, SSMVC and SSBC still terminate with agreement when their states are corrupted while they run
+func TestSimSelfStabilizedTransientFaults(t *testing.T) {
+	n := 4
+	network := newSimNetwork(t, n)
+	defer network.Close()
+	network.SetLinks(messenger.SimLink{Latency: messenger.Exponential(2 * time.Millisecond)})
+
+	options := config.InitializeScenario(0, 1)
+	options.DeterministicCoin = true
+	options.TestExecution = true
+	nodes := newSimCluster(t, n, network, options, false)
+
+	/*** Start Testing ***/
+
+	for _, node := range nodes {
+		node.SSVCAnswer[1] = make(chan map[int][]byte, 1)
+	}
+	for _, node := range nodes {
+		go node.SelfStabilizedVectorConsensus(1, []byte("SSVC"+strconv.Itoa(node.ID)))
+	}
+	checkVectors(t, "SSVC", nodes, func(node *modules.Node) chan map[int][]byte { return node.SSVCAnswer[1] },
+		60*time.Second)
+
+	// Every layer flushed what was corrupted
+	for _, protocol := range []string{"SSVC", "SSMVC", "SSBC"} {
+		flushes := 0.0
+		for _, node := range nodes {
+			for _, scope := range []string{"all", "process", "types", "vect", "round"} {
+				flushes += node.Messenger.Metrics.Flushes.Value(protocol, scope)
+			}
+		}
+		if flushes == 0 {
+			t.Errorf("%s did not flush any corrupted state", protocol)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// checkVectors - Waits for the vector decided by every replica, and checks that they agree and
+// that they hold the values of at least n-f replicas (the others are DEFAULT), unless the vector is
+// DEFAULT everywhere (SSVC decides it when MVC does)
+func checkVectors(t *testing.T, module string, nodes []*modules.Node,
+	answer func(*modules.Node) chan map[int][]byte, timeout time.Duration) {
+	vectors := make(map[int]map[int][]byte, len(nodes))
+	deadline := time.After(timeout)
+	for _, node := range nodes {
+		select {
+		case vectors[node.ID] = <-answer(node):
+		case <-deadline:
+			t.Fatalf("%s did not terminate at replica %d", module, node.ID)
+		}
+	}
+
+	f := (len(nodes) - 1) / 3
+	for id, vector := range vectors {
+		values := 0
+		for k, v := range vector {
+			if !bytes.Equal(v, variables.DEFAULT) {
+				values++
+				if !bytes.Equal(v, []byte(module+strconv.Itoa(k))) {
+					t.Errorf("%s: replica %d decided %q for replica %d", module, id, v, k)
+				}
+			}
+			if !bytes.Equal(v, vectors[0][k]) {
+				t.Errorf("%s: replicas disagree: %v", module, vectors)
+				return
+			}
+		}
+		if values < len(nodes)-f && (module != "SSVC" || values != 0) {
+			t.Errorf("%s: the vector of replica %d has %d values", module, id, values)
+		}
+	}
+}
+
+// newSimNetwork - A simulated network seeded from SIM_SEED (or the time), whose seed and end of
+// trace are logged if the test fails
+func newSimNetwork(t *testing.T, n int) *messenger.SimNetwork {
+	seed := time.Now().UnixNano()
+	if s := os.Getenv("SIM_SEED"); s != "" {
+		var err error
+		if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			t.Fatal(err)
+		}
+	}
+	network := messenger.NewSimNetwork(n, seed)
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		trace := network.Trace()
+		if len(trace) > 50 {
+			trace = trace[len(trace)-50:]
+		}
+		lines := make([]string, len(trace))
+		for k, e := range trace {
+			lines[k] = e.String()
+		}
+		t.Logf("Rerun with SIM_SEED=%d for the same decisions on the frames; the trace ends with\n%s", seed,
+			strings.Join(lines, "\n"))
+	})
+	return network
+}
+
+// newSimCluster - Initializes n replicas with the given options on the servers of the network
+func newSimCluster(t *testing.T, n int, network *messenger.SimNetwork, options config.Options,
+	runSSABC bool) []*modules.Node {
+	discardLogs()
+
+	keys := generateTestKeys(t, n)
+	nodes := make([]*modules.Node, n)
+	for i := 0; i < n; i++ {
+		nodes[i] = newTestNode(variables.NewReplica(i, n, 0, 0), options, keys[i], network.Transport(i), runSSABC)
+	}
+	return nodes
+}
+
+// sendFrames - Sends count frames with a message on every link of the network, and waits until
+// the ones that are not lost are delivered
+func sendFrames(t *testing.T, network *messenger.SimNetwork, n int, count int) {
+	for to := 0; to < n; to++ {
+		for from := 0; from < n; from++ {
+			if from == to {
+				continue // Not myself
+			}
+			go func(to int, from int) {
+				for range network.Transport(to).Receive(from) {
+				}
+			}(to, from)
+		}
+	}
+
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			if from == to {
+				continue // Not myself
+			}
+			for seq := 1; seq <= count; seq++ {
+				frame, err := messenger.EncodeFrame(messenger.Frame{Seq: uint64(seq), Payload: []byte("m")})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := network.Transport(from).Send(to, frame); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+}
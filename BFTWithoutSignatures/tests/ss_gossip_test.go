@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/checker"
+	"BFTWithoutSignatures/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// SSABC delivers while the replicas gossip deltas and digests of their messages, and once nothing changes
+// they still send all of their messages from time to time, but far less often than the digests
+func TestSSGossip(t *testing.T) {
+	n, values := 4, 2
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes, network := newTestCluster(t, n, options, true, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	for _, node := range nodes {
+		node.InitiateSelfStabilizedAtomicBroadcast()
+	}
+	c := checker.New(0, 1, 2, 3)
+	for _, node := range nodes {
+		go func(id int) {
+			for k := 0; k < values; k++ {
+				value := []byte(strconv.Itoa(id) + "-" + strconv.Itoa(k))
+				c.Broadcast(id, value)
+				nodes[id].SelfStabilizedAtomicBroadcast(value)
+			}
+		}(node.ID)
+	}
+
+	collectDeliveries(t, nodes, c, n*values, 120*time.Second)
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(3 * time.Second) // Nothing changes
+	for _, node := range nodes {
+		metrics := node.Messenger.Metrics.Gossip
+		full, delta, digest := metrics.Value("SSABC", "full"), metrics.Value("SSABC", "delta"),
+			metrics.Value("SSABC", "digest")
+		if delta == 0 || digest == 0 || full == 0 {
+			t.Errorf("Replica %d sent %v deltas, %v digests and %v whole states", node.ID, delta, digest, full)
+		} else if full >= delta+digest {
+			t.Errorf("Replica %d sent %v whole states with %v deltas and %v digests", node.ID, full, delta, digest)
+		}
+		if delta := metrics.Value("SSVC", "delta"); delta == 0 {
+			t.Errorf("Replica %d sent no delta in SSVC", node.ID)
+		}
+	}
+
+	/*** End Testing ***/
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/modules"
+	"BFTWithoutSignatures/types"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// ssabcTuple - The value of a sender with a number
+func ssabcTuple(sender int, num uint32) types.SSABCMessageTuple {
+	return types.SSABCMessageTuple{Sender: sender, Num: num, Value: []byte("v")}
+}
+
+// The window of a sender slides over the numbers that were delivered, in order or not, and jumps to a
+// number beyond it
+func TestSSABCHistoryWindow(t *testing.T) {
+	h := modules.SSABCHistory{}
+
+	/*** Start Testing ***/
+
+	for _, num := range []uint32{5, 3, 0, 1} {
+		h.Add(ssabcTuple(0, num))
+	}
+	if w := h[0]; w.Next != 2 || w.Above != 1<<1|1<<3 {
+		t.Errorf("The window is %+v after 0, 1, 3 and 5", w)
+	}
+	for num, delivered := range []bool{true, true, false, true, false, true, false} {
+		if h.Delivered(ssabcTuple(0, uint32(num))) != delivered {
+			t.Errorf("%d is delivered: %v", num, !delivered)
+		}
+	}
+	if last, in := h.Last(0); !in || last != 5 {
+		t.Errorf("The last number is %d (%v) instead of 5", last, in)
+	}
+	if _, in := h.Last(1); in {
+		t.Error("A sender without a window has a last number")
+	}
+
+	h.Add(ssabcTuple(0, 2))
+	h.Add(ssabcTuple(0, 4))
+	if w := h[0]; w.Next != 6 || w.Above != 0 {
+		t.Errorf("The window is %+v after 0 to 5", w)
+	}
+
+	// A number beyond the window moves it past the numbers that were skipped
+	h.Add(ssabcTuple(0, 200))
+	if w := h[0]; w.Next != 200-(modules.SSABCWindowSize-1) || w.Above != 1<<(modules.SSABCWindowSize-1) {
+		t.Errorf("The window is %+v after 200", w)
+	}
+	if !h.Delivered(ssabcTuple(0, 100)) || h.Delivered(ssabcTuple(0, 199)) || !h.Delivered(ssabcTuple(0, 200)) {
+		t.Error("The window does not cover the numbers up to 200")
+	}
+	if kept := h.Remove([]types.SSABCMessageTuple{ssabcTuple(0, 7), ssabcTuple(0, 199), ssabcTuple(1, 0)}); len(kept) != 2 ||
+		kept[0].Num != 199 || kept[1].Sender != 1 {
+		t.Errorf("%v were not delivered", kept)
+	}
+
+	/*** End Testing ***/
+}
+
+// The corrupted windows are repaired, and the history of a replica after a transient fault becomes the
+// same as the others once they deliver the same batches
+func TestSSABCHistoryTransientFault(t *testing.T) {
+	n := 4
+
+	/*** Start Testing ***/
+
+	corrupted := modules.SSABCHistory{
+		0:  {Next: 5, Above: 0xf0f0f0f0f0f0f0f1}, // 5 is delivered, and Next should be past it
+		1:  {Next: math.MaxUint32 - 1, Above: math.MaxUint64},
+		9:  {Next: 3}, // Not a replica
+		-1: {Next: 7}, // Not a replica
+	}
+	corrupted.Check(n)
+	if _, in := corrupted[9]; in || len(corrupted) != 2 {
+		t.Errorf("The windows of %v are kept", corrupted)
+	}
+	if w := corrupted[0]; w.Next != 6 || w.Above&1 != 0 {
+		t.Errorf("The window of 0 is %+v after the check", w)
+	}
+	if w := corrupted[1]; w.Next != math.MaxUint32 {
+		t.Errorf("The window of 1 is %+v after the check", w)
+	}
+
+	correct := modules.SSABCHistory{}
+	delete(corrupted, 1)
+	for num := uint32(0); num < 3*modules.SSABCWindowSize; num++ {
+		batch := []types.SSABCMessageTuple{ssabcTuple(0, num), ssabcTuple(2, num)}
+		for _, h := range []modules.SSABCHistory{correct, corrupted} {
+			for _, m := range h.Remove(batch) {
+				h.Add(m)
+			}
+		}
+	}
+	if !reflect.DeepEqual(correct, corrupted) {
+		t.Errorf("The histories are %v and %v after the same batches", correct, corrupted)
+	}
+
+	/*** End Testing ***/
+}
+
+// One sender that numbers a value at the end of the counter does not exhaust the counters on its own
+func TestSSABCHistoryExhausted(t *testing.T) {
+	limit, f := uint32(10), 1
+	h := modules.SSABCHistory{}
+
+	/*** Start Testing ***/
+
+	h.Add(ssabcTuple(3, limit-1)) // Byzantine
+	h.Add(ssabcTuple(0, limit-2))
+	if h.Exhausted(limit, f+1) {
+		t.Error("The counters are exhausted by one sender")
+	}
+	h.Add(ssabcTuple(0, limit-1))
+	if !h.Exhausted(limit, f+1) {
+		t.Errorf("The counters of %d senders are not exhausted", f+1)
+	}
+
+	/*** End Testing ***/
+}
@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/checker"
+	"BFTWithoutSignatures/config"
+	"BFTWithoutSignatures/modules"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The counters of SSABC are exhausted after a few values, and a global reset moves every replica to the
+// next epoch, where the values are still delivered once, in the same order on every replica
+func TestSSABCGlobalReset(t *testing.T) {
+	n, values := 4, 3
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	options.SSABCNumLimit = 2
+	nodes, network := newTestCluster(t, n, options, true, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	for _, node := range nodes {
+		node.InitiateSelfStabilizedAtomicBroadcast()
+	}
+	c := checker.New(0, 1, 2, 3)
+	for _, node := range nodes {
+		go func(id int) {
+			for k := 0; k < values; k++ {
+				value := []byte(strconv.Itoa(id) + "-" + strconv.Itoa(k))
+				c.Broadcast(id, value)
+				nodes[id].SelfStabilizedAtomicBroadcast(value)
+			}
+		}(node.ID)
+	}
+
+	collectDeliveries(t, nodes, c, n*values, 120*time.Second)
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+	for _, node := range nodes {
+		if resets := node.Messenger.Metrics.SSABCResets.Value("agreed"); resets == 0 {
+			t.Errorf("Replica %d delivered %d values of every replica without a global reset", node.ID, values)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// startSSABCFaults - Starts SSABC on every replica, with a channel to inject transient faults into it
+func startSSABCFaults(nodes []*modules.Node) {
+	for _, node := range nodes {
+		node.SSABCFaults = make(chan func(epoch, num *uint32))
+		node.InitiateSelfStabilizedAtomicBroadcast()
+	}
+}
+
+// ssabcFault - Applies fault to the epoch and counter of replica node between two steps of SSABC, and
+// returns once it has been applied
+func ssabcFault(t *testing.T, node *modules.Node, fault func(epoch, num *uint32)) {
+	applied := make(chan struct{})
+	select {
+	case node.SSABCFaults <- func(epoch, num *uint32) { fault(epoch, num); close(applied) }:
+		<-applied
+	case <-time.After(30 * time.Second):
+		t.Fatalf("Replica %d does not run SSABC", node.ID)
+	}
+}
+
+// ssabcRound - Every replica broadcasts one value of the given round, which all of them deliver
+func ssabcRound(t *testing.T, nodes []*modules.Node, c *checker.Checker, round int) {
+	for _, node := range nodes {
+		value := []byte(strconv.Itoa(node.ID) + "-" + strconv.Itoa(round))
+		c.Broadcast(node.ID, value)
+		go node.SelfStabilizedAtomicBroadcast(value)
+	}
+	collectDeliveries(t, nodes, c, len(nodes), 120*time.Second)
+}
+
+// checkSSABCEpoch - All the replicas are in the given epoch
+func checkSSABCEpoch(t *testing.T, nodes []*modules.Node, want uint32) {
+	for _, node := range nodes {
+		var epoch uint32
+		ssabcFault(t, node, func(e, _ *uint32) { epoch = *e })
+		if epoch != want {
+			t.Errorf("Replica %d is in epoch %d instead of %d", node.ID, epoch, want)
+		}
+	}
+}
+
+// The epoch that follows the last one is the first one: a reset in the last epoch moves every replica to 0
+// (and a value that the reset broadcasts again may exhaust the counters of epoch 0 too)
+func TestSSABCResetWraparound(t *testing.T) {
+	n, values := 4, 3
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	options.SSABCNumLimit = 2
+	nodes, network := newTestCluster(t, n, options, true, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	startSSABCFaults(nodes)
+	for _, node := range nodes {
+		ssabcFault(t, node, func(epoch, _ *uint32) { *epoch = math.MaxUint32 })
+	}
+	c := checker.New(0, 1, 2, 3)
+	for round := 0; round < values; round++ {
+		ssabcRound(t, nodes, c, round)
+	}
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+	for _, node := range nodes {
+		resets := node.Messenger.Metrics.SSABCResets.Value("agreed")
+		if resets == 0 {
+			t.Errorf("Replica %d delivered %d values of every replica without a global reset", node.ID, values)
+		}
+		checkSSABCEpoch(t, []*modules.Node{node}, uint32(resets)-1) // The first reset moves it to 0
+	}
+
+	/*** End Testing ***/
+}
+
+// A replica whose epoch was corrupted adopts the one of the others, and delivers what they deliver
+func TestSSABCResetAdopted(t *testing.T) {
+	n := 4
+	options := config.InitializeScenario(0, -1)
+	options.DeterministicCoin = true
+	nodes, network := newTestCluster(t, n, options, true, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	startSSABCFaults(nodes)
+	ssabcFault(t, nodes[0], func(epoch, _ *uint32) { *epoch = 1000 })
+	c := checker.New(0, 1, 2, 3)
+	for round := 0; round < 2; round++ {
+		ssabcRound(t, nodes, c, round)
+	}
+	if err := c.Check(); err != nil {
+		t.Error(err)
+	}
+	checkSSABCEpoch(t, nodes, 0)
+	if resets := nodes[0].Messenger.Metrics.SSABCResets.Value("adopted"); resets == 0 {
+		t.Error("Replica 0 kept its corrupted epoch")
+	}
+	for _, node := range nodes[1:] {
+		if resets := node.Messenger.Metrics.SSABCResets.Value("adopted"); resets != 0 {
+			t.Errorf("Replica %d adopted the corrupted epoch of replica 0", node.ID)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// A corrupted counter never numbers two values alike: it is repaired at once, and the replica asks for a
+// global reset, which takes place only once f+1 replicas ask for it
+func TestSSABCResetCorruptedNum(t *testing.T) {
+	for _, corrupted := range []int{1, 2} {
+		t.Run(fmt.Sprintf("corrupted=%d", corrupted), func(t *testing.T) {
+			n := 4
+			options := config.InitializeScenario(0, -1)
+			options.DeterministicCoin = true
+			nodes, network := newTestCluster(t, n, options, true, nil)
+			defer network.Close()
+
+			/*** Start Testing ***/
+
+			startSSABCFaults(nodes)
+			c := checker.New(0, 1, 2, 3)
+			ssabcRound(t, nodes, c, 0)
+			for _, node := range nodes[:corrupted] {
+				ssabcFault(t, node, func(_, num *uint32) { *num = 0 }) // The number of round 0
+			}
+			for round := 1; round < 3; round++ {
+				ssabcRound(t, nodes, c, round)
+			}
+			if err := c.Check(); err != nil {
+				t.Error(err)
+			}
+
+			for _, node := range nodes[:corrupted] {
+				if flushes := node.Messenger.Metrics.Flushes.Value("SSABC", "num"); flushes == 0 {
+					t.Errorf("Replica %d kept its corrupted counter", node.ID)
+				}
+			}
+			epoch := uint32(0)
+			if corrupted > (n-1)/3 {
+				epoch = 1 // f+1 replicas asked for a reset
+			}
+			checkSSABCEpoch(t, nodes, epoch)
+			for _, node := range nodes {
+				if resets := node.Messenger.Metrics.SSABCResets.Value("agreed"); resets != float64(epoch) {
+					t.Errorf("Replica %d made %v global resets instead of %d", node.ID, resets, epoch)
+				}
+			}
+
+			/*** End Testing ***/
+		})
+	}
+}
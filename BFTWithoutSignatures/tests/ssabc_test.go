@@ -7,6 +7,7 @@ import (
 	"BFTWithoutSignatures/modules"
 	"BFTWithoutSignatures/threshenc"
 	"BFTWithoutSignatures/variables"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -17,7 +18,11 @@ import (
 )
 
 func TestSSABroadcast(t *testing.T) {
-	args := os.Args[5:]
+	var node *modules.Node
+	args := flag.Args()
+	if len(args) == 0 {
+		t.Skip("Arguments should be '<id> <n> <clients> <scenario> <remote> <transient_probability>'")
+	}
 	if len(args) == 6 {
 		id, _ := strconv.Atoi(args[0])
 		n, _ := strconv.Atoi(args[1])
@@ -26,37 +31,43 @@ func TestSSABroadcast(t *testing.T) {
 		remote, _ := strconv.Atoi(args[4])
 		transientProb, _ := strconv.ParseFloat(args[5], 64)
 
-		initializeForTestSSAbc(id, n, clients, scenario, remote, transientProb)
-		config.SetTest(true)
+		node = initializeForTestSSAbc(id, n, clients, scenario, remote, transientProb)
+		node.TestExecution = true
 	} else {
 		log.Fatal("Arguments should be '<id> <n> <clients> <scenario> <remote> <transient_probability>'")
 	}
 
-	// dummy requests reads
+	// Record the trace of the run (checked by TestCheckTraces)
+	recorder := createTestRecorder(node.ID)
 	go func(){
 		for {
-			<- modules.Delivered
+			message := <- node.Delivered
+			recorder.Deliver(message.Id, message.Value)
 		}
 	}()
+	broadcast := func(value []byte) {
+		recorder.Broadcast(value)
+		node.SelfStabilizedAtomicBroadcast(value)
+	}
 
 	/*** Start Testing ***/
-	modules.InitiateSelfStabilizedAtomicBroadcast()
+	node.InitiateSelfStabilizedAtomicBroadcast()
 	time.Sleep(2 * time.Second)
 
-	if (variables.ID % 2) == 0 {
-		modules.SelfStabilizedAtomicBroadcast([]byte("A"))
+	if (node.ID % 2) == 0 {
+		broadcast([]byte("A"))
 	} else {
-		modules.SelfStabilizedAtomicBroadcast([]byte("B"))
+		broadcast([]byte("B"))
 	}
 
-	modules.SelfStabilizedAtomicBroadcast([]byte("AEK"))
+	broadcast([]byte("AEK"))
 
-	if variables.ID == 0 {
-		modules.SelfStabilizedAtomicBroadcast([]byte("ABCD"))
+	if node.ID == 0 {
+		broadcast([]byte("ABCD"))
 	}
 
-	if (variables.ID % 2) == 1 {
-		modules.SelfStabilizedAtomicBroadcast([]byte("test"))
+	if (node.ID % 2) == 1 {
+		broadcast([]byte("test"))
 	}
 
 	/*** End Testing ***/
@@ -66,34 +77,36 @@ func TestSSABroadcast(t *testing.T) {
 }
 
 // Initializes the environment for the test
-func initializeForTestSSAbc(id int, n int, clients int, scenario int, rem int, transientProb float64) {
-	variables.Initialize(id, n, clients, rem)
+func initializeForTestSSAbc(id int, n int, clients int, scenario int, rem int, transientProb float64) *modules.Node {
+	replica := variables.NewReplica(id, n, clients, rem)
 
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal( err )
 	}
 	user_dirname = user_dirname + "/go/src"
-	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/")
+	logger.InitializeLogger(user_dirname+"/tests/out/", user_dirname+"/tests/error/", replica.ID)
 
-	if variables.Remote {
-		config.InitializeIP()
+	var addresses config.Addresses
+	if replica.Remote {
+		addresses = config.InitializeIP(replica)
 	} else {
-		config.InitializeLocal()
+		addresses = config.InitializeLocal(replica)
 	}
-	config.InitializeScenario(scenario, transientProb)
+	options := config.InitializeScenario(scenario, transientProb)
 
 	logger.OutLogger.Print(
-		"ID:", variables.ID, " | N:", variables.N, " | F:", variables.F, " | Clients:",
-		variables.Clients, " | Scenario:", config.Scenario, " | Remote:", variables.Remote,
-		" | Transient:", config.Transient, " | TransientProbability:", config.TransientProbability, "\n\n",
+		"ID:", replica.ID, " | N:", replica.N, " | F:", replica.F, " | Clients:",
+		replica.Clients, " | Scenario:", options.Scenario, " | Remote:", replica.Remote,
+		" | Transient:", options.Transient, " | TransientProbability:", options.TransientProbability, "\n\n",
 	)
 
-	threshenc.ReadKeys(user_dirname+"/tests/keys/")
+	keys := threshenc.ReadKeys(user_dirname+"/tests/keys/", replica.ID, replica.N)
 
-	messenger.InitializeMessenger()
-	messenger.Subscribe()
-	messenger.TransmitMessages()
+	msgr := messenger.NewMessenger(replica, options.Scenario, keys)
+	msgr.InitializeMessenger(addresses)
+	msgr.Subscribe()
+	msgr.TransmitMessages()
 
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate,
@@ -104,19 +117,10 @@ func initializeForTestSSAbc(id int, n int, clients int, scenario int, rem int, t
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			for i := 0; i < variables.N; i++ {
-				if i == variables.ID {
-					continue // Not myself
-				}
-				messenger.ReceiveSockets[i].Close()
-				messenger.SendSockets[i].Close()
-			}
-
-			for i := 0; i < variables.Clients; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
+			msgr.Close()
 			os.Exit(0)
 		}
 	}()
+
+	return modules.NewNode(replica, options, true, msgr)
 }
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/modules"
+	"testing"
+)
+
+func TestRuneArrayStateMachine(t *testing.T) {
+	var sm modules.StateMachine = modules.NewRuneArray()
+
+	/*** Start Testing ***/
+
+	// Every command is appended and the result is the new length of the array
+	for k, cmd := range []string{"A", "BC", "λ"} {
+		result := string(sm.Apply([]byte(cmd)))
+		if expected := []string{"1", "3", "4"}[k]; result != expected {
+			t.Errorf("Apply(%q) returned %q instead of %q", cmd, result, expected)
+		}
+	}
+
+	snapshot := sm.Snapshot()
+	if string(snapshot) != "ABCλ" {
+		t.Fatalf("Snapshot is %q instead of %q", snapshot, "ABCλ")
+	}
+
+	// A restored replica continues from the same state
+	restored := modules.NewRuneArray()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := string(sm.Apply([]byte("D"))), string(restored.Apply([]byte("D"))); a != b {
+		t.Errorf("Replicas returned different results after restore: %q != %q", a, b)
+	}
+	if string(restored.Array()) != "ABCλD" {
+		t.Errorf("Restored array is %q instead of %q", string(restored.Array()), "ABCλD")
+	}
+
+	/*** End Testing ***/
+}
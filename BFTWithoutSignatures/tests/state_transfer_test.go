@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/messenger"
+	"BFTWithoutSignatures/wal"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A replica that starts from scratch gets the state that f+1 other replicas agree on
+func TestStateTransfer(t *testing.T) {
+	n := 4
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	logs := make([]*wal.Log, n)
+	for i := 0; i < n; i++ {
+		logs[i], err = wal.Open(filepath.Join(dir, "wal_"+strconv.Itoa(i)+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logs[i].Close()
+	}
+
+	// Replicas 1 and 2 delivered 2 batches, while 0 reports a state that nobody else has
+	for i := 1; i <= 2; i++ {
+		logs[i].Append(1, [][]byte{encodeRequest(t, 0, 1, "A"), encodeRequest(t, 1, 1, "B")})
+		logs[i].Append(2, [][]byte{encodeRequest(t, 0, 2, "C")})
+	}
+	logs[0].Append(5, [][]byte{encodeRequest(t, 0, 1, "XYZ")})
+
+	for i := 0; i < n; i++ {
+		if err := nodes[i].Recover(logs[i]); err != nil {
+			t.Fatal(err)
+		}
+		nodes[i].StateTransfer()
+	}
+
+	/*** Start Testing ***/
+
+	if err := nodes[3].CatchUp(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := string(nodes[3].StateMachine.Snapshot()); snapshot != "ABC" {
+		t.Errorf("Transferred state is %q instead of %q", snapshot, "ABC")
+	}
+
+	// The transferred state survives a restart
+	index, _, err := logs[3].Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 2 || logs[3].LastIndex() != 2 {
+		t.Errorf("Saved snapshot is at %d (last index %d) instead of 2", index, logs[3].LastIndex())
+	}
+
+	// The older checkpoints cannot become stable (and replace the saved snapshot) anymore
+	if stable := nodes[3].StableCheckpoint(); stable != 2 {
+		t.Errorf("Stable checkpoint is %d instead of 2", stable)
+	}
+
+	// An up to date replica keeps its state
+	if err := nodes[1].CatchUp(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := string(nodes[1].StateMachine.Snapshot()); snapshot != "ABC" {
+		t.Errorf("State of replica 1 changed to %q", snapshot)
+	}
+
+	/*** End Testing ***/
+}
+
+// A replica is not up to date while f+1 replicas report a state ahead of its own, even if f+1 others
+// report states that are not
+func TestStateTransferStale(t *testing.T) {
+	n := 7
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	// Replicas 0 to 2 and 6 delivered 2 batches, replicas 3 to 5 delivered a third one
+	for i := 0; i < n; i++ {
+		log, err := wal.Open(filepath.Join(dir, "wal_"+strconv.Itoa(i)+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer log.Close()
+
+		log.Append(1, [][]byte{encodeRequest(t, 0, 1, "A"), encodeRequest(t, 1, 1, "B")})
+		log.Append(2, [][]byte{encodeRequest(t, 0, 2, "C")})
+		if i >= 3 && i <= 5 {
+			log.Append(3, [][]byte{encodeRequest(t, 1, 2, "D")})
+		}
+		if err := nodes[i].Recover(log); err != nil {
+			t.Fatal(err)
+		}
+		nodes[i].StateTransfer()
+	}
+
+	/*** Start Testing ***/
+
+	if err := nodes[6].CatchUp(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := string(nodes[6].StateMachine.Snapshot()); snapshot != "ABCD" {
+		t.Errorf("Transferred state is %q instead of %q", snapshot, "ABCD")
+	}
+
+	/*** End Testing ***/
+}
+
+// A replica that missed batches at runtime catches up once the checkpoints of f+1 replicas show that it
+// is behind, and goes on with the next batches
+func TestStateTransferBehind(t *testing.T) {
+	n := 4
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	for _, node := range nodes {
+		node.CheckpointInterval = 1
+		node.StateTransfer()
+		node.RequestHandler()
+	}
+
+	/*** Start Testing ***/
+
+	// Replicas 0 to 2 deliver 3 batches that replica 3 misses, and take a checkpoint of each one
+	for i, command := range []string{"A", "B", "C"} {
+		for _, node := range nodes[:3] {
+			node.Delivered <- struct {
+				Id    int
+				Value [][]byte
+			}{i + 1, [][]byte{encodeRequest(t, 0, i, command)}}
+		}
+	}
+	waitState(t, nodes, "ABC")
+
+	for _, node := range nodes {
+		node.Delivered <- struct {
+			Id    int
+			Value [][]byte
+		}{4, [][]byte{encodeRequest(t, 0, 3, "D")}}
+	}
+	waitState(t, nodes, "ABCD")
+
+	/*** End Testing ***/
+}
+
+// The requests of a client are applied once, in whatever order their nums are delivered, and a
+// transferred state keeps suppressing the duplicates of the requests it holds
+func TestStateTransferRequests(t *testing.T) {
+	n := 4
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false, nil)
+	defer network.Close()
+
+	// Replicas 0 to 2 delivered the requests 3, 1 and 0 of client 1 (and 3 again)
+	for i := 0; i < 3; i++ {
+		log, err := wal.Open(filepath.Join(dir, "wal_"+strconv.Itoa(i)+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer log.Close()
+		log.Append(1, [][]byte{encodeRequest(t, 1, 3, "C"), encodeRequest(t, 1, 1, "A")})
+		log.Append(2, [][]byte{encodeRequest(t, 1, 3, "C"), encodeRequest(t, 1, 0, "Z")})
+		if err := nodes[i].Recover(log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range nodes {
+		node.StateTransfer()
+		node.RequestHandler()
+	}
+
+	/*** Start Testing ***/
+
+	if err := nodes[3].CatchUp(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes {
+		node.Delivered <- struct {
+			Id    int
+			Value [][]byte
+		}{3, [][]byte{encodeRequest(t, 1, 0, "Z"), encodeRequest(t, 1, 1, "A"), encodeRequest(t, 1, 2, "B"),
+			encodeRequest(t, 1, 3, "C"), encodeRequest(t, 1, 4, "D")}}
+	}
+	waitState(t, nodes, "CAZBD")
+
+	/*** End Testing ***/
+}
+
+// A replica that is cut off while the others order and collect a few batches catches up once it is
+// reconnected: its ABC skips the instances of the installed state, and the requests it broadcast
+// meanwhile are ordered with the others
+func TestStateTransferSkip(t *testing.T) {
+	n := 5
+	var cut *cutTransport
+	nodes, network := initializeForTestCluster(t, n, 0, -1, false,
+		func(transport messenger.Transport) messenger.Transport {
+			cut = &cutTransport{Transport: transport} // The last one is the transport of replica 4
+			return cut
+		})
+	defer network.Close()
+
+	for _, node := range nodes {
+		node.CheckpointInterval = 1
+		node.StateTransfer()
+		node.RequestHandler()
+		node.InitiateAtomicBroadcast()
+	}
+
+	/*** Start Testing ***/
+
+	cut.set(true)
+	nodes[4].AtomicBroadcast(encodeRequest(t, 4, 1, "F"))
+	for round, command := range []string{"A", "B", "C"} {
+		nodes[0].AtomicBroadcast(encodeRequest(t, 0, round+1, command))
+		waitState(t, nodes[:4], command)
+	}
+	deadline := time.Now().Add(reconfigurationTimeout)
+	for _, node := range nodes[:4] {
+		for node.StableCheckpoint() < 3 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Replica %d is at checkpoint %d instead of 3", node.ID, node.StableCheckpoint())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	cut.set(false)
+	waitState(t, nodes, "ABCF")
+
+	/*** End Testing ***/
+}
+
+// cutTransport - Drops the frames that its server sends and receives while it is cut off (the links
+// send them again once it is reconnected)
+type cutTransport struct {
+	messenger.Transport
+	cut   bool
+	mutex sync.Mutex
+}
+
+func (transport *cutTransport) Send(to int, message []byte) error {
+	if transport.isCut() {
+		return nil
+	}
+	return transport.Transport.Send(to, message)
+}
+
+func (transport *cutTransport) Receive(from int) <-chan []byte {
+	frames := make(chan []byte)
+	go func() {
+		for frame := range transport.Transport.Receive(from) {
+			if !transport.isCut() {
+				frames <- frame
+			}
+		}
+		close(frames)
+	}()
+	return frames
+}
+
+func (transport *cutTransport) set(cut bool) {
+	transport.mutex.Lock()
+	transport.cut = cut
+	transport.mutex.Unlock()
+}
+
+func (transport *cutTransport) isCut() bool {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	return transport.cut
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/messenger"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryTransport(t *testing.T) {
+	n := 4
+	network := messenger.NewMemoryNetwork(n)
+	defer network.Close()
+
+	transports := make(map[int]messenger.Transport, n)
+	for i := 0; i < n; i++ {
+		transports[i] = network.Transport(i)
+	}
+
+	/*** Start Testing ***/
+
+	// Every server receives from every other server on its own link
+	received := make(map[int]map[int]string, n) // to, from
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for to := 0; to < n; to++ {
+		received[to] = make(map[int]string)
+		for from := 0; from < n; from++ {
+			if from == to {
+				continue // Not myself
+			}
+			wg.Add(1)
+			go func(to int, from int) {
+				defer wg.Done()
+				message := <-transports[to].Receive(from)
+				mutex.Lock()
+				received[to][from] = string(message)
+				mutex.Unlock()
+			}(to, from)
+		}
+	}
+
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			if from == to {
+				continue // Not myself
+			}
+			go func(from int, to int) {
+				err := transports[from].Send(to, []byte(strconv.Itoa(from)+"->"+strconv.Itoa(to)))
+				if err != nil {
+					t.Error(err)
+				}
+			}(from, to)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Messages were not delivered through the memory network")
+	}
+
+	for to := 0; to < n; to++ {
+		for from := 0; from < n; from++ {
+			if from == to {
+				continue // Not myself
+			}
+			if received[to][from] != strconv.Itoa(from)+"->"+strconv.Itoa(to) {
+				t.Errorf("Server %d received %q from %d", to, received[to][from], from)
+			}
+		}
+	}
+
+	// Sending through a closed network must fail instead of blocking forever
+	network.Close()
+	if err := transports[0].Send(1, []byte("late")); err != messenger.ErrNetworkClosed {
+		t.Errorf("Expected ErrNetworkClosed, got %v", err)
+	}
+
+	/*** End Testing ***/
+}
@@ -0,0 +1,253 @@
+package tests
+
+// go test -run XXX -bench Round BFTWithoutSignatures/tests
+
+import (
+	"BFTWithoutSignatures/tupleset"
+	"BFTWithoutSignatures/types"
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// A set holds a tuple once, whatever the order it was added and removed in
+func TestTupleSet(t *testing.T) {
+	tuple := func(sender int, num uint32, value string) types.SSABCMessageTuple {
+		return types.SSABCMessageTuple{Sender: sender, Num: num, Value: []byte(value)}
+	}
+
+	/*** Start Testing ***/
+
+	s := tupleset.NewSSABCSet(tuple(0, 0, "A"), tuple(1, 0, "A"), tuple(0, 0, "A"))
+	if s.Len() != 2 {
+		t.Fatalf("The set holds %d tuples instead of 2", s.Len())
+	}
+	for _, m := range []types.SSABCMessageTuple{tuple(0, 1, "A"), tuple(0, 0, "B"),
+		{Sender: 0, Epoch: 1, Value: []byte("A")}} {
+		if s.Contains(m) {
+			t.Errorf("The set holds %v, which differs from its tuples", m)
+		}
+	}
+
+	if s.Add(tuple(1, 0, "A")) || !s.Add(tuple(2, 0, "A")) {
+		t.Error("A tuple is added only if the set does not hold it")
+	}
+	if !s.Remove(tuple(0, 0, "A")) || s.Remove(tuple(0, 0, "A")) || s.Contains(tuple(0, 0, "A")) {
+		t.Error("A tuple is removed only if the set holds it")
+	}
+	if !s.Contains(tuple(1, 0, "A")) || !s.Contains(tuple(2, 0, "A")) || s.Len() != 2 {
+		t.Errorf("The set holds %v after a removal", s.Tuples())
+	}
+
+	if removed := s.Filter(func(m types.SSABCMessageTuple) bool { return m.Sender != 1 }); removed != 1 ||
+		s.Len() != 1 || !s.Contains(tuple(2, 0, "A")) {
+		t.Errorf("Filter removed %d tuples and left %v", removed, s.Tuples())
+	}
+
+	/*** End Testing ***/
+}
+
+// The counters of the messages follow the tuples that are added, removed and flushed
+func TestTupleSetThreshold(t *testing.T) {
+	n := 4
+	value := func(v string) types.SSVCMessageTuple {
+		return types.SSVCMessageTuple{Sender: 0, Value: []byte(v)}
+	}
+
+	/*** Start Testing ***/
+
+	msg := tupleset.NewSSVCMessages(n)
+	for p := 0; p < n; p++ {
+		msg.Of(p, tupleset.Ready).Add(value("A"))
+		msg.Of(p, tupleset.Ready).Add(value("A")) // Counted once
+	}
+	msg.Of(0, tupleset.Ready).Add(value("B"))
+	msg.Of(0, tupleset.Echo).Add(value("B"))
+
+	if count := msg.Count(tupleset.Ready, value("A")); count != n {
+		t.Errorf("A is counted %d times instead of %d", count, n)
+	}
+	if ready := msg.Threshold(2, tupleset.Ready); len(ready) != 1 || string(ready[0].Value) != "A" {
+		t.Errorf("%v are the ready tuples of 2 processes instead of A", ready)
+	}
+
+	msg.Of(1, tupleset.Ready).Remove(value("A"))
+	msg.Flush(2)
+	if count := msg.Count(tupleset.Ready, value("A")); count != n-2 {
+		t.Errorf("A is counted %d times after a removal and a flush instead of %d", count, n-2)
+	}
+	if ready := msg.Threshold(n-1, tupleset.Ready); len(ready) != 0 {
+		t.Errorf("%v are the ready tuples of %d processes", ready, n-1)
+	}
+
+	// The messages are the same once they are converted to slices and back
+	copied := tupleset.SSVCMessagesFrom(msg.Slices())
+	for _, mtype := range tupleset.Types {
+		for _, m := range []types.SSVCMessageTuple{value("A"), value("B")} {
+			if copied.Count(mtype, m) != msg.Count(mtype, m) {
+				t.Errorf("%s %s is counted %d times in the copy instead of %d", mtype, m.Value,
+					copied.Count(mtype, m), msg.Count(mtype, m))
+			}
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// A delta (Diff) applied to a view of the messages makes it match them, and so do their digests
+func TestTupleSetDigest(t *testing.T) {
+	tuple := func(num uint32, value string) types.SSABCMessageTuple {
+		return types.SSABCMessageTuple{Sender: 0, Num: num, Value: []byte(value)}
+	}
+
+	/*** Start Testing ***/
+
+	msg, view := tupleset.NewSSABCMessages(1), tupleset.NewSSABCMessages(1)
+	msg.Of(0, tupleset.Init).Add(tuple(0, "A"))
+	msg.Of(0, tupleset.Echo).Add(tuple(0, "A"))
+	if msg.Digest(0) == view.Digest(0) {
+		t.Fatal("Different messages have the same digest")
+	}
+	added, removed := msg.Diff(0, view, 0)
+	view.Apply(0, added, removed)
+	if msg.Digest(0) != view.Digest(0) {
+		t.Fatalf("The view %v does not match %v after the delta", view.Content(0), msg.Content(0))
+	}
+
+	// The digest does not depend on the order the tuples were added in
+	msg.Of(0, tupleset.Init).Remove(tuple(0, "A"))
+	msg.Of(0, tupleset.Ready).Add(tuple(1, "B"))
+	msg.Of(0, tupleset.Ready).Add(tuple(0, "A"))
+	other := tupleset.NewSSABCMessages(1)
+	other.Replace(0, map[string][]types.SSABCMessageTuple{tupleset.Echo: {tuple(0, "A")},
+		tupleset.Ready: {tuple(0, "A"), tuple(1, "B")}})
+	if msg.Digest(0) != other.Digest(0) {
+		t.Error("The same messages have different digests")
+	}
+
+	added, removed = msg.Diff(0, view, 0)
+	if len(removed[tupleset.Init]) != 1 || len(added[tupleset.Ready]) != 2 || len(added[tupleset.Echo]) != 0 {
+		t.Errorf("The delta adds %v and removes %v", added, removed)
+	}
+	view.Apply(0, added, removed)
+	if msg.Digest(0) != view.Digest(0) {
+		t.Errorf("The view %v does not match %v after the delta", view.Content(0), msg.Content(0))
+	}
+
+	/*** End Testing ***/
+}
+
+// The cost of a round of SSABC as the replicas and their pending requests grow: fresh tuples are added to the
+// messages of every replica, the echo and ready tuples with enough support are found, and the delivered
+// ones are removed; "slices" is the same round with the messages in slices, as SSABC kept them before
+func BenchmarkSSABCRound(b *testing.B) {
+	for _, n := range []int{4, 10, 31} {
+		for _, pending := range []int{1, 10, 100, 1000} {
+			f := (n - 1) / 3
+			values := make([][]byte, pending)
+			for k := range values {
+				values[k] = []byte("request-" + strconv.Itoa(k))
+			}
+			fresh := func(round int) []types.SSABCMessageTuple { // Not sent in any earlier round
+				tuples := make([]types.SSABCMessageTuple, pending)
+				for k := range tuples {
+					tuples[k] = types.SSABCMessageTuple{Sender: k % n, Num: uint32(round), Value: values[k]}
+				}
+				return tuples
+			}
+
+			b.Run(fmt.Sprintf("sets/n=%d/pending=%d", n, pending), func(b *testing.B) {
+				msg := tupleset.NewSSABCMessages(n)
+				for i := 0; i < b.N; i++ {
+					tuples := fresh(i)
+					for p := 0; p < n; p++ {
+						for _, m := range tuples {
+							msg.Of(p, tupleset.Echo).Add(m)
+							msg.Of(p, tupleset.Ready).Add(m)
+						}
+					}
+					msg.Threshold((n+f+1)/2, tupleset.Echo)
+					msg.Threshold(f+1, tupleset.Ready)
+					delivered := msg.Threshold(2*f+1, tupleset.Ready)
+					if len(delivered) != pending {
+						b.Fatalf("%d tuples are delivered instead of %d", len(delivered), pending)
+					}
+					for p := 0; p < n; p++ {
+						for _, m := range delivered {
+							msg.Of(p, tupleset.Echo).Remove(m)
+							msg.Of(p, tupleset.Ready).Remove(m)
+						}
+					}
+				}
+			})
+
+			b.Run(fmt.Sprintf("slices/n=%d/pending=%d", n, pending), func(b *testing.B) {
+				msg := make([]map[string][]types.SSABCMessageTuple, n)
+				for p := range msg {
+					msg[p] = map[string][]types.SSABCMessageTuple{}
+				}
+				for i := 0; i < b.N; i++ {
+					tuples := fresh(i)
+					for p := 0; p < n; p++ {
+						for _, m := range tuples {
+							for _, mtype := range []string{tupleset.Echo, tupleset.Ready} {
+								if sliceIndex(msg[p][mtype], m) < 0 {
+									msg[p][mtype] = append(msg[p][mtype], m)
+								}
+							}
+						}
+					}
+					sliceThreshold(msg, (n+f+1)/2, tupleset.Echo)
+					sliceThreshold(msg, f+1, tupleset.Ready)
+					delivered := sliceThreshold(msg, 2*f+1, tupleset.Ready)
+					if len(delivered) != pending {
+						b.Fatalf("%d tuples are delivered instead of %d", len(delivered), pending)
+					}
+					for p := 0; p < n; p++ {
+						for _, m := range delivered {
+							for _, mtype := range []string{tupleset.Echo, tupleset.Ready} {
+								if k := sliceIndex(msg[p][mtype], m); k >= 0 {
+									last := len(msg[p][mtype]) - 1
+									msg[p][mtype][k] = msg[p][mtype][last]
+									msg[p][mtype] = msg[p][mtype][:last]
+								}
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// sliceIndex - The position of m in tuples (-1 if they do not hold it), found with a scan
+func sliceIndex(tuples []types.SSABCMessageTuple, m types.SSABCMessageTuple) int {
+	for k, t := range tuples {
+		if t.Sender == m.Sender && t.Num == m.Num && t.Epoch == m.Epoch && bytes.Equal(t.Value, m.Value) {
+			return k
+		}
+	}
+	return -1
+}
+
+// sliceThreshold - The tuples that at least s processes sent in a message of type mtype, counted with scans
+func sliceThreshold(msg []map[string][]types.SSABCMessageTuple, s int, mtype string) []types.SSABCMessageTuple {
+	tuples, counts := []types.SSABCMessageTuple{}, []int{}
+	for p := range msg {
+		for _, m := range msg[p][mtype] {
+			if k := sliceIndex(tuples, m); k >= 0 {
+				counts[k]++
+			} else {
+				tuples, counts = append(tuples, m), append(counts, 1)
+			}
+		}
+	}
+	values := []types.SSABCMessageTuple{}
+	for k, m := range tuples {
+		if counts[k] >= s {
+			values = append(values, m)
+		}
+	}
+	return values
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"BFTWithoutSignatures/types"
+	"BFTWithoutSignatures/wal"
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wal.log")
+
+	batches := map[int][][]byte{
+		1: {[]byte("a"), []byte("b")},
+		2: {},
+		4: {[]byte("c")},
+	}
+
+	/*** Start Testing ***/
+
+	walLog, err := wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range []int{1, 2, 4} {
+		if err := walLog.Append(index, batches[index]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := walLog.Append(3, batches[1]); err != wal.ErrIndex {
+		t.Errorf("Appending an old index returned %v", err)
+	}
+	walLog.Close()
+
+	// A record that was torn by a crash is discarded
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{1, 2, 3, 4, 0, 0, 0, 9, 0})
+	file.Close()
+
+	walLog, err = wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, walLog, batches, []int{1, 2, 4})
+	if err := walLog.Append(5, [][]byte{[]byte("d")}); err != nil {
+		t.Fatal(err)
+	}
+	batches[5] = [][]byte{[]byte("d")}
+	walLog.Close()
+
+	walLog, err = wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, walLog, batches, []int{1, 2, 4, 5})
+	walLog.Close()
+
+	// A corrupted record is detected by its checksum, and the log ends before it
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[len(content)-1] ^= 0xff
+	if err := ioutil.WriteFile(path, content, 0660); err != nil {
+		t.Fatal(err)
+	}
+	walLog, err = wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer walLog.Close()
+	checkReplay(t, walLog, batches, []int{1, 2, 4})
+
+	/*** End Testing ***/
+}
+
+func TestWALSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wal.log")
+
+	batches := map[int][][]byte{1: {[]byte("a")}, 2: {[]byte("b")}, 3: {[]byte("c")}, 4: {[]byte("d")}}
+
+	/*** Start Testing ***/
+
+	walLog, err := wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := 1; index <= 3; index++ {
+		walLog.Append(index, batches[index])
+	}
+
+	// The snapshot replaces the batches up to its index
+	if err := walLog.SaveSnapshot(2, []byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, walLog, batches, []int{3})
+	if err := walLog.SaveSnapshot(2, []byte("ab")); err != wal.ErrIndex {
+		t.Errorf("Saving an old snapshot returned %v", err)
+	}
+	walLog.Close()
+
+	walLog, err = wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer walLog.Close()
+	index, snapshot, err := walLog.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 2 || string(snapshot) != "ab" {
+		t.Errorf("Snapshot is %q at %d instead of %q at 2", snapshot, index, "ab")
+	}
+	if err := walLog.Append(4, batches[4]); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, walLog, batches, []int{3, 4})
+
+	/*** End Testing ***/
+}
+
+func TestNodeRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	walLog, err := wal.Open(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer walLog.Close()
+
+	walLog.Append(1, [][]byte{encodeRequest(t, 0, 1, "A"), encodeRequest(t, 1, 1, "B")})
+	walLog.Append(2, [][]byte{encodeRequest(t, 1, 1, "B"), encodeRequest(t, 0, 2, "C")}) // (1, 1) is a duplicate
+
+	nodes, network := initializeForTestCluster(t, 4, 0, -1, false, nil)
+	defer network.Close()
+
+	/*** Start Testing ***/
+
+	if err := nodes[0].Recover(walLog); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := string(nodes[0].StateMachine.Snapshot()); snapshot != "ABC" {
+		t.Errorf("Recovered state is %q instead of %q", snapshot, "ABC")
+	}
+	if nodes[0].Aid != 3 {
+		t.Errorf("Recovered replica continues from %d instead of 3", nodes[0].Aid)
+	}
+
+	/*** End Testing ***/
+}
+
+// encodeRequest - Encodes a write request of a client as it is delivered by ABC
+func encodeRequest(t *testing.T, cid int, num int, command string) []byte {
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(types.NewClientMessage(cid, num, types.OpWrite, []byte(command)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Bytes()
+}
+
+// checkReplay - Checks that the log contains exactly the batches with the given indexes
+func checkReplay(t *testing.T, walLog *wal.Log, batches map[int][][]byte, indexes []int) {
+	replayed := make([]int, 0)
+	err := walLog.Replay(func(index int, batch [][]byte) {
+		replayed = append(replayed, index)
+		if len(batch) != len(batches[index]) {
+			t.Errorf("Batch %d has %d values instead of %d", index, len(batch), len(batches[index]))
+			return
+		}
+		for k := range batch {
+			if !bytes.Equal(batch[k], batches[index][k]) {
+				t.Errorf("Batch %d differs at %d: %q != %q", index, k, batch[k], batches[index][k])
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replayed) != len(indexes) {
+		t.Fatalf("Replayed batches %v instead of %v", replayed, indexes)
+	}
+	for k := range indexes {
+		if replayed[k] != indexes[k] {
+			t.Fatalf("Replayed batches %v instead of %v", replayed, indexes)
+		}
+	}
+	if walLog.LastIndex() != indexes[len(indexes)-1] {
+		t.Errorf("Last index is %d instead of %d", walLog.LastIndex(), indexes[len(indexes)-1])
+	}
+}
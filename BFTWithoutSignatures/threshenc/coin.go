@@ -0,0 +1,282 @@
+package threshenc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"strconv"
+)
+
+/*
+	Threshold common coin of Cachin, Kursawe and Shoup (Diffie-Hellman based).
+	A trusted dealer shares a secret x with a polynomial of degree f. The coin
+	of a name C is the hash of H(C)^x, which any f+1 servers can compute by
+	combining their shares H(C)^x_i, while f servers learn nothing about it.
+*/
+
+var (
+	// coinPrime - The 2048-bit MODP safe prime of RFC 3526 (p = 2q + 1)
+	coinPrime, _ = new(big.Int).SetString(
+		"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05"+
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB"+
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718"+
+			"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF", 16)
+
+	// coinOrder - The order of the subgroup of quadratic residues
+	coinOrder = new(big.Int).Rsh(coinPrime, 1)
+
+	// coinGenerator - Generator of the subgroup of quadratic residues
+	coinGenerator = big.NewInt(4)
+
+	coinOne = big.NewInt(1)
+)
+
+// CoinKeys - The keys of a server for the threshold common coin
+type CoinKeys struct {
+	// ID - The id of the server that owns the keys
+	ID int
+
+	// Share - The server's share of the coin secret
+	Share *big.Int
+
+	// VerificationKeys - All servers verification keys (g^share)
+	VerificationKeys map[int]*big.Int
+}
+
+// CoinShare - The share of a server for the coin with some name
+type CoinShare struct {
+	Value *big.Int // H(name)^share
+
+	// Proof that the share has been computed with the secret of the server
+	Challenge *big.Int
+	Response  *big.Int
+}
+
+// GenerateCoinKeys - Deals the shares of a coin secret (any f+1 of n servers can toss) and saves them locally
+func GenerateCoinKeys(N int, folder string) {
+	shares, verificationKeys, err := dealCoinKeys(N, (N-1)/3)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := 0; i < N; i++ {
+		secretFile := folder + "coin_secret_" + strconv.Itoa(i) + ".pem"
+		err = writeKeyToFile(exportIntAsPEM(shares[i], "COIN SECRET SHARE"), secretFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		verificationFile := folder + "coin_verification_" + strconv.Itoa(i) + ".key"
+		err = writeKeyToFile(exportIntAsPEM(verificationKeys[i], "COIN VERIFICATION KEY"), verificationFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// NewCoinKeys - Deals the coin keys of n servers in memory (used in tests)
+func NewCoinKeys(n int) map[int]*CoinKeys {
+	shares, verificationKeys, err := dealCoinKeys(n, (n-1)/3)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys := make(map[int]*CoinKeys, n)
+	for i := 0; i < n; i++ {
+		keys[i] = &CoinKeys{ID: i, Share: shares[i], VerificationKeys: verificationKeys}
+	}
+	return keys
+}
+
+// ReadCoinKeys - Reads the coin keys of server id (in a system of n servers) from local files
+func ReadCoinKeys(folder string, id int, n int) *CoinKeys {
+	keys, err := LoadCoinKeys(folder, id, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return keys
+}
+
+// LoadCoinKeys - Reads the coin keys of server id (in a system of n servers) from local files, or returns
+// the error that the files have
+func LoadCoinKeys(folder string, id int, n int) (*CoinKeys, error) {
+	keys := &CoinKeys{ID: id}
+
+	secretFile := folder + "coin_secret_" + strconv.Itoa(id) + ".pem"
+	sKey, err := readKeyFromFile(secretFile)
+	if err != nil {
+		return nil, err
+	}
+	keys.Share, err = parseIntFromPEM(sKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys.VerificationKeys = make(map[int]*big.Int, n)
+	for i := 0; i < n; i++ {
+		verificationFile := folder + "coin_verification_" + strconv.Itoa(i) + ".key"
+		vKey, err := readKeyFromFile(verificationFile)
+		if err != nil {
+			return nil, err
+		}
+		keys.VerificationKeys[i], err = parseIntFromPEM(vKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// NewShare - Computes the share of this server for the coin with the given name
+func (keys *CoinKeys) NewShare(name []byte) (CoinShare, error) {
+	base := hashToGroup(name)
+	value := new(big.Int).Exp(base, keys.Share, coinPrime)
+
+	// Chaum-Pedersen proof that log_g(verification key) == log_base(value)
+	r, err := rand.Int(rand.Reader, coinOrder)
+	if err != nil {
+		return CoinShare{}, err
+	}
+	a1 := new(big.Int).Exp(coinGenerator, r, coinPrime)
+	a2 := new(big.Int).Exp(base, r, coinPrime)
+	c := challenge(base, keys.VerificationKeys[keys.ID], value, a1, a2)
+
+	z := new(big.Int).Mul(c, keys.Share)
+	z.Add(z, r)
+	z.Mod(z, coinOrder)
+
+	return CoinShare{Value: value, Challenge: c, Response: z}, nil
+}
+
+// VerifyShare - Verifies that the share of the coin with the given name was computed by server i
+func (keys *CoinKeys) VerifyShare(name []byte, share CoinShare, i int) bool {
+	verificationKey, in := keys.VerificationKeys[i]
+	if !in || share.Value == nil || share.Challenge == nil || share.Response == nil {
+		return false
+	}
+	if share.Value.Cmp(coinOne) <= 0 || share.Value.Cmp(coinPrime) >= 0 ||
+		share.Response.Sign() < 0 || share.Response.Cmp(coinOrder) >= 0 {
+		return false
+	}
+	if new(big.Int).Exp(share.Value, coinOrder, coinPrime).Cmp(coinOne) != 0 {
+		return false // Not in the subgroup
+	}
+
+	base := hashToGroup(name)
+	negC := new(big.Int).Sub(coinOrder, new(big.Int).Mod(share.Challenge, coinOrder))
+
+	// a1 = g^z * vk^-c, a2 = base^z * value^-c
+	a1 := new(big.Int).Exp(coinGenerator, share.Response, coinPrime)
+	a1.Mul(a1, new(big.Int).Exp(verificationKey, negC, coinPrime))
+	a1.Mod(a1, coinPrime)
+	a2 := new(big.Int).Exp(base, share.Response, coinPrime)
+	a2.Mul(a2, new(big.Int).Exp(share.Value, negC, coinPrime))
+	a2.Mod(a2, coinPrime)
+
+	return challenge(base, verificationKey, share.Value, a1, a2).Cmp(share.Challenge) == 0
+}
+
+// CombineShares - Combines f+1 (verified) shares of servers into the coin value (0 or 1)
+func CombineShares(shares map[int]CoinShare) uint {
+	// Lagrange interpolation at 0 in the exponent (server i holds the point i+1)
+	result := big.NewInt(1)
+	for i, share := range shares {
+		numerator := big.NewInt(1)
+		denominator := big.NewInt(1)
+		for j := range shares {
+			if j == i {
+				continue
+			}
+			numerator.Mul(numerator, big.NewInt(int64(j+1)))
+			denominator.Mul(denominator, big.NewInt(int64(j-i)))
+		}
+		denominator.Mod(denominator, coinOrder)
+		lambda := numerator.Mul(numerator, denominator.ModInverse(denominator, coinOrder))
+		lambda.Mod(lambda, coinOrder)
+
+		result.Mul(result, new(big.Int).Exp(share.Value, lambda, coinPrime))
+		result.Mod(result, coinPrime)
+	}
+
+	hash := sha256.Sum256(result.Bytes())
+	return uint(hash[0] & 1)
+}
+
+// dealCoinKeys - Shares a random secret among n servers with a polynomial of degree f
+func dealCoinKeys(n int, f int) (map[int]*big.Int, map[int]*big.Int, error) {
+	coefficients := make([]*big.Int, f+1)
+	for k := 0; k <= f; k++ {
+		c, err := rand.Int(rand.Reader, coinOrder)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients[k] = c
+	}
+
+	shares := make(map[int]*big.Int, n)
+	verificationKeys := make(map[int]*big.Int, n)
+	for i := 0; i < n; i++ {
+		x := big.NewInt(int64(i + 1))
+		share := new(big.Int)
+		for k := f; k >= 0; k-- { // Horner's method
+			share.Mul(share, x)
+			share.Add(share, coefficients[k])
+			share.Mod(share, coinOrder)
+		}
+		shares[i] = share
+		verificationKeys[i] = new(big.Int).Exp(coinGenerator, share, coinPrime)
+	}
+
+	return shares, verificationKeys, nil
+}
+
+// hashToGroup - Maps a name to an element of the subgroup of quadratic residues
+func hashToGroup(name []byte) *big.Int {
+	digest := make([]byte, 0, 8*sha256.Size)
+	for counter := byte(0); len(digest) < cap(digest); counter++ {
+		h := sha256.Sum256(append([]byte{counter}, name...))
+		digest = append(digest, h[:]...)
+	}
+
+	x := new(big.Int).SetBytes(digest)
+	x.Mod(x, coinPrime)
+	if x.Cmp(coinOne) <= 0 {
+		x.SetInt64(2)
+	}
+	return x.Exp(x, big.NewInt(2), coinPrime)
+}
+
+// challenge - The Fiat-Shamir challenge of the Chaum-Pedersen proof
+func challenge(values ...*big.Int) *big.Int {
+	hasher := sha256.New()
+	hasher.Write(coinGenerator.Bytes())
+	for _, v := range values {
+		b := v.Bytes()
+		hasher.Write([]byte{byte(len(b) >> 8), byte(len(b))})
+		hasher.Write(b)
+	}
+	c := new(big.Int).SetBytes(hasher.Sum(nil))
+	return c.Mod(c, coinOrder)
+}
+
+// exportIntAsPEM - Exports a coin key as PEM
+func exportIntAsPEM(x *big.Int, blockType string) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: x.Bytes()})
+}
+
+// parseIntFromPEM - Parses a coin key from PEM
+func parseIntFromPEM(keyPEM string) (*big.Int, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("Failed to parse PEM block containing the coin key")
+	}
+	return new(big.Int).SetBytes(block.Bytes), nil
+}
@@ -37,6 +37,8 @@ func GenerateKeys(N int, folder string) {
 			log.Fatal(err)
 		}
 	}
+
+	GenerateCoinKeys(N, folder)
 }
 
 // generateKeyPair - Creates a RSA Key Pair of specified byte size
@@ -82,6 +84,11 @@ func exportRSAPublicKeyAsPEM(publicKey *rsa.PublicKey) ([]byte, error) {
 	return publicKeyPEM, nil
 }
 
+// EncodeVerificationKey - Exports the verification key of a server as PEM (as in its verification file)
+func EncodeVerificationKey(publicKey *rsa.PublicKey) ([]byte, error) {
+	return exportRSAPublicKeyAsPEM(publicKey)
+}
+
 // writeKeyToFile - Writes the key bytes to a file
 func writeKeyToFile(keyBytes []byte, file string) error {
 	err := ioutil.WriteFile(file, keyBytes, 0600)
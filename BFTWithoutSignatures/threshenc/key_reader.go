@@ -1,7 +1,6 @@
 package threshenc
 
 import (
-	"BFTWithoutSignatures/variables"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -9,42 +8,70 @@ import (
 	"io/ioutil"
 	"log"
 	"strconv"
+	"strings"
 )
 
-var (
+// Keys - The keys of a server
+type Keys struct {
 	// SecretKey - The server's secret key
 	SecretKey *rsa.PrivateKey
 
 	// VerificationKeys - All servers verification keys
 	VerificationKeys map[int]*rsa.PublicKey
-)
 
-// ReadKeys - Reads the keys from local files
-func ReadKeys(folder string) {
-	secretFile := folder + "secret_" + strconv.Itoa(variables.ID) + ".pem"
+	// Coin - The server's keys for the threshold common coin
+	Coin *CoinKeys
+}
+
+// ReadKeys - Reads the keys of server id (in a system of n servers) from local files, with the coin keys
+// in the same folder
+func ReadKeys(folder string, id int, n int) *Keys {
+	verificationFiles := make(map[int]string, n)
+	for i := 0; i < n; i++ {
+		verificationFiles[i] = folder + "verification_" + strconv.Itoa(i) + ".key"
+	}
+	keys := ReadClusterKeys(folder, id, verificationFiles)
+	keys.Coin = ReadCoinKeys(strings.TrimSuffix(folder, "/")+"/", id, n)
+	return keys
+}
+
+// ReadClusterKeys - Reads the keys of server id from local files, with the verification keys of the
+// servers in the given files (as listed in a cluster file). The coin keys are not read (see LoadCoinKeys)
+func ReadClusterKeys(folder string, id int, verificationFiles map[int]string) *Keys {
+	n := len(verificationFiles)
+	folder = strings.TrimSuffix(folder, "/") + "/"
+
+	keys := new(Keys)
+	secretFile := folder + "secret_" + strconv.Itoa(id) + ".pem"
 	sKey, err := readKeyFromFile(secretFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	SecretKey, err = parseRSAPrivateKeyFromPEM(sKey)
+	keys.SecretKey, err = parseRSAPrivateKeyFromPEM(sKey)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	VerificationKeys = make(map[int]*rsa.PublicKey, variables.N)
-	for i := 0; i < variables.N; i++ {
-		verificationFile := folder + "verification_" + strconv.Itoa(i) + ".key"
-		vKey, err := readKeyFromFile(verificationFile)
+	keys.VerificationKeys = make(map[int]*rsa.PublicKey, n)
+	for i := 0; i < n; i++ {
+		vKey, err := readKeyFromFile(verificationFiles[i])
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		VerificationKeys[i], err = parseRSAPublicKeyFromPEM(vKey)
+		keys.VerificationKeys[i], err = parseRSAPublicKeyFromPEM(vKey)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+
+	return keys
+}
+
+// ParseVerificationKey - Parses the verification key of a server from PEM (as in its verification file)
+func ParseVerificationKey(keyPEM []byte) (*rsa.PublicKey, error) {
+	return parseRSAPublicKeyFromPEM(string(keyPEM))
 }
 
 // parseRSAPrivateKeyFromPEM - Parses a rsa.PrivateKey from PEM
@@ -9,7 +9,7 @@ import (
 )
 
 // SignMessage - Signs the message with the secret key
-func SignMessage(message []byte) []byte {
+func (keys *Keys) SignMessage(message []byte) []byte {
 	hash := sha256.New()
 	_, err := hash.Write(message)
 	if err != nil {
@@ -17,22 +17,27 @@ func SignMessage(message []byte) []byte {
 	}
 	hashSum := hash.Sum(nil)
 
-	signature, err := rsa.SignPSS(rand.Reader, SecretKey, crypto.SHA256, hashSum, nil)
+	signature, err := rsa.SignPSS(rand.Reader, keys.SecretKey, crypto.SHA256, hashSum, nil)
 	if err != nil {
 		logger.ErrLogger.Fatal(err)
 	}
 	return signature
 }
 
-// VerifyMessage - Verifies if the message is signed by the correct public key
-func VerifyMessage(message []byte, signature []byte, i int) bool {
+// VerifyMessage - Verifies if the message is signed by the correct public key (false for a server
+// whose key is unknown, e.g. one that has left)
+func (keys *Keys) VerifyMessage(message []byte, signature []byte, i int) bool {
+	key, in := keys.VerificationKeys[i]
+	if !in || key == nil {
+		return false
+	}
 	hash := sha256.New()
 	_, err := hash.Write(message)
 	if err != nil {
 		logger.ErrLogger.Fatal(err)
 	}
 
-	err = rsa.VerifyPSS(VerificationKeys[i], crypto.SHA256, hash.Sum(nil), signature, nil)
+	err = rsa.VerifyPSS(key, crypto.SHA256, hash.Sum(nil), signature, nil)
 	if err != nil {
 		logger.ErrLogger.Println(err)
 		return false
@@ -0,0 +1,295 @@
+package tupleset
+
+import "BFTWithoutSignatures/types"
+
+// count - How many sets of a message type hold a tuple (and the hash of its key)
+type count[T any] struct {
+	n     int
+	tuple T
+	hash  types.Digest
+}
+
+// Set - A set of tuples of type T (SSABC or SSVC ones), identified by their keys
+type Set[T any] struct {
+	tuples []T
+	keys   []Key
+	index  map[Key]int       // The position of every tuple
+	counts map[Key]*count[T] // Shared by the sets of a message type (nil if the set is not counted)
+	key    func(T) Key
+	digest types.Digest
+}
+
+func newSet[T any](key func(T) Key, counts map[Key]*count[T], tuples ...T) *Set[T] {
+	s := &Set[T]{index: make(map[Key]int, len(tuples)), counts: counts, key: key}
+	for _, m := range tuples {
+		s.Add(m)
+	}
+	return s
+}
+
+// Len - How many tuples the set holds
+func (s *Set[T]) Len() int {
+	return len(s.tuples)
+}
+
+// Tuples - The tuples of the set (they must not be modified, and change with the set)
+func (s *Set[T]) Tuples() []T {
+	return s.tuples
+}
+
+// Contains - If the set holds the tuple
+func (s *Set[T]) Contains(m T) bool {
+	_, in := s.index[s.key(m)]
+	return in
+}
+
+// Add - Adds the tuple (false if the set already holds it)
+func (s *Set[T]) Add(m T) bool {
+	k := s.key(m)
+	if _, in := s.index[k]; in {
+		return false
+	}
+	s.index[k] = len(s.tuples)
+	s.tuples = append(s.tuples, m)
+	s.keys = append(s.keys, k)
+	if s.counts == nil {
+		xorHash(&s.digest, hashKey(k))
+		return true
+	}
+	c := s.counts[k]
+	if c == nil {
+		c = &count[T]{tuple: m, hash: hashKey(k)}
+		s.counts[k] = c
+	}
+	c.n++
+	xorHash(&s.digest, c.hash)
+	return true
+}
+
+// Remove - Removes the tuple (false if the set does not hold it)
+func (s *Set[T]) Remove(m T) bool {
+	return s.remove(s.key(m))
+}
+
+func (s *Set[T]) remove(k Key) bool {
+	i, in := s.index[k]
+	if !in {
+		return false
+	}
+	last := len(s.tuples) - 1
+	s.tuples[i], s.keys[i] = s.tuples[last], s.keys[last]
+	s.index[s.keys[i]] = i
+	s.tuples, s.keys = s.tuples[:last], s.keys[:last]
+	delete(s.index, k)
+	c := s.counts[k]
+	if c == nil {
+		xorHash(&s.digest, hashKey(k))
+		return true
+	}
+	xorHash(&s.digest, c.hash)
+	if c.n--; c.n <= 0 {
+		delete(s.counts, k)
+	}
+	return true
+}
+
+// Digest - The digest of the tuples of the set
+func (s *Set[T]) Digest() types.Digest {
+	return s.digest
+}
+
+// Filter - Removes the tuples that keep rejects, and returns how many it removed
+func (s *Set[T]) Filter(keep func(m T) bool) int {
+	removed := 0
+	for i := len(s.tuples) - 1; i >= 0; i-- { // the tuples after i were kept, so the one moved to i was
+		if !keep(s.tuples[i]) {
+			s.remove(s.keys[i])
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clear - Removes every tuple
+func (s *Set[T]) Clear() {
+	s.Filter(func(T) bool { return false })
+}
+
+// Messages - The init, echo and ready messages of every process, with the tuples of every message
+// type counted
+type Messages[T any] struct {
+	sets   map[int]map[string]*Set[T]
+	counts map[string]map[Key]*count[T]
+	key    func(T) Key
+}
+
+func newMessages[T any](key func(T) Key, n int) *Messages[T] {
+	msg := &Messages[T]{sets: make(map[int]map[string]*Set[T], n), counts: make(map[string]map[Key]*count[T]),
+		key: key}
+	for _, mtype := range Types {
+		msg.counts[mtype] = make(map[Key]*count[T])
+	}
+	for p := 0; p < n; p++ {
+		msg.Flush(p)
+	}
+	return msg
+}
+
+// messagesFrom - Creates the messages in slices (see Slices)
+func messagesFrom[T any](key func(T) Key, slices map[int]map[string][]T) *Messages[T] {
+	msg := newMessages(key, 0)
+	for p, content := range slices {
+		msg.Replace(p, content)
+	}
+	return msg
+}
+
+// contentDigest - The digest of the messages in content, as Digest finds it once they are applied
+func contentDigest[T any](key func(T) Key, content map[string][]T) types.Digest {
+	digests := make([]types.Digest, len(Types))
+	for k, mtype := range Types {
+		digests[k] = newSet(key, nil, content[mtype]...).digest
+	}
+	return digestOf(digests...)
+}
+
+// Has - If the messages of process p are kept
+func (msg *Messages[T]) Has(p int) bool {
+	_, in := msg.sets[p]
+	return in
+}
+
+// Processes - The processes whose messages are kept
+func (msg *Messages[T]) Processes() []int {
+	processes := make([]int, 0, len(msg.sets))
+	for p := range msg.sets {
+		processes = append(processes, p)
+	}
+	return processes
+}
+
+// Flush - Empties the messages of process p (which are kept from now on)
+func (msg *Messages[T]) Flush(p int) {
+	if _, in := msg.sets[p]; !in {
+		msg.sets[p] = make(map[string]*Set[T], len(Types))
+		for _, mtype := range Types {
+			msg.sets[p][mtype] = newSet(msg.key, msg.counts[mtype])
+		}
+		return
+	}
+	for _, mtype := range Types {
+		msg.sets[p][mtype].Clear()
+	}
+}
+
+// Of - The messages of type mtype of process p (nil if they are not kept)
+func (msg *Messages[T]) Of(p int, mtype string) *Set[T] {
+	return msg.sets[p][mtype]
+}
+
+// Content - The messages of process p, as they are sent (they change with the sets)
+func (msg *Messages[T]) Content(p int) map[string][]T {
+	content := make(map[string][]T, len(Types))
+	for _, mtype := range Types {
+		if s := msg.Of(p, mtype); s != nil {
+			content[mtype] = s.tuples
+		} else {
+			content[mtype] = []T{}
+		}
+	}
+	return content
+}
+
+// Digest - The digest of the messages of process p
+func (msg *Messages[T]) Digest(p int) types.Digest {
+	digests := make([]types.Digest, len(Types))
+	for k, mtype := range Types {
+		if s := msg.Of(p, mtype); s != nil {
+			digests[k] = s.digest
+		}
+	}
+	return digestOf(digests...)
+}
+
+// Replace - Replaces the messages of process p with content
+func (msg *Messages[T]) Replace(p int, content map[string][]T) {
+	msg.Flush(p)
+	msg.Apply(p, content, nil)
+}
+
+// Apply - Removes the tuples of removed from the messages of process p and adds the ones of added
+func (msg *Messages[T]) Apply(p int, added, removed map[string][]T) {
+	if !msg.Has(p) {
+		msg.Flush(p)
+	}
+	for _, mtype := range Types {
+		for _, m := range removed[mtype] {
+			msg.sets[p][mtype].Remove(m)
+		}
+		for _, m := range added[mtype] {
+			msg.sets[p][mtype].Add(m)
+		}
+	}
+}
+
+// Diff - The tuples of the messages of process p that the messages of process q in other do not hold
+// (added), and the other way around (removed); both processes must be kept
+func (msg *Messages[T]) Diff(p int, other *Messages[T], q int) (added, removed map[string][]T) {
+	added, removed = make(map[string][]T), make(map[string][]T)
+	for _, mtype := range Types {
+		mine, theirs := msg.Of(p, mtype), other.Of(q, mtype)
+		for k, m := range mine.tuples {
+			if _, in := theirs.index[mine.keys[k]]; !in {
+				added[mtype] = append(added[mtype], m)
+			}
+		}
+		for k, m := range theirs.tuples {
+			if _, in := mine.index[theirs.keys[k]]; !in {
+				removed[mtype] = append(removed[mtype], m)
+			}
+		}
+	}
+	return added, removed
+}
+
+// Size - How many messages process p has
+func (msg *Messages[T]) Size(p int) int {
+	size := 0
+	for _, mtype := range Types {
+		if s := msg.Of(p, mtype); s != nil {
+			size += s.Len()
+		}
+	}
+	return size
+}
+
+// Count - How many processes sent the tuple in a message of type mtype
+func (msg *Messages[T]) Count(mtype string, m T) int {
+	if c := msg.counts[mtype][msg.key(m)]; c != nil {
+		return c.n
+	}
+	return 0
+}
+
+// Threshold - The tuples that at least s processes sent in a message of type mtype
+func (msg *Messages[T]) Threshold(s int, mtype string) []T {
+	values := []T{}
+	for _, c := range msg.counts[mtype] {
+		if c.n >= s {
+			values = append(values, c.tuple)
+		}
+	}
+	return values
+}
+
+// Slices - A copy of the messages of every process, in slices
+func (msg *Messages[T]) Slices() map[int]map[string][]T {
+	slices := make(map[int]map[string][]T, len(msg.sets))
+	for p, sets := range msg.sets {
+		slices[p] = make(map[string][]T, len(Types))
+		for _, mtype := range Types {
+			slices[p][mtype] = append([]T{}, sets[mtype].tuples...)
+		}
+	}
+	return slices
+}
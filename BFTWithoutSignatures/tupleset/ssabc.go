@@ -0,0 +1,36 @@
+package tupleset
+
+import "BFTWithoutSignatures/types"
+
+type ssabcMT = types.SSABCMessageTuple
+
+// SSABCSet - A set of SSABC tuples
+type SSABCSet = Set[ssabcMT]
+
+// SSABCMessages - The init, echo and ready messages of every process (msg in SSABC)
+type SSABCMessages = Messages[ssabcMT]
+
+// ssabcKey - The key of an SSABC tuple
+func ssabcKey(m ssabcMT) Key {
+	return newKey(m.Sender, m.Num, m.Epoch, m.Value)
+}
+
+// NewSSABCSet - Creates a set of the given tuples
+func NewSSABCSet(tuples ...ssabcMT) *SSABCSet {
+	return newSet(ssabcKey, nil, tuples...)
+}
+
+// NewSSABCMessages - Creates the (empty) messages of processes 0 to n-1
+func NewSSABCMessages(n int) *SSABCMessages {
+	return newMessages(ssabcKey, n)
+}
+
+// SSABCMessagesFrom - Creates the messages in slices (see Slices)
+func SSABCMessagesFrom(slices map[int]map[string][]ssabcMT) *SSABCMessages {
+	return messagesFrom(ssabcKey, slices)
+}
+
+// SSABCContentDigest - The digest of the messages in content (as SSABCMessages.Digest finds it)
+func SSABCContentDigest(content map[string][]ssabcMT) types.Digest {
+	return contentDigest(ssabcKey, content)
+}
@@ -0,0 +1,36 @@
+package tupleset
+
+import "BFTWithoutSignatures/types"
+
+type ssvcMT = types.SSVCMessageTuple
+
+// SSVCSet - A set of SSVC tuples
+type SSVCSet = Set[ssvcMT]
+
+// SSVCMessages - The init, echo and ready messages of every process (msg in SSVC and SSMVC)
+type SSVCMessages = Messages[ssvcMT]
+
+// ssvcKey - The key of an SSVC tuple
+func ssvcKey(m ssvcMT) Key {
+	return newKey(m.Sender, 0, 0, m.Value)
+}
+
+// NewSSVCSet - Creates a set of the given tuples
+func NewSSVCSet(tuples ...ssvcMT) *SSVCSet {
+	return newSet(ssvcKey, nil, tuples...)
+}
+
+// NewSSVCMessages - Creates the (empty) messages of processes 0 to n-1
+func NewSSVCMessages(n int) *SSVCMessages {
+	return newMessages(ssvcKey, n)
+}
+
+// SSVCMessagesFrom - Creates the messages in slices (see Slices)
+func SSVCMessagesFrom(slices map[int]map[string][]ssvcMT) *SSVCMessages {
+	return messagesFrom(ssvcKey, slices)
+}
+
+// SSVCContentDigest - The digest of the messages in content (as SSVCMessages.Digest finds it)
+func SSVCContentDigest(content map[string][]ssvcMT) types.Digest {
+	return contentDigest(ssvcKey, content)
+}
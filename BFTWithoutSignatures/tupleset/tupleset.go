@@ -0,0 +1,84 @@
+package tupleset
+
+import (
+	"BFTWithoutSignatures/types"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+/*
+	Sets of the tuples that the self-stabilizing protocols (SSVC, SSMVC and
+	SSABC) exchange in their init, echo and ready messages. A tuple is keyed
+	by its sender, its number and epoch (SSABC only) and its value, so
+	looking it up, adding it or removing it takes constant time
+	instead of a scan of the set. The messages of every process are kept in
+	one set per message type, and the sets of a message type share a counter
+	of how many of them hold every tuple, which they update as tuples are
+	added and removed: the tuples that enough processes sent are found
+	without counting them again in every step.
+	The tuples of a set keep the order they were added in, until one is
+	removed (the last one takes its place). The sets are not safe for
+	concurrent use, like the maps they replace.
+	Every set also keeps the digest of its tuples (the hashes of their keys
+	XORed together), which the gossip compares with the digest of the same
+	messages at the other end instead of sending them again. The hash of a
+	tuple is kept with its counter, so the sets of a message type hash it
+	once, when the first of them adds it.
+	One implementation (Set and Messages) holds both kinds of tuples, which
+	differ only in how they are keyed (ssabcKey and ssvcKey).
+*/
+
+// Message types
+const (
+	Init  = "init"
+	Echo  = "echo"
+	Ready = "ready"
+)
+
+// Types - The message types, in the order they are sent in
+var Types = []string{Init, Echo, Ready}
+
+// Key - Identifies a tuple: its sender, its number and epoch (0 in SSVC) and its value
+type Key struct {
+	Sender int
+	Num    uint32
+	Epoch  uint32
+	Value  string
+}
+
+// newKey - The key of a tuple
+func newKey(sender int, num uint32, epoch uint32, value []byte) Key {
+	return Key{Sender: sender, Num: num, Epoch: epoch, Value: string(value)}
+}
+
+// hashKey - The hash of a key in the digests
+func hashKey(k Key) types.Digest {
+	h := sha256.New()
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(k.Sender))
+	binary.BigEndian.PutUint32(b[8:12], k.Num)
+	binary.BigEndian.PutUint32(b[12:16], k.Epoch)
+	h.Write(b[:])
+	h.Write([]byte(k.Value))
+	var d types.Digest
+	h.Sum(d[:0])
+	return d
+}
+
+// xorHash - Adds the hash of a key to a digest, or removes it from the digest (XOR is its own inverse)
+func xorHash(d *types.Digest, h types.Digest) {
+	for i := range d {
+		d[i] ^= h[i]
+	}
+}
+
+// digestOf - The digest of the sets of the message types of a process
+func digestOf(digests ...types.Digest) types.Digest {
+	h := sha256.New()
+	for _, d := range digests {
+		h.Write(d[:])
+	}
+	var d types.Digest
+	h.Sum(d[:0])
+	return d
+}
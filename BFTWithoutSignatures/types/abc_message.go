@@ -1,7 +1,6 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
 	"bytes"
 	"encoding/gob"
 )
@@ -23,11 +22,11 @@ func (abcm AbcMessage) GobEncode() ([]byte, error) {
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(abcm.Num)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(abcm.Value)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
@@ -38,11 +37,11 @@ func (abcm *AbcMessage) GobDecode(buf []byte) error {
 	decoder := gob.NewDecoder(r)
 	err := decoder.Decode(&abcm.Num)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&abcm.Value)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	return nil
 }
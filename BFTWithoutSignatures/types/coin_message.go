@@ -0,0 +1,65 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// CoinMessage - Common coin share message struct
+type CoinMessage struct {
+	Id        int
+	Value     []byte
+	Challenge []byte
+	Response  []byte
+}
+
+// NewCoinMessage - Creates a new Coin message
+func NewCoinMessage(id int, value []byte, challenge []byte, response []byte) CoinMessage {
+	return CoinMessage{Id: id, Value: value, Challenge: challenge, Response: response}
+}
+
+// GobEncode - Common coin message encoder
+func (cm CoinMessage) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(cm.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(cm.Value)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(cm.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(cm.Response)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - Common coin message decoder
+func (cm *CoinMessage) GobDecode(buf []byte) error {
+	r := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(r)
+	err := decoder.Decode(&cm.Id)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&cm.Value)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&cm.Challenge)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&cm.Response)
+	if err != nil {
+		return err
+	}
+	return nil
+}
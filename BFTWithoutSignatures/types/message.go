@@ -1,9 +1,6 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/threshenc"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
 )
@@ -16,10 +13,9 @@ type Message struct {
 	From      int
 }
 
-// NewMessage - Creates a new payload message
-func NewMessage(payload []byte, Type string) Message {
-	signature := threshenc.SignMessage(payload)
-	return Message{Payload: payload, Signature: signature, Type: Type, From: variables.ID}
+// NewMessage - Creates a new payload message (signed by the sender)
+func NewMessage(payload []byte, Type string, from int, signature []byte) Message {
+	return Message{Payload: payload, Signature: signature, Type: Type, From: from}
 }
 
 // GobEncode - Message encoder
@@ -28,19 +24,19 @@ func (m Message) GobEncode() ([]byte, error) {
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(m.Payload)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(m.Signature)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(m.Type)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(m.From)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
@@ -51,19 +47,19 @@ func (m *Message) GobDecode(buf []byte) error {
 	decoder := gob.NewDecoder(r)
 	err := decoder.Decode(&m.Payload)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&m.Signature)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&m.Type)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&m.From)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	return nil
 }
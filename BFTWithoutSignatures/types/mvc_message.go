@@ -1,7 +1,6 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
 	"bytes"
 	"encoding/gob"
 )
@@ -25,19 +24,19 @@ func (mvcm MvcMessage) GobEncode() ([]byte, error) {
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(mvcm.Cid)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(mvcm.Type)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(mvcm.Value)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(mvcm.Vector)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
@@ -48,19 +47,19 @@ func (mvcm *MvcMessage) GobDecode(buf []byte) error {
 	decoder := gob.NewDecoder(r)
 	err := decoder.Decode(&mvcm.Cid)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&mvcm.Type)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&mvcm.Value)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&mvcm.Vector)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// MembershipOp - The type of a change in the membership of the replicas
+type MembershipOp uint8
+
+const (
+	// AddReplica - A new replica joins with the next id
+	AddReplica MembershipOp = iota
+
+	// RemoveReplica - The replica with the last id leaves (the ids stay contiguous)
+	RemoveReplica
+
+	// ReplaceReplica - A replica moves to another host, ports or key and keeps its id
+	ReplaceReplica
+)
+
+func (op MembershipOp) String() string {
+	switch op {
+	case AddReplica:
+		return "add"
+	case RemoveReplica:
+		return "remove"
+	case ReplaceReplica:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
+
+// Member - Where a replica runs and the key it signs with
+type Member struct {
+	ID         int
+	Host       string
+	PeerPort   int
+	ClientPort int
+	PublicKey  []byte // PEM (nil for the keys that the replicas already have)
+}
+
+// Reconfiguration - A change in the membership, ordered by ABC
+type Reconfiguration struct {
+	Op       MembershipOp
+	Member   Member // The replica that is added, removed (only its ID) or replaced
+	CoinKeys string // The folder of the coin keys dealt for the new membership (empty to keep them)
+}
+
+// ReconfigurationProposal - A reconfiguration signed by the replica that proposes it
+type ReconfigurationProposal struct {
+	Reconfiguration Reconfiguration
+	Version         int // The number of reconfigurations that the proposer has seen
+	Signature       []byte
+}
+
+// ReplicaCid - The client id under which replica id orders its proposals (negative, not a client)
+func ReplicaCid(id int) int {
+	return -1 - id
+}
+
+// ReplicaOf - The replica that ordered a request with client id cid (negative for clients)
+func ReplicaOf(cid int) int {
+	return -1 - cid
+}
+
+// GobEncode - Reconfiguration encoder
+func (r Reconfiguration) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(r.Op)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Member.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Member.Host)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Member.PeerPort)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Member.ClientPort)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Member.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.CoinKeys)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - Reconfiguration decoder
+func (r *Reconfiguration) GobDecode(buf []byte) error {
+	rd := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(rd)
+	err := decoder.Decode(&r.Op)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Member.ID)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Member.Host)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Member.PeerPort)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Member.ClientPort)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Member.PublicKey)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(&r.CoinKeys)
+}
+
+// GobEncode - Reconfiguration proposal encoder
+func (p ReconfigurationProposal) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(p.Reconfiguration)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(p.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - Reconfiguration proposal decoder
+func (p *ReconfigurationProposal) GobDecode(buf []byte) error {
+	r := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(r)
+	err := decoder.Decode(&p.Reconfiguration)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&p.Version)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(&p.Signature)
+}
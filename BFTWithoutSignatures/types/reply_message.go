@@ -1,49 +1,87 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
-	"BFTWithoutSignatures/variables"
 	"bytes"
 	"encoding/gob"
 )
 
+// Status - The outcome of a client request
+type Status uint8
+
+const (
+	// StatusOK - The request was applied to the state machine
+	StatusOK Status = iota
+
+	// StatusBadRequest - The operation of the request is unknown
+	StatusBadRequest
+
+	// StatusUnsupported - The state machine does not support the operation
+	StatusUnsupported
+)
+
 // Reply struct
 type Reply struct {
-	From  int
-	Value int
+	Version uint8
+	From    int
+	Num     int
+	Status  Status
+	Result  []byte // The result of the request in the replicated state machine
 }
 
 // NewReplyMessage - Creates a new Reply
-func NewReplyMessage(value int) Reply {
-	return Reply{From: variables.ID, Value: value}
+func NewReplyMessage(from int, num int, status Status, result []byte) Reply {
+	return Reply{Version: ProtocolVersion, From: from, Num: num, Status: status, Result: result}
 }
 
 // GobEncode - Reply message encoder
 func (r Reply) GobEncode() ([]byte, error) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
-	err := encoder.Encode(r.From)
+	err := encoder.Encode(r.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.From)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Num)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(r.Status)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
-	err = encoder.Encode(r.Value)
+	err = encoder.Encode(r.Result)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
 
-// GobDecode - Reply message decoder
+// GobDecode - Reply message decoder (fails with ErrProtocolVersion on a version mismatch)
 func (r *Reply) GobDecode(buf []byte) error {
 	d := bytes.NewBuffer(buf)
 	decoder := gob.NewDecoder(d)
-	err := decoder.Decode(&r.From)
+	err := decoder.Decode(&r.Version)
+	if err != nil {
+		return err
+	}
+	if r.Version != ProtocolVersion {
+		return ErrProtocolVersion
+	}
+	err = decoder.Decode(&r.From)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&r.Num)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
-	err = decoder.Decode(&r.Value)
+	err = decoder.Decode(&r.Status)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
-	return nil
+	return decoder.Decode(&r.Result)
 }
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+)
+
+// batchTag - The first byte of a batch, which never starts a gob stream (the length that starts it is
+// either below 0x80 or the negated count of its bytes, from 0xF8 up)
+const batchTag byte = 0x80
+
+// ErrEmptyBatch - A batch without requests
+var ErrEmptyBatch = errors.New("empty request batch")
+
+// PackRequests - Packs encoded ClientMessages into one value that is broadcast (a single request is
+// broadcast as it is)
+func PackRequests(requests [][]byte) ([]byte, error) {
+	if len(requests) == 1 {
+		return requests[0], nil
+	}
+	if len(requests) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	w := bytes.NewBuffer([]byte{batchTag})
+	err := gob.NewEncoder(w).Encode(requests)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// IsBatch - Whether a delivered value was packed by PackRequests from more than one request
+func IsBatch(value []byte) bool {
+	return len(value) > 0 && value[0] == batchTag
+}
+
+// UnpackRequests - The encoded ClientMessages of a delivered value
+func UnpackRequests(value []byte) ([][]byte, error) {
+	if !IsBatch(value) {
+		return [][]byte{value}, nil
+	}
+
+	var requests [][]byte
+	err := gob.NewDecoder(bytes.NewBuffer(value[1:])).Decode(&requests)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	return requests, nil
+}
@@ -0,0 +1,21 @@
+package types
+
+import "crypto/sha256"
+
+// Gossip - What a message of SSVC or SSABC carries of the state of its sender (see the anti-entropy
+// gossip of the modules)
+type Gossip uint8
+
+const (
+	// GossipFull - The whole state
+	GossipFull Gossip = iota
+	// GossipDelta - The tuples added (Content) and removed (Removed) since the previous message
+	GossipDelta
+	// GossipDigest - Only the digest of the state
+	GossipDigest
+	// GossipPull - Asks the receiver for its whole state
+	GossipPull
+)
+
+// Digest - A hash of the tuples of a state that does not depend on their order
+type Digest [sha256.Size]byte
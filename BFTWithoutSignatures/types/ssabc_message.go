@@ -1,26 +1,34 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
 	"bytes"
 	"encoding/gob"
 )
 
-// Tuple containing the sender and the corresponding value of the sender
+// Tuple containing the sender and the corresponding value of the sender (numbered in the epoch
+// of the sender)
 type SSABCMessageTuple struct {
 	Sender int
 	Num	uint32
+	Epoch	uint32
 	Value []byte
 }
 
-// Self Stabilizing Atomic Broadcast - SSABCMessage message struct
+// Self Stabilizing Atomic Broadcast - SSABCMessage message struct (the messages of the sender, its
+// epoch, and if it asks for a global reset of that epoch; Gossip tells which part of the messages
+// it carries, and Digest is the digest of all of them)
 type SSABCMessage struct {
 	Content map[string][]SSABCMessageTuple
+	Epoch	uint32
+	Reset	bool
+	Gossip	Gossip
+	Digest	Digest
+	Removed map[string][]SSABCMessageTuple
 }
 
 // NewSSABCMessage - Creates a new SS ABC message
-func NewSSABCMessage(content map[string][]SSABCMessageTuple) SSABCMessage {
-	return SSABCMessage{Content: content}
+func NewSSABCMessage(content map[string][]SSABCMessageTuple, epoch uint32, reset bool) SSABCMessage {
+	return SSABCMessage{Content: content, Epoch: epoch, Reset: reset}
 }
 
 // GobEncode - SS Atomic Broadcast message encoder
@@ -29,7 +37,27 @@ func (abcm SSABCMessage) GobEncode() ([]byte, error) {
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(abcm.Content)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
+	}
+	err = encoder.Encode(abcm.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(abcm.Reset)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(abcm.Gossip)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(abcm.Digest)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(abcm.Removed)
+	if err != nil {
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
@@ -40,7 +68,27 @@ func (abcm *SSABCMessage) GobDecode(buf []byte) error {
 	decoder := gob.NewDecoder(r)
 	err := decoder.Decode(&abcm.Content)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
+	}
+	err = decoder.Decode(&abcm.Epoch)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&abcm.Reset)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&abcm.Gossip)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&abcm.Digest)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&abcm.Removed)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// SSBCRound - The state of a replica in a round of Self Stabilizing Binary Consensus
+type SSBCRound struct {
+	Round int
+	Est   []uint // The values that it has EST broadcast
+	Aux   []uint // Its AUX value (at most one)
+}
+
+// SSBCMessage - Self Stabilizing Binary consensus message struct (the last rounds of the sender and
+// its decision)
+type SSBCMessage struct {
+	SSBCid  int
+	Rounds  []SSBCRound
+	Decided []uint // At most one value
+}
+
+// NewSSBCMessage - Creates a new Self Stabilizing BC message
+func NewSSBCMessage(id int, rounds []SSBCRound, decided []uint) SSBCMessage {
+	return SSBCMessage{SSBCid: id, Rounds: rounds, Decided: decided}
+}
+
+// GobEncode - Self Stabilizing Binary consensus message encoder
+func (ssbcm SSBCMessage) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssbcm.SSBCid)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssbcm.Rounds)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssbcm.Decided)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - Self Stabilizing Binary consensus message decoder
+func (ssbcm *SSBCMessage) GobDecode(buf []byte) error {
+	r := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(r)
+	err := decoder.Decode(&ssbcm.SSBCid)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssbcm.Rounds)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssbcm.Decided)
+	if err != nil {
+		return err
+	}
+	return nil
+}
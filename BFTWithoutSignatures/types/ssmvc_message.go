@@ -0,0 +1,67 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// SSMVCMessage - Self Stabilizing Multi-valued consensus message struct (the init, echo and ready
+// messages of the sender for the INIT and the VECT values of the instance, and if it has decided)
+type SSMVCMessage struct {
+	SSMVCid int
+	Init    map[string][]SSVCMessageTuple
+	Vect    map[string][]SSVCMessageTuple
+	Decided bool
+}
+
+// NewSSMVCMessage - Creates a new Self Stabilizing MVC message
+func NewSSMVCMessage(id int, init map[string][]SSVCMessageTuple, vect map[string][]SSVCMessageTuple,
+	decided bool) SSMVCMessage {
+	return SSMVCMessage{SSMVCid: id, Init: init, Vect: vect, Decided: decided}
+}
+
+// GobEncode - Self Stabilizing Multi-valued consensus message encoder
+func (ssmvcm SSMVCMessage) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(ssmvcm.SSMVCid)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssmvcm.Init)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssmvcm.Vect)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssmvcm.Decided)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - Self Stabilizing Multi-valued consensus message decoder
+func (ssmvcm *SSMVCMessage) GobDecode(buf []byte) error {
+	r := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(r)
+	err := decoder.Decode(&ssmvcm.SSMVCid)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssmvcm.Init)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssmvcm.Vect)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssmvcm.Decided)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
 	"bytes"
 	"encoding/gob"
 )
@@ -12,10 +11,14 @@ type SSVCMessageTuple struct {
 	Value []byte
 }
 
-// Self Stabilizing Vector Consensus SSVCMessage - SSVector consensus message struct
+// Self Stabilizing Vector Consensus SSVCMessage - SSVector consensus message struct (Gossip tells
+// which part of the messages of the sender it carries, and Digest is the digest of all of them)
 type SSVCMessage struct {
 	SSVCid int
 	Content map[string][]SSVCMessageTuple
+	Gossip Gossip
+	Digest Digest
+	Removed map[string][]SSVCMessageTuple
 }
 
 // NewSSVCMessage - Creates a new Self Stabilizing VC message
@@ -29,11 +32,23 @@ func (ssvcm SSVCMessage) GobEncode() ([]byte, error) {
 	encoder := gob.NewEncoder(w)
 	err := encoder.Encode(ssvcm.SSVCid)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	err = encoder.Encode(ssvcm.Content)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
+	}
+	err = encoder.Encode(ssvcm.Gossip)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssvcm.Digest)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(ssvcm.Removed)
+	if err != nil {
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
@@ -44,11 +59,23 @@ func (ssvcm *SSVCMessage) GobDecode(buf []byte) error {
 	decoder := gob.NewDecoder(r)
 	err := decoder.Decode(&ssvcm.SSVCid)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&ssvcm.Content)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
+	}
+	err = decoder.Decode(&ssvcm.Gossip)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssvcm.Digest)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&ssvcm.Removed)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// StateMessage - State transfer message struct
+type StateMessage struct {
+	Tag      string // (request, reply)
+	Index    int    // The last delivery index of the sender
+	Digest   []byte // The digest of the snapshot (reply)
+	Snapshot []byte // The state of the sender at Index (reply)
+}
+
+// NewStateMessage - Creates a new State message
+func NewStateMessage(tag string, index int, digest []byte, snapshot []byte) StateMessage {
+	return StateMessage{Tag: tag, Index: index, Digest: digest, Snapshot: snapshot}
+}
+
+// GobEncode - State transfer message encoder
+func (sm StateMessage) GobEncode() ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	err := encoder.Encode(sm.Tag)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(sm.Index)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(sm.Digest)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(sm.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// GobDecode - State transfer message decoder
+func (sm *StateMessage) GobDecode(buf []byte) error {
+	r := bytes.NewBuffer(buf)
+	decoder := gob.NewDecoder(r)
+	err := decoder.Decode(&sm.Tag)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&sm.Index)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&sm.Digest)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(&sm.Snapshot)
+	if err != nil {
+		return err
+	}
+	return nil
+}
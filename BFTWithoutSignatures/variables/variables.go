@@ -1,8 +1,7 @@
 package variables
 
-import "sync"
-
-var (
+// Replica - The variables that identify a processor and the system it runs in
+type Replica struct {
 	// ID - This processor's id.
 	ID int
 
@@ -20,50 +19,31 @@ var (
 
 	// Remote - If we are running locally or remotely
 	Remote bool
+}
 
+var (
 	// DEFAULT - The default value that is used in the algorithms (bottom)
-	DEFAULT []byte
+	DEFAULT = []byte("")
 
 	// PSI - The psi value that is used in self-stabilized algorithms
-	PSI []byte
-
-	// Server metrics regarding the experiment evaluation
-	MsgComplexity int
-	MsgSize       int64
-	MsgMutex      sync.RWMutex
-
-	// Use SelfStabilizedAtomicBroadcast or not 
-	RunSSABC bool
+	PSI = []byte("Ψ")
 )
 
-// Initialize - Variables initializer method
-func Initialize(id int, n int, c int, rem int) {
-	ID = id
-	N = n
-	F = (N - 1) / 3
+// NewReplica - Variables initializer method
+func NewReplica(id int, n int, c int, rem int) Replica {
+	r := Replica{ID: id, N: n, F: (n - 1) / 3, Clients: c}
 
-	if ID < F {
-		Byzantine = true
+	if r.ID < r.F {
+		r.Byzantine = true
 	} else {
-		Byzantine = false
+		r.Byzantine = false
 	}
 
-	Clients = c
-
 	if rem == 1 {
-		Remote = true
+		r.Remote = true
 	} else {
-		Remote = false
+		r.Remote = false
 	}
 
-	DEFAULT = []byte("")
-	PSI = []byte("Ψ")
-
-	MsgComplexity = 0
-	MsgSize = 0
-	MsgMutex = sync.RWMutex{}
-}
-
-func SetABCAlgorithm(useSSABC bool) {
-	RunSSABC = useSSABC
+	return r
 }
@@ -0,0 +1,121 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The snapshot is a single record (with the same format as the batches) in its own file
+const snapshotSuffix = ".snapshot"
+
+// ErrSnapshot - The snapshot file is corrupted
+var ErrSnapshot = errors.New("wal: corrupted snapshot")
+
+// SaveSnapshot - Durably saves the snapshot of the state at the given index and removes the
+// batches up to this index from the log
+func (l *Log) SaveSnapshot(index int, snapshot []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if index <= l.snapshotIndex {
+		return ErrIndex
+	}
+
+	err := writeFileSync(l.path+snapshotSuffix, encodeRecord(index, snapshot))
+	if err != nil {
+		return err
+	}
+	l.snapshotIndex = index
+	if index > l.lastIndex {
+		l.lastIndex = index
+	}
+
+	// Rewrite the log with the batches after the snapshot
+	records := new(bytes.Buffer)
+	err = l.scan(func(i int, batch [][]byte) {
+		if i <= index || err != nil {
+			return
+		}
+		w := new(bytes.Buffer)
+		err = gob.NewEncoder(w).Encode(batch)
+		records.Write(encodeRecord(i, w.Bytes()))
+	})
+	if err != nil {
+		return err
+	}
+	err = writeFileSync(l.path, records.Bytes())
+	if err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file, err = os.OpenFile(l.path, os.O_RDWR, 0660)
+	if err != nil {
+		return err
+	}
+	l.size = int64(records.Len())
+	_, err = l.file.Seek(l.size, io.SeekStart)
+	return err
+}
+
+// Snapshot - Returns the index and the content of the last saved snapshot (0 and nil if there is none)
+func (l *Log) Snapshot() (int, []byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return readSnapshot(l.path + snapshotSuffix)
+}
+
+// readSnapshot - Reads and verifies the snapshot file
+func readSnapshot(path string) (int, []byte, error) {
+	record, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	if len(record) < headerSize ||
+		int(binary.BigEndian.Uint32(record[4:8])) != len(record)-headerSize ||
+		crc32.Checksum(record[8:], crcTable) != binary.BigEndian.Uint32(record[0:4]) {
+		return 0, nil, ErrSnapshot
+	}
+	return int(binary.BigEndian.Uint64(record[8:16])), record[headerSize:], nil
+}
+
+// writeFileSync - Atomically replaces the file in path with data (through a temporary file)
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return err
+	}
+
+	// Persist the rename
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,185 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+	Durable append-only log of the delivered batches. Every record is
+		| checksum (4) | length (4) | index (8) | batch (length) |
+	where the checksum (CRC-32C) covers the index and the gob encoded batch.
+	A record that was only partially written before a crash is discarded
+	(together with everything after it) when the log is opened.
+	A snapshot of the state (see snapshot.go) replaces the records up to its index.
+*/
+
+const headerSize = 16
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrIndex - The index of an appended batch is not greater than the last index in the log
+var ErrIndex = errors.New("wal: batch index is not increasing")
+
+// Log - A write-ahead log of delivered batches, keyed by their delivery index
+type Log struct {
+	path          string
+	file          *os.File
+	size          int64 // Where the next record is written
+	lastIndex     int
+	snapshotIndex int // Index of the last saved snapshot (0 if there is none)
+	mutex         sync.Mutex
+}
+
+// Open - Opens (or creates) the log in path and discards a corrupted tail, if any
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{path: path, file: file}
+	l.snapshotIndex, _, err = readSnapshot(path + snapshotSuffix)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.lastIndex = l.snapshotIndex
+	err = l.scan(func(index int, batch [][]byte) {
+		if index > l.lastIndex {
+			l.lastIndex = index
+		}
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Drop the records after the last valid one
+	err = file.Truncate(l.size)
+	if err == nil {
+		_, err = file.Seek(l.size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append - Writes the batch delivered with the given index and syncs it to disk
+func (l *Log) Append(index int, batch [][]byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if index <= l.lastIndex {
+		return ErrIndex
+	}
+
+	w := new(bytes.Buffer)
+	err := gob.NewEncoder(w).Encode(batch)
+	if err != nil {
+		return err
+	}
+	record := encodeRecord(index, w.Bytes())
+
+	_, err = l.file.Write(record)
+	if err != nil {
+		return err
+	}
+	err = l.file.Sync()
+	if err != nil {
+		return err
+	}
+
+	l.size += int64(len(record))
+	l.lastIndex = index
+	return nil
+}
+
+// Replay - Calls fn for every batch after the last snapshot in the order they were appended
+func (l *Log) Replay(fn func(index int, batch [][]byte)) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.scan(func(index int, batch [][]byte) {
+		if index > l.snapshotIndex {
+			fn(index, batch)
+		}
+	})
+}
+
+// LastIndex - The index of the last batch in the log (0 if it is empty)
+func (l *Log) LastIndex() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.lastIndex
+}
+
+// Close - Closes the file of the log
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// encodeRecord - Encodes a payload with its index and checksum
+func encodeRecord(index int, payload []byte) []byte {
+	record := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(record[4:8], uint32(len(payload)))
+	binary.BigEndian.PutUint64(record[8:16], uint64(index))
+	copy(record[headerSize:], payload)
+	binary.BigEndian.PutUint32(record[0:4], crc32.Checksum(record[8:], crcTable))
+	return record
+}
+
+// scan - Reads the valid records from the start of the file and sets the size of the log
+func (l *Log) scan(fn func(index int, batch [][]byte)) error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	header := make([]byte, headerSize)
+	for {
+		_, err = l.file.ReadAt(header, offset)
+		if err == io.EOF {
+			break // End of the log or torn header
+		} else if err != nil {
+			return err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[4:8]))
+		if offset+headerSize+length > info.Size() {
+			break // Torn record
+		}
+		record := make([]byte, 8+length)
+		_, err = l.file.ReadAt(record, offset+8)
+		if err != nil {
+			return err
+		}
+		if crc32.Checksum(record, crcTable) != binary.BigEndian.Uint32(header[0:4]) {
+			break // Corrupted record
+		}
+
+		var batch [][]byte
+		err = gob.NewDecoder(bytes.NewBuffer(record[8:])).Decode(&batch)
+		if err != nil {
+			break
+		}
+		fn(int(binary.BigEndian.Uint64(record[0:8])), batch)
+		offset += headerSize + length
+	}
+
+	l.size = offset
+	return nil
+}
@@ -1,72 +1,196 @@
 package app
 
 import (
-	"BFTWithoutSignatures_Client/logger"
+	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures_Client/messenger"
 	"BFTWithoutSignatures_Client/types"
 	"BFTWithoutSignatures_Client/variables"
+	"bytes"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 var (
 	runes = []rune("!\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~")
 
-	replies  = make(map[int]map[int]bool) // num, from
-	accepted = make(map[int]bool)         // if this num is accepted
+	// Guards the requests in flight and the metrics
+	mutex sync.Mutex
+
+	replies = make(map[int]map[int]types.Reply) // num, from (for the requests in flight)
+	window  chan struct{}                       // A slot for each request in flight
 
 	// Client metrics regarding the experiment evaluation
-	sentTime  = make(map[int]time.Time)
-	OpLatency = time.Duration(0)
+	sentTime  = make(map[int]time.Time) // When each request in flight was scheduled
+	isRead    = make(map[int]bool)      // The requests in flight that are reads
+	latencies = make([]time.Duration, 0)
+	reads     = 0 // The accepted reads
+	timedOut  = 0 // The requests given up after the request timeout
 	num       = 0
-	Total     = 0
-)
+	start     time.Time
+	last      time.Time // When the last request was accepted
 
-func Client() {
-	rand.Seed(int64((variables.ID + 3) * 9000))              // Pseudo-Random Generator
-	time.Sleep(time.Duration(variables.ID%10) * time.Second) // Wait a bit before sending 1st request
+	log = logger.Module("app") // With the field of the client once the workload starts
+)
 
-	sendRune()
+// Client - Runs the workload, and closes the returned channel once its requests are accepted (or
+// the timeout of the workload expires)
+func Client(w Workload) chan struct{} {
+	rand.Seed(int64((variables.ID + 3) * 9000)) // Pseudo-Random Generator
+	log = logger.Module("app").With("client", variables.ID)
+	window = make(chan struct{}, w.Window)
+	finished := make(chan struct{})
 
 	go func() {
 		for message := range messenger.ResponseChannel {
-			if _, in := replies[message.Value][message.From]; in {
-				continue // Only one value can be received from each server
-			}
-			if replies[message.Value] == nil {
-				replies[message.Value] = make(map[int]bool)
+			accept(message)
+		}
+	}()
+
+	go func() {
+		time.Sleep(time.Duration(variables.ID%10) * time.Second) // Wait a bit before sending 1st request
+
+		mutex.Lock()
+		start = time.Now()
+		mutex.Unlock()
+		for k := 0; !w.done(k, start); k++ {
+			var scheduled time.Time
+			if w.Rate > 0 {
+				scheduled = w.schedule(k, start)
+				time.Sleep(time.Until(scheduled))
 			}
-			replies[message.Value][message.From] = true
-
-			// If more than F+1 with the same value, accept the array.
-			if len(replies[message.Value]) >= (variables.F+1) && !accepted[message.Value] {
-				accepted[message.Value] = true
-				OpLatency += time.Since(sentTime[message.Value])
-				Total++
-				logger.OutLogger.Print("RECEIVED ACK for ", message.Value, " [",
-					time.Since(sentTime[message.Value]), "]\n")
-
-				if num < 2 {
-					sendRune()
-				}
+			window <- struct{}{} // Waits until a request in flight is accepted
+			if w.Rate == 0 {
+				scheduled = time.Now()
 			}
+			sendRequest(w, scheduled)
+		}
+
+		deadline := time.Now().Add(w.Timeout)
+		for len(window) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
 		}
+		close(finished)
 	}()
+
+	return finished
+}
+
+// Report - The stats of the requests sent so far
+func Report() Stats {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	elapsed := time.Duration(0)
+	if len(latencies) > 0 {
+		elapsed = last.Sub(start)
+	}
+	return newStats(num, reads, timedOut, latencies, elapsed)
 }
 
-func sendRune() {
+// sendRequest - Sends the next request of the workload to F+1 servers (each server is tried once, until
+// the request timeout)
+func sendRequest(w Workload, scheduled time.Time) {
+	op, command := types.OpWrite, make([]byte, w.PayloadSize)
+	if rand.Float64() < w.ReadRatio {
+		op, command = types.OpRead, nil
+	} else {
+		for i := range command {
+			command[i] = byte(runes[rand.Intn(len(runes))])
+		}
+	}
+
+	mutex.Lock()
 	num++
-	message := types.NewClientMessage(variables.ID, num, runes[rand.Intn(len(runes))])
+	message := types.NewClientMessage(variables.ID, num, op, command)
+	sentTime[num] = scheduled
+	isRead[num] = op == types.OpRead
+	mutex.Unlock()
+	requestsSent.Inc(opLabel(op == types.OpRead))
+
+	var deadline time.Time
+	if w.RequestTimeout > 0 {
+		deadline = time.Now().Add(w.RequestTimeout)
+		time.AfterFunc(w.RequestTimeout, func() { expire(message.Num) })
+	}
+
+	sent := 0
 	randServer := rand.Intn(variables.N)
+	for i := 0; i < variables.N && sent < variables.F+1; i++ {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		if messenger.SendRequest(message, (randServer+i)%variables.N) {
+			sent++
+		}
+	}
+	if sent < variables.F+1 {
+		log.Warn("request not sent to F+1 servers", "num", message.Num, "sent", sent)
+	}
+}
+
+// expire - Gives up a request that is still in flight after the request timeout, and frees its slot
+func expire(num int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, inFlight := sentTime[num]; !inFlight {
+		return // Accepted
+	}
+	timedOut++
+	requestsTimedOut.Inc(opLabel(isRead[num]))
+	delete(replies, num)
+	delete(sentTime, num)
+	delete(isRead, num)
+	<-window
+
+	log.Warn("timed out", "num", num)
+}
+
+// accept - Counts a reply, and accepts its request once F+1 servers replied the same
+func accept(message types.Reply) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sent, inFlight := sentTime[message.Num]
+	if !inFlight {
+		return // Already accepted
+	}
+	if _, in := replies[message.Num][message.From]; in {
+		return // Only one value can be received from each server
+	}
+	if replies[message.Num] == nil {
+		replies[message.Num] = make(map[int]types.Reply)
+	}
+	replies[message.Num][message.From] = message
 
-	for i := 0; i < (variables.F + 1); i++ {
-		to := (randServer + i) % variables.N
-		flag := messenger.SendRequest(message, to)
-		if !flag {
-			randServer = rand.Intn(variables.N)
-			i--
+	// If more than F+1 with the same status and result, accept it.
+	if countMatching(replies[message.Num], message) >= (variables.F + 1) {
+		last = time.Now()
+		latency := last.Sub(sent)
+		latencies = append(latencies, latency)
+		if isRead[message.Num] {
+			reads++
 		}
+		requestsAccepted.Inc(opLabel(isRead[message.Num]))
+		requestLatency.Observe(latency.Seconds(), opLabel(isRead[message.Num]))
+		delete(replies, message.Num)
+		delete(sentTime, message.Num)
+		delete(isRead, message.Num)
+		<-window
+
+		log.Debug("accepted", "num", message.Num, "status", message.Status, "result", string(message.Result),
+			"latency", latency)
 	}
+}
 
-	sentTime[num] = time.Now()
+// countMatching - Counts the servers that replied with the same status and result as reply
+func countMatching(replies map[int]types.Reply, reply types.Reply) int {
+	count := 0
+	for _, r := range replies {
+		if r.Status == reply.Status && bytes.Equal(r.Result, reply.Result) {
+			count++
+		}
+	}
+	return count
 }
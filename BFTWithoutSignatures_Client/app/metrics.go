@@ -0,0 +1,35 @@
+package app
+
+import (
+	"BFTWithoutSignatures/metrics"
+	"BFTWithoutSignatures_Client/messenger"
+)
+
+// Metrics of the workload, by operation (read or write)
+var (
+	requestsSent = messenger.Registry.NewCounter("bft_client_requests_total",
+		"Requests issued by the workload.", "op")
+	requestsAccepted = messenger.Registry.NewCounter("bft_client_requests_accepted_total",
+		"Requests accepted with F+1 matching replies.", "op")
+	requestsTimedOut = messenger.Registry.NewCounter("bft_client_requests_timed_out_total",
+		"Requests given up after the request timeout.", "op")
+	requestLatency = messenger.Registry.NewHistogram("bft_client_request_latency_seconds",
+		"From the time a request was scheduled to its acceptance.", metrics.LatencyBuckets, "op")
+)
+
+func init() {
+	messenger.Registry.NewGaugeFunc("bft_client_requests_in_flight", "Requests sent and not accepted yet.",
+		nil, func(set func(float64, ...string)) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			set(float64(len(sentTime)))
+		})
+}
+
+// opLabel - The label of an operation
+func opLabel(read bool) string {
+	if read {
+		return "read"
+	}
+	return "write"
+}
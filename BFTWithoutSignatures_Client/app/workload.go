@@ -0,0 +1,139 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	A client runs a workload: it keeps up to Window requests in flight, each one
+	sent to F+1 replicas and accepted with F+1 matching replies. Without a Rate
+	the next request is sent as soon as one is accepted (closed loop); with a
+	Rate the requests are issued on a fixed schedule (open loop), and a full
+	window delays them. The latency of a request is measured from the time it
+	was scheduled, so the delay of a saturated cluster is not hidden. A request
+	that is not accepted within RequestTimeout is given up: its slot is freed,
+	and it is counted as timed out. The client
+	stops after Requests requests or Duration, whichever comes first (never if
+	both are 0), and reports the throughput and the latency percentiles.
+*/
+
+// Workload - The requests that a client sends
+type Workload struct {
+	Window      int           // How many requests can be in flight
+	Rate        float64       // Requests per second (0 for a closed loop)
+	Requests    int           // How many requests to send (0 for no limit)
+	Duration    time.Duration // How long to send requests (0 for no limit)
+	PayloadSize int           // The number of characters of a write command
+	ReadRatio   float64       // The fraction of the requests that are reads
+	Timeout     time.Duration // How long the requests in flight are waited for at the end
+
+	// RequestTimeout - How long a request is waited for before it is given up (0 for no limit). It also
+	// bounds the attempts to send it to F+1 servers.
+	RequestTimeout time.Duration
+}
+
+// DefaultWorkload - One write of one character at a time, until the client is stopped
+func DefaultWorkload() Workload {
+	return Workload{Window: 1, PayloadSize: 1, Timeout: 30 * time.Second, RequestTimeout: 30 * time.Second}
+}
+
+// Validate - Checks the options of the workload
+func (w Workload) Validate() error {
+	problems := make([]string, 0)
+	if w.Window < 1 {
+		problems = append(problems, fmt.Sprintf("window is %d, it must be at least 1", w.Window))
+	}
+	if w.Rate < 0 {
+		problems = append(problems, fmt.Sprintf("rate is %g, it cannot be negative", w.Rate))
+	}
+	if w.Requests < 0 || w.Duration < 0 || w.Timeout < 0 || w.RequestTimeout < 0 {
+		problems = append(problems, "requests, duration, timeout and request timeout cannot be negative")
+	}
+	if w.PayloadSize < 1 {
+		problems = append(problems, fmt.Sprintf("payload is %d, it must be at least 1", w.PayloadSize))
+	}
+	if w.ReadRatio < 0 || w.ReadRatio > 1 {
+		problems = append(problems, fmt.Sprintf("reads is %g, it must be in [0, 1]", w.ReadRatio))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid workload: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// done - Whether the workload has sent its requests (sent so far, since start)
+func (w Workload) done(sent int, start time.Time) bool {
+	return (w.Requests > 0 && sent >= w.Requests) || (w.Duration > 0 && time.Since(start) >= w.Duration)
+}
+
+// schedule - When request k (from 0) of an open loop is due
+func (w Workload) schedule(k int, start time.Time) time.Time {
+	return start.Add(time.Duration(float64(k) / w.Rate * float64(time.Second)))
+}
+
+// Stats - The outcome of the requests of a workload
+type Stats struct {
+	Sent       int
+	Accepted   int
+	Reads      int             // The accepted reads
+	TimedOut   int             // The requests given up after the request timeout
+	Elapsed    time.Duration   // From the first request to the last accepted one
+	Latencies  []time.Duration // Of the accepted requests, sorted
+	Throughput float64         // Accepted requests per second
+}
+
+// newStats - Computes the stats of the accepted requests
+func newStats(sent int, reads int, timedOut int, latencies []time.Duration, elapsed time.Duration) Stats {
+	s := Stats{Sent: sent, Accepted: len(latencies), Reads: reads, TimedOut: timedOut, Elapsed: elapsed}
+	s.Latencies = append([]time.Duration(nil), latencies...)
+	sort.Slice(s.Latencies, func(i, j int) bool { return s.Latencies[i] < s.Latencies[j] })
+	if elapsed > 0 {
+		s.Throughput = float64(s.Accepted) / elapsed.Seconds()
+	}
+	return s
+}
+
+// Percentile - The latency that p percent of the accepted requests did not exceed (nearest rank)
+func (s Stats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(s.Latencies)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(s.Latencies) {
+		rank = len(s.Latencies) - 1
+	}
+	return s.Latencies[rank]
+}
+
+// Mean - The average latency of the accepted requests
+func (s Stats) Mean() time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	total := time.Duration(0)
+	for _, l := range s.Latencies {
+		total += l
+	}
+	return total / time.Duration(len(s.Latencies))
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("Requests: %d sent, %d accepted (%d reads), %d timed out, %d unanswered\n"+
+		"Throughput: %.1f req/s over %.3f s\n"+
+		"Latency: avg %.3f ms | p50 %.3f ms | p95 %.3f ms | p99 %.3f ms | max %.3f ms",
+		s.Sent, s.Accepted, s.Reads, s.TimedOut, s.Sent-s.Accepted-s.TimedOut, s.Throughput, s.Elapsed.Seconds(),
+		ms(s.Mean()), ms(s.Percentile(50)), ms(s.Percentile(95)), ms(s.Percentile(99)), ms(s.Percentile(100)))
+}
+
+// ms - A duration in milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
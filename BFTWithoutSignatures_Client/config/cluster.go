@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+/*
+	The cluster file (JSON) is shared with the servers (see the config package of
+	BFTWithoutSignatures). A client sends its requests to replica j on
+	ClientPort+ID and gets the responses on ClientPort+Clients+ID. The keys of
+	the replicas are not read by the clients, so their files are not checked.
+	The replicas may change while they run, but a client only learns about it
+	when it is restarted with the new file.
+*/
+
+// Cluster - The replicas of the system and the protocol they run
+type Cluster struct {
+	Replicas []ReplicaConfig `json:"replicas"`
+	Clients  int             `json:"clients"`
+	Keys     string          `json:"keys"`
+	CoinKeys string          `json:"coin_keys"`
+	Protocol Protocol        `json:"protocol"`
+
+	file string
+}
+
+// ReplicaConfig - Where a replica runs
+type ReplicaConfig struct {
+	ID          int    `json:"id"`
+	Host        string `json:"host"`
+	PeerPort    int    `json:"peer_port"`
+	ClientPort  int    `json:"client_port"`
+	MetricsPort int    `json:"metrics_port"`
+	PublicKey   string `json:"public_key"`
+}
+
+// Protocol - The options that the replicas run with
+type Protocol struct {
+	Scenario             string  `json:"scenario"`
+	Algorithm            string  `json:"algorithm"`
+	TransientProbability float64 `json:"transient_probability"`
+	Auth                 string  `json:"auth"`
+	BatchBytes           int     `json:"batch_bytes"`
+	BatchRequests        int     `json:"batch_requests"`
+	BatchLingerMs        int     `json:"batch_linger_ms"`
+	ConsensusWindow      int     `json:"consensus_window"`
+}
+
+// ClusterError - The problems found in a cluster file
+type ClusterError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ClusterError) Error() string {
+	return "invalid cluster file " + e.File + ":\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+var (
+	// ServerAddresses - The addresses of the Server sockets of the replicas (requests)
+	ServerAddresses map[int]string
+
+	// ResponseAddresses - The addresses of the Response sockets of the replicas (responses)
+	ResponseAddresses map[int]string
+)
+
+// LoadCluster - Reads and validates the cluster file
+func LoadCluster(file string) (*Cluster, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{file: file}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cluster)
+	if err != nil {
+		return nil, &ClusterError{File: file, Problems: []string{err.Error()}}
+	}
+
+	err = cluster.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// The replicas in the order of their ids
+	replicas := make([]ReplicaConfig, len(cluster.Replicas))
+	for _, r := range cluster.Replicas {
+		replicas[r.ID] = r
+	}
+	cluster.Replicas = replicas
+	return cluster, nil
+}
+
+// Validate - Checks the part of the cluster that the clients use, and returns a *ClusterError with
+// every problem found
+func (c *Cluster) Validate() error {
+	problems := make([]string, 0)
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	n := len(c.Replicas)
+	if n == 0 {
+		problem("no replicas")
+	}
+	if c.Clients <= 0 {
+		problem("clients is %d, it must be positive", c.Clients)
+	}
+
+	ids := make(map[int]bool, n)
+	for k, r := range c.Replicas {
+		name := "replica " + strconv.Itoa(r.ID)
+		if r.ID < 0 || r.ID >= n {
+			problem("replicas[%d]: id %d is not in [0, %d]", k, r.ID, n-1)
+		} else if ids[r.ID] {
+			problem("%s: listed more than once", name)
+		}
+		ids[r.ID] = true
+
+		if r.Host == "" {
+			problem("%s: no host", name)
+		}
+		if r.ClientPort <= 0 || r.ClientPort+2*c.Clients-1 > 65535 {
+			problem("%s: client_port %d does not leave room for %d ports", name, r.ClientPort, 2*c.Clients)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ClusterError{File: c.file, Problems: problems}
+	}
+	return nil
+}
+
+// InitializeCluster - Initializes the addresses of the replicas for client id
+func InitializeCluster(c *Cluster, id int) {
+	ServerAddresses = make(map[int]string, len(c.Replicas))
+	ResponseAddresses = make(map[int]string, len(c.Replicas))
+
+	for i, r := range c.Replicas {
+		ServerAddresses[i] = "tcp://" + r.Host + ":" + strconv.Itoa(r.ClientPort+id)
+		ResponseAddresses[i] = "tcp://" + r.Host + ":" + strconv.Itoa(r.ClientPort+c.Clients+id)
+	}
+}
+
+// GetServerAddress - Returns the Server address of the replica with that id
+func GetServerAddress(id int) string {
+	return ServerAddresses[id]
+}
+
+// GetResponseAddress - Returns the Response address of the replica with that id
+func GetResponseAddress(id int) string {
+	return ResponseAddresses[id]
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures_Client/app"
 	"BFTWithoutSignatures_Client/config"
-	"BFTWithoutSignatures_Client/logger"
 	"BFTWithoutSignatures_Client/messenger"
 	"BFTWithoutSignatures_Client/variables"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -15,8 +17,49 @@ import (
 
 var folderName string
 
+// Flags of the logs
+var (
+	logTarget = flag.String("log", "file",
+		"Where the logs go: file (the files in the logger folder), stderr or the path of a file")
+	logFormat = flag.String("log-format", "logfmt", "The format of the logs: logfmt or json")
+	logLevel  = flag.String("log-level", "info",
+		"The levels of the logs, for all the modules or per module (e.g. 'info,messenger=debug')")
+)
+
+// initializeLogs - Sends the logs of the client where the flags say, in their format and levels
+func initializeLogs(outFolder string, errFolder string) {
+	format, err := logger.ParseFormat(*logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := logger.SetLevels(*logLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	var sink logger.Sink
+	switch *logTarget {
+	case "file":
+		sink, err = logger.FileSink(outFolder, errFolder, variables.ID, format)
+	case "stderr":
+		sink = logger.StderrSink(format)
+	default:
+		sink, err = logger.OpenFileSink(*logTarget, format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.SetSink(sink)
+}
+
 // Initializer - Method that initializes all required processes
-func initializer(id int, n int, clients int, rem int) {
+func initializer(id int, cluster *config.Cluster, workload app.Workload) chan struct{} {
+	n, clients := len(cluster.Replicas), cluster.Clients
+	rem := 0
+	for _, r := range cluster.Replicas {
+		if r.Host != "localhost" && r.Host != "127.0.0.1" {
+			rem = 1
+		}
+	}
 	variables.Initialize(id, n, rem)
 	user_dirname, err := os.UserHomeDir()
 	if err != nil {
@@ -37,13 +80,9 @@ func initializer(id int, n int, clients int, rem int) {
 			log.Fatal(err)
 		}
 	}
-	logger.InitializeLogger(logger_dir+"logs/client/", logger_dir+"logs/client/")
+	initializeLogs(logger_dir+"logs/client/", logger_dir+"logs/client/")
 
-	if variables.Remote {
-		config.InitializeIP(clients)
-	} else {
-		config.InitializeLocal()
-	}
+	config.InitializeCluster(cluster, variables.ID)
 
 	logger.OutLogger.Print(
 		"ID:", variables.ID, " | N:", variables.N, " | F:", variables.F,
@@ -54,7 +93,7 @@ func initializer(id int, n int, clients int, rem int) {
 	messenger.Subscribe()
 	messenger.TransmitRequests()
 
-	app.Client()
+	return app.Client(workload)
 }
 
 func cleanup() {
@@ -67,41 +106,71 @@ func cleanup() {
 		syscall.SIGQUIT)
 	go func() {
 		for range terminate {
-			if app.Total == 0 {
-				logger.OutLogger.Print("\n\nAverage Operation Latency: 0.000 s\n\n")
-			} else {
-				logger.OutLogger.Printf("\n\nAverage Operation Latency: %.3f s\n\n",
-					(app.OpLatency.Seconds() / float64(app.Total)))
-			}
-
-			for i := 0; i < variables.N; i++ {
-				messenger.ServerSockets[i].Close()
-				messenger.ResponseSockets[i].Close()
-			}
-			os.Exit(0)
+			finish()
 		}
 	}()
 }
 
+// finish - Reports the stats of the workload, closes the sockets and exits
+func finish() {
+	report := app.Report().String()
+	logger.OutLogger.Print("\n\n", report, "\n\n")
+	fmt.Printf("Client %d\n%s\n", variables.ID, report)
+
+	for i := 0; i < variables.N; i++ {
+		messenger.ServerSockets[i].Close()
+		messenger.ResponseSockets[i].Close()
+	}
+	os.Exit(0)
+}
+
 func main() {
-	args := os.Args[1:]
-	if len(args) == 4 || len(args) == 5 {
-		id, _ := strconv.Atoi(args[0])
-		n, _ := strconv.Atoi(args[1])
-		clients, _ := strconv.Atoi(args[2])
-		remote, _ := strconv.Atoi(args[3])
-
-		if len(args) == 5 {
-			folderName = args[4]
+	workload := app.DefaultWorkload()
+	flag.IntVar(&workload.Window, "window", workload.Window, "How many requests can be in flight")
+	flag.Float64Var(&workload.Rate, "rate", workload.Rate, "Requests per second (open loop), 0 for a closed loop")
+	flag.IntVar(&workload.Requests, "requests", workload.Requests, "How many requests to send, 0 for no limit")
+	flag.DurationVar(&workload.Duration, "duration", workload.Duration, "How long to send requests, 0 for no limit")
+	flag.IntVar(&workload.PayloadSize, "payload", workload.PayloadSize, "The number of characters of a write")
+	flag.Float64Var(&workload.ReadRatio, "reads", workload.ReadRatio, "The fraction of the requests that are reads")
+	flag.DurationVar(&workload.Timeout, "timeout", workload.Timeout, "How long to wait for the requests in flight at the end")
+	flag.DurationVar(&workload.RequestTimeout, "request-timeout", workload.RequestTimeout,
+		"How long to wait for a request before giving it up, 0 for no limit")
+	metricsAddr := flag.String("metrics", "", "Serve the metrics on http://<address>/metrics (e.g. :9200)")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 2 || len(args) == 3 {
+		cluster, err := config.LoadCluster(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id < 0 || id >= cluster.Clients {
+			log.Fatalf("Client ID %q is not in the cluster file (0 to %d)", args[1], cluster.Clients-1)
+		}
+
+		if err := workload.Validate(); err != nil {
+			log.Fatal(err)
+		}
+
+		if len(args) == 3 {
+			folderName = args[2]
+		}
+
+		if *metricsAddr != "" {
+			_, err := messenger.Registry.Listen(*metricsAddr)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 
-		initializer(id, n, clients, remote)
+		finished := initializer(id, cluster, workload)
 		cleanup()
 
-		done := make(chan interface{}) // To keep the client running
-		<-done
+		<-finished // The client runs until the workload is over or it is stopped
+		finish()
 
 	} else {
-		log.Fatal("Arguments should be '<ID> <N> <Clients> <Remote>'")
+		log.Fatal("Arguments should be '[<Options>] <Cluster_File> <ID> [<Folder>]' (see -help for the options)")
 	}
 }
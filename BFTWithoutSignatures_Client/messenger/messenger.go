@@ -1,12 +1,13 @@
 package messenger
 
 import (
+	"BFTWithoutSignatures/logger"
 	"BFTWithoutSignatures_Client/config"
-	"BFTWithoutSignatures_Client/logger"
 	"BFTWithoutSignatures_Client/types"
 	"BFTWithoutSignatures_Client/variables"
 	"bytes"
 	"encoding/gob"
+	"strconv"
 	"time"
 
 	"github.com/pebbe/zmq4"
@@ -24,6 +25,9 @@ var (
 	ResponseSockets map[int]*zmq4.Socket
 )
 
+// log - The logger of the messenger (with the field of the client once it is initialized)
+var log = logger.Module("messenger")
+
 // Channels for messages
 var (
 	// RequestChannel - Channel to put the requests in
@@ -35,6 +39,8 @@ var (
 
 // InitializeMessenger - Initializes the ZeroMQ sockets
 func InitializeMessenger() {
+	log = logger.Module("messenger").With("client", variables.ID)
+
 	Context, err := zmq4.NewContext()
 	if err != nil {
 		logger.ErrLogger.Fatal(err)
@@ -50,51 +56,44 @@ func InitializeMessenger() {
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		var serverAddr string
-		if !variables.Remote {
-			serverAddr = config.GetServerAddressLocal(i)
-		} else {
-			serverAddr = config.GetServerAddress(i)
-		}
+		serverAddr := config.GetServerAddress(i)
 		err = ServerSockets[i].Connect(serverAddr)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		logger.OutLogger.Println("Request to Server", i, "on", serverAddr)
+		log.Info("request socket", "server", i, "address", serverAddr)
 
 		// ResponseSockets initialization to get the response back from the servers
 		ResponseSockets[i], err = Context.NewSocket(zmq4.SUB)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
-		var responseAddr string
-		if !variables.Remote {
-			responseAddr = config.GetResponseAddressLocal(i)
-		} else {
-			responseAddr = config.GetResponseAddress(i)
-		}
+		responseAddr := config.GetResponseAddress(i)
 		err = ResponseSockets[i].Connect(responseAddr)
 		if err != nil {
 			logger.ErrLogger.Fatal(err)
 		}
 		ResponseSockets[i].SetSubscribe("")
-		logger.OutLogger.Println("Response from Server", i, "on", responseAddr)
+		log.Info("response socket", "server", i, "address", responseAddr)
 
 		// Init request channel
 		RequestChannel[i] = make(chan types.ClientMessage)
 	}
-
-	logger.OutLogger.Print("------------------------------------------------\n\n")
 }
 
-// SendRequest - Puts the messages in the request channel to be transmitted
+// SendTimeout - How long SendRequest waits for a server to take a request
+var SendTimeout = 10 * time.Second
+
+// SendRequest - Puts the messages in the request channel to be transmitted, and returns false if the
+// server does not take it before SendTimeout
 func SendRequest(message types.ClientMessage, to int) bool {
-	timeout := time.NewTicker(10 * time.Second)
+	timeout := time.NewTimer(SendTimeout)
+	defer timeout.Stop()
 	select {
 	case RequestChannel[to] <- message:
 		return true
 	case <-timeout.C:
-		<-RequestChannel[to]
+		sendTimeouts.Inc(strconv.Itoa(to))
 		return false
 	}
 }
@@ -120,7 +119,11 @@ func TransmitRequests() {
 				if err != nil {
 					logger.ErrLogger.Fatal(err)
 				}
-				logger.OutLogger.Printf("SENT [%d, %c] to %d", message.Num, message.Value, i)
+				if log.Enabled(logger.LevelDebug) {
+					log.Debug("sent", "num", message.Num, "op", message.Op, "command", message.Command, "server", i)
+				}
+				messagesSent.Inc(strconv.Itoa(i))
+				bytesSent.Add(float64(w.Len()), strconv.Itoa(i))
 			}
 		}(i)
 	}
@@ -149,9 +152,12 @@ func handleResponse(msg []byte) {
 	decoder := gob.NewDecoder(buffer)
 	err := decoder.Decode(&message)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		log.Warn("dropped server response", "err", err)
+		return
 	}
 
-	logger.OutLogger.Println("RECEIVED REP from", message.From, "for", message.Value)
+	log.Debug("received reply", "peer", message.From, "num", message.Num)
+	messagesReceived.Inc(strconv.Itoa(message.From))
+	bytesReceived.Add(float64(len(msg)), strconv.Itoa(message.From))
 	ResponseChannel <- message
 }
@@ -0,0 +1,22 @@
+package messenger
+
+import (
+	"BFTWithoutSignatures/metrics"
+)
+
+// Metrics of the client (served with the -metrics option, see main), by replica
+var (
+	// Registry - The metrics of the client
+	Registry = metrics.NewRegistry()
+
+	messagesSent = Registry.NewCounter("bft_client_messages_sent_total",
+		"Requests sent to the replicas.", "peer")
+	bytesSent = Registry.NewCounter("bft_client_bytes_sent_total",
+		"Bytes of the requests sent to the replicas.", "peer")
+	messagesReceived = Registry.NewCounter("bft_client_messages_received_total",
+		"Replies received from the replicas.", "peer")
+	bytesReceived = Registry.NewCounter("bft_client_bytes_received_total",
+		"Bytes of the replies received from the replicas.", "peer")
+	sendTimeouts = Registry.NewCounter("bft_client_send_timeouts_total",
+		"Requests that a replica did not take in time (they are sent to another one).", "peer")
+)
@@ -1,9 +1,10 @@
 #!/bin/bash
 
-N=10
-CLIENTS=25
+CLUSTER=~/go/src/BFTWithoutSignatures/config/cluster-remote.json # The same cluster file as the servers
+CLIENTS=25	# Number of clients in the cluster file
+WORKLOAD="-window 1 -requests 3"	# e.g. "-window 16 -rate 200 -duration 60s -payload 64 -reads 0.1"
 
 for (( ID=0; ID<$CLIENTS; ID++ ))
 do
-	go run BFTWithoutSignatures_Client $ID $N $CLIENTS 1 &
-done
\ No newline at end of file
+	go run BFTWithoutSignatures_Client $WORKLOAD $CLUSTER $ID &
+done
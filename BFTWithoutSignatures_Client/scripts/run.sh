@@ -1,12 +1,12 @@
 #!/bin/bash
 
-N=4 
-CLIENTS=5
-REM=0
+CLUSTER=~/go/src/BFTWithoutSignatures/config/cluster.json # The same cluster file as the servers
+CLIENTS=5	# Number of clients in the cluster file
+WORKLOAD="-window 1 -requests 3"	# e.g. "-window 16 -rate 200 -duration 60s -payload 64 -reads 0.1"
 
 go install BFTWithoutSignatures_Client
 
 for (( ID=0; ID<$CLIENTS; ID++ ))
 do
-	BFTWithoutSignatures_Client $ID $N $CLIENTS $REM &
+	BFTWithoutSignatures_Client $WORKLOAD $CLUSTER $ID &
 done
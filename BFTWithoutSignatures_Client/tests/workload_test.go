@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"BFTWithoutSignatures_Client/app"
+	"BFTWithoutSignatures_Client/messenger"
+	"BFTWithoutSignatures_Client/types"
+	"BFTWithoutSignatures_Client/variables"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The percentiles are the nearest ranks of the sorted latencies
+func TestStatsPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 10)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	stats := app.Stats{Latencies: latencies}
+
+	/*** Start Testing ***/
+
+	for p, expected := range map[float64]time.Duration{
+		0:   1 * time.Millisecond,
+		10:  1 * time.Millisecond,
+		12:  2 * time.Millisecond,
+		50:  5 * time.Millisecond,
+		51:  6 * time.Millisecond,
+		95:  10 * time.Millisecond,
+		99:  10 * time.Millisecond,
+		100: 10 * time.Millisecond,
+	} {
+		if latency := stats.Percentile(p); latency != expected {
+			t.Errorf("Percentile %g is %v instead of %v", p, latency, expected)
+		}
+	}
+	if mean := stats.Mean(); mean != 5500*time.Microsecond {
+		t.Errorf("Mean is %v instead of 5.5ms", mean)
+	}
+	if empty := (app.Stats{}); empty.Percentile(50) != 0 || empty.Mean() != 0 {
+		t.Error("Stats without latencies are not 0")
+	}
+
+	/*** End Testing ***/
+}
+
+// Every problem of a workload is reported at once, and the default one is valid
+func TestWorkloadValidate(t *testing.T) {
+	if err := app.DefaultWorkload().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	/*** Start Testing ***/
+
+	for name, change := range map[string]func(*app.Workload){
+		"window":          func(w *app.Workload) { w.Window = 0 },
+		"rate":            func(w *app.Workload) { w.Rate = -1 },
+		"requests":        func(w *app.Workload) { w.Requests = -1 },
+		"duration":        func(w *app.Workload) { w.Duration = -time.Second },
+		"request timeout": func(w *app.Workload) { w.RequestTimeout = -time.Second },
+		"payload":         func(w *app.Workload) { w.PayloadSize = 0 },
+		"reads":           func(w *app.Workload) { w.ReadRatio = 1.5 },
+	} {
+		w := app.DefaultWorkload()
+		change(&w)
+		if err := w.Validate(); err == nil {
+			t.Errorf("Invalid %s accepted", name)
+		}
+	}
+
+	w := app.DefaultWorkload()
+	w.Window, w.PayloadSize, w.ReadRatio = 0, 0, -1
+	err := w.Validate()
+	if err == nil {
+		t.Fatal("Invalid workload accepted")
+	}
+	for _, problem := range []string{"window", "payload", "reads"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("%q does not report the %s", err, problem)
+		}
+	}
+
+	/*** End Testing ***/
+}
+
+// A request that is not answered is given up after the request timeout, and frees its slot for the
+// next ones, even when fewer than F+1 servers take the requests
+func TestWorkloadRequestTimeout(t *testing.T) {
+	n := 4
+	variables.Initialize(0, n, 0)
+	defer func(timeout time.Duration) { messenger.SendTimeout = timeout }(messenger.SendTimeout)
+	messenger.SendTimeout = 20 * time.Millisecond
+
+	// Only server 0 takes the requests, and no server replies
+	taken := make(chan types.ClientMessage, 100)
+	for i := 0; i < n; i++ {
+		messenger.RequestChannel[i] = make(chan types.ClientMessage)
+	}
+	go func() {
+		for message := range messenger.RequestChannel[0] {
+			taken <- message
+		}
+	}()
+
+	w := app.DefaultWorkload()
+	w.Window, w.Requests, w.RequestTimeout = 2, 5, 200*time.Millisecond
+	before := app.Report()
+
+	/*** Start Testing ***/
+
+	select {
+	case <-app.Client(w):
+	case <-time.After(20 * time.Second):
+		t.Fatal("Workload did not finish")
+	}
+	stats := app.Report()
+	if sent, timedOut := stats.Sent-before.Sent, stats.TimedOut-before.TimedOut; sent != 5 || timedOut != 5 {
+		t.Errorf("%d requests sent and %d timed out instead of 5", sent, timedOut)
+	}
+	if len(taken) != 5 {
+		t.Errorf("Server 0 took %d requests instead of 5", len(taken))
+	}
+
+	/*** End Testing ***/
+}
@@ -1,57 +1,96 @@
 package types
 
 import (
-	"BFTWithoutSignatures/logger"
 	"bytes"
 	"encoding/gob"
+	"errors"
 )
 
+// ProtocolVersion - The version of the client protocol (requests and replies), which must match on clients and servers
+const ProtocolVersion uint8 = 2
+
+// ErrProtocolVersion - A request or reply was encoded with another version of the client protocol
+var ErrProtocolVersion = errors.New("unsupported client protocol version")
+
+// Operation - The type of a client request
+type Operation uint8
+
+const (
+	// OpWrite - A command that modifies the state of the application
+	OpWrite Operation = iota
+
+	// OpRead - A command that only reads the state of the application (it is still ordered by ABC)
+	OpRead
+)
+
+// Valid - Whether the operation is known by this version of the protocol
+func (op Operation) Valid() bool {
+	return op == OpWrite || op == OpRead
+}
+
 // ClientMessage - Client message struct
 type ClientMessage struct {
-	Cid   int
-	Num   int
-	Value rune
+	Version uint8
+	Cid     int
+	Num     int
+	Op      Operation
+	Command []byte // Opaque command for the replicated state machine
 }
 
 // NewClientMessage - Creates a new Client message
-func NewClientMessage(id int, num int, value rune) ClientMessage {
-	return ClientMessage{Cid: id, Num: num, Value: value}
+func NewClientMessage(id int, num int, op Operation, command []byte) ClientMessage {
+	return ClientMessage{Version: ProtocolVersion, Cid: id, Num: num, Op: op, Command: command}
 }
 
 // GobEncode - Client message encoder
 func (cm ClientMessage) GobEncode() ([]byte, error) {
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
-	err := encoder.Encode(cm.Cid)
+	err := encoder.Encode(cm.Version)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
+	}
+	err = encoder.Encode(cm.Cid)
+	if err != nil {
+		return nil, err
 	}
 	err = encoder.Encode(cm.Num)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
+	}
+	err = encoder.Encode(cm.Op)
+	if err != nil {
+		return nil, err
 	}
-	err = encoder.Encode(cm.Value)
+	err = encoder.Encode(cm.Command)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return nil, err
 	}
 	return w.Bytes(), nil
 }
 
-// GobDecode - Client message decoder
+// GobDecode - Client message decoder (fails with ErrProtocolVersion on a version mismatch)
 func (cm *ClientMessage) GobDecode(buf []byte) error {
 	r := bytes.NewBuffer(buf)
 	decoder := gob.NewDecoder(r)
-	err := decoder.Decode(&cm.Cid)
+	err := decoder.Decode(&cm.Version)
+	if err != nil {
+		return err
+	}
+	if cm.Version != ProtocolVersion {
+		return ErrProtocolVersion
+	}
+	err = decoder.Decode(&cm.Cid)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
 	err = decoder.Decode(&cm.Num)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
-	err = decoder.Decode(&cm.Value)
+	err = decoder.Decode(&cm.Op)
 	if err != nil {
-		logger.ErrLogger.Fatal(err)
+		return err
 	}
-	return nil
+	return decoder.Decode(&cm.Command)
 }